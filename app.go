@@ -6,6 +6,7 @@ import (
 	"ffmpeg-web/internal/database"
 	"ffmpeg-web/internal/model"
 	"ffmpeg-web/internal/service"
+	"ffmpeg-web/internal/watch"
 	"ffmpeg-web/internal/worker"
 	"fmt"
 	"log"
@@ -24,12 +25,16 @@ import (
 
 // App struct
 type App struct {
-	ctx        context.Context
-	config     *Config
-	db         *database.DB
-	httpServer *http.Server
-	workerPool *worker.Pool
-	port       int
+	ctx              context.Context
+	config           *Config
+	db               *database.DB
+	httpServer       *http.Server
+	workerPool       *worker.Pool
+	coordinator      *worker.Coordinator
+	watchManager     *watch.Manager
+	sampleService    *service.SampleService
+	thumbnailService *service.ThumbnailService
+	port             int
 }
 
 // Config holds the application configuration
@@ -189,6 +194,18 @@ func (a *App) checkFFmpegAvailability() {
 
 // shutdown is called when the app is closing
 func (a *App) shutdown(ctx context.Context) {
+	if a.watchManager != nil {
+		a.watchManager.Shutdown()
+	}
+	if a.sampleService != nil {
+		a.sampleService.Shutdown()
+	}
+	if a.thumbnailService != nil {
+		a.thumbnailService.Shutdown()
+	}
+	if a.coordinator != nil {
+		a.coordinator.Shutdown()
+	}
 	if a.workerPool != nil {
 		a.workerPool.Shutdown()
 	}
@@ -260,6 +277,7 @@ func (a *App) startHTTPServer() error {
 		a.config.FFprobePath,
 		a.config.OutputPath,
 	)
+	ffmpegService.SetProbeCache(service.NewProbeCache(a.db))
 	systemService := service.NewSystemService()
 	systemService.StartMonitoring()
 
@@ -268,6 +286,7 @@ func (a *App) startHTTPServer() error {
 		a.db,
 		ffmpegService,
 		fileService,
+		hardwareService,
 		a.config.MaxConcurrentTasks,
 	)
 
@@ -275,13 +294,31 @@ func (a *App) startHTTPServer() error {
 	wsHandler := api.NewWebSocketHandler()
 	a.workerPool.SetBroadcastChannel(wsHandler.GetBroadcastChannel())
 
+	// Remote agents (the desktop server only listens on localhost, agents stay disabled)
+	a.coordinator = worker.NewCoordinator(a.workerPool)
+
+	// Watch folders queue new media files
+	a.watchManager = watch.NewManager(a.db, a.workerPool, ffmpegService, fileService, hardwareService)
+	if err := a.watchManager.Start(); err != nil {
+		log.Printf("Warning: Failed to start watch folders: %v", err)
+	}
+
+	// Sample encodes are kept in a temp directory until they expire
+	a.sampleService = service.NewSampleService(ffmpegService, hardwareService)
+
+	// Thumbnails are cached next to the config
+	a.thumbnailService = service.NewThumbnailService(ffmpegService, filepath.Join(a.config.ConfigPath, "thumbnails"))
+
 	// Initialize API handlers
-	filesHandler := api.NewFilesHandler(fileService, ffmpegService)
-	tasksHandler := api.NewTasksHandler(a.db, a.workerPool, fileService)
+	filesHandler := api.NewFilesHandler(fileService, ffmpegService, a.thumbnailService)
+	tasksHandler := api.NewTasksHandler(a.db, a.workerPool, ffmpegService, fileService, hardwareService)
+	samplesHandler := api.NewSamplesHandler(a.db, a.sampleService, fileService, hardwareService)
 	presetsHandler := api.NewPresetsHandler(a.db)
 	hardwareHandler := api.NewHardwareHandler(hardwareService)
 	settingsHandler := api.NewSettingsHandler(a.db.Conn())
 	systemHandler := api.NewSystemHandler(systemService)
+	watchFoldersHandler := api.NewWatchFoldersHandler(a.db, a.watchManager, fileService)
+	agentsHandler := api.NewAgentsHandler(a.coordinator, "")
 
 	// Setup Gin router
 	gin.SetMode(gin.ReleaseMode)
@@ -301,6 +338,7 @@ func (a *App) startHTTPServer() error {
 		apiGroup.GET("/files/browse", filesHandler.BrowseDirectory)
 		apiGroup.GET("/files/info", filesHandler.GetFileInfo)
 		apiGroup.GET("/files/default-path", filesHandler.GetDefaultPath)
+		apiGroup.GET("/files/thumbnail", filesHandler.GetThumbnail)
 
 		// Tasks
 		apiGroup.POST("/tasks", tasksHandler.CreateTask)
@@ -312,6 +350,10 @@ func (a *App) startHTTPServer() error {
 		apiGroup.POST("/tasks/:id/retry", tasksHandler.RetryTask)
 		apiGroup.DELETE("/tasks/:id", tasksHandler.DeleteTask)
 
+		// Sample encodes
+		apiGroup.POST("/tasks/sample", samplesHandler.CreateSample)
+		apiGroup.GET("/tasks/sample/:id/file", samplesHandler.DownloadSample)
+
 		// Presets
 		apiGroup.GET("/presets", presetsHandler.GetAllPresets)
 		apiGroup.GET("/presets/:id", presetsHandler.GetPreset)
@@ -333,9 +375,26 @@ func (a *App) startHTTPServer() error {
 		apiGroup.GET("/system/history", systemHandler.GetHistory)
 
 		// Command preview
-		commandHandler := api.NewCommandHandler(ffmpegService)
+		commandHandler := api.NewCommandHandler(ffmpegService, fileService)
 		apiGroup.POST("/command/preview", commandHandler.PreviewCommand)
 
+		// Watch folders
+		apiGroup.GET("/watch-folders", watchFoldersHandler.GetAllWatchFolders)
+		apiGroup.GET("/watch-folders/:id", watchFoldersHandler.GetWatchFolder)
+		apiGroup.POST("/watch-folders", watchFoldersHandler.CreateWatchFolder)
+		apiGroup.PUT("/watch-folders/:id", watchFoldersHandler.UpdateWatchFolder)
+		apiGroup.DELETE("/watch-folders/:id", watchFoldersHandler.DeleteWatchFolder)
+
+		// Remote agents
+		apiGroup.GET("/agents", agentsHandler.GetAgents)
+		apiGroup.POST("/agents/register", agentsHandler.RequireToken, agentsHandler.RegisterAgent)
+		apiGroup.DELETE("/agents/:id", agentsHandler.RequireToken, agentsHandler.UnregisterAgent)
+		apiGroup.POST("/agents/:id/heartbeat", agentsHandler.RequireToken, agentsHandler.Heartbeat)
+		apiGroup.POST("/agents/:id/claim", agentsHandler.RequireToken, agentsHandler.ClaimTask)
+		apiGroup.PUT("/agents/:id/tasks/:taskId", agentsHandler.RequireToken, agentsHandler.UpdateTask)
+		apiGroup.GET("/agents/:id/tasks/:taskId/source", agentsHandler.GetTaskSource)
+		apiGroup.PUT("/agents/:id/tasks/:taskId/output", agentsHandler.RequireToken, agentsHandler.UploadTaskOutput)
+
 		// WebSocket
 		apiGroup.GET("/ws/progress", wsHandler.HandleWebSocket)
 	}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"ffmpeg-web/internal/agent"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// runAgent runs the remote agent mode ("server agent"): tasks come from the ffforge
+// server at SERVER_URL and are encoded with the local ffmpeg
+func runAgent() {
+	hostname, _ := os.Hostname()
+
+	pathMappings, err := agent.ParsePathMappings(os.Getenv("AGENT_PATH_MAP"))
+	if err != nil {
+		log.Fatalf("Invalid AGENT_PATH_MAP: %v", err)
+	}
+
+	config := &agent.Config{
+		ServerURL:    os.Getenv("SERVER_URL"),
+		Token:        os.Getenv("AGENT_TOKEN"),
+		Name:         getEnv("AGENT_NAME", hostname),
+		MaxTasks:     getEnvInt("MAX_CONCURRENT_TASKS", 1),
+		PathMappings: pathMappings,
+		Stream:       getEnvBool("AGENT_STREAM", false),
+		WorkDir:      os.Getenv("AGENT_WORK_DIR"),
+		FFmpegPath:   getEnv("FFMPEG_PATH", "ffmpeg"),
+		FFprobePath:  getEnv("FFPROBE_PATH", "ffprobe"),
+	}
+	if config.ServerURL == "" || config.Token == "" {
+		log.Fatal("Agent mode requires SERVER_URL and AGENT_TOKEN")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Agent %s starting, server %s, max concurrent tasks: %d", config.Name, config.ServerURL, config.MaxTasks)
+	if err := agent.New(config).Run(ctx); err != nil {
+		log.Fatalf("Agent stopped: %v", err)
+	}
+	log.Println("Agent stopped")
+}
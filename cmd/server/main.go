@@ -5,6 +5,7 @@ import (
 	"ffmpeg-web/internal/database"
 	"ffmpeg-web/internal/model"
 	"ffmpeg-web/internal/service"
+	"ffmpeg-web/internal/watch"
 	"ffmpeg-web/internal/worker"
 	"fmt"
 	"log"
@@ -17,6 +18,12 @@ import (
 )
 
 func main() {
+	// Remote agent mode: pull tasks from another ffforge server
+	if len(os.Args) > 1 && os.Args[1] == "agent" {
+		runAgent()
+		return
+	}
+
 	// Load configuration from environment
 	config := loadConfig()
 
@@ -42,25 +49,49 @@ func main() {
 	hardwareService := service.NewHardwareService()
 	hardwareService.SetFFmpegPath(config.FFmpegPath)
 	ffmpegService := service.NewFFmpegService(config.FFmpegPath, config.FFprobePath, config.OutputPath)
+	ffmpegService.SetProbeTimeout(time.Duration(config.ProbeTimeout) * time.Second)
+	ffmpegService.SetProbeCache(service.NewProbeCache(db))
 	systemService := service.NewSystemService()
 	systemService.StartMonitoring()
 	defer systemService.StopMonitoring()
 
 	// Initialize worker pool
-	workerPool := worker.NewPool(db, ffmpegService, fileService, config.MaxConcurrentTasks)
+	workerPool := worker.NewPool(db, ffmpegService, fileService, hardwareService, config.MaxConcurrentTasks)
 	defer workerPool.Shutdown()
 
 	// Initialize WebSocket handler
 	wsHandler := api.NewWebSocketHandler()
 	workerPool.SetBroadcastChannel(wsHandler.GetBroadcastChannel())
 
+	// Remote agents report into the same progress stream
+	coordinator := worker.NewCoordinator(workerPool)
+	defer coordinator.Shutdown()
+
+	// Watch folders queue new media files
+	watchManager := watch.NewManager(db, workerPool, ffmpegService, fileService, hardwareService)
+	if err := watchManager.Start(); err != nil {
+		log.Printf("Warning: Failed to start watch folders: %v", err)
+	}
+	defer watchManager.Shutdown()
+
+	// Sample encodes are kept in a temp directory until they expire
+	sampleService := service.NewSampleService(ffmpegService, hardwareService)
+	defer sampleService.Shutdown()
+
+	// Thumbnails are cached next to the config
+	thumbnailService := service.NewThumbnailService(ffmpegService, filepath.Join(config.ConfigPath, "thumbnails"))
+	defer thumbnailService.Shutdown()
+
 	// Initialize API handlers
-	filesHandler := api.NewFilesHandler(fileService, ffmpegService)
-	tasksHandler := api.NewTasksHandler(db, workerPool, fileService)
+	filesHandler := api.NewFilesHandler(fileService, ffmpegService, thumbnailService)
+	tasksHandler := api.NewTasksHandler(db, workerPool, ffmpegService, fileService, hardwareService)
+	samplesHandler := api.NewSamplesHandler(db, sampleService, fileService, hardwareService)
 	presetsHandler := api.NewPresetsHandler(db)
 	hardwareHandler := api.NewHardwareHandler(hardwareService)
 	settingsHandler := api.NewSettingsHandler(db.Conn())
 	systemHandler := api.NewSystemHandler(systemService)
+	watchFoldersHandler := api.NewWatchFoldersHandler(db, watchManager, fileService)
+	agentsHandler := api.NewAgentsHandler(coordinator, config.AgentToken)
 
 	// Setup Gin router
 	if config.GinMode == "release" {
@@ -82,6 +113,7 @@ func main() {
 		apiGroup.GET("/files/browse", filesHandler.BrowseDirectory)
 		apiGroup.GET("/files/info", filesHandler.GetFileInfo)
 		apiGroup.GET("/files/default-path", filesHandler.GetDefaultPath)
+		apiGroup.GET("/files/thumbnail", filesHandler.GetThumbnail)
 
 		// Tasks
 		apiGroup.POST("/tasks", tasksHandler.CreateTask)
@@ -93,6 +125,10 @@ func main() {
 		apiGroup.POST("/tasks/:id/retry", tasksHandler.RetryTask)
 		apiGroup.DELETE("/tasks/:id", tasksHandler.DeleteTask)
 
+		// Sample encodes
+		apiGroup.POST("/tasks/sample", samplesHandler.CreateSample)
+		apiGroup.GET("/tasks/sample/:id/file", samplesHandler.DownloadSample)
+
 		// Presets
 		apiGroup.GET("/presets", presetsHandler.GetAllPresets)
 		apiGroup.GET("/presets/:id", presetsHandler.GetPreset)
@@ -114,9 +150,26 @@ func main() {
 		apiGroup.GET("/system/history", systemHandler.GetHistory)
 
 		// Command preview
-		commandHandler := api.NewCommandHandler(ffmpegService)
+		commandHandler := api.NewCommandHandler(ffmpegService, fileService)
 		apiGroup.POST("/command/preview", commandHandler.PreviewCommand)
 
+		// Watch folders
+		apiGroup.GET("/watch-folders", watchFoldersHandler.GetAllWatchFolders)
+		apiGroup.GET("/watch-folders/:id", watchFoldersHandler.GetWatchFolder)
+		apiGroup.POST("/watch-folders", watchFoldersHandler.CreateWatchFolder)
+		apiGroup.PUT("/watch-folders/:id", watchFoldersHandler.UpdateWatchFolder)
+		apiGroup.DELETE("/watch-folders/:id", watchFoldersHandler.DeleteWatchFolder)
+
+		// Remote agents
+		apiGroup.GET("/agents", agentsHandler.GetAgents)
+		apiGroup.POST("/agents/register", agentsHandler.RequireToken, agentsHandler.RegisterAgent)
+		apiGroup.DELETE("/agents/:id", agentsHandler.RequireToken, agentsHandler.UnregisterAgent)
+		apiGroup.POST("/agents/:id/heartbeat", agentsHandler.RequireToken, agentsHandler.Heartbeat)
+		apiGroup.POST("/agents/:id/claim", agentsHandler.RequireToken, agentsHandler.ClaimTask)
+		apiGroup.PUT("/agents/:id/tasks/:taskId", agentsHandler.RequireToken, agentsHandler.UpdateTask)
+		apiGroup.GET("/agents/:id/tasks/:taskId/source", agentsHandler.GetTaskSource)
+		apiGroup.PUT("/agents/:id/tasks/:taskId/output", agentsHandler.RequireToken, agentsHandler.UploadTaskOutput)
+
 		// WebSocket
 		apiGroup.GET("/ws/progress", wsHandler.HandleWebSocket)
 	}
@@ -141,6 +194,9 @@ func main() {
 	log.Printf("Data path: %s", config.DataPath)
 	log.Printf("Output path: %s", config.OutputPath)
 	log.Printf("Max concurrent tasks: %d", config.MaxConcurrentTasks)
+	if config.AgentToken != "" {
+		log.Printf("Remote agents enabled")
+	}
 
 	if err := router.Run(addr); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
@@ -160,6 +216,8 @@ type Config struct {
 	CORSOrigins        string
 	FFmpegPath         string
 	FFprobePath        string
+	ProbeTimeout       int    // Seconds before a hung ffprobe is killed
+	AgentToken         string // Shared token of remote agents (empty = agents disabled)
 }
 
 // loadConfig loads configuration from environment variables
@@ -176,6 +234,8 @@ func loadConfig() *Config {
 		CORSOrigins:        getEnv("CORS_ORIGINS", "http://localhost:3000"),
 		FFmpegPath:         getEnv("FFMPEG_PATH", "ffmpeg"),
 		FFprobePath:        getEnv("FFPROBE_PATH", "ffprobe"),
+		ProbeTimeout:       getEnvInt("PROBE_TIMEOUT", 60),
+		AgentToken:         os.Getenv("AGENT_TOKEN"),
 	}
 
 	// Ensure directories exist
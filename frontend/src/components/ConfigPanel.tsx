@@ -6,7 +6,7 @@ import { Card, CardContent, CardHeader, CardTitle } from '@/components/ui/card'
 import { Select } from '@/components/ui/select'
 import { Slider } from '@/components/ui/slider'
 import { useApp } from '@/contexts/AppContext'
-import type { TranscodeConfig, HardwareAccel, AudioCodec, OutputPathType, HdrMode } from '@/types'
+import type { TranscodeConfig, HardwareAccel, AudioCodec, OutputPathType, OutputKind, HdrMode, Deinterlace, FrameRateMode } from '@/types'
 
 interface ConfigPanelProps {
   selectedFiles: string[]
@@ -64,6 +64,9 @@ export default function ConfigPanel({ selectedFiles, onConfigChange, initialConf
 
   const [config, setConfig] = useState<TranscodeConfig>(getInitialConfig())
 
+  // HLS/DASH outputs are a package directory instead of a single file
+  const isPackaged = config.output.kind === 'hls' || config.output.kind === 'dash'
+
   // Reset config when resetTrigger changes (for external control)
   useEffect(() => {
     if (initialConfig && resetTrigger !== undefined) {
@@ -115,6 +118,9 @@ export default function ConfigPanel({ selectedFiles, onConfigChange, initialConf
     if (hardware?.amd) {
       options.push({ value: 'amd', label: 'AMD' })
     }
+    if (hardware?.vaapi && hardware.vaapiEncoders?.includes(config.encoder)) {
+      options.push({ value: 'vaapi', label: 'VA-API' })
+    }
 
     return options
   }
@@ -158,6 +164,15 @@ export default function ConfigPanel({ selectedFiles, onConfigChange, initialConf
       ]
     }
 
+    // VA-API compression levels (driver-specific)
+    if (hardwareAccel === 'vaapi') {
+      return [
+        { value: '1', label: language === 'zh' ? '1 (质量优先)' : '1 (Best Quality)' },
+        { value: '4', label: language === 'zh' ? '4 (平衡)' : '4 (Balanced)' },
+        { value: '7', label: language === 'zh' ? '7 (速度优先)' : '7 (Fastest)' },
+      ]
+    }
+
     // CPU encoding presets
     if (encoder === 'av1') {
       // SVT-AV1 presets (0-13)
@@ -194,6 +209,7 @@ export default function ConfigPanel({ selectedFiles, onConfigChange, initialConf
       case 'intel':
         return 'bg-blue-500/10 text-blue-600 dark:text-blue-500'
       case 'amd':
+      case 'vaapi':
         return 'bg-red-500/10 text-red-600 dark:text-red-500'
       case 'cpu':
       default:
@@ -250,6 +266,28 @@ export default function ConfigPanel({ selectedFiles, onConfigChange, initialConf
             >
               {language === 'zh' ? '高级' : 'Advanced'}
             </button>
+            <button
+              className={cn(
+                'px-2 py-0.5 text-[10px] font-medium rounded transition-colors',
+                config.mode === 'remux'
+                  ? 'bg-background text-foreground shadow-sm'
+                  : 'text-muted-foreground hover:text-foreground'
+              )}
+              onClick={() => setConfig({ ...config, mode: 'remux' })}
+            >
+              {t.config.remuxMode}
+            </button>
+            <button
+              className={cn(
+                'px-2 py-0.5 text-[10px] font-medium rounded transition-colors',
+                config.mode === 'audio'
+                  ? 'bg-background text-foreground shadow-sm'
+                  : 'text-muted-foreground hover:text-foreground'
+              )}
+              onClick={() => setConfig({ ...config, mode: 'audio' })}
+            >
+              {t.config.audioMode}
+            </button>
           </div>
         </div>
       </CardHeader>
@@ -376,6 +414,45 @@ export default function ConfigPanel({ selectedFiles, onConfigChange, initialConf
               </div>
             </div>
 
+            {/* Deinterlacing & Frame Timing - Two columns, "auto" follows the source scan analysis */}
+            <div className="grid grid-cols-2 gap-2 items-end">
+              <div>
+                <label className="block text-[11px] font-medium mb-1 text-muted-foreground">
+                  {t.config.deinterlace}
+                </label>
+                <Select
+                  value={config.video.deinterlace || ''}
+                  onChange={(val) => setConfig({
+                    ...config,
+                    video: { ...config.video, deinterlace: (val || undefined) as Deinterlace | undefined }
+                  })}
+                  options={[
+                    { value: '', label: t.config.deinterlaceOptions.off },
+                    { value: 'auto', label: t.config.deinterlaceOptions.auto },
+                    { value: 'deinterlace', label: t.config.deinterlaceOptions.deinterlace },
+                    { value: 'ivtc', label: t.config.deinterlaceOptions.ivtc },
+                  ]}
+                />
+              </div>
+              <div>
+                <label className="block text-[11px] font-medium mb-1 text-muted-foreground">
+                  {t.config.frameRateMode}
+                </label>
+                <Select
+                  value={config.video.frameRateMode || ''}
+                  onChange={(val) => setConfig({
+                    ...config,
+                    video: { ...config.video, frameRateMode: (val || undefined) as FrameRateMode | undefined }
+                  })}
+                  options={[
+                    { value: '', label: t.config.frameRateModeOptions.source },
+                    { value: 'auto', label: t.config.frameRateModeOptions.auto },
+                    { value: 'cfr', label: t.config.frameRateModeOptions.cfr },
+                  ]}
+                />
+              </div>
+            </div>
+
             {/* Audio Codec & Output Path Type - Two columns with aligned heights */}
             <div className="grid grid-cols-2 gap-2 items-end">
               {/* Audio Codec */}
@@ -444,33 +521,95 @@ export default function ConfigPanel({ selectedFiles, onConfigChange, initialConf
               </div>
             )}
 
+            {/* Output Kind - single file or HLS/DASH package directory, Hidden in overwrite mode */}
+            {config.output.pathType !== 'overwrite' && (
+              <div>
+                <label className="block text-[11px] font-medium mb-1 text-muted-foreground">
+                  {t.config.outputKind}
+                </label>
+                <div className="grid grid-cols-3 gap-1">
+                  {(['file', 'hls', 'dash'] as OutputKind[]).map((kind) => (
+                    <button
+                      key={kind}
+                      className={cn(
+                        'px-1.5 py-2.5 text-[11px] font-medium border rounded transition-colors',
+                        (config.output.kind || 'file') === kind
+                          ? 'bg-primary text-primary-foreground border-primary'
+                          : 'bg-background hover:bg-accent'
+                      )}
+                      onClick={() => setConfig({
+                        ...config,
+                        output: { ...config.output, kind }
+                      })}
+                    >
+                      {t.config.outputKindOptions[kind]}
+                    </button>
+                  ))}
+                </div>
+                {isPackaged && (
+                  <p className="text-[10px] text-muted-foreground mt-1">
+                    {t.config.outputKindHint}
+                  </p>
+                )}
+              </div>
+            )}
+
             {/* Output Format & File Suffix - Two columns with aligned heights, Hidden in overwrite mode */}
             {config.output.pathType !== 'overwrite' && (
               <div className="grid grid-cols-2 gap-2 items-end">
-                {/* Output Format */}
+                {/* Output Format (segment format of HLS packages, DASH always uses fMP4) */}
                 <div>
                   <label className="block text-[11px] font-medium mb-1 text-muted-foreground">
-                    {t.config.outputFormat}
+                    {isPackaged ? t.config.segmentType : t.config.outputFormat}
                   </label>
-                  <div className="grid grid-cols-3 gap-1">
-                    {['mp4', 'mkv', 'webm'].map((format) => (
-                      <button
-                        key={format}
-                        className={cn(
-                          'px-1.5 py-2.5 text-[11px] font-medium border rounded transition-colors',
-                          config.output.container === format
-                            ? 'bg-primary text-primary-foreground border-primary'
-                            : 'bg-background hover:bg-accent'
-                        )}
-                        onClick={() => setConfig({
-                          ...config,
-                          output: { ...config.output, container: format }
-                        })}
-                      >
-                        {format.toUpperCase()}
-                      </button>
-                    ))}
-                  </div>
+                  {config.output.kind === 'hls' ? (
+                    <div className="grid grid-cols-2 gap-1">
+                      {(['fmp4', 'ts'] as const).map((segmentType) => (
+                        <button
+                          key={segmentType}
+                          className={cn(
+                            'px-1.5 py-2.5 text-[11px] font-medium border rounded transition-colors',
+                            (config.output.streaming?.segmentType || 'fmp4') === segmentType
+                              ? 'bg-primary text-primary-foreground border-primary'
+                              : 'bg-background hover:bg-accent'
+                          )}
+                          onClick={() => setConfig({
+                            ...config,
+                            output: {
+                              ...config.output,
+                              streaming: { ...config.output.streaming, segmentType }
+                            }
+                          })}
+                        >
+                          {segmentType === 'fmp4' ? 'fMP4' : 'TS'}
+                        </button>
+                      ))}
+                    </div>
+                  ) : config.output.kind === 'dash' ? (
+                    <div className="px-1.5 py-2.5 text-[11px] font-medium border rounded bg-muted text-center">
+                      fMP4
+                    </div>
+                  ) : (
+                    <div className="grid grid-cols-3 gap-1">
+                      {['mp4', 'mkv', 'webm'].map((format) => (
+                        <button
+                          key={format}
+                          className={cn(
+                            'px-1.5 py-2.5 text-[11px] font-medium border rounded transition-colors',
+                            config.output.container === format
+                              ? 'bg-primary text-primary-foreground border-primary'
+                              : 'bg-background hover:bg-accent'
+                          )}
+                          onClick={() => setConfig({
+                            ...config,
+                            output: { ...config.output, container: format }
+                          })}
+                        >
+                          {format.toUpperCase()}
+                        </button>
+                      ))}
+                    </div>
+                  )}
                 </div>
 
                 {/* File Suffix */}
@@ -514,6 +653,161 @@ export default function ConfigPanel({ selectedFiles, onConfigChange, initialConf
           </>
         )}
 
+        {/* Remux Mode Configuration: only the output container, streams are copied */}
+        {config.mode === 'remux' && (
+          <>
+            <p className="text-[10px] text-muted-foreground">
+              {t.config.remuxHint}
+            </p>
+
+            <div className="grid grid-cols-2 gap-2 items-end">
+              {/* Output Format */}
+              <div>
+                <label className="block text-[11px] font-medium mb-1 text-muted-foreground">
+                  {t.config.outputFormat}
+                </label>
+                <div className="grid grid-cols-3 gap-1">
+                  {['mkv', 'mp4', 'mov'].map((format) => (
+                    <button
+                      key={format}
+                      className={cn(
+                        'px-1.5 py-2.5 text-[11px] font-medium border rounded transition-colors',
+                        config.output.container === format
+                          ? 'bg-primary text-primary-foreground border-primary'
+                          : 'bg-background hover:bg-accent'
+                      )}
+                      onClick={() => setConfig({
+                        ...config,
+                        output: { ...config.output, container: format }
+                      })}
+                    >
+                      {format.toUpperCase()}
+                    </button>
+                  ))}
+                </div>
+              </div>
+
+              {/* File Suffix */}
+              <div>
+                <label className="block text-[11px] font-medium mb-1 text-muted-foreground">
+                  {t.config.fileSuffix}
+                </label>
+                <input
+                  type="text"
+                  className="w-full px-2 py-2.5 text-xs border rounded bg-background"
+                  value={config.output.suffix}
+                  onChange={(e) => setConfig({
+                    ...config,
+                    output: { ...config.output, suffix: e.target.value }
+                  })}
+                  placeholder="_remux"
+                />
+              </div>
+            </div>
+          </>
+        )}
+
+        {/* Audio Mode Configuration: the audio tracks are written into an audio file */}
+        {config.mode === 'audio' && (
+          <>
+            <p className="text-[10px] text-muted-foreground">
+              {t.config.audioHint}
+            </p>
+
+            {/* Output Format, picks the matching codec */}
+            <div>
+              <label className="block text-[11px] font-medium mb-1 text-muted-foreground">
+                {t.config.outputFormat}
+              </label>
+              <div className="grid grid-cols-6 gap-1">
+                {([
+                  ['flac', 'flac'],
+                  ['mp3', 'mp3'],
+                  ['m4a', 'aac'],
+                  ['opus', 'opus'],
+                  ['ogg', 'opus'],
+                  ['mka', 'copy'],
+                ] as [string, AudioCodec][]).map(([format, codec]) => (
+                  <button
+                    key={format}
+                    className={cn(
+                      'px-1 py-2.5 text-[11px] font-medium border rounded transition-colors',
+                      config.output.container === format
+                        ? 'bg-primary text-primary-foreground border-primary'
+                        : 'bg-background hover:bg-accent'
+                    )}
+                    onClick={() => setConfig({
+                      ...config,
+                      audio: { ...config.audio, codec },
+                      output: { ...config.output, container: format }
+                    })}
+                  >
+                    {format.toUpperCase()}
+                  </button>
+                ))}
+              </div>
+            </div>
+
+            <div className="grid grid-cols-3 gap-2 items-end">
+              {/* Audio Codec */}
+              <div>
+                <label className="block text-[11px] font-medium mb-1 text-muted-foreground">
+                  {t.config.audioCodec}
+                </label>
+                <Select
+                  value={config.audio.codec}
+                  onChange={(val) => setConfig({
+                    ...config,
+                    audio: { ...config.audio, codec: val as AudioCodec }
+                  })}
+                  options={[
+                    { value: 'copy', label: t.config.audioCopy },
+                    { value: 'flac', label: 'FLAC' },
+                    { value: 'aac', label: 'AAC' },
+                    { value: 'opus', label: 'Opus' },
+                    { value: 'mp3', label: 'MP3' },
+                  ]}
+                />
+              </div>
+
+              {/* Audio Bitrate (lossy codecs) */}
+              <div>
+                <label className="block text-[11px] font-medium mb-1 text-muted-foreground">
+                  {t.config.audioBitrate}
+                </label>
+                <input
+                  type="text"
+                  className="w-full px-2 py-2.5 text-xs border rounded bg-background disabled:opacity-50"
+                  value={config.audio.bitrate || ''}
+                  disabled={config.audio.codec === 'copy' || config.audio.codec === 'flac'}
+                  onChange={(e) => setConfig({
+                    ...config,
+                    audio: { ...config.audio, bitrate: e.target.value }
+                  })}
+                  placeholder="192k"
+                />
+              </div>
+
+              {/* File Suffix */}
+              <div>
+                <label className="block text-[11px] font-medium mb-1 text-muted-foreground">
+                  {t.config.fileSuffix}
+                </label>
+                <input
+                  type="text"
+                  className="w-full px-2 py-2.5 text-xs border rounded bg-background"
+                  value={config.output.suffix}
+                  onChange={(e) => setConfig({
+                    ...config,
+                    output: { ...config.output, suffix: e.target.value }
+                  })}
+                  placeholder="_audio"
+                />
+              </div>
+            </div>
+          </>
+        )}
+
         {/* Advanced Mode Configuration */}
         {config.mode === 'advanced' && (
           <>
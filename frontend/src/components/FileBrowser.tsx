@@ -8,7 +8,7 @@ import { Card, CardContent } from '@/components/ui/card'
 import { Button } from '@/components/ui/button'
 import { Badge } from '@/components/ui/badge'
 import { Dialog, DialogContent, DialogHeader, DialogTitle, DialogClose } from '@/components/ui/dialog'
-import { formatBytes } from '@/lib/utils'
+import { formatBytes, formatDuration as formatTimestamp } from '@/lib/utils'
 import { cn } from '@/lib/utils'
 
 interface FileBrowserProps {
@@ -44,7 +44,7 @@ export default function FileBrowser({ onFilesSelected, selectedFiles, onStartTra
   })
 
   const videoInfoMutation = useMutation({
-    mutationFn: (path: string) => api.getVideoInfo(path),
+    mutationFn: ({ path, idet }: { path: string; idet?: boolean }) => api.getVideoInfo(path, idet),
   })
 
   const toggleFolder = (path: string) => {
@@ -111,7 +111,7 @@ export default function FileBrowser({ onFilesSelected, selectedFiles, onStartTra
   }
 
   const handleShowVideoInfo = (path: string) => {
-    videoInfoMutation.mutate(path)
+    videoInfoMutation.mutate({ path })
     setVideoInfoDialogOpen(true)
   }
 
@@ -259,6 +259,17 @@ export default function FileBrowser({ onFilesSelected, selectedFiles, onStartTra
                           </div>
                           <File className="h-4 w-4 text-muted-foreground flex-shrink-0" />
                           <span className="flex-1 truncate text-sm">{file.name}</span>
+                          {/* Media details of files probed before */}
+                          {file.height !== undefined && (
+                            <span className="text-xs text-muted-foreground flex-shrink-0">
+                              {file.height}p
+                            </span>
+                          )}
+                          {file.duration !== undefined && file.duration > 0 && (
+                            <span className="text-xs text-muted-foreground flex-shrink-0">
+                              {formatTimestamp(file.duration)}
+                            </span>
+                          )}
                           <span className="text-xs text-muted-foreground flex-shrink-0">
                             {formatBytes(file.size)}
                           </span>
@@ -309,6 +320,16 @@ export default function FileBrowser({ onFilesSelected, selectedFiles, onStartTra
             </div>
           ) : videoInfoMutation.data ? (
             <div className="space-y-2">
+              {/* Poster frame, hidden when the file has no video */}
+              {api.thumbnailURL(videoInfoMutation.data.path) && (
+                <img
+                  src={api.thumbnailURL(videoInfoMutation.data.path)}
+                  alt={videoInfoMutation.data.name}
+                  className="w-full rounded-md bg-muted"
+                  onError={(e) => { e.currentTarget.style.display = 'none' }}
+                />
+              )}
+
               {/* File Name - with word break */}
               <div className="py-2 border-b">
                 <div className="text-xs font-medium text-muted-foreground mb-1">{t.transcode.fileName}:</div>
@@ -369,6 +390,86 @@ export default function FileBrowser({ onFilesSelected, selectedFiles, onStartTra
               {videoInfoMutation.data.colorTransfer && videoInfoMutation.data.isHDR && (
                 <InfoRow label="Transfer" value={videoInfoMutation.data.colorTransfer} />
               )}
+
+              {videoInfoMutation.data.format && (
+                <InfoRow label={t.transcode.container} value={videoInfoMutation.data.format} />
+              )}
+
+              {/* Scan analysis from the metadata; Detect samples the video with idet */}
+              {videoInfoMutation.data.scan && (
+                <div className="flex justify-between items-center gap-4 py-2 border-b">
+                  <span className="text-xs font-medium text-muted-foreground flex-shrink-0">{t.transcode.scan}:</span>
+                  <span className="flex items-center gap-2 text-sm font-mono text-right break-all">
+                    {t.transcode.scanTypes[videoInfoMutation.data.scan.type ?? 'unknown']}
+                    {videoInfoMutation.data.scan.pulldown && ` (${videoInfoMutation.data.scan.pulldown})`}
+                    {videoInfoMutation.data.scan.vfr && `, ${t.transcode.vfr}`}
+                    {videoInfoMutation.data.scan.idet ? (
+                      <span className="text-xs text-muted-foreground">
+                        idet {videoInfoMutation.data.scan.idet.tff + videoInfoMutation.data.scan.idet.bff}/{videoInfoMutation.data.scan.idet.progressive}
+                      </span>
+                    ) : (
+                      <Button
+                        variant="outline"
+                        size="sm"
+                        className="h-6 px-2 text-xs"
+                        onClick={() => videoInfoMutation.variables && videoInfoMutation.mutate({ path: videoInfoMutation.variables.path, idet: true })}
+                      >
+                        {t.transcode.detectScan}
+                      </Button>
+                    )}
+                  </span>
+                </div>
+              )}
+
+              {/* Every stream with its absolute index, as used by stream selections */}
+              {videoInfoMutation.data.streams && videoInfoMutation.data.streams.length > 0 && (
+                <div className="py-2 border-b">
+                  <div className="text-xs font-medium text-muted-foreground mb-1">{t.transcode.streams}:</div>
+                  <div className="space-y-1">
+                    {videoInfoMutation.data.streams.map((stream) => (
+                      <div key={stream.index} className="text-xs font-mono break-all">
+                        #{stream.index} {stream.type} {stream.codec}
+                        {stream.language && ` [${stream.language}]`}
+                        {stream.title && ` "${stream.title}"`}
+                        {stream.width && stream.height && ` ${stream.width}x${stream.height}`}
+                        {stream.channelLayout ? ` ${stream.channelLayout}` : stream.channels ? ` ${stream.channels}ch` : ''}
+                        {stream.sampleRate && ` ${stream.sampleRate} Hz`}
+                        {stream.subtitleType && ` (${stream.subtitleType})`}
+                        {stream.bitrate && ` ${Math.round(stream.bitrate / 1000)} kb/s`}
+                        {stream.disposition && stream.disposition.length > 0 && (
+                          <span className="text-muted-foreground"> {stream.disposition.join(', ')}</span>
+                        )}
+                      </div>
+                    ))}
+                  </div>
+                </div>
+              )}
+
+              {videoInfoMutation.data.chapters && videoInfoMutation.data.chapters.length > 0 && (
+                <div className="py-2 border-b">
+                  <div className="text-xs font-medium text-muted-foreground mb-1">{t.transcode.chapters}:</div>
+                  <div className="space-y-1">
+                    {videoInfoMutation.data.chapters.map((chapter, i) => (
+                      <div key={i} className="text-xs font-mono break-all">
+                        {formatTimestamp(chapter.start)} {chapter.title || `#${i + 1}`}
+                      </div>
+                    ))}
+                  </div>
+                </div>
+              )}
+
+              {videoInfoMutation.data.tags && Object.keys(videoInfoMutation.data.tags).length > 0 && (
+                <div className="py-2 border-b">
+                  <div className="text-xs font-medium text-muted-foreground mb-1">{t.transcode.tags}:</div>
+                  <div className="space-y-1">
+                    {Object.entries(videoInfoMutation.data.tags).map(([key, value]) => (
+                      <div key={key} className="text-xs font-mono break-all">
+                        <span className="text-muted-foreground">{key}:</span> {value}
+                      </div>
+                    ))}
+                  </div>
+                </div>
+              )}
             </div>
           ) : null}
         </DialogContent>
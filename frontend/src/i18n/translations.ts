@@ -96,8 +96,22 @@ export const translations = {
       codec: 'Codec',
       bitrate: 'Bitrate',
       fps: 'Frame Rate',
+      container: 'Container',
+      streams: 'Streams',
+      chapters: 'Chapters',
+      scan: 'Scan',
+      scanTypes: {
+        progressive: 'Progressive',
+        interlaced: 'Interlaced',
+        telecined: 'Telecined',
+        unknown: 'Unknown',
+      },
+      vfr: 'variable frame rate',
+      detectScan: 'Detect',
+      tags: 'Tags',
       loading: 'Loading...',
       tasksCreated: 'Successfully created {count} transcoding task(s)',
+      filesSkipped: 'Skipped {count} file(s) matching the skip rules',
       createTasksFailed: 'Failed to create tasks',
     },
     // Configuration panel
@@ -106,6 +120,11 @@ export const translations = {
       mode: 'Configuration Mode',
       simpleMode: 'Simple Mode',
       advancedMode: 'Advanced Mode',
+      remuxMode: 'Remux',
+      remuxHint: 'Streams are copied into the new container without re-encoding. Encoder and hardware acceleration settings are not used.',
+      audioMode: 'Audio',
+      audioHint: 'Writes the audio of each file into an audio file, with tags and cover art. Single-track formats keep the first audio track; MKA keeps all of them.',
+      audioBitrate: 'Audio Bitrate',
       customCommand: 'Custom FFmpeg Command',
       customCommandHint: 'Enter complete FFmpeg parameters (input and output files will be added automatically). Example: -c:v libx265 -preset medium -crf 23 -c:a aac -b:a 192k',
       preset: 'Preset Configuration',
@@ -119,6 +138,14 @@ export const translations = {
       audioCodec: 'Audio Codec',
       audioCopy: 'Copy (No Re-encoding)',
       outputFormat: 'Output Format',
+      outputKind: 'Output Kind',
+      outputKindOptions: {
+        file: 'File',
+        hls: 'HLS',
+        dash: 'DASH',
+      },
+      outputKindHint: 'Written to a folder named after the source (playlist + segments) with the main video and the first audio track',
+      segmentType: 'Segment Format',
       outputPath: 'Output Path',
       outputPathOptions: {
         source: 'Source Directory',
@@ -143,6 +170,19 @@ export const translations = {
         keep: 'Preserve HDR/SDR as-is',
         discard: 'Convert HDR to SDR',
       },
+      deinterlace: 'Deinterlace',
+      deinterlaceOptions: {
+        off: 'Off',
+        auto: 'Auto',
+        deinterlace: 'Deinterlace',
+        ivtc: 'Inverse Telecine',
+      },
+      frameRateMode: 'Frame Timing',
+      frameRateModeOptions: {
+        source: 'Source',
+        auto: 'Auto (CFR for VFR)',
+        cfr: 'Constant (CFR)',
+      },
       speeds: {
         ultrafast: 'Ultra Fast',
         superfast: 'Super Fast',
@@ -168,6 +208,7 @@ export const translations = {
         completed: 'Completed',
         failed: 'Failed',
         cancelled: 'Cancelled',
+        skipped: 'Skipped',
         interrupted: 'Interrupted',
       },
       progress: 'Progress',
@@ -250,6 +291,13 @@ export const translations = {
       retryFailed: 'Failed to retry task',
       retrySelected: 'Retry Selected',
       retryMultipleSuccess: 'Selected tasks retry submitted successfully',
+      quality: 'Quality',
+      qualityFilterAll: 'All tasks',
+      qualityMinScore: 'Min score',
+      qualityMean: 'Mean',
+      qualityMin: 'Min',
+      qualityP5: '5th percentile',
+      qualitySamples: '{frames} frames in {samples} samples',
     },
     // Settings page
     settings: {
@@ -311,6 +359,7 @@ export const translations = {
         nvidia: 'NVIDIA',
         intel: 'Intel',
         amd: 'AMD',
+        vaapi: 'VA-API',
       },
       deleteConfirm: 'Are you sure you want to delete this preset?',
       importSuccess: 'Presets imported successfully',
@@ -443,8 +492,22 @@ export const translations = {
       codec: '编码',
       bitrate: '比特率',
       fps: '帧率',
+      container: '容器',
+      streams: '流',
+      chapters: '章节',
+      scan: '扫描方式',
+      scanTypes: {
+        progressive: '逐行',
+        interlaced: '隔行',
+        telecined: '电视电影',
+        unknown: '未知',
+      },
+      vfr: '可变帧率',
+      detectScan: '检测',
+      tags: '标签',
       loading: '加载中...',
       tasksCreated: '成功创建 {count} 个转码任务',
+      filesSkipped: '已跳过 {count} 个符合跳过规则的文件',
       createTasksFailed: '创建任务失败',
     },
     // 配置面板
@@ -453,6 +516,11 @@ export const translations = {
       mode: '配置模式',
       simpleMode: '简单模式',
       advancedMode: '高级模式',
+      remuxMode: '封装',
+      remuxHint: '不重新编码，直接将流复制到新的容器中。不使用编码器和硬件加速设置。',
+      audioMode: '音频',
+      audioHint: '将每个文件的音频写入音频文件，保留标签和封面。单音轨格式只保留第一条音轨，MKA 保留全部音轨。',
+      audioBitrate: '音频比特率',
       customCommand: '自定义 FFmpeg 命令',
       customCommandHint: '输入完整的 FFmpeg 参数（输入和输出文件会自动添加）。例如：-c:v libx265 -preset medium -crf 23 -c:a aac -b:a 192k',
       preset: '预设配置',
@@ -466,6 +534,14 @@ export const translations = {
       audioCodec: '音频编码',
       audioCopy: '复制（不重新编码）',
       outputFormat: '输出格式',
+      outputKind: '输出类型',
+      outputKindOptions: {
+        file: '文件',
+        hls: 'HLS',
+        dash: 'DASH',
+      },
+      outputKindHint: '输出到以源文件命名的文件夹（播放列表 + 分片），包含主视频和第一条音轨',
+      segmentType: '分片格式',
       outputPath: '输出路径',
       outputPathOptions: {
         source: '源文件目录',
@@ -490,6 +566,19 @@ export const translations = {
         keep: '保持HDR/SDR原样',
         discard: '将HDR转为SDR',
       },
+      deinterlace: '反交错',
+      deinterlaceOptions: {
+        off: '关闭',
+        auto: '自动',
+        deinterlace: '反交错',
+        ivtc: '反胶片过带',
+      },
+      frameRateMode: '帧时序',
+      frameRateModeOptions: {
+        source: '跟随源',
+        auto: '自动（VFR转CFR）',
+        cfr: '恒定帧率',
+      },
       speeds: {
         ultrafast: '极快',
         superfast: '超快',
@@ -515,6 +604,7 @@ export const translations = {
         completed: '已完成',
         failed: '失败',
         cancelled: '已取消',
+        skipped: '已跳过',
         interrupted: '被中断',
       },
       progress: '进度',
@@ -597,6 +687,13 @@ export const translations = {
       retryFailed: '重试任务失败',
       retrySelected: '重试选中',
       retryMultipleSuccess: '选中任务重试已提交',
+      quality: '画质',
+      qualityFilterAll: '全部任务',
+      qualityMinScore: '最低分',
+      qualityMean: '平均',
+      qualityMin: '最低',
+      qualityP5: '5% 分位',
+      qualitySamples: '{samples} 个采样片段共 {frames} 帧',
     },
     // 设置页面
     settings: {
@@ -658,6 +755,7 @@ export const translations = {
         nvidia: 'NVIDIA',
         intel: 'Intel',
         amd: 'AMD',
+        vaapi: 'VA-API',
       },
       deleteConfirm: '确定要删除此预设吗？',
       importSuccess: '预设导入成功',
@@ -1,4 +1,4 @@
-import type { FileInfo, Task, Preset, HardwareInfo, TranscodeConfig, Settings } from '@/types'
+import type { FileInfo, Task, Preset, HardwareInfo, TranscodeConfig, Settings, Agent, WatchFolder, WatchFolderInput, CreateTasksResponse, TaskQualityFilter, SampleRequest, SampleResult, ThumbnailKind } from '@/types'
 import type { HostInfo, SystemUsage, SystemHistory } from '@/types/system'
 import type { GPUCapabilities } from '@/types/hardware'
 import { getAPIBase } from './config'
@@ -16,12 +16,18 @@ class APIClient {
     return response.json()
   }
 
-  async getVideoInfo(path: string): Promise<FileInfo> {
-    const response = await fetch(`${getAPIBaseURL()}/files/info?path=${encodeURIComponent(path)}`)
+  // idet classifies the video scan on a sample (slower) instead of the metadata alone
+  async getVideoInfo(path: string, idet = false): Promise<FileInfo> {
+    const response = await fetch(`${getAPIBaseURL()}/files/info?path=${encodeURIComponent(path)}${idet ? '&idet=true' : ''}`)
     if (!response.ok) throw new Error('Failed to get video info')
     return response.json()
   }
 
+  // URL of a cached thumbnail (trickplay returns a WebVTT file with sprite cues)
+  thumbnailURL(path: string, kind: ThumbnailKind = 'poster'): string {
+    return `${getAPIBaseURL()}/files/thumbnail?path=${encodeURIComponent(path)}&kind=${kind}`
+  }
+
   async getDefaultPath(): Promise<string> {
     const response = await fetch(`${getAPIBaseURL()}/files/default-path`)
     if (!response.ok) throw new Error('Failed to get default path')
@@ -30,8 +36,13 @@ class APIClient {
   }
 
   // Tasks
-  async getTasks(): Promise<Task[]> {
-    const response = await fetch(`${getAPIBaseURL()}/tasks`)
+  async getTasks(filter?: TaskQualityFilter): Promise<Task[]> {
+    const params = new URLSearchParams()
+    if (filter?.metric) params.set('metric', filter.metric)
+    if (filter?.minScore !== undefined) params.set('minScore', filter.minScore.toString())
+    if (filter?.maxScore !== undefined) params.set('maxScore', filter.maxScore.toString())
+    const query = params.toString()
+    const response = await fetch(`${getAPIBaseURL()}/tasks${query ? `?${query}` : ''}`)
     if (!response.ok) throw new Error('Failed to get tasks')
     return response.json()
   }
@@ -42,7 +53,7 @@ class APIClient {
     return response.json()
   }
 
-  async createTasks(sourceFiles: string[], preset?: string, config?: TranscodeConfig): Promise<Task[]> {
+  async createTasks(sourceFiles: string[], preset?: string, config?: TranscodeConfig): Promise<CreateTasksResponse> {
     const response = await fetch(`${getAPIBaseURL()}/tasks`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
@@ -52,6 +63,16 @@ class APIClient {
     return response.json()
   }
 
+  async createSample(request: SampleRequest): Promise<SampleResult> {
+    const response = await fetch(`${getAPIBaseURL()}/tasks/sample`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(request),
+    })
+    if (!response.ok) throw new Error('Failed to encode sample')
+    return response.json()
+  }
+
   async deleteTask(id: string): Promise<void> {
     const response = await fetch(`${getAPIBaseURL()}/tasks/${id}`, {
       method: 'DELETE',
@@ -143,6 +164,47 @@ class APIClient {
     return response.json()
   }
 
+  // Watch folders
+  async getWatchFolders(): Promise<WatchFolder[]> {
+    const response = await fetch(`${getAPIBaseURL()}/watch-folders`)
+    if (!response.ok) throw new Error('Failed to get watch folders')
+    return response.json()
+  }
+
+  async createWatchFolder(folder: WatchFolderInput): Promise<WatchFolder> {
+    const response = await fetch(`${getAPIBaseURL()}/watch-folders`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(folder),
+    })
+    if (!response.ok) throw new Error('Failed to create watch folder')
+    return response.json()
+  }
+
+  async updateWatchFolder(id: string, folder: WatchFolderInput): Promise<WatchFolder> {
+    const response = await fetch(`${getAPIBaseURL()}/watch-folders/${id}`, {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(folder),
+    })
+    if (!response.ok) throw new Error('Failed to update watch folder')
+    return response.json()
+  }
+
+  async deleteWatchFolder(id: string): Promise<void> {
+    const response = await fetch(`${getAPIBaseURL()}/watch-folders/${id}`, {
+      method: 'DELETE',
+    })
+    if (!response.ok) throw new Error('Failed to delete watch folder')
+  }
+
+  // Agents
+  async getAgents(): Promise<Agent[]> {
+    const response = await fetch(`${getAPIBaseURL()}/agents`)
+    if (!response.ok) throw new Error('Failed to get agents')
+    return response.json()
+  }
+
   // System
   async getSystemHostInfo(): Promise<HostInfo> {
     const response = await fetch(`${getAPIBaseURL()}/system/host`)
@@ -1,5 +1,5 @@
 // Mock API Client for frontend development/testing
-import type { FileInfo, Task, Preset, HardwareInfo, TranscodeConfig, Settings } from '@/types'
+import type { FileInfo, Task, Preset, HardwareInfo, TranscodeConfig, Settings, Agent, WatchFolder, WatchFolderInput, CreateTasksResponse, TaskQualityFilter, SampleRequest, SampleResult, ThumbnailKind } from '@/types'
 import type { HostInfo, SystemUsage, SystemHistory } from '@/types/system'
 import type { GPUCapabilities } from '@/types/hardware'
 import {
@@ -21,6 +21,7 @@ const delay = (ms: number = 100) => new Promise(resolve => setTimeout(resolve, 5
 let tasks = [...mockTasks]
 let presets = [...mockPresets]
 let settings = { ...mockSettings }
+let watchFolders: WatchFolder[] = []
 let taskIdCounter = 100
 
 // Mock API Client with same interface as real APIClient
@@ -32,7 +33,7 @@ class MockAPIClient {
         return mockFiles[normalizedPath] || []
     }
 
-    async getVideoInfo(path: string): Promise<FileInfo> {
+    async getVideoInfo(path: string, _idet = false): Promise<FileInfo> {
         await delay()
         // Find the file in mock data
         for (const files of Object.values(mockFiles)) {
@@ -42,13 +43,18 @@ class MockAPIClient {
         throw new Error('File not found')
     }
 
+    // No thumbnails without a server, the image falls back to nothing
+    thumbnailURL(_path: string, _kind: ThumbnailKind = 'poster'): string {
+        return ''
+    }
+
     async getDefaultPath(): Promise<string> {
         await delay()
         return '/Videos'
     }
 
     // Tasks
-    async getTasks(): Promise<Task[]> {
+    async getTasks(filter?: TaskQualityFilter): Promise<Task[]> {
         await delay()
         // Simulate progress for running tasks
         tasks = tasks.map(task => {
@@ -74,6 +80,14 @@ class MockAPIClient {
             }
             return task
         })
+        if (filter && (filter.metric || filter.minScore !== undefined || filter.maxScore !== undefined)) {
+            return tasks.filter(task =>
+                task.quality &&
+                (!filter.metric || task.quality.metric === filter.metric) &&
+                (filter.minScore === undefined || task.quality.mean >= filter.minScore) &&
+                (filter.maxScore === undefined || task.quality.mean <= filter.maxScore)
+            )
+        }
         return tasks
     }
 
@@ -84,7 +98,7 @@ class MockAPIClient {
         return task
     }
 
-    async createTasks(sourceFiles: string[], _preset?: string, config?: TranscodeConfig): Promise<Task[]> {
+    async createTasks(sourceFiles: string[], _preset?: string, config?: TranscodeConfig): Promise<CreateTasksResponse> {
         await delay(200)
         const newTasks: Task[] = sourceFiles.map(file => {
             const id = `task-mock-${++taskIdCounter}`
@@ -122,7 +136,31 @@ class MockAPIClient {
         }
 
         tasks = [...tasks, ...newTasks]
-        return newTasks
+        return { tasks: newTasks, skipped: [] }
+    }
+
+    async createSample(request: SampleRequest): Promise<SampleResult> {
+        await delay(1000)
+        const duration = request.duration || 30
+        const sourceSize = 1500000000
+        const outputSize = 45000000
+        return {
+            id: `sample-mock-${++taskIdCounter}`,
+            sourceFile: request.sourceFile,
+            start: request.start || 0,
+            duration,
+            command: `ffmpeg -ss ${request.start || 0} -i ${request.sourceFile} -t ${duration} ...`,
+            outputSize,
+            sourceSize,
+            projectedSize: outputSize * (1440 / duration),
+            encodeTime: duration / 2.5,
+            speed: 2.5,
+            quality: request.quality
+                ? { metric: 'vmaf', mean: 94.2, min: 88.1, p5: 90.3, frames: duration * 24, samples: 1 }
+                : undefined,
+            downloadUrl: '#',
+            expiresAt: new Date(Date.now() + 3600000).toISOString(),
+        }
     }
 
     async deleteTask(id: string): Promise<void> {
@@ -238,6 +276,53 @@ class MockAPIClient {
         return mockGPUCapabilities
     }
 
+    // Watch folders
+    async getWatchFolders(): Promise<WatchFolder[]> {
+        await delay()
+        return watchFolders
+    }
+
+    async createWatchFolder(input: WatchFolderInput): Promise<WatchFolder> {
+        await delay(200)
+        const now = new Date().toISOString()
+        const folder: WatchFolder = {
+            include: [],
+            exclude: [],
+            recursive: true,
+            settleSeconds: 30,
+            poll: false,
+            enabled: true,
+            ...input,
+            id: `watch-${Date.now()}`,
+            createdAt: now,
+            updatedAt: now,
+            mode: 'inotify',
+        }
+        watchFolders = [...watchFolders, folder]
+        return folder
+    }
+
+    async updateWatchFolder(id: string, input: WatchFolderInput): Promise<WatchFolder> {
+        await delay(200)
+        watchFolders = watchFolders.map(f =>
+            f.id === id ? { ...f, ...input, updatedAt: new Date().toISOString() } : f
+        )
+        const updated = watchFolders.find(f => f.id === id)
+        if (!updated) throw new Error('Watch folder not found')
+        return updated
+    }
+
+    async deleteWatchFolder(id: string): Promise<void> {
+        await delay()
+        watchFolders = watchFolders.filter(f => f.id !== id)
+    }
+
+    // Agents
+    async getAgents(): Promise<Agent[]> {
+        await delay()
+        return []
+    }
+
     // System
     async getSystemHostInfo(): Promise<HostInfo> {
         await delay()
@@ -28,6 +28,13 @@ export const mockFiles: Record<string, FileInfo[]> = {
             codec: 'h264',
             bitrate: 8000000,
             frameRate: '24000/1001',
+            format: 'mov,mp4,m4a,3gp,3g2,mj2',
+            streams: [
+                { index: 0, type: 'video', codec: 'h264', profile: 'High', width: 1920, height: 1080, frameRate: '24000/1001', disposition: ['default'] },
+                { index: 1, type: 'audio', codec: 'aac', channels: 2, channelLayout: 'stereo', sampleRate: 48000, language: 'eng', bitrate: 192000, disposition: ['default'] },
+                { index: 2, type: 'subtitle', codec: 'mov_text', subtitleType: 'text', language: 'eng' },
+            ],
+            scan: { type: 'progressive', fieldOrder: 'progressive', frameRate: '24000/1001', avgFrameRate: '24000/1001', vfr: false },
         },
         {
             name: 'sample_4k_hdr.mkv',
@@ -27,7 +27,11 @@ function computeOutputFilePath(
   const suffix = output.suffix || '_transcoded'
   // Determine output extension
   const outputExt = output.container ? `.${output.container}` : '.mp4'
-  const outputFilename = inputFile.substring(inputFile.lastIndexOf('/') + 1).replace(/\.[^/.]+$/, '') + suffix + outputExt
+  let outputFilename = inputFile.substring(inputFile.lastIndexOf('/') + 1).replace(/\.[^/.]+$/, '') + suffix + outputExt
+  // HLS/DASH packages are a directory named after the source
+  if (output.kind === 'hls' || output.kind === 'dash') {
+    outputFilename = outputFilename.substring(0, outputFilename.length - outputExt.length)
+  }
 
   // Determine output directory based on PathType
   let outputDir: string
@@ -76,6 +80,25 @@ export function generateFFmpegCommand(
 
     parts.push(customCmd)
     return parts.join(' ')
+  } else if (config.mode === 'remux') {
+    // Remux mode: every stream copied, no hardware acceleration
+    parts.push('-fflags', '+genpts', '-i', inputFile)
+    parts.push('-map', '0', '-map_metadata', '0', '-c', 'copy')
+    if (['mp4', 'm4v', 'mov'].includes(config.output.container)) {
+      parts.push('-movflags', '+faststart')
+    }
+    parts.push('-avoid_negative_ts', 'make_zero')
+  } else if (config.mode === 'audio') {
+    // Audio task: the audio tracks only (single-track formats keep the first one)
+    parts.push('-i', inputFile)
+    parts.push('-map', config.output.container === 'mka' ? '0:a' : '0:a:0', '-map_metadata', '0')
+    parts.push('-c:a', config.audio.codec)
+    if (config.audio.codec !== 'copy' && config.audio.bitrate) {
+      parts.push('-b:a', config.audio.bitrate)
+    }
+    if (config.output.container === 'mp3') {
+      parts.push('-id3v2_version', '3')
+    }
   } else {
     // Simple mode: build command from UI config
     const { encoder, hardwareAccel, video, audio } = config
@@ -92,12 +115,28 @@ export function generateFFmpegCommand(
     // Add input file
     parts.push('-i', inputFile)
 
-    // Map all streams to preserve multiple audio tracks, subtitles, attachments
-    parts.push('-map', '0')
+    const packaged = config.output.kind === 'hls' || config.output.kind === 'dash'
+    if (packaged) {
+      // HLS/DASH packages carry the main video and the first audio track
+      parts.push('-map', '0:v:0', '-map', '0:a:0?')
+    } else {
+      // Map all streams to preserve multiple audio tracks, subtitles, attachments
+      parts.push('-map', '0')
+    }
 
     // Preserve metadata from source
     parts.push('-map_metadata', '0')
 
+    // Deinterlacing ("auto" is decided by the backend from the source scan)
+    if (video.deinterlace === 'deinterlace') {
+      parts.push('-filter:v:0', 'bwdif=mode=send_frame')
+    } else if (video.deinterlace === 'ivtc') {
+      parts.push('-filter:v:0', 'fieldmatch,bwdif=mode=send_frame:deint=interlaced,decimate')
+    }
+    if (video.frameRateMode === 'cfr') {
+      parts.push('-fps_mode', 'cfr')
+    }
+
     // Video codec
     let videoCodec: string
     if (hardwareAccel === 'nvidia') {
@@ -175,11 +214,16 @@ export function generateFFmpegCommand(
       }
     }
 
-    // Preserve subtitles
-    parts.push('-c:s', 'copy')
+    if (packaged) {
+      // Keyframes on the segment boundaries
+      parts.push('-force_key_frames', `expr:gte(t,n_forced*${config.output.streaming?.segmentDuration || 6})`)
+    } else {
+      // Preserve subtitles
+      parts.push('-c:s', 'copy')
 
-    // Preserve attachments (fonts for subtitles, etc.)
-    parts.push('-c:t', 'copy')
+      // Preserve attachments (fonts for subtitles, etc.)
+      parts.push('-c:t', 'copy')
+    }
 
     // Extra parameters
     if (config.extraParams && config.extraParams.trim()) {
@@ -190,7 +234,17 @@ export function generateFFmpegCommand(
   // Output file (only for simple mode, already handled in advanced mode)
   if (config.mode !== 'advanced') {
     const outputFile = computeOutputFilePath(inputFile, config.output, defaultOutputPath)
-    parts.push(outputFile)
+    const segmentDuration = String(config.output.streaming?.segmentDuration || 6)
+    if (config.output.kind === 'hls') {
+      // Output is the package directory, players open master.m3u8
+      const segmentType = config.output.streaming?.segmentType === 'ts' ? 'mpegts' : 'fmp4'
+      parts.push('-f', 'hls', '-hls_time', segmentDuration, '-hls_playlist_type', 'vod')
+      parts.push('-hls_segment_type', segmentType, '-master_pl_name', 'master.m3u8', `${outputFile}/stream.m3u8`)
+    } else if (config.output.kind === 'dash') {
+      parts.push('-f', 'dash', '-seg_duration', segmentDuration, `${outputFile}/manifest.mpd`)
+    } else {
+      parts.push(outputFile)
+    }
   }
 
   return parts.join(' ')
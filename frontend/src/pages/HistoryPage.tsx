@@ -6,12 +6,14 @@ import { useApp } from '@/contexts/AppContext'
 import { useCommandPreview } from '@/hooks/useCommandPreview'
 import { Button } from '@/components/ui/button'
 import { Badge } from '@/components/ui/badge'
+import { Input } from '@/components/ui/input'
+import { Select } from '@/components/ui/select'
 import { ConfirmDialog } from '@/components/ui/confirm-dialog'
 import { Pagination } from '@/components/ui/pagination'
 import { useToast } from '@/components/ui/toast'
 import { formatDuration, formatBytes } from '@/lib/utils'
 import { cn } from '@/lib/utils'
-import type { Task, TaskStatus } from '@/types'
+import type { Task, TaskStatus, QualityMetric, TaskQualityFilter } from '@/types'
 
 const getStatusVariant = (status: TaskStatus): "default" | "secondary" | "destructive" | "outline" => {
   switch (status) {
@@ -42,6 +44,8 @@ export default function HistoryPage() {
   const [selectedTasks, setSelectedTasks] = useState<string[]>([])
   const [currentPage, setCurrentPage] = useState(1)
   const [pageSize, setPageSize] = useState(10)
+  const [qualityMetric, setQualityMetric] = useState<QualityMetric | ''>('')
+  const [qualityMinScore, setQualityMinScore] = useState('')
 
   // Get command preview for selected task (when actualCommand is not available)
   const { command: previewCommand } = useCommandPreview(
@@ -49,9 +53,14 @@ export default function HistoryPage() {
     { sourceFile: selectedTask?.sourceFile }
   )
 
+  // Quality filter (metric and minimum mean score), applied by the backend
+  const qualityFilter: TaskQualityFilter = {}
+  if (qualityMetric) qualityFilter.metric = qualityMetric
+  if (qualityMinScore !== '' && !isNaN(Number(qualityMinScore))) qualityFilter.minScore = Number(qualityMinScore)
+
   const { data: tasks, isLoading } = useQuery({
-    queryKey: ['tasks'],
-    queryFn: () => api.getTasks(),
+    queryKey: ['tasks', qualityFilter],
+    queryFn: () => api.getTasks(qualityFilter),
   })
 
   const deleteMutation = useMutation({
@@ -161,10 +170,10 @@ export default function HistoryPage() {
     }
   }
 
-  // Filter only completed, failed, and cancelled tasks
+  // Filter only completed, failed, cancelled, and skipped tasks
   // Sort by completedAt or createdAt descending (newest first)
   const completedTasks = (tasks?.filter(t =>
-    t.status === 'completed' || t.status === 'failed' || t.status === 'cancelled'
+    t.status === 'completed' || t.status === 'failed' || t.status === 'cancelled' || t.status === 'skipped'
   ) || []).sort((a, b) => {
     const aTime = new Date(a.completedAt || a.createdAt || 0).getTime()
     const bTime = new Date(b.completedAt || b.createdAt || 0).getTime()
@@ -231,6 +240,34 @@ export default function HistoryPage() {
           <XCircle className="h-4 w-4 text-destructive" />
           <span className="text-sm">{t.history.failedCount}: {completedTasks.filter(t => t.status === 'failed' || t.status === 'cancelled').length}</span>
         </div>
+        <div className="ml-auto flex items-center gap-2">
+          <span className="text-sm text-muted-foreground">{t.history.quality}</span>
+          <Select
+            className="w-36"
+            value={qualityMetric}
+            onChange={(value: string) => {
+              setQualityMetric(value as QualityMetric | '')
+              setCurrentPage(1)
+            }}
+            options={[
+              { value: '', label: t.history.qualityFilterAll },
+              { value: 'vmaf', label: 'VMAF' },
+              { value: 'ssim', label: 'SSIM' },
+              { value: 'psnr', label: 'PSNR' },
+            ]}
+          />
+          <Input
+            className="w-28 h-9"
+            type="number"
+            step="any"
+            placeholder={t.history.qualityMinScore}
+            value={qualityMinScore}
+            onChange={(e) => {
+              setQualityMinScore(e.target.value)
+              setCurrentPage(1)
+            }}
+          />
+        </div>
       </div>
 
       {/* Two column layout: History List (left) + Details (right) */}
@@ -443,7 +480,7 @@ export default function HistoryPage() {
                       </div>
 
                       {/* Compression Ratio */}
-                      {selectedTask.status === 'completed' &&
+                      {(selectedTask.status === 'completed' || selectedTask.status === 'skipped') &&
                         selectedTask.sourceFileSize && selectedTask.sourceFileSize > 0 &&
                         selectedTask.outputFileSize && selectedTask.outputFileSize > 0 && (
                           <div className="bg-primary/5 border border-primary/20 rounded-lg p-3">
@@ -466,6 +503,34 @@ export default function HistoryPage() {
                             </div>
                           </div>
                         )}
+
+                      {/* Quality Score */}
+                      {selectedTask.quality && (
+                        <div className="bg-primary/5 border border-primary/20 rounded-lg p-3">
+                          <label className="text-xs font-medium text-muted-foreground uppercase tracking-wide">
+                            {t.history.quality} ({selectedTask.quality.metric.toUpperCase()})
+                          </label>
+                          <div className="mt-2 grid grid-cols-3 gap-2 text-sm">
+                            <div>
+                              <p className="text-xs text-muted-foreground">{t.history.qualityMean}</p>
+                              <p className="font-bold text-primary">{selectedTask.quality.mean}</p>
+                            </div>
+                            <div>
+                              <p className="text-xs text-muted-foreground">{t.history.qualityMin}</p>
+                              <p className="font-medium">{selectedTask.quality.min}</p>
+                            </div>
+                            <div>
+                              <p className="text-xs text-muted-foreground">{t.history.qualityP5}</p>
+                              <p className="font-medium">{selectedTask.quality.p5}</p>
+                            </div>
+                          </div>
+                          <p className="mt-2 text-xs text-muted-foreground">
+                            {t.history.qualitySamples
+                              .replace('{frames}', selectedTask.quality.frames.toString())
+                              .replace('{samples}', selectedTask.quality.samples.toString())}
+                          </p>
+                        </div>
+                      )}
                     </div>
 
                     {/* Timestamps */}
@@ -104,6 +104,21 @@ export default function PresetsPage() {
       return
     }
 
+    if (config.mode === 'remux') {
+      // Remux mode: every stream copied, no hardware acceleration
+      const fastStart = ['mp4', 'm4v', 'mov'].includes(config.output.container) ? ' -movflags +faststart' : ''
+      setCommandPreview(`ffmpeg -fflags +genpts -i ${inputFile} -map 0 -map_metadata 0 -c copy${fastStart} -avoid_negative_ts make_zero ${outputFile}`)
+      return
+    }
+
+    if (config.mode === 'audio') {
+      // Audio task: the audio tracks only (single-track formats keep the first one)
+      const map = config.output.container === 'mka' ? '0:a' : '0:a:0'
+      const bitrate = config.audio.codec !== 'copy' && config.audio.bitrate ? ` -b:a ${config.audio.bitrate}` : ''
+      setCommandPreview(`ffmpeg -i ${inputFile} -map ${map} -map_metadata 0 -c:a ${config.audio.codec}${bitrate} ${outputFile}`)
+      return
+    }
+
     const parts: string[] = ['ffmpeg']
 
     // IMPORTANT: Hardware acceleration flags must come BEFORE -i input file
@@ -118,6 +133,16 @@ export default function PresetsPage() {
     // Add input file
     parts.push('-i', inputFile)
 
+    // Deinterlacing ("auto" is decided by the backend from the source scan)
+    if (config.video.deinterlace === 'deinterlace') {
+      parts.push('-filter:v:0', 'bwdif=mode=send_frame')
+    } else if (config.video.deinterlace === 'ivtc') {
+      parts.push('-filter:v:0', 'fieldmatch,bwdif=mode=send_frame:deint=interlaced,decimate')
+    }
+    if (config.video.frameRateMode === 'cfr') {
+      parts.push('-fps_mode', 'cfr')
+    }
+
     // Video codec
     let videoCodec: string
     if (config.hardwareAccel === 'nvidia') {
@@ -168,8 +193,14 @@ export default function PresetsPage() {
       parts.push(config.extraParams)
     }
 
-    // Output
-    parts.push(outputFile)
+    // Output (HLS/DASH packages are a directory named after the source)
+    if (config.output.kind === 'hls') {
+      parts.push('-f', 'hls', '-master_pl_name', 'master.m3u8', `output${config.output.suffix}/stream.m3u8`)
+    } else if (config.output.kind === 'dash') {
+      parts.push('-f', 'dash', `output${config.output.suffix}/manifest.mpd`)
+    } else {
+      parts.push(outputFile)
+    }
 
     setCommandPreview(parts.join(' '))
   }
@@ -42,10 +42,13 @@ export default function TranscodePage() {
 
   const createTasksMutation = useMutation({
     mutationFn: () => api.createTasks(selectedFiles, undefined, config),
-    onSuccess: (tasks) => {
+    onSuccess: ({ tasks, skipped }) => {
       queryClient.invalidateQueries({ queryKey: ['tasks'] })
       setSelectedFiles([])
       showToast(t.transcode.tasksCreated.replace('{count}', tasks.length.toString()), 'success')
+      if (skipped.length > 0) {
+        showToast(t.transcode.filesSkipped.replace('{count}', skipped.length.toString()), 'info')
+      }
     },
     onError: () => {
       showToast(t.transcode.createTasksFailed, 'error')
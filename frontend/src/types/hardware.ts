@@ -3,6 +3,8 @@ export interface GPUCapabilities {
   intelVA?: VAInfo
   hasNVIDIA: boolean
   hasAMD: boolean
+  vaapiEncoders?: string[]
+  encoders?: EncoderCapabilities
 }
 
 export interface VAInfo {
@@ -12,3 +14,20 @@ export interface VAInfo {
   encodeProfiles: string[]
 }
 
+export interface EncoderStatus {
+  encoder: string
+  available: boolean
+  tested: boolean
+  working: boolean
+  error?: string
+}
+
+export interface EncoderCapabilities {
+  encoders: string[]
+  decoders: string[]
+  hwaccels: string[]
+  filters: string[]
+  // encoder family (h265, av1, ...) -> hardware acceleration (cpu, nvidia, ...) -> status
+  matrix: Record<string, Record<string, EncoderStatus>>
+  tested: boolean
+}
@@ -17,15 +17,66 @@ export interface FileInfo {
   colorSpace?: string
   colorTransfer?: string
   isHDR?: boolean
+  // Every stream, the chapters and the container of a probed file
+  format?: string // e.g. "matroska,webm"
+  tags?: Record<string, string> // container tags (title, encoder, ...)
+  streams?: MediaStream[]
+  chapters?: Chapter[]
+  scan?: ScanInfo // Scan analysis of the main video stream
+}
+
+// How the main video stream is scanned and timed; idet is set when a sample was classified
+export interface ScanInfo {
+  type?: 'progressive' | 'interlaced' | 'telecined' // undefined = unknown
+  pulldown?: 'soft' | 'hard' // telecined only
+  fieldOrder?: string // ffprobe field_order: progressive, tt, bb, tb, bt
+  frameRate?: string
+  avgFrameRate?: string
+  vfr: boolean
+  idet?: IdetCounts
+}
+
+export interface IdetCounts {
+  tff: number
+  bff: number
+  progressive: number
+  undetermined: number
+  repeatedTop: number
+  repeatedBottom: number
+}
+
+// One stream of a probed file; index is the absolute stream index used by stream selections
+export interface MediaStream {
+  index: number
+  type: 'video' | 'audio' | 'subtitle' | 'attachment' | 'data'
+  codec: string
+  profile?: string
+  width?: number
+  height?: number
+  frameRate?: string
+  channels?: number
+  channelLayout?: string
+  sampleRate?: number
+  subtitleType?: 'text' | 'image'
+  language?: string
+  title?: string
+  bitrate?: number
+  disposition?: string[] // e.g. default, forced, attached_pic
+}
+
+export interface Chapter {
+  start: number // seconds
+  end: number // seconds
+  title?: string
 }
 
 // Task types
-export type TaskStatus = 'pending' | 'paused' | 'running' | 'completed' | 'failed' | 'cancelled'
+export type TaskStatus = 'pending' | 'paused' | 'running' | 'completed' | 'failed' | 'cancelled' | 'skipped'
 
 export interface Task {
   id: string
   sourceFile: string
-  outputFile: string
+  outputFile: string // output file, or the package directory of HLS/DASH outputs
   status: TaskStatus
   progress: number
   speed: number
@@ -39,21 +90,33 @@ export interface Task {
   preset?: string
   config: TranscodeConfig
   actualCommand?: string // Actual FFmpeg command executed (from backend)
+  loudness?: LoudnessStats[] // Loudnorm measurement pass results, one per measured audio track
+  warnings?: string[] // Non-fatal problems (e.g. streams converted or dropped for the container)
+  agentId?: string // Remote agent running the task (empty = local worker pool)
+  skipReason?: SkipReason // Why a skipped task's output was discarded
+  verification?: Verification // Post-encode verification result
+  quality?: QualityScore // Objective quality of the output against the source
+  thumbnails?: string[] // Preview images written next to the output
 }
 
 // Transcode configuration
-export type EncoderType = 'h265' | 'av1'
-export type HardwareAccel = 'cpu' | 'nvidia' | 'intel' | 'amd'
-export type AudioCodec = 'copy' | 'aac' | 'opus' | 'mp3'
+export type EncoderType = 'h265' | 'av1' | 'h264' | 'vp9' | 'prores'
+export type HardwareAccel = 'cpu' | 'nvidia' | 'intel' | 'amd' | 'vaapi'
+export type AudioCodec = 'copy' | 'aac' | 'opus' | 'mp3' | 'flac'
 export type OutputPathType = 'source' | 'custom' | 'default' | 'overwrite'
 export type HdrMode = 'auto' // HDR handling: auto = preserve HDR when source is HDR
+export type Deinterlace = 'auto' | 'deinterlace' | 'ivtc' // auto = decided from the source scan analysis
+export type FrameRateMode = 'auto' | 'cfr' // auto = constant frame rate for VFR sources
+export type RateControl = 'crf' | 'cbr' | 'vbr-2pass' | 'target-size'
+export type CompatibilityPolicy = 'auto' | 'strict' | 'warn'
 
 export interface TranscodeConfig {
-  mode?: 'simple' | 'advanced' // Configuration mode (default: simple)
+  mode?: 'simple' | 'advanced' | 'remux' | 'audio' // Configuration mode (default: simple); remux copies streams into a new container, audio writes an audio file
 
   // Simple mode fields (UI-based configuration)
   encoder: EncoderType
   hardwareAccel: HardwareAccel
+  device?: string // "auto" (default) or a GPUDevice id
   video: {
     crf?: number
     preset?: string
@@ -61,24 +124,90 @@ export interface TranscodeConfig {
     fps?: string | number
     bitrate?: string
     hdrMode?: HdrMode[] // HDR handling modes (multi-select): keep, discard
+    deinterlace?: Deinterlace // Default: keep the source fields
+    frameRateMode?: FrameRateMode // Default: container default
+    rateControl?: RateControl // Default: crf
+    targetSize?: string // Target file size for target-size mode (e.g. "4G", "700M")
   }
   audio: {
     codec: AudioCodec
     bitrate?: string
     channels?: number
+    rules?: AudioRule[] // Per-track rules, first match wins
+    loudnorm?: LoudnormConfig // EBU R128 loudness normalization (two-pass)
+    stereoCompat?: boolean // Add a stereo AAC track next to each surround track
+    stereoCompatBitrate?: string
   }
+  subtitle?: SubtitleConfig
   output: {
     container: string
     suffix: string
     pathType: OutputPathType
     customPath?: string
+    compatibility?: CompatibilityPolicy // Handling of streams the container can't hold (default: auto)
+    kind?: OutputKind // file (default), or an HLS/DASH package directory
+    streaming?: StreamingConfig // HLS/DASH packaging
   }
   extraParams?: string // Extra FFmpeg parameters
+  chunking?: ChunkConfig // Chunked parallel encoding
+  skip?: SkipRules // Source files that don't get a task
+  sizePolicy?: SizePolicy // Outputs larger than the source
+  verify?: VerifyConfig // Post-encode verification
+  quality?: QualityConfig // Objective quality scoring
+  trim?: TrimConfig // Only transcode part of the source
+  streams?: StreamSelection // Source streams kept by remux mode and audio tasks
+  thumbnails?: ThumbnailConfig // Preview images of the output
 
   // Advanced mode field (custom CLI parameters)
   customCommand?: string // Custom FFmpeg CLI parameters (between input and output)
 }
 
+// Subtitle handling
+export type SubtitleMode = 'copy' | 'convert' | 'burn' | 'none'
+export type SubtitleFormat = 'mov_text' | 'srt' | 'webvtt' | 'ass'
+
+export interface SubtitleConfig {
+  mode?: SubtitleMode // Default: copy
+  format?: SubtitleFormat // Convert target (default: chosen by container)
+  burnStream?: number // Subtitle track index to burn in (relative to subtitle tracks)
+  keepOthers?: boolean // Keep the remaining subtitle tracks when burning in
+}
+
+// Loudness normalization
+export type LoudnormPreset = 'ebu' | 'atsc' | 'streaming' | 'podcast' | 'custom'
+
+export interface LoudnormConfig {
+  enabled: boolean
+  preset?: LoudnormPreset
+  i?: number // LUFS (custom preset)
+  tp?: number // dBTP (custom preset)
+  lra?: number // LU (custom preset)
+}
+
+export interface LoudnessStats {
+  stream: number // Source stream index of the measured audio track
+  inputI: number
+  inputTP: number
+  inputLRA: number
+  inputThresh: number
+  targetOffset: number
+  targetI: number
+  targetTP: number
+  targetLRA: number
+}
+
+// Per-track audio rule (match conditions + encoding)
+export interface AudioRule {
+  codecs?: string[]
+  languages?: string[]
+  minChannels?: number
+  maxChannels?: number
+  codec: string
+  bitrate?: string
+  bitratePerChannel?: string // e.g. "96k", multiplied by output channels
+  channels?: number
+}
+
 // Preset types
 export interface Preset {
   id: string
@@ -106,8 +235,212 @@ export interface HardwareInfo {
   intel: boolean
   amd: boolean
   gpuName?: string
+  vaapi?: boolean
+  vaapiDevice?: string
+  vaapiEncoders?: EncoderType[]
+  devices?: GPUDevice[]
+}
+
+export interface GPUDevice {
+  id: string // "nvidia:<index>" or a DRM render node path
+  vendor: 'nvidia' | 'intel' | 'amd' | ''
+  name: string
+}
+
+// Chunked parallel encoding: segments split at keyframes, encoded in parallel and joined
+export interface ChunkConfig {
+  enabled: boolean
+  duration?: number // target segment length in seconds (default: 120)
+  parallel?: number // segments encoded at the same time (default: CPU cores / 8, at least 2)
+  retries?: number // retries of a failed segment (default: 2)
+}
+
+// Output kind: a single file, or an HLS/DASH package directory named after the source
+// (playlists/manifest plus segments, main video and first audio track)
+export type OutputKind = 'file' | 'hls' | 'dash'
+
+export interface StreamingConfig {
+  segmentType?: 'fmp4' | 'ts' // HLS segment format (DASH always uses fmp4)
+  segmentDuration?: number // seconds, default 6
+  renditions?: Rendition[] // multi-rendition ladder (empty = single rendition)
+}
+
+// One video variant of an HLS/DASH ladder
+export interface Rendition {
+  height: number // e.g. 1080, 720, 480
+  videoBitrate?: string // e.g. "5000k": average with bitrate rate control, peak with CRF
+}
+
+// Streams a remux or audio task keeps (all by default); streams without a language tag pass the language filters
+export interface StreamSelection {
+  drop?: number[] // absolute source stream indexes
+  audioLanguages?: string[] // e.g. ['jpn', 'eng']
+  subtitleLanguages?: string[]
+  dropData?: boolean // remove data streams (teletext, timed metadata)
+  audioTracks?: number[] // keep only these audio tracks (absolute source stream indexes)
+}
+
+// Trim: one start/end range (accurate seeking) or several kept segments (trim/concat filter graph;
+// segments need re-encoded audio and drop subtitles, data streams and chapters)
+export interface TrimConfig {
+  start?: number // seconds cut from the beginning
+  end?: number // source time the output stops at (0 = end of the source)
+  segments?: TrimSegment[] // kept segments in source order, instead of start/end
+}
+
+export interface TrimSegment {
+  start: number
+  end?: number // 0 = end of the source (last segment only)
+}
+
+// Skip rules: checked against ffprobe results when tasks are created, any match skips the file
+export interface SkipRules {
+  sameCodec?: boolean // source video codec already equals the target encoder
+  bitrateBelow?: string // source bitrate below this value, e.g. "2M"
+  heightBelow?: number // source video height below this many lines
+  outputSuffix?: boolean // file name already ends with the output suffix
+}
+
+// Size policy: outputs above maxPercent of the source size are discarded (task skipped) or kept with a warning
+export interface SizePolicy {
+  maxPercent: number // 0 = off
+  action?: 'discard' | 'keep' // default: discard
 }
 
+// Post-encode verification: the output is re-probed and compared with the source, failures fail the task
+export interface VerifyConfig {
+  enabled: boolean
+  durationTolerance?: number // allowed duration difference in seconds (default: 1)
+  decode?: boolean // also decode the whole output and fail on decode errors
+}
+
+export interface StreamCounts {
+  video: number
+  audio: number
+  subtitle: number
+}
+
+export interface Verification {
+  passed: boolean
+  sourceDuration: number // seconds
+  outputDuration: number // seconds
+  expected: StreamCounts // streams the output must have at least
+  output: StreamCounts
+  decoded: boolean // the decode check ran
+  problems?: string[]
+}
+
+// Quality scoring on sampled segments: vmaf falls back to ssim/psnr when ffmpeg lacks libvmaf
+export type QualityMetric = 'vmaf' | 'ssim' | 'psnr'
+
+export interface QualityConfig {
+  enabled: boolean
+  metric?: QualityMetric // default: vmaf
+  samples?: number // number of sampled segments (default: 5)
+  sampleDuration?: number // segment length in seconds (default: 10)
+}
+
+export interface QualityScore {
+  metric: QualityMetric
+  mean: number
+  min: number
+  p5: number // 5th percentile of the per-frame scores
+  frames: number
+  samples: number
+}
+
+// GET /api/files/thumbnail kinds: a single frame, a grid over the whole duration, WebVTT seek previews
+export type ThumbnailKind = 'poster' | 'sheet' | 'trickplay'
+
+// Preview images written next to the output after the encode (failures only warn)
+export interface ThumbnailConfig {
+  poster?: boolean // <name>.jpg
+  posterAt?: number // percent of the duration (default: 10)
+  contactSheet?: boolean // <name>_sheet.jpg
+  trickplay?: boolean // <name>_trickplay.vtt with sprite images
+  interval?: number // seconds between trickplay frames (default: 10)
+}
+
+// GET /api/tasks filter, any field limits the list to scored tasks
+export interface TaskQualityFilter {
+  metric?: QualityMetric
+  minScore?: number // on the mean score
+  maxScore?: number
+}
+
+// POST /api/tasks/sample request: a short encode of one source to try a config
+export interface SampleRequest {
+  sourceFile: string
+  preset?: string
+  config?: TranscodeConfig
+  start?: number // offset in the source in seconds
+  duration?: number // sample length in seconds (default: 30)
+  quality?: boolean // score the sample against the source
+}
+
+export interface SampleResult {
+  id: string
+  sourceFile: string
+  start: number
+  duration: number
+  command: string
+  outputSize: number
+  sourceSize: number
+  projectedSize: number // output size extrapolated to the whole source
+  encodeTime: number // seconds
+  speed: number // encoded seconds per wall second
+  quality?: QualityScore
+  qualityError?: string
+  downloadUrl: string // sample files expire after an hour
+  expiresAt: string
+}
+
+export type SkipReason = 'same_codec' | 'low_bitrate' | 'low_resolution' | 'output_suffix' | 'no_gain'
+
+export interface SkippedFile {
+  file: string
+  reason: SkipReason
+  message: string
+}
+
+export interface CreateTasksResponse {
+  tasks: Task[]
+  skipped: SkippedFile[]
+}
+
+// Remote transcoding agent (registered with the server via `server agent`)
+export interface Agent {
+  id: string
+  name: string
+  maxTasks: number
+  hardware?: HardwareInfo
+  writesInPlace: boolean // Outputs are written to the mapped server paths instead of uploaded
+  tasks: string[] // IDs of the tasks running on the agent
+  registeredAt: string
+  lastSeen: string
+}
+
+// Watch folder: new media files are queued with the folder's preset
+export interface WatchFolder {
+  id: string
+  name: string
+  path: string
+  preset: string // Preset ID
+  include: string[] // Glob patterns a file must match (any), empty = all video files
+  exclude: string[] // Glob patterns that skip a file
+  recursive: boolean
+  settleSeconds: number // Seconds a file must stay unchanged before it is queued
+  poll: boolean // Always scan periodically (network mounts are detected automatically)
+  enabled: boolean
+  createdAt: string
+  updatedAt: string
+  mode?: 'inotify' | 'poll' // Set while the watcher runs
+  error?: string
+}
+
+export type WatchFolderInput = Pick<WatchFolder, 'name' | 'path' | 'preset'> &
+  Partial<Pick<WatchFolder, 'include' | 'exclude' | 'recursive' | 'settleSeconds' | 'poll' | 'enabled'>>
+
 // Settings types
 export type FilePermissionMode = 'same_as_source' | 'specify' | 'no_action'
 
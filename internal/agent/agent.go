@@ -0,0 +1,386 @@
+// Package agent runs ffforge as a remote transcoding agent: it registers with a server,
+// pulls tasks, encodes them with its own ffmpeg and reports progress back
+package agent
+
+import (
+	"context"
+	"errors"
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/internal/service"
+	"ffmpeg-web/internal/worker"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// pollInterval is how long an idle agent waits before asking for a task again
+const pollInterval = 5 * time.Second
+
+// reportInterval limits how often running task progress is sent to the server
+const reportInterval = time.Second
+
+// reportRetries is how often the final state of a task is sent before giving up
+const reportRetries = 5
+
+// Config holds the agent configuration
+type Config struct {
+	ServerURL    string        // Base URL of the ffforge server, e.g. http://nas:8080
+	Token        string        // Shared agent token (AGENT_TOKEN of the server)
+	Name         string        // Shown in the server's agent list
+	MaxTasks     int           // Tasks encoded at the same time
+	PathMappings []PathMapping // Server paths reachable on this host
+	Stream       bool          // Always stream media over HTTP, even if the paths are reachable
+	WorkDir      string        // Temporary outputs of streamed tasks
+	FFmpegPath   string
+	FFprobePath  string
+}
+
+// runningTask is a task assigned to this agent
+type runningTask struct {
+	task       *model.Task
+	mediaKey   string
+	sourcePath string // Local path or streaming URL
+	outputPath string // Local path (mapped output or temporary file)
+	uploadDir  string // Temporary directory of an output that is uploaded, "" = written in place
+	lastReport time.Time
+}
+
+// Agent pulls tasks from a server and runs them in its own worker pool
+type Agent struct {
+	config          *Config
+	client          *client
+	ffmpegService   *service.FFmpegService
+	hardwareService *service.HardwareService
+	pool            *worker.Pool
+	mu              sync.Mutex
+	id              string
+	tasks           map[string]*runningTask
+	finished        chan struct{} // Signalled when a task finishes (a slot is free again)
+}
+
+// New creates an agent
+func New(config *Config) *Agent {
+	if config.MaxTasks < 1 {
+		config.MaxTasks = 1
+	}
+	if config.WorkDir == "" {
+		config.WorkDir = filepath.Join(os.TempDir(), "ffforge-agent")
+	}
+
+	hardwareService := service.NewHardwareService()
+	hardwareService.SetFFmpegPath(config.FFmpegPath)
+
+	a := &Agent{
+		config:          config,
+		client:          newClient(config.ServerURL, config.Token),
+		ffmpegService:   service.NewFFmpegService(config.FFmpegPath, config.FFprobePath, config.WorkDir),
+		hardwareService: hardwareService,
+		tasks:           make(map[string]*runningTask),
+		finished:        make(chan struct{}, 1),
+	}
+	a.pool = worker.NewAgentPool(a, a, a.ffmpegService, hardwareService, config.MaxTasks)
+
+	return a
+}
+
+// Run registers with the server and processes tasks until ctx is cancelled
+// Running tasks go back to the server's queue when the agent stops
+func (a *Agent) Run(ctx context.Context) error {
+	if err := os.MkdirAll(a.config.WorkDir, 0755); err != nil {
+		return fmt.Errorf("failed to create work directory: %w", err)
+	}
+
+	if err := a.register(ctx); err != nil {
+		return err
+	}
+	go a.heartbeat(ctx)
+
+	for {
+		if a.runningTasks() < a.config.MaxTasks {
+			// An unknown agent is registered again by the heartbeat
+			claimed, err := a.claim()
+			if err != nil {
+				log.Printf("Failed to get a task: %v", err)
+			}
+			if claimed {
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			a.shutdown()
+			return nil
+		case <-a.finished:
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// register registers the agent, retrying until the server is reachable
+func (a *Agent) register(ctx context.Context) error {
+	registration := &model.AgentRegistration{
+		Name:         a.config.Name,
+		MaxTasks:     a.config.MaxTasks,
+		Hardware:     a.hardwareService.DetectHardware(),
+		Capabilities: a.hardwareService.GetEncoderCapabilities(false),
+		// Outputs are only written in place through a path mapping, streaming agents upload them
+		WritesInPlace: !a.config.Stream && len(a.config.PathMappings) > 0,
+	}
+
+	for {
+		var agent model.Agent
+		_, err := a.client.do("POST", "/api/agents/register", registration, &agent)
+		if err == nil {
+			a.mu.Lock()
+			a.id = agent.ID
+			a.mu.Unlock()
+			log.Printf("Registered with %s as %s (%s)", a.config.ServerURL, agent.Name, agent.ID)
+			return nil
+		}
+
+		log.Printf("Failed to register with %s: %v", a.config.ServerURL, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// reregister registers again after the server lost the agent (restart or missed heartbeats)
+// The server has requeued the agent's tasks, so they are stopped here
+func (a *Agent) reregister(ctx context.Context) {
+	a.mu.Lock()
+	taskIDs := make([]string, 0, len(a.tasks))
+	for id := range a.tasks {
+		taskIDs = append(taskIDs, id)
+	}
+	a.mu.Unlock()
+
+	for _, id := range taskIDs {
+		a.pool.CancelTask(id)
+	}
+	a.register(ctx)
+}
+
+// heartbeat tells the server the agent is alive
+func (a *Agent) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(worker.AgentHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := a.client.do("POST", a.agentPath("/heartbeat"), nil, nil)
+			if errors.Is(err, errNotRegistered) {
+				log.Printf("Server dropped the agent, registering again")
+				a.reregister(ctx)
+			} else if err != nil {
+				log.Printf("Heartbeat failed: %v", err)
+			}
+		}
+	}
+}
+
+// claim asks the server for a task and submits it to the pool
+// Returns false when the server has no task for this agent
+func (a *Agent) claim() (bool, error) {
+	var assigned model.AgentTask
+	ok, err := a.client.do("POST", a.agentPath("/claim"), nil, &assigned)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	task := assigned.Task
+	rt := &runningTask{
+		task:     task,
+		mediaKey: assigned.MediaKey,
+	}
+
+	// Shared storage when the paths are reachable, HTTP otherwise
+	if !a.config.Stream {
+		rt.sourcePath = localSource(a.config.PathMappings, assigned.SourcePath)
+		rt.outputPath = localOutput(a.config.PathMappings, task.OutputFile)
+	}
+	if rt.sourcePath == "" {
+		rt.sourcePath = a.client.sourceURL(a.agentID(), task.ID, rt.mediaKey)
+	}
+	if rt.outputPath == "" {
+		dir, err := os.MkdirTemp(a.config.WorkDir, task.ID+"-")
+		if err != nil {
+			return false, fmt.Errorf("failed to create work directory: %w", err)
+		}
+		rt.uploadDir = dir
+		rt.outputPath = filepath.Join(dir, filepath.Base(task.OutputFile))
+	}
+
+	a.mu.Lock()
+	a.tasks[task.ID] = rt
+	a.mu.Unlock()
+
+	log.Printf("Got task %s: %s", task.ID, task.SourceFile)
+	a.pool.SubmitTask(task.ID)
+	return true, nil
+}
+
+// shutdown hands the running tasks back to the server and stops the pool
+func (a *Agent) shutdown() {
+	if _, err := a.client.do("DELETE", a.agentPath(""), nil, nil); err != nil {
+		log.Printf("Failed to unregister: %v", err)
+	}
+	a.pool.Shutdown()
+}
+
+// ClaimTask returns a task assigned by the server (worker.TaskStore)
+func (a *Agent) ClaimTask(id string) (*model.Task, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	rt, ok := a.tasks[id]
+	if !ok {
+		return nil, nil
+	}
+	return rt.task, nil
+}
+
+// UpdateTask reports a task's state to the server (worker.TaskStore)
+// Running progress is sent at most once per reportInterval
+// A task the server took back is stopped
+func (a *Agent) UpdateTask(task *model.Task) error {
+	a.mu.Lock()
+	rt, ok := a.tasks[task.ID]
+	if !ok {
+		a.mu.Unlock()
+		return nil
+	}
+	finished := task.Status != model.TaskStatusRunning
+	if !finished && time.Since(rt.lastReport) < reportInterval {
+		a.mu.Unlock()
+		return nil
+	}
+	rt.lastReport = time.Now()
+	if finished {
+		delete(a.tasks, task.ID)
+	}
+	a.mu.Unlock()
+
+	if finished {
+		if rt.uploadDir != "" {
+			os.RemoveAll(rt.uploadDir)
+		}
+		select {
+		case a.finished <- struct{}{}:
+		default:
+		}
+	}
+
+	// The final state is retried, the server would wait for the task otherwise
+	_, err := a.client.do("PUT", a.agentPath("/tasks/"+task.ID), task, nil)
+	for attempt := 1; finished && err != nil && attempt < reportRetries && !isGone(err); attempt++ {
+		time.Sleep(pollInterval)
+		_, err = a.client.do("PUT", a.agentPath("/tasks/"+task.ID), task, nil)
+	}
+	if isGone(err) {
+		if !finished {
+			log.Printf("Task %s was cancelled or reassigned by the server, stopping", task.ID)
+			a.pool.CancelTask(task.ID)
+		}
+		return nil
+	}
+	return err
+}
+
+// SourcePath returns the local path or streaming URL of a task's source (worker.MediaPaths)
+func (a *Agent) SourcePath(task *model.Task) (string, error) {
+	rt, err := a.runningTask(task.ID)
+	if err != nil {
+		return "", err
+	}
+	return rt.sourcePath, nil
+}
+
+// OutputPath returns where the agent writes a task's output (worker.MediaPaths)
+func (a *Agent) OutputPath(task *model.Task) (string, error) {
+	rt, err := a.runningTask(task.ID)
+	if err != nil {
+		return "", err
+	}
+	// Only single files are uploaded
+	if rt.uploadDir != "" && service.Packaged(&task.Config) {
+		return "", fmt.Errorf("HLS/DASH packages can't be uploaded to the server, map the output directory with AGENT_PATH_MAP instead")
+	}
+	if rt.uploadDir != "" && service.ThumbnailsEnabled(&task.Config) {
+		task.Config.Thumbnails = nil
+		task.Warnings = append(task.Warnings, "thumbnails are not uploaded to the server and were skipped (map the output directory with AGENT_PATH_MAP to keep them)")
+	}
+	return rt.outputPath, nil
+}
+
+// Finish uploads the output of a streamed task to the server (worker.MediaPaths)
+func (a *Agent) Finish(ctx context.Context, task *model.Task, sourceFile, outputFile string) error {
+	rt, err := a.runningTask(task.ID)
+	if err != nil || rt.uploadDir == "" {
+		return err
+	}
+
+	file, err := os.Open(outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to open output file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read output file: %w", err)
+	}
+
+	log.Printf("Uploading output of task %s (%d bytes)", task.ID, info.Size())
+	path := a.agentPath("/tasks/"+task.ID+"/output") + "?key=" + url.QueryEscape(rt.mediaKey)
+	if err := a.client.upload(path, file, info.Size()); err != nil {
+		return fmt.Errorf("failed to upload output file: %w", err)
+	}
+	return nil
+}
+
+// isGone reports whether the server doesn't run a task on this agent anymore
+func isGone(err error) bool {
+	return errors.Is(err, errTaskGone) || errors.Is(err, errNotRegistered)
+}
+
+// runningTask returns an assigned task
+func (a *Agent) runningTask(id string) (*runningTask, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	rt, ok := a.tasks[id]
+	if !ok {
+		return nil, fmt.Errorf("task %s is not assigned to this agent", id)
+	}
+	return rt, nil
+}
+
+// runningTasks returns the number of assigned tasks
+func (a *Agent) runningTasks() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.tasks)
+}
+
+// agentID returns the ID the server assigned at registration
+func (a *Agent) agentID() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.id
+}
+
+// agentPath returns the API path of this agent
+func (a *Agent) agentPath(suffix string) string {
+	return "/api/agents/" + a.agentID() + suffix
+}
@@ -0,0 +1,121 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// errNotRegistered is returned when the server doesn't know the agent (e.g. after a server restart)
+	errNotRegistered = errors.New("agent not registered with the server")
+	// errTaskGone is returned when the server took a task back (cancelled or reassigned)
+	errTaskGone = errors.New("task no longer assigned to this agent")
+)
+
+// client talks to the agent API of the ffforge server
+type client struct {
+	serverURL string
+	token     string
+	http      *http.Client
+}
+
+// newClient creates a client for the server at serverURL
+func newClient(serverURL, token string) *client {
+	return &client{
+		serverURL: strings.TrimRight(serverURL, "/"),
+		token:     token,
+		http:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// do sends a JSON request and decodes the JSON response into out (if not nil)
+// Returns false for 204 No Content
+func (c *client) do(method, path string, in, out interface{}) (bool, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.serverURL+path, body)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.send(req, c.http)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return false, nil
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return false, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return true, nil
+}
+
+// upload streams a file to the server with a PUT request
+func (c *client) upload(path string, body io.Reader, size int64) error {
+	req, err := http.NewRequest(http.MethodPut, c.serverURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.ContentLength = size
+
+	// No timeout, outputs can take a while to upload
+	resp, err := c.send(req, &http.Client{})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// send adds the agent token and maps error responses to errors
+func (c *client) send(req *http.Request, httpClient *http.Client) (*http.Response, error) {
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach server: %w", err)
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&apiErr)
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, errNotRegistered
+	case http.StatusConflict:
+		return nil, errTaskGone
+	}
+	return nil, fmt.Errorf("server returned %s: %s", resp.Status, apiErr.Error)
+}
+
+// sourceURL returns the URL ffmpeg streams a task's source from
+func (c *client) sourceURL(agentID, taskID, key string) string {
+	return fmt.Sprintf("%s/api/agents/%s/tasks/%s/source?key=%s", c.serverURL, agentID, taskID, url.QueryEscape(key))
+}
@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PathMapping maps a directory on the server to the same directory on the agent host
+// (e.g. an NFS or SMB mount of the server's media share)
+type PathMapping struct {
+	Server string
+	Local  string
+}
+
+// ParsePathMappings parses "server=local" pairs separated by commas
+// Example: "/data=/mnt/nas/data,/output=/mnt/nas/output"
+func ParsePathMappings(value string) ([]PathMapping, error) {
+	mappings := []PathMapping{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		server, local, ok := strings.Cut(pair, "=")
+		if !ok || server == "" || local == "" {
+			return nil, fmt.Errorf("invalid path mapping %q (expected server=local)", pair)
+		}
+		mappings = append(mappings, PathMapping{
+			Server: filepath.Clean(server),
+			Local:  filepath.Clean(local),
+		})
+	}
+	return mappings, nil
+}
+
+// mapPath translates a server path with the longest matching mapping
+// Paths without a mapping are used as-is (agent on the same host or identical mounts)
+func mapPath(mappings []PathMapping, serverPath string) string {
+	serverPath = filepath.Clean(serverPath)
+
+	best := -1
+	for i, mapping := range mappings {
+		rel, err := filepath.Rel(mapping.Server, serverPath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if best == -1 || len(mapping.Server) > len(mappings[best].Server) {
+			best = i
+		}
+	}
+	if best == -1 {
+		return serverPath
+	}
+
+	rel, _ := filepath.Rel(mappings[best].Server, serverPath)
+	return filepath.Join(mappings[best].Local, rel)
+}
+
+// localSource returns the agent's path of a server source file, or "" when it isn't reachable
+func localSource(mappings []PathMapping, serverPath string) string {
+	path := mapPath(mappings, serverPath)
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return ""
+	}
+	return path
+}
+
+// localOutput returns the agent's path of a server output file, or "" when the output
+// directory doesn't exist on the agent host (the output is uploaded then)
+func localOutput(mappings []PathMapping, serverPath string) string {
+	path := mapPath(mappings, serverPath)
+	if info, err := os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+		return ""
+	}
+	return path
+}
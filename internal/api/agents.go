@@ -0,0 +1,148 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/internal/worker"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AgentsHandler handles the API of remote transcoding agents
+type AgentsHandler struct {
+	coordinator *worker.Coordinator
+	token       string
+}
+
+// NewAgentsHandler creates a new agents handler
+// The agent endpoints are disabled when token is empty
+func NewAgentsHandler(coordinator *worker.Coordinator, token string) *AgentsHandler {
+	return &AgentsHandler{
+		coordinator: coordinator,
+		token:       token,
+	}
+}
+
+// RequireToken checks the agent token in the Authorization header ("Bearer <token>")
+func (h *AgentsHandler) RequireToken(c *gin.Context) {
+	if h.token == "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "remote agents are disabled (set AGENT_TOKEN)"})
+		return
+	}
+
+	expected := "Bearer " + h.token
+	if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte(expected)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid agent token"})
+		return
+	}
+
+	c.Next()
+}
+
+// GetAgents handles GET /api/agents
+func (h *AgentsHandler) GetAgents(c *gin.Context) {
+	c.JSON(http.StatusOK, h.coordinator.Agents())
+}
+
+// RegisterAgent handles POST /api/agents/register
+func (h *AgentsHandler) RegisterAgent(c *gin.Context) {
+	var registration model.AgentRegistration
+	if err := c.ShouldBindJSON(&registration); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.coordinator.Register(&registration))
+}
+
+// UnregisterAgent handles DELETE /api/agents/:id
+// The agent's running tasks go back to the queue
+func (h *AgentsHandler) UnregisterAgent(c *gin.Context) {
+	if err := h.coordinator.Unregister(c.Param("id")); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "agent unregistered"})
+}
+
+// Heartbeat handles POST /api/agents/:id/heartbeat
+func (h *AgentsHandler) Heartbeat(c *gin.Context) {
+	if err := h.coordinator.Heartbeat(c.Param("id")); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ClaimTask handles POST /api/agents/:id/claim
+// Responds with 204 No Content when there is no task for the agent
+func (h *AgentsHandler) ClaimTask(c *gin.Context) {
+	task, err := h.coordinator.ClaimTask(c.Param("id"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	if task == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// UpdateTask handles PUT /api/agents/:id/tasks/:taskId
+// Responds with 409 Conflict when the task was cancelled or reassigned
+func (h *AgentsHandler) UpdateTask(c *gin.Context) {
+	var task model.Task
+	if err := c.ShouldBindJSON(&task); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	task.ID = c.Param("taskId")
+
+	if err := h.coordinator.UpdateTask(c.Param("id"), &task); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetTaskSource handles GET /api/agents/:id/tasks/:taskId/source?key=
+// Streams the source file (with range requests, so ffmpeg can seek)
+// Authenticated by the task's media key, ffmpeg can't send the agent token
+func (h *AgentsHandler) GetTaskSource(c *gin.Context) {
+	path, err := h.coordinator.MediaSource(c.Param("id"), c.Param("taskId"), c.Query("key"))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.File(path)
+}
+
+// UploadTaskOutput handles PUT /api/agents/:id/tasks/:taskId/output?key=
+func (h *AgentsHandler) UploadTaskOutput(c *gin.Context) {
+	if err := h.coordinator.WriteOutput(c.Param("id"), c.Param("taskId"), c.Query("key"), c.Request.Body); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "output stored"})
+}
+
+// respondError maps coordinator errors to status codes
+func (h *AgentsHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, worker.ErrAgentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, worker.ErrTaskNotAssigned):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -3,7 +3,10 @@ package api
 import (
 	"ffmpeg-web/internal/model"
 	"ffmpeg-web/internal/service"
+	"ffmpeg-web/pkg/ffprobe"
+	"log"
 	"net/http"
+	"os"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,12 +14,14 @@ import (
 // CommandHandler handles command-related API requests
 type CommandHandler struct {
 	ffmpegService *service.FFmpegService
+	fileService   *service.FileService
 }
 
 // NewCommandHandler creates a new command handler
-func NewCommandHandler(ffmpegService *service.FFmpegService) *CommandHandler {
+func NewCommandHandler(ffmpegService *service.FFmpegService, fileService *service.FileService) *CommandHandler {
 	return &CommandHandler{
 		ffmpegService: ffmpegService,
+		fileService:   fileService,
 	}
 }
 
@@ -28,7 +33,8 @@ type PreviewRequest struct {
 
 // PreviewResponse represents the command preview response
 type PreviewResponse struct {
-	Command string `json:"command"`
+	Command  string   `json:"command"`
+	Warnings []string `json:"warnings,omitempty"` // Container compatibility problems
 }
 
 // PreviewCommand handles POST /api/command/preview
@@ -46,12 +52,37 @@ func (h *CommandHandler) PreviewCommand(c *gin.Context) {
 		sourceFile = "input.mp4"
 	}
 
+	// Probe the source when it exists so the preview matches the real streams
+	var videoInfo *ffprobe.VideoInfo
+	if req.SourceFile != "" {
+		if fullPath, err := h.fileService.GetFullPath(req.SourceFile); err == nil {
+			if _, err := os.Stat(fullPath); err == nil {
+				if videoInfo, err = h.ffmpegService.ProbeFile(c.Request.Context(), fullPath); err != nil {
+					log.Printf("Preview: failed to probe %s: %v", fullPath, err)
+					videoInfo = nil
+				}
+			}
+		}
+	}
+
 	// Generate command preview
-	commandArgs := h.ffmpegService.BuildCommandPreview(sourceFile, &req.Config)
+	commandArgs, err := h.ffmpegService.BuildCommandPreview(sourceFile, &req.Config, videoInfo)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	command := "ffmpeg " + joinArgs(commandArgs)
 
+	// Report streams the output container can't hold
+	compat := service.CheckCompatibility(&req.Config, videoInfo)
+	warnings := compat.Warnings()
+	if err := compat.Err(); err != nil {
+		warnings = append(warnings, err.Error())
+	}
+
 	c.JSON(http.StatusOK, PreviewResponse{
-		Command: command,
+		Command:  command,
+		Warnings: warnings,
 	})
 }
 
@@ -1,23 +1,30 @@
 package api
 
 import (
+	"ffmpeg-web/internal/model"
 	"ffmpeg-web/internal/service"
+	"ffmpeg-web/pkg/ffprobe"
+	"log"
 	"net/http"
+	"os"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
 // FilesHandler handles file-related API requests
 type FilesHandler struct {
-	fileService   *service.FileService
-	ffmpegService *service.FFmpegService
+	fileService      *service.FileService
+	ffmpegService    *service.FFmpegService
+	thumbnailService *service.ThumbnailService
 }
 
 // NewFilesHandler creates a new files handler
-func NewFilesHandler(fileService *service.FileService, ffmpegService *service.FFmpegService) *FilesHandler {
+func NewFilesHandler(fileService *service.FileService, ffmpegService *service.FFmpegService, thumbnailService *service.ThumbnailService) *FilesHandler {
 	return &FilesHandler{
-		fileService:   fileService,
-		ffmpegService: ffmpegService,
+		fileService:      fileService,
+		ffmpegService:    ffmpegService,
+		thumbnailService: thumbnailService,
 	}
 }
 
@@ -31,6 +38,16 @@ func (h *FilesHandler) BrowseDirectory(c *gin.Context) {
 		return
 	}
 
+	// Files probed before (info, tasks) show their media details without running ffprobe
+	for _, file := range files {
+		if file.IsDir {
+			continue
+		}
+		if videoInfo := h.ffmpegService.CachedProbe(file.Path, file.Size, file.ModTime); videoInfo != nil {
+			setMediaSummary(file, videoInfo)
+		}
+	}
+
 	c.JSON(http.StatusOK, files)
 }
 
@@ -46,6 +63,7 @@ func (h *FilesHandler) GetDefaultPath(c *gin.Context) {
 }
 
 // GetFileInfo handles GET /api/files/info
+// idet=true classifies the video scan with the idet filter on a sample instead of the metadata alone
 func (h *FilesHandler) GetFileInfo(c *gin.Context) {
 	path := c.Query("path")
 	if path == "" {
@@ -60,43 +78,222 @@ func (h *FilesHandler) GetFileInfo(c *gin.Context) {
 		return
 	}
 
-	// If it's a video file, get detailed metadata using ffprobe
+	// If it's a media file, get detailed metadata using ffprobe
 	if !info.IsDir {
 		fullPath, err := h.fileService.GetFullPath(path)
 		if err == nil {
-			videoInfo, err := h.ffmpegService.ProbeFile(fullPath)
+			videoInfo, err := h.ffmpegService.ProbeFile(c.Request.Context(), fullPath)
 			if err == nil {
-				// Create copies of values to get pointers
-				duration := videoInfo.Duration
-				width := videoInfo.Width
-				height := videoInfo.Height
-				codec := videoInfo.Codec
-				bitrate := videoInfo.Bitrate
-				frameRate := videoInfo.FrameRate
-				pixelFormat := videoInfo.PixelFormat
-				colorSpace := videoInfo.ColorSpace
-				colorTransfer := videoInfo.ColorTransfer
-				colorPrimaries := videoInfo.ColorPrimaries
-				isHDR := videoInfo.IsHDR
-				profile := videoInfo.Profile
-				level := videoInfo.Level
-
-				info.Duration = &duration
-				info.Width = &width
-				info.Height = &height
-				info.Codec = &codec
-				info.Bitrate = &bitrate
-				info.FrameRate = &frameRate
-				info.PixelFormat = &pixelFormat
-				info.ColorSpace = &colorSpace
-				info.ColorTransfer = &colorTransfer
-				info.ColorPrimaries = &colorPrimaries
-				info.IsHDR = &isHDR
-				info.Profile = &profile
-				info.Level = &level
+				setMediaSummary(info, videoInfo)
+				info.Tags = videoInfo.Tags
+				info.Streams = mediaStreams(videoInfo.Streams)
+				info.Chapters = mediaChapters(videoInfo.Chapters)
+				info.Scan = h.scanInfo(c, fullPath, videoInfo)
 			}
 		}
 	}
 
 	c.JSON(http.StatusOK, info)
 }
+
+// setMediaSummary sets the container and main video stream fields of a file from its probe result
+func setMediaSummary(info *model.FileInfo, videoInfo *ffprobe.VideoInfo) {
+	// Create copies of values to get pointers
+	duration := videoInfo.Duration
+	bitrate := videoInfo.Bitrate
+	format := videoInfo.Format
+
+	info.Duration = &duration
+	info.Bitrate = &bitrate
+	info.Format = &format
+
+	// Video fields describe the main video stream (audio files have none)
+	if !videoInfo.HasVideo() {
+		return
+	}
+	width := videoInfo.Width
+	height := videoInfo.Height
+	codec := videoInfo.Codec
+	frameRate := videoInfo.FrameRate
+	pixelFormat := videoInfo.PixelFormat
+	colorSpace := videoInfo.ColorSpace
+	colorTransfer := videoInfo.ColorTransfer
+	colorPrimaries := videoInfo.ColorPrimaries
+	isHDR := videoInfo.IsHDR
+	profile := videoInfo.Profile
+	level := videoInfo.Level
+
+	info.Width = &width
+	info.Height = &height
+	info.Codec = &codec
+	info.FrameRate = &frameRate
+	info.PixelFormat = &pixelFormat
+	info.ColorSpace = &colorSpace
+	info.ColorTransfer = &colorTransfer
+	info.ColorPrimaries = &colorPrimaries
+	info.IsHDR = &isHDR
+	info.Profile = &profile
+	info.Level = &level
+}
+
+// scanInfo returns the scan analysis of a file for the API, sampled with idet when requested
+func (h *FilesHandler) scanInfo(c *gin.Context, fullPath string, videoInfo *ffprobe.VideoInfo) *model.ScanInfo {
+	scan := ffprobe.AnalyzeScan(videoInfo)
+	if scan != nil && c.Query("idet") == "true" {
+		if detected, err := h.ffmpegService.DetectScan(c.Request.Context(), fullPath, videoInfo); err != nil {
+			log.Printf("Warning: %v (%s)", err, fullPath)
+		} else {
+			scan = detected
+		}
+	}
+	if scan == nil {
+		return nil
+	}
+
+	result := &model.ScanInfo{
+		Type:         scan.Type,
+		Pulldown:     scan.Pulldown,
+		FieldOrder:   scan.FieldOrder,
+		FrameRate:    scan.FrameRate,
+		AvgFrameRate: scan.AvgFrameRate,
+		VFR:          scan.VFR,
+	}
+	if scan.Idet != nil {
+		result.Idet = &model.IdetCounts{
+			TFF:            scan.Idet.TFF,
+			BFF:            scan.Idet.BFF,
+			Progressive:    scan.Idet.Progressive,
+			Undetermined:   scan.Idet.Undetermined,
+			RepeatedTop:    scan.Idet.RepeatedTop,
+			RepeatedBottom: scan.Idet.RepeatedBottom,
+		}
+	}
+	return result
+}
+
+// mediaStreams converts probed streams into their API model
+func mediaStreams(streams []ffprobe.StreamInfo) []model.MediaStream {
+	result := make([]model.MediaStream, 0, len(streams))
+	for _, stream := range streams {
+		result = append(result, model.MediaStream{
+			Index:         stream.Index,
+			Type:          stream.Type,
+			Codec:         stream.Codec,
+			Profile:       stream.Profile,
+			Width:         stream.Width,
+			Height:        stream.Height,
+			FrameRate:     stream.FrameRate,
+			Channels:      stream.Channels,
+			ChannelLayout: stream.ChannelLayout,
+			SampleRate:    stream.SampleRate,
+			SubtitleType:  stream.SubtitleType,
+			Language:      stream.Language,
+			Title:         stream.Title,
+			Bitrate:       stream.Bitrate,
+			Disposition:   stream.Disposition,
+		})
+	}
+	return result
+}
+
+// mediaChapters converts probed chapters into their API model
+func mediaChapters(chapters []ffprobe.Chapter) []model.Chapter {
+	result := make([]model.Chapter, 0, len(chapters))
+	for _, chapter := range chapters {
+		result = append(result, model.Chapter{
+			Start: chapter.Start,
+			End:   chapter.End,
+			Title: chapter.Title,
+		})
+	}
+	return result
+}
+
+// ThumbnailQuery holds the options of a thumbnail request (unset = defaults)
+type ThumbnailQuery struct {
+	Kind     string  `form:"kind"`     // poster (default), sheet or trickplay
+	At       float64 `form:"at"`       // Poster position in percent of the duration
+	Width    int     `form:"width"`    // Poster width, tile width of sheets and sprites
+	Columns  int     `form:"columns"`  // Sheet/sprite grid
+	Rows     int     `form:"rows"`     // Sheet/sprite grid
+	Interval float64 `form:"interval"` // Seconds between trickplay frames
+}
+
+// GetThumbnail handles GET /api/files/thumbnail
+// kind is poster (at = percent of the duration), sheet or trickplay; width, columns, rows and
+// interval override the defaults. Trickplay returns the WebVTT index, its cues point back here
+// with sprite=<name> for the sprite images.
+func (h *FilesHandler) GetThumbnail(c *gin.Context) {
+	path := c.Query("path")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path parameter is required"})
+		return
+	}
+
+	var query ThumbnailQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid thumbnail options: " + err.Error()})
+		return
+	}
+	opts := service.ThumbnailOptions{
+		Kind:     query.Kind,
+		At:       query.At,
+		Width:    query.Width,
+		Columns:  query.Columns,
+		Rows:     query.Rows,
+		Interval: query.Interval,
+	}
+	if opts.Kind == "" {
+		opts.Kind = service.ThumbnailPoster
+	}
+	if err := opts.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fullPath, err := h.fileService.GetFullPath(path)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if info, err := os.Stat(fullPath); err != nil || info.IsDir() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	if sprite := c.Query("sprite"); sprite != "" {
+		file, err := h.thumbnailService.Sprite(c.Request.Context(), fullPath, opts, sprite)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.File(file)
+		return
+	}
+
+	file, err := h.thumbnailService.Thumbnail(c.Request.Context(), fullPath, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if opts.Kind != service.ThumbnailTrickplay {
+		c.File(file)
+		return
+	}
+
+	// Sprites are served by this endpoint too, the cues get their URLs
+	vtt, err := os.ReadFile(file)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	spriteQuery := c.Request.URL.Query()
+	lines := strings.Split(string(vtt), "\n")
+	for i, line := range lines {
+		if name, fragment, ok := strings.Cut(line, "#xywh="); ok {
+			spriteQuery.Set("sprite", name)
+			lines[i] = "thumbnail?" + spriteQuery.Encode() + "#xywh=" + fragment
+		}
+	}
+	c.Data(http.StatusOK, "text/vtt; charset=utf-8", []byte(strings.Join(lines, "\n")))
+}
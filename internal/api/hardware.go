@@ -26,8 +26,12 @@ func (h *HardwareHandler) GetHardwareInfo(c *gin.Context) {
 }
 
 // GetGPUCapabilities handles GET /api/hardware/capabilities
+// Query parameter test=true runs a short test encode per hardware encoder
 func (h *HardwareHandler) GetGPUCapabilities(c *gin.Context) {
-	caps := h.hardwareService.GetGPUCapabilities()
+	caps := *h.hardwareService.GetGPUCapabilities()
+	if c.Query("test") == "true" {
+		caps.Encoders = h.hardwareService.GetEncoderCapabilities(true)
+	}
 	c.JSON(http.StatusOK, caps)
 }
 
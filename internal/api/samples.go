@@ -0,0 +1,103 @@
+package api
+
+import (
+	"ffmpeg-web/internal/database"
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/internal/service"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SamplesHandler handles sample encode API requests
+type SamplesHandler struct {
+	db              *database.DB
+	sampleService   *service.SampleService
+	fileService     *service.FileService
+	hardwareService *service.HardwareService
+}
+
+// NewSamplesHandler creates a new samples handler
+func NewSamplesHandler(db *database.DB, sampleService *service.SampleService, fileService *service.FileService, hardwareService *service.HardwareService) *SamplesHandler {
+	return &SamplesHandler{
+		db:              db,
+		sampleService:   sampleService,
+		fileService:     fileService,
+		hardwareService: hardwareService,
+	}
+}
+
+// SampleRequest represents a request to encode a sample of a source file
+type SampleRequest struct {
+	SourceFile string                 `json:"sourceFile" binding:"required"`
+	Preset     string                 `json:"preset,omitempty"`
+	Config     *model.TranscodeConfig `json:"config,omitempty"`
+	Start      float64                `json:"start"`    // Offset in the source (seconds)
+	Duration   float64                `json:"duration"` // Sample length (seconds, default 30)
+	Quality    bool                   `json:"quality"`  // Score the sample against the source
+}
+
+// CreateSample handles POST /api/tasks/sample
+// Encodes a short range of the source with the real encode command and reports
+// the projected output size, the encode speed and optionally a quality score
+func (h *SamplesHandler) CreateSample(c *gin.Context) {
+	var req SampleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get config from preset or use provided config
+	var config model.TranscodeConfig
+	if req.Preset != "" {
+		preset, err := h.db.GetPreset(req.Preset)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "preset not found"})
+			return
+		}
+		config = preset.Config
+	} else if req.Config != nil {
+		config = *req.Config
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "either preset or config must be provided"})
+		return
+	}
+
+	if err := service.ValidateTranscodeConfig(&config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check the ffmpeg build supports the encoder, hwaccel and filters
+	if err := h.hardwareService.ValidateEncoderSupport(&config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sourceFile, err := h.fileService.GetFullPath(req.SourceFile)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.sampleService.Encode(c.Request.Context(), sourceFile, &config, req.Start, req.Duration, req.Quality)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	result.DownloadURL = "/api/tasks/sample/" + result.ID + "/file"
+
+	c.JSON(http.StatusOK, result)
+}
+
+// DownloadSample handles GET /api/tasks/sample/:id/file
+func (h *SamplesHandler) DownloadSample(c *gin.Context) {
+	path, err := h.sampleService.File(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.FileAttachment(path, "sample"+filepath.Ext(path))
+}
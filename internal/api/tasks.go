@@ -4,7 +4,9 @@ import (
 	"ffmpeg-web/internal/database"
 	"ffmpeg-web/internal/model"
 	"ffmpeg-web/internal/service"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -19,20 +21,30 @@ type WorkerPool interface {
 
 // TasksHandler handles task-related API requests
 type TasksHandler struct {
-	db          *database.DB
-	pool        WorkerPool
-	fileService *service.FileService
+	db              *database.DB
+	pool            WorkerPool
+	ffmpegService   *service.FFmpegService
+	fileService     *service.FileService
+	hardwareService *service.HardwareService
 }
 
 // NewTasksHandler creates a new tasks handler
-func NewTasksHandler(db *database.DB, pool WorkerPool, fileService *service.FileService) *TasksHandler {
+func NewTasksHandler(db *database.DB, pool WorkerPool, ffmpegService *service.FFmpegService, fileService *service.FileService, hardwareService *service.HardwareService) *TasksHandler {
 	return &TasksHandler{
-		db:          db,
-		pool:        pool,
-		fileService: fileService,
+		db:              db,
+		pool:            pool,
+		ffmpegService:   ffmpegService,
+		fileService:     fileService,
+		hardwareService: hardwareService,
 	}
 }
 
+// CreateTaskResponse lists the created tasks and the source files skipped by the skip rules
+type CreateTaskResponse struct {
+	Tasks   []*model.Task        `json:"tasks"`
+	Skipped []*model.SkippedFile `json:"skipped"`
+}
+
 // CreateTaskRequest represents a request to create a new task
 type CreateTaskRequest struct {
 	SourceFiles []string               `json:"sourceFiles" binding:"required"`
@@ -70,32 +82,39 @@ func (h *TasksHandler) CreateTask(c *gin.Context) {
 		return
 	}
 
-	// Expand directories to video files
-	// This allows selecting folders and having all videos within transcoded
-	var allSourceFiles []string
-	for _, path := range req.SourceFiles {
-		if h.fileService.IsDirectory(path) {
-			// Scan directory for video files
-			videoFiles, err := h.fileService.ScanVideoFilesInDirectory(path)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "failed to scan directory: " + path})
-				return
-			}
-			allSourceFiles = append(allSourceFiles, videoFiles...)
-		} else {
-			allSourceFiles = append(allSourceFiles, path)
-		}
+	if err := service.ValidateTranscodeConfig(&config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check the ffmpeg build supports the encoder, hwaccel and filters
+	if err := h.hardwareService.ValidateEncoderSupport(&config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Expand directories to video files (and audio files for audio tasks)
+	allSourceFiles, err := h.fileService.ExpandSourceFiles(req.SourceFiles, service.AudioMode(&config))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	// Check if any files were found
 	if len(allSourceFiles) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "no video files found in selected paths"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no media files found in selected paths"})
 		return
 	}
 
-	// Create tasks for each source file
+	// Create tasks for each source file that doesn't match a skip rule
 	tasks := make([]*model.Task, 0, len(allSourceFiles))
+	skipped := []*model.SkippedFile{}
 	for _, sourceFile := range allSourceFiles {
+		if skip := h.ffmpegService.CheckSkipRules(c.Request.Context(), sourceFile, &config); skip != nil {
+			skipped = append(skipped, skip)
+			continue
+		}
+
 		task := &model.Task{
 			ID:         uuid.New().String(),
 			SourceFile: sourceFile,
@@ -120,20 +139,87 @@ func (h *TasksHandler) CreateTask(c *gin.Context) {
 		tasks = append(tasks, task)
 	}
 
-	c.JSON(http.StatusOK, tasks)
+	c.JSON(http.StatusOK, CreateTaskResponse{Tasks: tasks, Skipped: skipped})
 }
 
 // GetAllTasks handles GET /api/tasks
+// Optional quality filters: metric (vmaf, ssim, psnr), minScore and maxScore (on the mean score);
+// any of them limits the result to scored tasks
 func (h *TasksHandler) GetAllTasks(c *gin.Context) {
+	filter, err := parseQualityFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	tasks, err := h.db.GetAllTasks()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve tasks"})
 		return
 	}
 
+	if filter != nil {
+		matching := []*model.Task{}
+		for _, task := range tasks {
+			if filter.matches(task) {
+				matching = append(matching, task)
+			}
+		}
+		tasks = matching
+	}
+
 	c.JSON(http.StatusOK, tasks)
 }
 
+// qualityFilter limits a task list to scored tasks
+type qualityFilter struct {
+	metric   string
+	minScore *float64
+	maxScore *float64
+}
+
+// parseQualityFilter reads the quality filter query parameters (nil when none is set)
+func parseQualityFilter(c *gin.Context) (*qualityFilter, error) {
+	filter := &qualityFilter{metric: c.Query("metric")}
+	set := filter.metric != ""
+
+	for _, bound := range []struct {
+		name  string
+		value **float64
+	}{
+		{"minScore", &filter.minScore},
+		{"maxScore", &filter.maxScore},
+	} {
+		raw := c.Query(bound.name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", bound.name, raw)
+		}
+		*bound.value = &value
+		set = true
+	}
+
+	if !set {
+		return nil, nil
+	}
+	return filter, nil
+}
+
+// matches reports whether a task has a quality score within the filter
+func (f *qualityFilter) matches(task *model.Task) bool {
+	quality := task.Quality
+	if quality == nil || (f.metric != "" && quality.Metric != f.metric) {
+		return false
+	}
+	if f.minScore != nil && quality.Mean < *f.minScore {
+		return false
+	}
+	return f.maxScore == nil || quality.Mean <= *f.maxScore
+}
+
 // GetTask handles GET /api/tasks/:id
 func (h *TasksHandler) GetTask(c *gin.Context) {
 	id := c.Param("id")
@@ -275,11 +361,12 @@ func (h *TasksHandler) RetryTask(c *gin.Context) {
 		return
 	}
 
-	// Can only retry completed, failed, or cancelled tasks
+	// Can only retry completed, failed, cancelled, or skipped tasks
 	if originalTask.Status != model.TaskStatusCompleted &&
 		originalTask.Status != model.TaskStatusFailed &&
-		originalTask.Status != model.TaskStatusCancelled {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "can only retry completed, failed, or cancelled tasks"})
+		originalTask.Status != model.TaskStatusCancelled &&
+		originalTask.Status != model.TaskStatusSkipped {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "can only retry completed, failed, cancelled, or skipped tasks"})
 		return
 	}
 
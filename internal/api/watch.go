@@ -0,0 +1,187 @@
+package api
+
+import (
+	"ffmpeg-web/internal/database"
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/internal/service"
+	"ffmpeg-web/internal/watch"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// WatchFoldersHandler handles watch folder API requests
+type WatchFoldersHandler struct {
+	db          *database.DB
+	manager     *watch.Manager
+	fileService *service.FileService
+}
+
+// NewWatchFoldersHandler creates a new watch folders handler
+func NewWatchFoldersHandler(db *database.DB, manager *watch.Manager, fileService *service.FileService) *WatchFoldersHandler {
+	return &WatchFoldersHandler{
+		db:          db,
+		manager:     manager,
+		fileService: fileService,
+	}
+}
+
+// WatchFolderRequest represents a request to create or update a watch folder
+// Omitted optional fields default to recursive, enabled and a 30 second settle delay
+type WatchFolderRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Path          string   `json:"path" binding:"required"`
+	Preset        string   `json:"preset" binding:"required"`
+	Include       []string `json:"include"`
+	Exclude       []string `json:"exclude"`
+	Recursive     *bool    `json:"recursive"`
+	SettleSeconds *int     `json:"settleSeconds"`
+	Poll          bool     `json:"poll"`
+	Enabled       *bool    `json:"enabled"`
+}
+
+// GetAllWatchFolders handles GET /api/watch-folders
+func (h *WatchFoldersHandler) GetAllWatchFolders(c *gin.Context) {
+	folders, err := h.db.GetAllWatchFolders()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get watch folders"})
+		return
+	}
+
+	statuses := make([]*model.WatchFolderStatus, 0, len(folders))
+	for _, folder := range folders {
+		statuses = append(statuses, h.manager.Status(folder))
+	}
+
+	c.JSON(http.StatusOK, statuses)
+}
+
+// GetWatchFolder handles GET /api/watch-folders/:id
+func (h *WatchFoldersHandler) GetWatchFolder(c *gin.Context) {
+	folder, err := h.db.GetWatchFolder(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "watch folder not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.manager.Status(folder))
+}
+
+// CreateWatchFolder handles POST /api/watch-folders
+func (h *WatchFoldersHandler) CreateWatchFolder(c *gin.Context) {
+	var req WatchFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	folder := &model.WatchFolder{
+		ID:        uuid.New().String(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := h.applyRequest(folder, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.CreateWatchFolder(folder); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create watch folder"})
+		return
+	}
+
+	h.manager.Reload(folder)
+	c.JSON(http.StatusOK, h.manager.Status(folder))
+}
+
+// UpdateWatchFolder handles PUT /api/watch-folders/:id
+func (h *WatchFoldersHandler) UpdateWatchFolder(c *gin.Context) {
+	folder, err := h.db.GetWatchFolder(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "watch folder not found"})
+		return
+	}
+
+	var req WatchFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.applyRequest(folder, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	folder.UpdatedAt = time.Now()
+
+	if err := h.db.UpdateWatchFolder(folder); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update watch folder"})
+		return
+	}
+
+	h.manager.Reload(folder)
+	c.JSON(http.StatusOK, h.manager.Status(folder))
+}
+
+// DeleteWatchFolder handles DELETE /api/watch-folders/:id
+func (h *WatchFoldersHandler) DeleteWatchFolder(c *gin.Context) {
+	id := c.Param("id")
+
+	if err := h.db.DeleteWatchFolder(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.manager.Remove(id)
+	c.JSON(http.StatusOK, gin.H{"message": "watch folder deleted"})
+}
+
+// applyRequest validates a request and copies it into a watch folder
+func (h *WatchFoldersHandler) applyRequest(folder *model.WatchFolder, req *WatchFolderRequest) error {
+	path, err := h.fileService.GetFullPath(req.Path)
+	if err != nil {
+		return err
+	}
+	if !h.fileService.IsDirectory(path) {
+		return fmt.Errorf("watch folder path is not a directory: %s", req.Path)
+	}
+
+	preset, err := h.db.GetPreset(req.Preset)
+	if err != nil {
+		return fmt.Errorf("preset not found")
+	}
+	if err := watch.CheckPreset(&preset.Config); err != nil {
+		return err
+	}
+
+	for _, pattern := range append(append([]string{}, req.Include...), req.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid glob pattern %q", pattern)
+		}
+	}
+
+	settle := watch.DefaultSettleSeconds
+	if req.SettleSeconds != nil {
+		if *req.SettleSeconds < 0 {
+			return fmt.Errorf("settle delay can't be negative")
+		}
+		settle = *req.SettleSeconds
+	}
+
+	folder.Name = req.Name
+	folder.Path = path
+	folder.Preset = req.Preset
+	folder.Include = append([]string{}, req.Include...)
+	folder.Exclude = append([]string{}, req.Exclude...)
+	folder.Recursive = req.Recursive == nil || *req.Recursive
+	folder.SettleSeconds = settle
+	folder.Poll = req.Poll
+	folder.Enabled = req.Enabled == nil || *req.Enabled
+
+	return nil
+}
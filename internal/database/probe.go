@@ -0,0 +1,60 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"ffmpeg-web/pkg/ffprobe"
+	"fmt"
+	"time"
+)
+
+// Probe cache operations
+// A row holds the latest probe result of a path; size and mod_time (Unix nanoseconds) identify the file version.
+
+// LoadProbe returns the stored probe result of a file version (nil when there is none)
+func (db *DB) LoadProbe(path string, size, modTime int64) (*ffprobe.VideoInfo, error) {
+	query := `
+		SELECT info FROM probe_cache
+		WHERE path = ? AND size = ? AND mod_time = ? AND version = ?
+	`
+
+	var infoJSON string
+	err := db.conn.QueryRow(query, path, size, modTime, ffprobe.InfoVersion).Scan(&infoJSON)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var info ffprobe.VideoInfo
+	if err := json.Unmarshal([]byte(infoJSON), &info); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal probe result: %w", err)
+	}
+	return &info, nil
+}
+
+// SaveProbe stores the probe result of a file version, replacing the one of the previous version
+func (db *DB) SaveProbe(path string, size, modTime int64, info *ffprobe.VideoInfo) error {
+	infoJSON, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("failed to marshal probe result: %w", err)
+	}
+
+	query := `
+		INSERT OR REPLACE INTO probe_cache (path, size, mod_time, version, info, probed_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	_, err = db.conn.Exec(query, path, size, modTime, ffprobe.InfoVersion, string(infoJSON), time.Now())
+	return err
+}
+
+// PruneProbes removes the probe results stored before a time and those of older versions
+func (db *DB) PruneProbes(before time.Time) (int64, error) {
+	result, err := db.conn.Exec(`DELETE FROM probe_cache WHERE probed_at < ? OR version != ?`, before, ffprobe.InfoVersion)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"ffmpeg-web/internal/model"
 	"fmt"
+	"reflect"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -59,7 +61,14 @@ func (db *DB) initialize() error {
 		started_at DATETIME,
 		completed_at DATETIME,
 		preset TEXT,
-		config TEXT NOT NULL
+		config TEXT NOT NULL,
+		loudness TEXT,
+		warnings TEXT,
+		agent_id TEXT NOT NULL DEFAULT '',
+		skip_reason TEXT NOT NULL DEFAULT '',
+		verification TEXT,
+		quality TEXT,
+		thumbnails TEXT
 	);
 
 	CREATE TABLE IF NOT EXISTS presets (
@@ -85,6 +94,39 @@ func (db *DB) initialize() error {
 		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
 	);
 
+	CREATE TABLE IF NOT EXISTS watch_folders (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		path TEXT NOT NULL,
+		preset TEXT NOT NULL,
+		include TEXT,
+		exclude TEXT,
+		recursive INTEGER DEFAULT 1,
+		settle_seconds INTEGER DEFAULT 30,
+		poll INTEGER DEFAULT 0,
+		enabled INTEGER DEFAULT 1,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS watch_files (
+		watch_id TEXT NOT NULL,
+		path TEXT NOT NULL,
+		size INTEGER DEFAULT 0,
+		task_id TEXT,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (watch_id, path)
+	);
+
+	CREATE TABLE IF NOT EXISTS probe_cache (
+		path TEXT PRIMARY KEY,
+		size INTEGER NOT NULL,
+		mod_time INTEGER NOT NULL,
+		version INTEGER NOT NULL,
+		info TEXT NOT NULL,
+		probed_at DATETIME NOT NULL
+	);
+
 	CREATE INDEX IF NOT EXISTS idx_tasks_status ON tasks(status);
 	CREATE INDEX IF NOT EXISTS idx_tasks_created_at ON tasks(created_at DESC);
 	`
@@ -227,58 +269,88 @@ func (db *DB) migrate() error {
 		}
 	}
 
+	// Columns added to the tasks table later on
+	taskColumnMigrations := []struct {
+		name       string
+		definition string
+	}{
+		{"loudness", "TEXT"},
+		{"warnings", "TEXT"},
+		{"agent_id", "TEXT NOT NULL DEFAULT ''"},
+		{"skip_reason", "TEXT NOT NULL DEFAULT ''"},
+		{"verification", "TEXT"},
+		{"quality", "TEXT"},
+		{"thumbnails", "TEXT"},
+	}
+	for _, column := range taskColumnMigrations {
+		if err := db.addColumnIfMissing("tasks", column.name, column.definition); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// Task operations
-
-// CreateTask creates a new task in the database
-func (db *DB) CreateTask(task *model.Task) error {
-	configJSON, err := json.Marshal(task.Config)
+// addColumnIfMissing adds a column to a table unless it already exists
+func (db *DB) addColumnIfMissing(table, column, definition string) error {
+	rows, err := db.conn.Query("PRAGMA table_info(" + table + ")")
 	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
+		return err
 	}
+	defer rows.Close()
 
-	query := `
-		INSERT INTO tasks (id, source_file, output_file, status, progress, speed, eta, 
-			error, source_file_size, output_file_size, created_at, started_at, completed_at, preset, config)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
+	for rows.Next() {
+		var cid int
+		var name string
+		var dataType string
+		var notNull int
+		var defaultValue sql.NullString
+		var pk int
 
-	_, err = db.conn.Exec(query,
-		task.ID, task.SourceFile, task.OutputFile, task.Status,
-		task.Progress, task.Speed, task.ETA, task.Error,
-		task.SourceFileSize, task.OutputFileSize,
-		task.CreatedAt, task.StartedAt, task.CompletedAt,
-		task.Preset, string(configJSON),
-	)
+		if err := rows.Scan(&cid, &name, &dataType, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
 
-	return err
+		if name == column {
+			return nil
+		}
+	}
+	rows.Close() // Close before altering the table
+
+	if _, err := db.conn.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add %s column: %w", column, err)
+	}
+
+	return nil
 }
 
-// GetTask retrieves a task by ID
-func (db *DB) GetTask(id string) (*model.Task, error) {
-	query := `
-		SELECT id, source_file, output_file, status, progress, speed, eta,
-			error, source_file_size, output_file_size, created_at, started_at, completed_at, preset, config
-		FROM tasks WHERE id = ?
-	`
+// Task operations
+
+// taskColumns is the column list shared by all task queries (order matches scanTask)
+const taskColumns = `id, source_file, output_file, status, progress, speed, eta,
+	error, source_file_size, output_file_size, created_at, started_at, completed_at, preset, config,
+	loudness, warnings, agent_id, skip_reason, verification, quality, thumbnails`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
+// scanTask scans a single task row selected with taskColumns
+func scanTask(row rowScanner) (*model.Task, error) {
 	task := &model.Task{}
 	var configJSON string
 	var startedAt, completedAt sql.NullTime
+	var loudnessJSON, warningsJSON, verificationJSON, qualityJSON, thumbnailsJSON sql.NullString
 
-	err := db.conn.QueryRow(query, id).Scan(
+	err := row.Scan(
 		&task.ID, &task.SourceFile, &task.OutputFile, &task.Status,
 		&task.Progress, &task.Speed, &task.ETA, &task.Error,
 		&task.SourceFileSize, &task.OutputFileSize,
 		&task.CreatedAt, &startedAt, &completedAt,
 		&task.Preset, &configJSON,
+		&loudnessJSON, &warningsJSON, &task.AgentID, &task.SkipReason, &verificationJSON, &qualityJSON, &thumbnailsJSON,
 	)
-
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("task not found")
-	}
 	if err != nil {
 		return nil, err
 	}
@@ -294,17 +366,123 @@ func (db *DB) GetTask(id string) (*model.Task, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	// Tasks stored before per-track measurements hold a single measurement
+	if strings.HasPrefix(loudnessJSON.String, "{") {
+		loudnessJSON.String = "[" + loudnessJSON.String + "]"
+	}
+	if err := unmarshalOptionalJSON(loudnessJSON, &task.Loudness); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal loudness: %w", err)
+	}
+
+	if err := unmarshalOptionalJSON(warningsJSON, &task.Warnings); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal warnings: %w", err)
+	}
+
+	if err := unmarshalOptionalJSON(verificationJSON, &task.Verification); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal verification: %w", err)
+	}
+
+	if err := unmarshalOptionalJSON(qualityJSON, &task.Quality); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal quality: %w", err)
+	}
+
+	if err := unmarshalOptionalJSON(thumbnailsJSON, &task.Thumbnails); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal thumbnails: %w", err)
+	}
+
 	return task, nil
 }
 
-// GetAllTasks retrieves all tasks
-func (db *DB) GetAllTasks() ([]*model.Task, error) {
+// marshalOptionalJSON marshals a pointer value to a JSON string, or NULL when it is nil
+func marshalOptionalJSON(v interface{}) (sql.NullString, error) {
+	if v == nil || reflect.ValueOf(v).IsNil() {
+		return sql.NullString{}, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// unmarshalOptionalJSON unmarshals a nullable JSON column into dst (left untouched when NULL)
+func unmarshalOptionalJSON(data sql.NullString, dst interface{}) error {
+	if !data.Valid || data.String == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(data.String), dst)
+}
+
+// CreateTask creates a new task in the database
+func (db *DB) CreateTask(task *model.Task) error {
+	configJSON, err := json.Marshal(task.Config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	loudnessJSON, err := marshalOptionalJSON(task.Loudness)
+	if err != nil {
+		return fmt.Errorf("failed to marshal loudness: %w", err)
+	}
+
+	warningsJSON, err := marshalOptionalJSON(task.Warnings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal warnings: %w", err)
+	}
+
+	verificationJSON, err := marshalOptionalJSON(task.Verification)
+	if err != nil {
+		return fmt.Errorf("failed to marshal verification: %w", err)
+	}
+
+	qualityJSON, err := marshalOptionalJSON(task.Quality)
+	if err != nil {
+		return fmt.Errorf("failed to marshal quality: %w", err)
+	}
+
+	thumbnailsJSON, err := marshalOptionalJSON(task.Thumbnails)
+	if err != nil {
+		return fmt.Errorf("failed to marshal thumbnails: %w", err)
+	}
+
 	query := `
-		SELECT id, source_file, output_file, status, progress, speed, eta,
-			error, source_file_size, output_file_size, created_at, started_at, completed_at, preset, config
-		FROM tasks ORDER BY created_at DESC
+		INSERT INTO tasks (` + taskColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
+	_, err = db.conn.Exec(query,
+		task.ID, task.SourceFile, task.OutputFile, task.Status,
+		task.Progress, task.Speed, task.ETA, task.Error,
+		task.SourceFileSize, task.OutputFileSize,
+		task.CreatedAt, task.StartedAt, task.CompletedAt,
+		task.Preset, string(configJSON),
+		loudnessJSON, warningsJSON, task.AgentID, task.SkipReason, verificationJSON, qualityJSON, thumbnailsJSON,
+	)
+
+	return err
+}
+
+// GetTask retrieves a task by ID
+func (db *DB) GetTask(id string) (*model.Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE id = ?`
+
+	task, err := scanTask(db.conn.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("task not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+// GetAllTasks retrieves all tasks
+func (db *DB) GetAllTasks() ([]*model.Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks ORDER BY created_at DESC`
+
 	rows, err := db.conn.Query(query)
 	if err != nil {
 		return nil, err
@@ -313,32 +491,11 @@ func (db *DB) GetAllTasks() ([]*model.Task, error) {
 
 	tasks := []*model.Task{}
 	for rows.Next() {
-		task := &model.Task{}
-		var configJSON string
-		var startedAt, completedAt sql.NullTime
-
-		err := rows.Scan(
-			&task.ID, &task.SourceFile, &task.OutputFile, &task.Status,
-			&task.Progress, &task.Speed, &task.ETA, &task.Error,
-			&task.SourceFileSize, &task.OutputFileSize,
-			&task.CreatedAt, &startedAt, &completedAt,
-			&task.Preset, &configJSON,
-		)
+		task, err := scanTask(rows)
 		if err != nil {
 			return nil, err
 		}
 
-		if startedAt.Valid {
-			task.StartedAt = &startedAt.Time
-		}
-		if completedAt.Valid {
-			task.CompletedAt = &completedAt.Time
-		}
-
-		if err := json.Unmarshal([]byte(configJSON), &task.Config); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal config: %w", err)
-		}
-
 		tasks = append(tasks, task)
 	}
 
@@ -352,11 +509,38 @@ func (db *DB) UpdateTask(task *model.Task) error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
+	loudnessJSON, err := marshalOptionalJSON(task.Loudness)
+	if err != nil {
+		return fmt.Errorf("failed to marshal loudness: %w", err)
+	}
+
+	warningsJSON, err := marshalOptionalJSON(task.Warnings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal warnings: %w", err)
+	}
+
+	verificationJSON, err := marshalOptionalJSON(task.Verification)
+	if err != nil {
+		return fmt.Errorf("failed to marshal verification: %w", err)
+	}
+
+	qualityJSON, err := marshalOptionalJSON(task.Quality)
+	if err != nil {
+		return fmt.Errorf("failed to marshal quality: %w", err)
+	}
+
+	thumbnailsJSON, err := marshalOptionalJSON(task.Thumbnails)
+	if err != nil {
+		return fmt.Errorf("failed to marshal thumbnails: %w", err)
+	}
+
 	query := `
 		UPDATE tasks SET
 			source_file = ?, output_file = ?, status = ?, progress = ?,
 			speed = ?, eta = ?, error = ?, source_file_size = ?, output_file_size = ?,
-			started_at = ?, completed_at = ?, preset = ?, config = ?
+			started_at = ?, completed_at = ?, preset = ?, config = ?,
+			loudness = ?, warnings = ?, agent_id = ?, skip_reason = ?, verification = ?, quality = ?,
+			thumbnails = ?
 		WHERE id = ?
 	`
 
@@ -364,12 +548,59 @@ func (db *DB) UpdateTask(task *model.Task) error {
 		task.SourceFile, task.OutputFile, task.Status, task.Progress,
 		task.Speed, task.ETA, task.Error, task.SourceFileSize, task.OutputFileSize,
 		task.StartedAt, task.CompletedAt,
-		task.Preset, string(configJSON), task.ID,
+		task.Preset, string(configJSON),
+		loudnessJSON, warningsJSON, task.AgentID, task.SkipReason, verificationJSON, qualityJSON,
+		thumbnailsJSON, task.ID,
 	)
 
 	return err
 }
 
+// GetPendingTasks retrieves the pending tasks, oldest first
+func (db *DB) GetPendingTasks() ([]*model.Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE status = ? ORDER BY created_at ASC`
+
+	rows, err := db.conn.Query(query, model.TaskStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []*model.Task{}
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		tasks = append(tasks, task)
+	}
+
+	return tasks, rows.Err()
+}
+
+// ClaimTask marks a pending task as running on an agent ("" = local worker pool)
+// The status check and update are a single statement, so a task is only claimed once.
+// Returns false when the task isn't pending anymore.
+func (db *DB) ClaimTask(id, agentID string, startedAt time.Time) (bool, error) {
+	query := `
+		UPDATE tasks SET status = ?, started_at = ?, agent_id = ?
+		WHERE id = ? AND status = ?
+	`
+
+	result, err := db.conn.Exec(query, model.TaskStatusRunning, startedAt, agentID, id, model.TaskStatusPending)
+	if err != nil {
+		return false, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return count == 1, nil
+}
+
 // DeleteTask deletes a task by ID
 func (db *DB) DeleteTask(id string) error {
 	query := `DELETE FROM tasks WHERE id = ?`
@@ -690,6 +921,121 @@ func (db *DB) InitializeBuiltinPresets() error {
 				ExtraParams: `-svtav1-params "keyint=10s:scd=1:scm=0:enable-tf=2:tf-strength=2:sharpness=4"`,
 			},
 		},
+		{
+			ID:          "builtin-remux-mkv",
+			Name:        "Remux to MKV",
+			Description: "Copy all streams into MKV without re-encoding / 不重新编码，全部流封装为 MKV",
+			IsBuiltin:   true,
+			CreatedAt:   time.Now(),
+			Config: model.TranscodeConfig{
+				Mode: "remux",
+				Audio: model.AudioConfig{
+					Codec: "copy",
+				},
+				Output: model.OutputConfig{
+					Container: "mkv",
+					Suffix:    "_remux",
+					PathType:  "source",
+				},
+			},
+		},
+		{
+			ID:          "builtin-remux-mp4",
+			Name:        "Remux to MP4",
+			Description: "Copy streams into MP4 with fast start, subtitles converted to mov_text / 不重新编码封装为 MP4（快速启动），字幕转为 mov_text",
+			IsBuiltin:   true,
+			CreatedAt:   time.Now(),
+			Config: model.TranscodeConfig{
+				Mode: "remux",
+				Audio: model.AudioConfig{
+					Codec: "copy",
+				},
+				Output: model.OutputConfig{
+					Container: "mp4",
+					Suffix:    "_remux",
+					PathType:  "source",
+				},
+			},
+		},
+		{
+			ID:          "builtin-remux-mkv-clean",
+			Name:        "Remux to MKV (Video + Audio)",
+			Description: "Copy video and audio into MKV, drop subtitles and data streams / 仅保留视频和音频封装为 MKV，去除字幕和数据流",
+			IsBuiltin:   true,
+			CreatedAt:   time.Now(),
+			Config: model.TranscodeConfig{
+				Mode: "remux",
+				Audio: model.AudioConfig{
+					Codec: "copy",
+				},
+				Subtitle: model.SubtitleConfig{
+					Mode: "none",
+				},
+				Output: model.OutputConfig{
+					Container: "mkv",
+					Suffix:    "_remux",
+					PathType:  "source",
+				},
+				Streams: &model.StreamSelection{
+					DropData: true,
+				},
+			},
+		},
+		{
+			ID:          "builtin-audio-flac",
+			Name:        "Extract Audio (FLAC)",
+			Description: "Lossless FLAC of the first audio track with tags and cover art / 提取第一条音轨为无损 FLAC，保留标签和封面",
+			IsBuiltin:   true,
+			CreatedAt:   time.Now(),
+			Config: model.TranscodeConfig{
+				Mode: "audio",
+				Audio: model.AudioConfig{
+					Codec: "flac",
+				},
+				Output: model.OutputConfig{
+					Container: "flac",
+					Suffix:    "_audio",
+					PathType:  "source",
+				},
+			},
+		},
+		{
+			ID:          "builtin-audio-mp3",
+			Name:        "Convert Audio to MP3 (320k)",
+			Description: "MP3 320 kb/s of the first audio track with tags and cover art / 转换第一条音轨为 320 kb/s MP3，保留标签和封面",
+			IsBuiltin:   true,
+			CreatedAt:   time.Now(),
+			Config: model.TranscodeConfig{
+				Mode: "audio",
+				Audio: model.AudioConfig{
+					Codec:   "mp3",
+					Bitrate: "320k",
+				},
+				Output: model.OutputConfig{
+					Container: "mp3",
+					Suffix:    "_audio",
+					PathType:  "source",
+				},
+			},
+		},
+		{
+			ID:          "builtin-audio-mka",
+			Name:        "Extract Audio Tracks (MKA)",
+			Description: "Copy all audio tracks into MKA without re-encoding / 不重新编码，提取全部音轨为 MKA",
+			IsBuiltin:   true,
+			CreatedAt:   time.Now(),
+			Config: model.TranscodeConfig{
+				Mode: "audio",
+				Audio: model.AudioConfig{
+					Codec: "copy",
+				},
+				Output: model.OutputConfig{
+					Container: "mka",
+					Suffix:    "_audio",
+					PathType:  "source",
+				},
+			},
+		},
 	}
 
 	for _, preset := range builtinPresets {
@@ -0,0 +1,179 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"ffmpeg-web/internal/model"
+	"fmt"
+	"time"
+)
+
+// Watch folder operations
+
+// watchFolderColumns is the column list shared by all watch folder queries (order matches scanWatchFolder)
+const watchFolderColumns = `id, name, path, preset, include, exclude, recursive, settle_seconds,
+	poll, enabled, created_at, updated_at`
+
+// scanWatchFolder scans a single watch folder row selected with watchFolderColumns
+func scanWatchFolder(row rowScanner) (*model.WatchFolder, error) {
+	folder := &model.WatchFolder{}
+	var includeJSON, excludeJSON sql.NullString
+
+	err := row.Scan(
+		&folder.ID, &folder.Name, &folder.Path, &folder.Preset,
+		&includeJSON, &excludeJSON, &folder.Recursive, &folder.SettleSeconds,
+		&folder.Poll, &folder.Enabled, &folder.CreatedAt, &folder.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := unmarshalOptionalJSON(includeJSON, &folder.Include); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal include patterns: %w", err)
+	}
+	if err := unmarshalOptionalJSON(excludeJSON, &folder.Exclude); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal exclude patterns: %w", err)
+	}
+
+	return folder, nil
+}
+
+// CreateWatchFolder creates a new watch folder
+func (db *DB) CreateWatchFolder(folder *model.WatchFolder) error {
+	includeJSON, excludeJSON, err := marshalPatterns(folder)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO watch_folders (` + watchFolderColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err = db.conn.Exec(query,
+		folder.ID, folder.Name, folder.Path, folder.Preset,
+		includeJSON, excludeJSON, folder.Recursive, folder.SettleSeconds,
+		folder.Poll, folder.Enabled, folder.CreatedAt, folder.UpdatedAt,
+	)
+
+	return err
+}
+
+// GetWatchFolder retrieves a watch folder by ID
+func (db *DB) GetWatchFolder(id string) (*model.WatchFolder, error) {
+	query := `SELECT ` + watchFolderColumns + ` FROM watch_folders WHERE id = ?`
+
+	folder, err := scanWatchFolder(db.conn.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("watch folder not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return folder, nil
+}
+
+// GetAllWatchFolders retrieves all watch folders
+func (db *DB) GetAllWatchFolders() ([]*model.WatchFolder, error) {
+	query := `SELECT ` + watchFolderColumns + ` FROM watch_folders ORDER BY created_at ASC`
+
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	folders := []*model.WatchFolder{}
+	for rows.Next() {
+		folder, err := scanWatchFolder(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		folders = append(folders, folder)
+	}
+
+	return folders, rows.Err()
+}
+
+// UpdateWatchFolder updates an existing watch folder
+func (db *DB) UpdateWatchFolder(folder *model.WatchFolder) error {
+	includeJSON, excludeJSON, err := marshalPatterns(folder)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE watch_folders SET
+			name = ?, path = ?, preset = ?, include = ?, exclude = ?, recursive = ?,
+			settle_seconds = ?, poll = ?, enabled = ?, updated_at = ?
+		WHERE id = ?
+	`
+
+	_, err = db.conn.Exec(query,
+		folder.Name, folder.Path, folder.Preset, includeJSON, excludeJSON, folder.Recursive,
+		folder.SettleSeconds, folder.Poll, folder.Enabled, folder.UpdatedAt, folder.ID,
+	)
+
+	return err
+}
+
+// DeleteWatchFolder deletes a watch folder and its processed file records
+func (db *DB) DeleteWatchFolder(id string) error {
+	result, err := db.conn.Exec(`DELETE FROM watch_folders WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("watch folder not found")
+	}
+
+	_, err = db.conn.Exec(`DELETE FROM watch_files WHERE watch_id = ?`, id)
+	return err
+}
+
+// marshalPatterns marshals the glob patterns of a watch folder
+func marshalPatterns(folder *model.WatchFolder) (sql.NullString, sql.NullString, error) {
+	includeJSON, err := json.Marshal(folder.Include)
+	if err != nil {
+		return sql.NullString{}, sql.NullString{}, fmt.Errorf("failed to marshal include patterns: %w", err)
+	}
+	excludeJSON, err := json.Marshal(folder.Exclude)
+	if err != nil {
+		return sql.NullString{}, sql.NullString{}, fmt.Errorf("failed to marshal exclude patterns: %w", err)
+	}
+
+	return sql.NullString{String: string(includeJSON), Valid: true},
+		sql.NullString{String: string(excludeJSON), Valid: true}, nil
+}
+
+// IsWatchFileProcessed reports whether a watch folder already queued a file
+// Output files of existing tasks count as processed, so outputs written into a watch folder aren't queued again
+func (db *DB) IsWatchFileProcessed(watchID, path string) (bool, error) {
+	var count int
+	err := db.conn.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM watch_files WHERE watch_id = ? AND path = ?)
+			+ (SELECT COUNT(*) FROM tasks WHERE output_file = ?)
+	`, watchID, path, path).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// AddWatchFile records that a watch folder queued a file
+func (db *DB) AddWatchFile(watchID, path string, size int64, taskID string) error {
+	_, err := db.conn.Exec(`
+		INSERT OR REPLACE INTO watch_files (watch_id, path, size, task_id, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, watchID, path, size, taskID, time.Now())
+
+	return err
+}
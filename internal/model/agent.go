@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// Agent represents a remote transcoding agent registered with the server
+type Agent struct {
+	ID            string               `json:"id"`
+	Name          string               `json:"name"`
+	MaxTasks      int                  `json:"maxTasks"`               // Tasks the agent runs at the same time
+	Hardware      *HardwareInfo        `json:"hardware,omitempty"`     // Detected hardware of the agent host
+	Capabilities  *EncoderCapabilities `json:"capabilities,omitempty"` // Encoders and filters of the agent's ffmpeg build
+	WritesInPlace bool                 `json:"writesInPlace"`          // Outputs are written to the mapped server paths instead of uploaded
+	Tasks         []string             `json:"tasks"`                  // IDs of the tasks running on the agent
+	RegisteredAt  time.Time            `json:"registeredAt"`
+	LastSeen      time.Time            `json:"lastSeen"` // Last heartbeat or task update
+}
+
+// AgentRegistration is sent by an agent when it connects to the server
+type AgentRegistration struct {
+	Name          string               `json:"name" binding:"required"`
+	MaxTasks      int                  `json:"maxTasks"`
+	Hardware      *HardwareInfo        `json:"hardware,omitempty"`
+	Capabilities  *EncoderCapabilities `json:"capabilities,omitempty"`
+	WritesInPlace bool                 `json:"writesInPlace"`
+}
+
+// AgentTask is a task handed out to an agent
+type AgentTask struct {
+	Task       *Task  `json:"task"`       // OutputFile is set to the output path on the server
+	SourcePath string `json:"sourcePath"` // Absolute source path on the server
+	MediaKey   string `json:"mediaKey"`   // Key for streaming the source over HTTP (valid while the task is assigned)
+}
@@ -23,6 +23,60 @@ type FileInfo struct {
 	IsHDR          *bool    `json:"isHDR,omitempty"`
 	Profile        *string  `json:"profile,omitempty"`
 	Level          *string  `json:"level,omitempty"`
+	// Every stream, the chapters and the container of a probed file
+	Format   *string           `json:"format,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"`
+	Streams  []MediaStream     `json:"streams,omitempty"`
+	Chapters []Chapter         `json:"chapters,omitempty"`
+	// Scan analysis of the main video stream (interlacing, telecine, variable frame rate)
+	Scan *ScanInfo `json:"scan,omitempty"`
+}
+
+// MediaStream describes one stream of a probed file
+type MediaStream struct {
+	Index         int      `json:"index"` // Absolute stream index (stream selection uses these)
+	Type          string   `json:"type"`  // video, audio, subtitle, attachment, data
+	Codec         string   `json:"codec"`
+	Profile       string   `json:"profile,omitempty"`
+	Width         int      `json:"width,omitempty"`
+	Height        int      `json:"height,omitempty"`
+	FrameRate     string   `json:"frameRate,omitempty"`
+	Channels      int      `json:"channels,omitempty"`
+	ChannelLayout string   `json:"channelLayout,omitempty"`
+	SampleRate    int      `json:"sampleRate,omitempty"`
+	SubtitleType  string   `json:"subtitleType,omitempty"` // text or image
+	Language      string   `json:"language,omitempty"`
+	Title         string   `json:"title,omitempty"`
+	Bitrate       int64    `json:"bitrate,omitempty"`
+	Disposition   []string `json:"disposition,omitempty"` // e.g. default, forced, attached_pic
+}
+
+// Chapter is a chapter marker of a probed file
+type Chapter struct {
+	Start float64 `json:"start"` // Seconds
+	End   float64 `json:"end"`   // Seconds
+	Title string  `json:"title,omitempty"`
+}
+
+// ScanInfo describes how the main video stream of a probed file is scanned and timed
+type ScanInfo struct {
+	Type         string      `json:"type,omitempty"`     // progressive, interlaced, telecined (empty = unknown)
+	Pulldown     string      `json:"pulldown,omitempty"` // Telecined only: soft or hard
+	FieldOrder   string      `json:"fieldOrder,omitempty"`
+	FrameRate    string      `json:"frameRate,omitempty"`    // Base frame rate (r_frame_rate)
+	AvgFrameRate string      `json:"avgFrameRate,omitempty"` // Average frame rate (avg_frame_rate)
+	VFR          bool        `json:"vfr"`
+	Idet         *IdetCounts `json:"idet,omitempty"` // Set when the idet filter classified a sample
+}
+
+// IdetCounts are the frame counts of an idet sample
+type IdetCounts struct {
+	TFF            int `json:"tff"`
+	BFF            int `json:"bff"`
+	Progressive    int `json:"progressive"`
+	Undetermined   int `json:"undetermined"`
+	RepeatedTop    int `json:"repeatedTop"`
+	RepeatedBottom int `json:"repeatedBottom"`
 }
 
 // HardwareInfo represents available hardware acceleration options
@@ -32,5 +86,11 @@ type HardwareInfo struct {
 	Intel   bool   `json:"intel"`
 	AMD     bool   `json:"amd"`
 	GPUName string `json:"gpuName,omitempty"`
+	// VA-API (Linux): usable for AMD and Intel GPUs, encoders come from the vainfo encode profiles
+	VAAPI         bool     `json:"vaapi"`
+	VAAPIDevice   string   `json:"vaapiDevice,omitempty"`   // DRM render node, e.g. /dev/dri/renderD128
+	VAAPIEncoders []string `json:"vaapiEncoders,omitempty"` // encoder families: h265, av1, h264, vp9
+	// Devices lists every NVIDIA GPU (nvidia-smi) and DRM render node for device selection
+	Devices []GPUDevice `json:"devices,omitempty"`
 }
 
@@ -2,10 +2,12 @@ package model
 
 // GPUCapabilities represents GPU encoding and decoding capabilities
 type GPUCapabilities struct {
-	HasIntelVA bool     `json:"hasIntelVA"`
-	IntelVA    *VAInfo  `json:"intelVA,omitempty"`
-	HasNVIDIA  bool     `json:"hasNVIDIA"`
-	HasAMD     bool     `json:"hasAMD"`
+	HasIntelVA    bool                 `json:"hasIntelVA"`
+	IntelVA       *VAInfo              `json:"intelVA,omitempty"`
+	HasNVIDIA     bool                 `json:"hasNVIDIA"`
+	HasAMD        bool                 `json:"hasAMD"`
+	VAAPIEncoders []string             `json:"vaapiEncoders,omitempty"` // Encoder families with VA-API encode profiles
+	Encoders      *EncoderCapabilities `json:"encoders,omitempty"`      // Encoders and filters of the ffmpeg build
 }
 
 // VAInfo represents Intel VA-API capabilities
@@ -16,3 +18,30 @@ type VAInfo struct {
 	EncodeProfiles  []string `json:"encodeProfiles"`
 }
 
+// EncoderCapabilities represents what the configured ffmpeg build supports
+type EncoderCapabilities struct {
+	Encoders []string `json:"encoders"` // ffmpeg -encoders
+	Decoders []string `json:"decoders"` // ffmpeg -decoders
+	HWAccels []string `json:"hwaccels"` // ffmpeg -hwaccels
+	Filters  []string `json:"filters"`  // ffmpeg -filters
+	// Matrix maps encoder family (h265, av1, ...) and hardware acceleration (cpu, nvidia, ...) to the encoder status
+	Matrix map[string]map[string]EncoderStatus `json:"matrix"`
+	// Tested is set when test encodes were run for the hardware encoders
+	Tested bool `json:"tested"`
+}
+
+// EncoderStatus represents the support of one encoder in the capability matrix
+type EncoderStatus struct {
+	Encoder   string `json:"encoder"`         // ffmpeg encoder name, e.g. hevc_nvenc
+	Available bool   `json:"available"`       // compiled into ffmpeg (and hwaccel available)
+	Tested    bool   `json:"tested"`          // a test encode was run
+	Working   bool   `json:"working"`         // the test encode succeeded
+	Error     string `json:"error,omitempty"` // test encode error
+}
+
+// GPUDevice represents a GPU that tasks can be assigned to
+type GPUDevice struct {
+	ID     string `json:"id"`     // "nvidia:<index>" for NVIDIA GPUs, the render node path for DRM devices
+	Vendor string `json:"vendor"` // nvidia, intel, amd
+	Name   string `json:"name"`
+}
@@ -14,40 +14,58 @@ const (
 	TaskStatusCompleted TaskStatus = "completed"
 	TaskStatusFailed    TaskStatus = "failed"
 	TaskStatusCancelled TaskStatus = "cancelled"
+	TaskStatusSkipped   TaskStatus = "skipped" // Finished without a kept output (see SkipReason)
 )
 
 // Task represents a video transcode task
 type Task struct {
 	ID             string          `json:"id"`
 	SourceFile     string          `json:"sourceFile"`
-	OutputFile     string          `json:"outputFile"`
+	OutputFile     string          `json:"outputFile"` // output file, or the package directory of HLS/DASH outputs
 	Status         TaskStatus      `json:"status"`
 	Progress       float64         `json:"progress"`
 	Speed          float64         `json:"speed"`
 	ETA            int64           `json:"eta"` // seconds
 	Error          string          `json:"error,omitempty"`
 	SourceFileSize int64           `json:"sourceFileSize,omitempty"` // in bytes
-	OutputFileSize int64           `json:"outputFileSize,omitempty"` // in bytes
+	OutputFileSize int64           `json:"outputFileSize,omitempty"` // in bytes (all files of a package)
 	CreatedAt      time.Time       `json:"createdAt"`
 	StartedAt      *time.Time      `json:"startedAt,omitempty"`
 	CompletedAt    *time.Time      `json:"completedAt,omitempty"`
 	Preset         string          `json:"preset,omitempty"`
 	Config         TranscodeConfig `json:"config"`
 	ActualCommand  string          `json:"actualCommand,omitempty"` // Actual FFmpeg command executed (for debugging)
+	Loudness       []LoudnessStats `json:"loudness,omitempty"`      // Loudnorm first-pass measurements, one per measured audio track (for auditing)
+	Warnings       []string        `json:"warnings,omitempty"`      // Non-fatal problems (e.g. streams converted or dropped for the container)
+	AgentID        string          `json:"agentId,omitempty"`       // Remote agent running the task (empty = local worker pool)
+	SkipReason     string          `json:"skipReason,omitempty"`    // Why a skipped task's output was discarded (e.g. no_gain)
+	Verification   *Verification   `json:"verification,omitempty"`  // Post-encode verification result
+	Quality        *QualityScore   `json:"quality,omitempty"`       // Objective quality of the output against the source
+	Thumbnails     []string        `json:"thumbnails,omitempty"`    // Preview images written next to the output
 }
 
 // TranscodeConfig represents the configuration for a transcode task
 type TranscodeConfig struct {
-	// Mode: "simple" (UI-based config) or "advanced" (custom CLI)
-	Mode string `json:"mode,omitempty"` // simple, advanced (default: simple)
+	// Mode: "simple" (UI-based config), "advanced" (custom CLI) or "remux" (streams copied into a new container)
+	Mode string `json:"mode,omitempty"` // simple, advanced, remux, audio (default: simple)
 
 	// Simple mode fields (UI-based configuration)
-	Encoder       string       `json:"encoder"`       // h265, av1
-	HardwareAccel string       `json:"hardwareAccel"` // cpu, nvidia, intel, amd
-	Video         VideoConfig  `json:"video"`
-	Audio         AudioConfig  `json:"audio"`
-	Output        OutputConfig `json:"output"`
-	ExtraParams   string       `json:"extraParams,omitempty"` // Extra FFmpeg parameters
+	Encoder       string           `json:"encoder"`          // h265, av1
+	HardwareAccel string           `json:"hardwareAccel"`    // cpu, nvidia, intel, amd (VA-API on Linux), vaapi
+	Device        string           `json:"device,omitempty"` // GPU: auto (default, spread across GPUs) or an ID from /api/hardware
+	Video         VideoConfig      `json:"video"`
+	Audio         AudioConfig      `json:"audio"`
+	Subtitle      SubtitleConfig   `json:"subtitle"`
+	Output        OutputConfig     `json:"output"`
+	ExtraParams   string           `json:"extraParams,omitempty"` // Extra FFmpeg parameters
+	Chunking      *ChunkConfig     `json:"chunking,omitempty"`    // Chunked parallel encoding
+	Skip          *SkipRules       `json:"skip,omitempty"`        // Source files that don't get a task
+	SizePolicy    *SizePolicy      `json:"sizePolicy,omitempty"`  // Outputs larger than the source
+	Verify        *VerifyConfig    `json:"verify,omitempty"`      // Post-encode verification
+	Quality       *QualityConfig   `json:"quality,omitempty"`     // Objective quality scoring
+	Trim          *TrimConfig      `json:"trim,omitempty"`        // Only transcode part of the source
+	Streams       *StreamSelection `json:"streams,omitempty"`     // Source streams kept by remux mode and audio tasks
+	Thumbnails    *ThumbnailConfig `json:"thumbnails,omitempty"`  // Preview images of the output
 
 	// Advanced mode field (custom CLI parameters)
 	CustomCommand string `json:"customCommand,omitempty"` // Custom FFmpeg parameters (between input and output)
@@ -61,6 +79,18 @@ type VideoConfig struct {
 	FPS        string   `json:"fps,omitempty"`        // "original", "30", "60", etc
 	Bitrate    string   `json:"bitrate,omitempty"`
 	HdrMode    []string `json:"hdrMode,omitempty"` // HDR handling: ["auto"] (default) or empty (passthrough)
+
+	// Scan handling, "auto" decides from the source's scan analysis
+	//   - Deinterlace: auto, deinterlace, ivtc (inverse telecine) or empty (keep the fields)
+	//   - FrameRateMode: auto (constant frame rate for VFR sources), cfr or empty (container default)
+	Deinterlace   string `json:"deinterlace,omitempty"`
+	FrameRateMode string `json:"frameRateMode,omitempty"`
+
+	// Rate control: crf (default), cbr, vbr-2pass, target-size
+	//   - cbr / vbr-2pass use Bitrate
+	//   - target-size computes the bitrate from TargetSize, the duration and the audio budget
+	RateControl string `json:"rateControl,omitempty"`
+	TargetSize  string `json:"targetSize,omitempty"` // e.g. "4G", "700M" (binary units)
 }
 
 // AudioConfig represents audio encoding configuration
@@ -68,6 +98,219 @@ type AudioConfig struct {
 	Codec    string `json:"codec"` // copy, aac, opus, mp3
 	Bitrate  string `json:"bitrate,omitempty"`
 	Channels int    `json:"channels,omitempty"`
+
+	// Per-track rules, evaluated in order against each probed source audio stream.
+	// The first matching rule wins; tracks matching no rule use Codec/Bitrate/Channels above.
+	Rules []AudioRule `json:"rules,omitempty"`
+
+	// Loudness normalization (EBU R128), applied to every re-encoded audio track
+	Loudnorm *LoudnormConfig `json:"loudnorm,omitempty"`
+
+	// StereoCompat adds a stereo AAC track after the originals for every surround (>2ch) source track
+	StereoCompat        bool   `json:"stereoCompat,omitempty"`
+	StereoCompatBitrate string `json:"stereoCompatBitrate,omitempty"` // default: 192k
+}
+
+// LoudnormConfig represents loudness normalization settings for ffmpeg's loudnorm filter
+// The worker runs a measurement pass over every normalized track first and then applies
+// linear normalization with the measured values; without measurements (e.g. command preview,
+// silent tracks) a single dynamic pass is used instead.
+type LoudnormConfig struct {
+	Enabled bool    `json:"enabled"`
+	Preset  string  `json:"preset,omitempty"` // ebu, streaming, podcast, atsc, custom (default: ebu)
+	I       float64 `json:"i,omitempty"`      // Integrated loudness target in LUFS (custom preset)
+	TP      float64 `json:"tp,omitempty"`     // Maximum true peak in dBTP (custom preset)
+	LRA     float64 `json:"lra,omitempty"`    // Loudness range target in LU (custom preset)
+}
+
+// LoudnessStats represents the values measured by a loudnorm analysis pass
+type LoudnessStats struct {
+	Stream       int     `json:"stream"` // Source stream index of the measured audio track
+	InputI       float64 `json:"inputI"`
+	InputTP      float64 `json:"inputTP"`
+	InputLRA     float64 `json:"inputLRA"`
+	InputThresh  float64 `json:"inputThresh"`
+	TargetOffset float64 `json:"targetOffset"`
+	// Targets used for the measurement
+	TargetI   float64 `json:"targetI"`
+	TargetTP  float64 `json:"targetTP"`
+	TargetLRA float64 `json:"targetLRA"`
+}
+
+// AudioRule describes how matching source audio tracks are encoded
+type AudioRule struct {
+	// Match conditions (all optional, an empty rule matches every track)
+	Codecs      []string `json:"codecs,omitempty"`      // source codec names, e.g. ["aac", "opus"]
+	Languages   []string `json:"languages,omitempty"`   // source language tags, e.g. ["eng", "jpn"]
+	MinChannels int      `json:"minChannels,omitempty"` // match tracks with at least this many channels
+	MaxChannels int      `json:"maxChannels,omitempty"` // match tracks with at most this many channels
+
+	// Encoding applied to matching tracks
+	Codec             string `json:"codec"`                       // copy, aac, opus, libopus, mp3, ...
+	Bitrate           string `json:"bitrate,omitempty"`           // fixed bitrate, e.g. "192k"
+	BitratePerChannel string `json:"bitratePerChannel,omitempty"` // e.g. "96k", multiplied by the output channel count
+	Channels          int    `json:"channels,omitempty"`          // output channels (0 = keep source layout)
+}
+
+// SubtitleConfig represents subtitle handling configuration
+type SubtitleConfig struct {
+	// Mode: copy (default), convert, burn, none
+	//   - copy: keep all subtitle streams as-is
+	//   - convert: convert text subtitles to a format the container supports,
+	//     image subtitles (PGS/DVD) are dropped when the container can't mux them
+	//   - burn: burn one subtitle track into the video
+	//   - none: drop all subtitle streams
+	Mode string `json:"mode,omitempty"`
+	// Format for converted text subtitles: mov_text, srt, webvtt, ass
+	// (default: chosen from the output container)
+	Format string `json:"format,omitempty"`
+	// BurnStream is the subtitle track to burn in (0 = first subtitle track)
+	BurnStream int `json:"burnStream,omitempty"`
+	// KeepOthers keeps the remaining subtitle tracks as soft subtitles in burn mode
+	// (converted like in convert mode)
+	KeepOthers bool `json:"keepOthers,omitempty"`
+}
+
+// ChunkConfig represents chunked parallel encoding settings
+// The video is split at keyframes into segments that are encoded in parallel and
+// joined with the concat demuxer; audio, subtitles and attachments come from the source.
+// Segments are encoded in a single pass (vbr-2pass is not supported, target-size uses ABR).
+type ChunkConfig struct {
+	Enabled  bool `json:"enabled"`
+	Duration int  `json:"duration,omitempty"` // Target segment length in seconds (default: 120)
+	Parallel int  `json:"parallel,omitempty"` // Segments encoded at the same time (default: CPU cores / 8, at least 2)
+	Retries  int  `json:"retries,omitempty"`  // Retries of a failed segment (default: 2)
+}
+
+// StreamSelection picks the source streams a remux or audio task keeps (all of them by default)
+// Streams without a language tag always pass the language filters.
+type StreamSelection struct {
+	Drop              []int    `json:"drop,omitempty"`              // Absolute source stream indexes removed from the output
+	AudioLanguages    []string `json:"audioLanguages,omitempty"`    // Keep only audio tracks in these languages, e.g. ["jpn", "eng"]
+	SubtitleLanguages []string `json:"subtitleLanguages,omitempty"` // Keep only subtitle tracks in these languages
+	DropData          bool     `json:"dropData,omitempty"`          // Remove data streams (e.g. teletext, timed metadata)
+	AudioTracks       []int    `json:"audioTracks,omitempty"`       // Keep only these audio tracks (absolute source stream indexes)
+}
+
+// TrimConfig keeps only part of the source, either one Start/End range or a list of segments
+// A single range is cut with accurate input seeking; several segments are cut and joined
+// with a trim/concat filter graph, which re-encodes audio and drops subtitle and data streams.
+type TrimConfig struct {
+	Start    float64       `json:"start,omitempty"`    // Seconds cut from the beginning
+	End      float64       `json:"end,omitempty"`      // Source time the output stops at (0 = end of the source)
+	Segments []TrimSegment `json:"segments,omitempty"` // Kept segments in source order (instead of Start/End)
+}
+
+// TrimSegment is a kept range of the source in seconds
+type TrimSegment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end,omitempty"` // 0 = end of the source (last segment only)
+}
+
+// SkipRules lists conditions under which a source file is not transcoded
+// They are checked against the ffprobe results when tasks are created; any matching rule skips the file.
+type SkipRules struct {
+	SameCodec    bool   `json:"sameCodec,omitempty"`    // Source video codec already equals the target encoder
+	BitrateBelow string `json:"bitrateBelow,omitempty"` // Source bitrate below this value, e.g. "2M", "800k"
+	HeightBelow  int    `json:"heightBelow,omitempty"`  // Source video height below this many lines, e.g. 720
+	OutputSuffix bool   `json:"outputSuffix,omitempty"` // File name already ends with the output suffix (a previous output)
+}
+
+// Skip reasons reported for source files that didn't get a task
+const (
+	SkipReasonSameCodec    = "same_codec"
+	SkipReasonLowBitrate   = "low_bitrate"
+	SkipReasonLowHeight    = "low_resolution"
+	SkipReasonOutputSuffix = "output_suffix"
+	SkipReasonNoGain       = "no_gain" // Output exceeded the size policy and was discarded
+)
+
+// SizePolicy handles outputs that end up larger than allowed compared to the source
+// The check runs after the encode; the task's source and output sizes hold the stats.
+type SizePolicy struct {
+	MaxPercent int    `json:"maxPercent"`       // Largest allowed output size in percent of the source (e.g. 100), 0 = off
+	Action     string `json:"action,omitempty"` // discard (default): delete the output and skip the task; keep: keep it with a warning
+}
+
+// VerifyConfig enables the post-encode verification of the output
+// The output is re-probed and compared with the source; a failed verification fails the task.
+type VerifyConfig struct {
+	Enabled           bool    `json:"enabled"`
+	DurationTolerance float64 `json:"durationTolerance,omitempty"` // Allowed duration difference in seconds (default: 1)
+	Decode            bool    `json:"decode,omitempty"`            // Also decode the whole output and fail on decode errors
+}
+
+// StreamCounts counts the streams of a file by type
+type StreamCounts struct {
+	Video    int `json:"video"`
+	Audio    int `json:"audio"`
+	Subtitle int `json:"subtitle"`
+}
+
+// Verification is the result of the post-encode verification
+type Verification struct {
+	Passed         bool         `json:"passed"`
+	SourceDuration float64      `json:"sourceDuration"` // seconds, after trimming
+	OutputDuration float64      `json:"outputDuration"` // seconds
+	Expected       StreamCounts `json:"expected"`       // Streams the output must have at least
+	Output         StreamCounts `json:"output"`
+	Decoded        bool         `json:"decoded"` // The decode check ran
+	Problems       []string     `json:"problems,omitempty"`
+}
+
+// QualityConfig enables objective quality scoring of the output against the source
+// Scores are computed on Samples segments of SampleDuration seconds spread over the file.
+type QualityConfig struct {
+	Enabled        bool   `json:"enabled"`
+	Metric         string `json:"metric,omitempty"`         // vmaf (default), ssim, psnr; falls back to ssim/psnr when the filter is missing
+	Samples        int    `json:"samples,omitempty"`        // Number of sampled segments (default: 5)
+	SampleDuration int    `json:"sampleDuration,omitempty"` // Segment length in seconds (default: 10)
+}
+
+// ThumbnailConfig writes preview images of the output after a successful encode
+// Images are written next to the output file (inside the package directory for HLS/DASH);
+// a failed image only adds a warning.
+type ThumbnailConfig struct {
+	Poster       bool    `json:"poster,omitempty"`       // A single frame (<name>.jpg)
+	PosterAt     float64 `json:"posterAt,omitempty"`     // Poster position in percent of the duration (default: 10)
+	ContactSheet bool    `json:"contactSheet,omitempty"` // Frames spread over the whole duration in a grid (<name>_sheet.jpg)
+	Trickplay    bool    `json:"trickplay,omitempty"`    // WebVTT seek previews with sprite images (<name>_trickplay.vtt)
+	Interval     float64 `json:"interval,omitempty"`     // Seconds between trickplay frames (default: 10)
+}
+
+// QualityScore is the result of the quality scoring, over all frames of all samples
+type QualityScore struct {
+	Metric  string  `json:"metric"` // vmaf, ssim or psnr
+	Mean    float64 `json:"mean"`
+	Min     float64 `json:"min"`
+	P5      float64 `json:"p5"`      // 5th percentile of the per-frame scores
+	Frames  int     `json:"frames"`  // Scored frames
+	Samples int     `json:"samples"` // Scored segments
+}
+
+// SampleResult is the result of a sample encode of a short range of a source file
+type SampleResult struct {
+	ID            string        `json:"id"`
+	SourceFile    string        `json:"sourceFile"`
+	Start         float64       `json:"start"`    // Offset in the source (seconds)
+	Duration      float64       `json:"duration"` // Encoded length (seconds)
+	Command       string        `json:"command"`
+	OutputSize    int64         `json:"outputSize"`
+	SourceSize    int64         `json:"sourceSize"`
+	ProjectedSize int64         `json:"projectedSize"` // Output size extrapolated to the whole source
+	EncodeTime    float64       `json:"encodeTime"`    // Wall time of the encode (seconds)
+	Speed         float64       `json:"speed"`         // Encoded seconds per wall second
+	Quality       *QualityScore `json:"quality,omitempty"`
+	QualityError  string        `json:"qualityError,omitempty"`
+	DownloadURL   string        `json:"downloadUrl"`
+	ExpiresAt     time.Time     `json:"expiresAt"`
+}
+
+// SkippedFile is a source file that matched a skip rule
+type SkippedFile struct {
+	File    string `json:"file"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
 }
 
 // OutputConfig represents output file configuration
@@ -76,4 +319,31 @@ type OutputConfig struct {
 	Suffix     string `json:"suffix"`               // filename suffix like "_h265"
 	PathType   string `json:"pathType"`             // source, custom, default, overwrite
 	CustomPath string `json:"customPath,omitempty"` // custom output directory
+	// Compatibility policy for streams the container can't hold: auto (default), strict, warn
+	//   - auto: convert incompatible audio/subtitles, drop what can't be converted
+	//   - strict: fail the task before ffmpeg starts
+	//   - warn: keep the command as configured, only report warnings
+	Compatibility string `json:"compatibility,omitempty"`
+	// Kind of output: file (default), hls or dash
+	// HLS and DASH outputs are written as a package directory named after the source
+	// (playlists/manifest plus segments) and carry the main video and the first audio track.
+	Kind      string           `json:"kind,omitempty"`
+	Streaming *StreamingConfig `json:"streaming,omitempty"` // HLS/DASH packaging settings
+}
+
+// StreamingConfig configures HLS/DASH packaging
+type StreamingConfig struct {
+	SegmentType     string `json:"segmentType,omitempty"`     // HLS segments: fmp4 (default) or ts; DASH always uses fmp4
+	SegmentDuration int    `json:"segmentDuration,omitempty"` // Target segment length in seconds (default 6)
+	// Renditions builds a multi-rendition ladder from the main video stream
+	// (empty = a single rendition with the configured resolution)
+	Renditions []Rendition `json:"renditions,omitempty"`
+}
+
+// Rendition is one video variant of an HLS/DASH ladder
+type Rendition struct {
+	Height int `json:"height"` // Output height in lines, the width keeps the aspect ratio
+	// VideoBitrate is the average bitrate with bitrate rate control, or the peak bitrate
+	// with quality-based rate control, e.g. "5000k" (empty = the configured rate control only)
+	VideoBitrate string `json:"videoBitrate,omitempty"`
 }
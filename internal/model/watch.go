@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// WatchFolder is a directory whose new media files are queued automatically
+type WatchFolder struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Path          string    `json:"path"`          // Directory under the data path
+	Preset        string    `json:"preset"`        // Preset ID used for the created tasks
+	Include       []string  `json:"include"`       // Glob patterns a file must match (any), empty = all video files
+	Exclude       []string  `json:"exclude"`       // Glob patterns that skip a file
+	Recursive     bool      `json:"recursive"`     // Watch subdirectories as well
+	SettleSeconds int       `json:"settleSeconds"` // Seconds a file's size must stay unchanged before it is queued
+	Poll          bool      `json:"poll"`          // Always scan periodically (network mounts are detected automatically)
+	Enabled       bool      `json:"enabled"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// WatchFolderStatus is a watch folder with the state of its watcher
+type WatchFolderStatus struct {
+	WatchFolder
+	Mode  string `json:"mode,omitempty"`  // inotify or poll, empty when not running
+	Error string `json:"error,omitempty"` // Why the watcher isn't running
+}
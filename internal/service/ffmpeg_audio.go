@@ -0,0 +1,475 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/pkg/ffprobe"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// defaultStereoCompatBitrate is used for stereo compatibility tracks when no bitrate is configured
+const defaultStereoCompatBitrate = "192k"
+
+// loudnormSampleRate is the output sample rate for normalized tracks
+// (loudnorm upsamples to 192 kHz internally)
+const loudnormSampleRate = "48000"
+
+// loudnormPresets maps preset names to I/TP/LRA targets
+var loudnormPresets = map[string][3]float64{
+	"ebu":       {-23, -1, 7},    // EBU R128 broadcast
+	"atsc":      {-24, -2, 7},    // ATSC A/85 (US broadcast)
+	"streaming": {-14, -1, 11},   // Typical streaming platforms
+	"podcast":   {-16, -1.5, 11}, // Speech content
+}
+
+// validateLoudnorm checks the loudness normalization settings
+func validateLoudnorm(audio *model.AudioConfig) error {
+	loudnorm := audio.Loudnorm
+	if loudnorm == nil || !loudnorm.Enabled {
+		return nil
+	}
+	if (audio.Codec == "copy" || audio.Codec == "") && len(audio.Rules) == 0 {
+		return fmt.Errorf("loudness normalization requires audio re-encoding (audio codec cannot be copy)")
+	}
+	if loudnorm.Preset != "" && loudnorm.Preset != "custom" {
+		if _, ok := loudnormPresets[loudnorm.Preset]; !ok {
+			return fmt.Errorf("unknown loudnorm preset: %s", loudnorm.Preset)
+		}
+	}
+	return nil
+}
+
+// buildAudioArgs builds audio encoding arguments
+// When the source audio streams are known and per-track rules or stereo compatibility
+// tracks are configured, every output audio stream gets its own -c:a:N options.
+// Otherwise a single codec setting is applied to all audio tracks.
+// loudness holds the loudnorm measurements of the first pass, one per measured source track;
+// tracks without a measurement are normalized in single-pass dynamic mode.
+// trimFilter is the trim/concat graph of multi-segment trims, applied to every track before loudnorm.
+func (fs *FFmpegService) buildAudioArgs(audio *model.AudioConfig, sourceVideoInfo *ffprobe.VideoInfo, loudness []model.LoudnessStats, trimFilter string) []string {
+	var streams []ffprobe.StreamInfo
+	if sourceVideoInfo != nil {
+		streams = sourceVideoInfo.StreamsOfType("audio")
+	}
+
+	normalize := audio.Loudnorm != nil && audio.Loudnorm.Enabled
+	loudnormFilter := func(stream int) string {
+		if !normalize {
+			return ""
+		}
+		return buildLoudnormFilter(audio.Loudnorm, loudnessOf(loudness, stream))
+	}
+
+	// No per-track configuration (or nothing probed): one setting for every track
+	// Measured tracks each need their own loudnorm values
+	if len(streams) == 0 || (len(audio.Rules) == 0 && !audio.StereoCompat && len(loudness) == 0) {
+		args := buildAudioCodecArgs("", audio.Codec, audio.Bitrate, audio.Channels)
+		if audio.Codec != "copy" && audio.Codec != "" {
+			args = append(args, audioFilterArgs("", trimFilter, loudnormFilter(-1))...)
+		}
+		return args
+	}
+
+	args := []string{}
+	needsStrict := false
+
+	// Original tracks, output audio index matches the source audio index
+	for i, stream := range streams {
+		rule := matchAudioRule(audio, stream)
+		bitrate := resolveAudioBitrate(rule, stream)
+		spec := strconv.Itoa(i)
+		args = append(args, buildAudioCodecArgs(spec, rule.Codec, bitrate, rule.Channels)...)
+		if rule.Codec == "opus" {
+			needsStrict = true
+		}
+		// Copied tracks cannot be filtered, only re-encoded tracks are normalized
+		if rule.Codec != "copy" && rule.Codec != "" {
+			args = append(args, audioFilterArgs(spec, trimFilter, loudnormFilter(stream.Index))...)
+		}
+	}
+
+	// Stereo compatibility tracks are appended after the originals
+	if audio.StereoCompat {
+		bitrate := audio.StereoCompatBitrate
+		if bitrate == "" {
+			bitrate = defaultStereoCompatBitrate
+		}
+
+		outputIndex := len(streams)
+		for i, stream := range streams {
+			if stream.Channels <= 2 {
+				continue
+			}
+
+			spec := strconv.Itoa(outputIndex)
+			args = append(args, "-map", fmt.Sprintf("0:a:%d", i))
+			args = append(args, "-c:a:"+spec, "aac", "-b:a:"+spec, bitrate, "-ac:a:"+spec, "2")
+			args = append(args, "-metadata:s:a:"+spec, "title=Stereo")
+			if stream.Language != "" {
+				args = append(args, "-metadata:s:a:"+spec, "language="+stream.Language)
+			}
+			// Never make the compatibility track the default one
+			args = append(args, "-disposition:a:"+spec, "0")
+			// Downmixing happens after the filters, so the surround track's measurement applies
+			args = append(args, audioFilterArgs(spec, trimFilter, loudnormFilter(stream.Index))...)
+			outputIndex++
+		}
+	}
+
+	// Native opus encoder is experimental and needs -strict -2 (global option)
+	if needsStrict {
+		args = append(args, "-strict", "-2")
+	}
+
+	return args
+}
+
+// audioFilterArgs builds the filter options of a re-encoded audio stream (spec "" = all audio streams)
+// The trim graph comes first, so loudness normalization sees the joined segments.
+func audioFilterArgs(spec, trimFilter, loudnormFilter string) []string {
+	var filters []string
+	if trimFilter != "" {
+		filters = append(filters, trimFilter)
+	}
+	if loudnormFilter != "" {
+		filters = append(filters, loudnormFilter)
+	}
+	if len(filters) == 0 {
+		return nil
+	}
+
+	filterOption, rateOption := "-filter:a", "-ar"
+	if spec != "" {
+		filterOption, rateOption = "-filter:a:"+spec, "-ar:a:"+spec
+	}
+	args := []string{filterOption, strings.Join(filters, ",")}
+	if loudnormFilter != "" {
+		args = append(args, rateOption, loudnormSampleRate)
+	}
+	return args
+}
+
+// loudnormTargets returns the I/TP/LRA targets for a loudnorm configuration
+func loudnormTargets(cfg *model.LoudnormConfig) (i, tp, lra float64) {
+	if cfg.Preset == "custom" {
+		i, tp, lra = cfg.I, cfg.TP, cfg.LRA
+		// Fall back to ffmpeg's defaults for unset values
+		if i == 0 {
+			i = -24
+		}
+		if tp == 0 {
+			tp = -2
+		}
+		if lra == 0 {
+			lra = 7
+		}
+		return i, tp, lra
+	}
+
+	targets, ok := loudnormPresets[cfg.Preset]
+	if !ok {
+		targets = loudnormPresets["ebu"]
+	}
+	return targets[0], targets[1], targets[2]
+}
+
+// buildLoudnormFilter builds the loudnorm filter string
+// With measurements, linear (two-pass) normalization is used; otherwise single-pass dynamic mode
+func buildLoudnormFilter(cfg *model.LoudnormConfig, stats *model.LoudnessStats) string {
+	i, tp, lra := loudnormTargets(cfg)
+	filter := fmt.Sprintf("loudnorm=I=%s:TP=%s:LRA=%s", formatFloat(i), formatFloat(tp), formatFloat(lra))
+
+	if stats != nil {
+		filter += fmt.Sprintf(":measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true",
+			formatFloat(stats.InputI), formatFloat(stats.InputTP), formatFloat(stats.InputLRA),
+			formatFloat(stats.InputThresh), formatFloat(stats.TargetOffset))
+	}
+
+	return filter
+}
+
+// loudnessOf returns the measurement of a source stream (nil = not measured)
+func loudnessOf(loudness []model.LoudnessStats, stream int) *model.LoudnessStats {
+	for i := range loudness {
+		if loudness[i].Stream == stream {
+			return &loudness[i]
+		}
+	}
+	return nil
+}
+
+// loudnessTracks returns the source audio tracks that get normalized:
+// re-encoded tracks and the surround tracks stereo compatibility tracks are made from.
+// Audio tasks only normalize the tracks they keep, packages only their first track.
+func loudnessTracks(config *model.TranscodeConfig, sourceVideoInfo *ffprobe.VideoInfo) []ffprobe.StreamInfo {
+	audioSource := sourceVideoInfo
+	if AudioMode(config) {
+		audio, _ := audioTaskStreams(config, sourceVideoInfo)
+		audioSource = &ffprobe.VideoInfo{Streams: audio}
+	} else if Packaged(config) {
+		audioSource = packagedSource(sourceVideoInfo)
+	}
+	if audioSource == nil {
+		return nil
+	}
+
+	var tracks []ffprobe.StreamInfo
+	for _, stream := range audioSource.StreamsOfType("audio") {
+		rule := matchAudioRule(&config.Audio, stream)
+		if (rule.Codec != "copy" && rule.Codec != "") || (config.Audio.StereoCompat && stream.Channels > 2) {
+			tracks = append(tracks, stream)
+		}
+	}
+	return tracks
+}
+
+// MeasureLoudness runs a loudnorm analysis pass over every normalized audio track of the source
+// and returns the measured values for the second (linear) pass.
+// Tracks that can't be measured (e.g. silence, reported as -inf) are left out, so they are
+// normalized in single-pass dynamic mode; why is returned as warnings.
+// Only the kept trim ranges are measured. An error is only returned when ctx ends.
+func (fs *FFmpegService) MeasureLoudness(ctx context.Context, sourceFile string, config *model.TranscodeConfig, sourceVideoInfo *ffprobe.VideoInfo) ([]model.LoudnessStats, []string, error) {
+	trimRanges := TrimRanges(config, durationOf(sourceVideoInfo))
+
+	var measured []model.LoudnessStats
+	var warnings []string
+	for _, stream := range loudnessTracks(config, sourceVideoInfo) {
+		stats, err := fs.measureTrackLoudness(ctx, sourceFile, config.Audio.Loudnorm, stream.Index, trimRanges)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			warnings = append(warnings, fmt.Sprintf("audio stream %d: %v, normalized in single-pass mode", stream.Index, err))
+			continue
+		}
+		measured = append(measured, *stats)
+	}
+	return measured, warnings, nil
+}
+
+// measureTrackLoudness runs the loudnorm analysis pass over one source stream
+// The trim ranges are cut the same way as in the encode (nil = the whole stream).
+func (fs *FFmpegService) measureTrackLoudness(ctx context.Context, sourceFile string, cfg *model.LoudnormConfig, stream int, trimRanges []ChunkRange) (*model.LoudnessStats, error) {
+	i, tp, lra := loudnormTargets(cfg)
+	filter := fmt.Sprintf("loudnorm=I=%s:TP=%s:LRA=%s:print_format=json", formatFloat(i), formatFloat(tp), formatFloat(lra))
+	if len(trimRanges) > 1 {
+		filter = buildTrimFilter(trimRanges, "a") + "," + filter
+	}
+
+	args := []string{"-hide_banner", "-nostats"}
+	if len(trimRanges) == 1 && trimRanges[0].Start > 0 {
+		args = append(args, "-ss", formatFloat(trimRanges[0].Start))
+	}
+	args = append(args, "-i", sourceFile, "-map", fmt.Sprintf("0:%d", stream))
+	if len(trimRanges) == 1 && trimRanges[0].Duration > 0 {
+		args = append(args, "-t", formatFloat(trimRanges[0].Duration))
+	}
+	args = append(args, "-vn", "-sn", "-dn", "-filter:a", filter, "-f", "null", "-")
+
+	cmd := exec.CommandContext(ctx, fs.ffmpegPath, args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("loudness measurement failed: %w", err)
+	}
+
+	stats, err := parseLoudnormOutput(stderr.String())
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Stream = stream
+	stats.TargetI = i
+	stats.TargetTP = tp
+	stats.TargetLRA = lra
+	return stats, nil
+}
+
+// loudnormJSON is the JSON block printed by loudnorm with print_format=json
+type loudnormJSON struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	TargetOffset string `json:"target_offset"`
+}
+
+// parseLoudnormOutput extracts the loudnorm JSON stats from ffmpeg's stderr
+func parseLoudnormOutput(stderr string) (*model.LoudnessStats, error) {
+	start := strings.LastIndex(stderr, "{")
+	end := strings.LastIndex(stderr, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("loudnorm stats not found in ffmpeg output")
+	}
+
+	var raw loudnormJSON
+	if err := json.Unmarshal([]byte(stderr[start:end+1]), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse loudnorm stats: %w", err)
+	}
+
+	stats := &model.LoudnessStats{}
+	fields := []struct {
+		value string
+		dst   *float64
+	}{
+		{raw.InputI, &stats.InputI},
+		{raw.InputTP, &stats.InputTP},
+		{raw.InputLRA, &stats.InputLRA},
+		{raw.InputThresh, &stats.InputThresh},
+		{raw.TargetOffset, &stats.TargetOffset},
+	}
+	for _, f := range fields {
+		v, err := strconv.ParseFloat(strings.TrimSpace(f.value), 64)
+		// "-inf" is reported for silent input, which can't be normalized linearly
+		if err != nil || math.IsInf(v, 0) || math.IsNaN(v) {
+			return nil, fmt.Errorf("invalid loudnorm value %q", f.value)
+		}
+		*f.dst = v
+	}
+
+	return stats, nil
+}
+
+// formatFloat formats a float without trailing zeros for use in filter arguments
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// buildAudioCodecArgs builds codec/bitrate/channel arguments for one audio stream
+// spec is the type-relative output stream index ("" applies to all audio streams)
+func buildAudioCodecArgs(spec, codec, bitrate string, channels int) []string {
+	suffix := ""
+	if spec != "" {
+		suffix = ":" + spec
+	}
+	// -ac takes a full stream specifier, a bare index would count every output stream
+	channelsOption := "-ac"
+	if spec != "" {
+		channelsOption = "-ac:a:" + spec
+	}
+
+	args := []string{}
+
+	// Audio codec
+	if codec == "copy" || codec == "" {
+		args = append(args, "-c:a"+suffix, "copy")
+		return args
+	}
+
+	args = append(args, "-c:a"+suffix, codec)
+
+	// Add -strict -2 for opus encoder (experimental feature flag)
+	if codec == "opus" && spec == "" {
+		args = append(args, "-strict", "-2")
+	}
+
+	// Audio bitrate
+	if bitrate != "" {
+		args = append(args, "-b:a"+suffix, bitrate)
+	}
+
+	// Audio channels
+	if channels > 0 {
+		args = append(args, channelsOption, strconv.Itoa(channels))
+	}
+
+	return args
+}
+
+// matchAudioRule returns the first rule matching the stream,
+// or a rule built from the global audio settings if none match
+func matchAudioRule(audio *model.AudioConfig, stream ffprobe.StreamInfo) model.AudioRule {
+	for _, rule := range audio.Rules {
+		if audioRuleMatches(rule, stream) {
+			return rule
+		}
+	}
+
+	return model.AudioRule{
+		Codec:    audio.Codec,
+		Bitrate:  audio.Bitrate,
+		Channels: audio.Channels,
+	}
+}
+
+// audioRuleMatches checks the rule's match conditions against a source stream
+func audioRuleMatches(rule model.AudioRule, stream ffprobe.StreamInfo) bool {
+	if len(rule.Codecs) > 0 && !containsFold(rule.Codecs, stream.Codec) {
+		return false
+	}
+	if len(rule.Languages) > 0 && !containsFold(rule.Languages, stream.Language) {
+		return false
+	}
+	if rule.MinChannels > 0 && stream.Channels < rule.MinChannels {
+		return false
+	}
+	if rule.MaxChannels > 0 && stream.Channels > rule.MaxChannels {
+		return false
+	}
+	return true
+}
+
+// resolveAudioBitrate returns the bitrate for a stream encoded with the given rule
+// BitratePerChannel takes precedence over a fixed Bitrate
+func resolveAudioBitrate(rule model.AudioRule, stream ffprobe.StreamInfo) string {
+	if rule.Codec == "copy" || rule.BitratePerChannel == "" {
+		return rule.Bitrate
+	}
+
+	perChannel, ok := parseBitrate(rule.BitratePerChannel)
+	if !ok {
+		return rule.Bitrate
+	}
+
+	channels := rule.Channels
+	if channels <= 0 {
+		channels = stream.Channels
+	}
+	if channels <= 0 {
+		channels = 2
+	}
+
+	return fmt.Sprintf("%dk", perChannel*int64(channels)/1000)
+}
+
+// parseBitrate parses ffmpeg-style bitrate strings ("96k", "4M", "128000") into bits/s
+func parseBitrate(s string) (int64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+
+	multiplier := 1.0
+	switch s[len(s)-1] {
+	case 'k', 'K':
+		multiplier = 1000
+		s = s[:len(s)-1]
+	case 'm', 'M':
+		multiplier = 1000 * 1000
+		s = s[:len(s)-1]
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || value <= 0 {
+		return 0, false
+	}
+
+	return int64(value * multiplier), true
+}
+
+// containsFold reports whether list contains value (case-insensitive)
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
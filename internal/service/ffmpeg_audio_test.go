@@ -0,0 +1,227 @@
+package service
+
+import (
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/pkg/ffprobe"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBuildAudioArgs(t *testing.T) {
+	source := &ffprobe.VideoInfo{Streams: []ffprobe.StreamInfo{
+		{Index: 0, Type: "video", Codec: "h264"},
+		{Index: 1, Type: "audio", Codec: "truehd", Channels: 8, Language: "eng"},
+		{Index: 2, Type: "audio", Codec: "ac3", Channels: 6, Language: "ger"},
+	}}
+
+	tests := []struct {
+		name     string
+		audio    model.AudioConfig
+		unprobed bool // source streams unknown (e.g. command preview)
+		want     string
+	}{
+		{
+			name:  "single setting for all tracks",
+			audio: model.AudioConfig{Codec: "aac", Bitrate: "192k", Channels: 2},
+			want:  "-c:a aac -b:a 192k -ac 2",
+		},
+		{
+			name:  "copy",
+			audio: model.AudioConfig{Codec: "copy"},
+			want:  "-c:a copy",
+		},
+		{
+			name: "rule downmix uses audio stream specifiers",
+			audio: model.AudioConfig{Codec: "copy", Rules: []model.AudioRule{
+				{Languages: []string{"ger"}, Codec: "aac", Bitrate: "160k", Channels: 2},
+			}},
+			want: "-c:a:0 copy -c:a:1 aac -b:a:1 160k -ac:a:1 2",
+		},
+		{
+			name: "bitrate per channel",
+			audio: model.AudioConfig{Codec: "copy", Rules: []model.AudioRule{
+				{Codecs: []string{"truehd"}, Codec: "opus", BitratePerChannel: "64k"},
+			}},
+			want: "-c:a:0 opus -b:a:0 512k -c:a:1 copy -strict -2",
+		},
+		{
+			name:  "stereo compatibility tracks",
+			audio: model.AudioConfig{Codec: "copy", StereoCompat: true},
+			want: "-c:a:0 copy -c:a:1 copy" +
+				" -map 0:a:0 -c:a:2 aac -b:a:2 192k -ac:a:2 2 -metadata:s:a:2 title=Stereo -metadata:s:a:2 language=eng -disposition:a:2 0" +
+				" -map 0:a:1 -c:a:3 aac -b:a:3 192k -ac:a:3 2 -metadata:s:a:3 title=Stereo -metadata:s:a:3 language=ger -disposition:a:3 0",
+		},
+		{
+			name:     "rules without a probed source",
+			audio:    model.AudioConfig{Codec: "aac", Rules: []model.AudioRule{{Codec: "opus"}}},
+			unprobed: true,
+			want:     "-c:a aac",
+		},
+	}
+
+	fs := &FFmpegService{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := source
+			if tt.unprobed {
+				info = nil
+			}
+			got := strings.Join(fs.buildAudioArgs(&tt.audio, info, nil, ""), " ")
+			if got != tt.want {
+				t.Errorf("buildAudioArgs()\n got: %s\nwant: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildAudioCodecArgs(t *testing.T) {
+	tests := []struct {
+		spec     string
+		codec    string
+		bitrate  string
+		channels int
+		want     []string
+	}{
+		{"", "aac", "128k", 2, []string{"-c:a", "aac", "-b:a", "128k", "-ac", "2"}},
+		{"", "opus", "", 0, []string{"-c:a", "opus", "-strict", "-2"}},
+		{"0", "aac", "", 6, []string{"-c:a:0", "aac", "-ac:a:0", "6"}},
+		{"3", "", "128k", 2, []string{"-c:a:3", "copy"}},
+	}
+
+	for _, tt := range tests {
+		got := buildAudioCodecArgs(tt.spec, tt.codec, tt.bitrate, tt.channels)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("buildAudioCodecArgs(%q, %q, %q, %d) = %v, want %v", tt.spec, tt.codec, tt.bitrate, tt.channels, got, tt.want)
+		}
+	}
+}
+
+func TestBuildAudioArgsLoudness(t *testing.T) {
+	source := &ffprobe.VideoInfo{Streams: []ffprobe.StreamInfo{
+		{Index: 0, Type: "video", Codec: "h264"},
+		{Index: 1, Type: "audio", Codec: "ac3", Channels: 6},
+		{Index: 2, Type: "audio", Codec: "aac", Channels: 2},
+	}}
+	audio := &model.AudioConfig{Codec: "aac", Loudnorm: &model.LoudnormConfig{Enabled: true, Preset: "ebu"}}
+	measured := []model.LoudnessStats{
+		{Stream: 2, InputI: -30, InputTP: -5, InputLRA: 8, InputThresh: -40, TargetOffset: 0.5},
+	}
+
+	fs := &FFmpegService{}
+	got := strings.Join(fs.buildAudioArgs(audio, source, measured, ""), " ")
+	want := "-c:a:0 aac -filter:a:0 loudnorm=I=-23:TP=-1:LRA=7 -ar:a:0 48000" +
+		" -c:a:1 aac -filter:a:1 loudnorm=I=-23:TP=-1:LRA=7:measured_I=-30:measured_TP=-5:measured_LRA=8:measured_thresh=-40:offset=0.5:linear=true -ar:a:1 48000"
+	if got != want {
+		t.Errorf("buildAudioArgs()\n got: %s\nwant: %s", got, want)
+	}
+
+	// Without measurements every track shares the single-pass filter
+	got = strings.Join(fs.buildAudioArgs(audio, source, nil, ""), " ")
+	want = "-c:a aac -filter:a loudnorm=I=-23:TP=-1:LRA=7 -ar 48000"
+	if got != want {
+		t.Errorf("buildAudioArgs() without measurements\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestLoudnessTracks(t *testing.T) {
+	source := &ffprobe.VideoInfo{Streams: []ffprobe.StreamInfo{
+		{Index: 0, Type: "video", Codec: "h264"},
+		{Index: 1, Type: "audio", Codec: "truehd", Channels: 8, Language: "eng"},
+		{Index: 2, Type: "audio", Codec: "aac", Channels: 2, Language: "eng"},
+		{Index: 3, Type: "audio", Codec: "ac3", Channels: 6, Language: "ger"},
+	}}
+
+	flac := model.AudioConfig{Codec: "flac"}
+	tests := []struct {
+		name   string
+		config model.TranscodeConfig
+		want   []int
+	}{
+		{"all re-encoded", model.TranscodeConfig{Audio: model.AudioConfig{Codec: "opus"}}, []int{1, 2, 3}},
+		{"copy", model.TranscodeConfig{Audio: model.AudioConfig{Codec: "copy"}}, nil},
+		{"stereo compatibility sources", model.TranscodeConfig{Audio: model.AudioConfig{Codec: "copy", StereoCompat: true}}, []int{1, 3}},
+		{"rules", model.TranscodeConfig{Audio: model.AudioConfig{Codec: "copy", Rules: []model.AudioRule{{Languages: []string{"ger"}, Codec: "aac"}}}}, []int{3}},
+		{
+			name:   "audio task track",
+			config: model.TranscodeConfig{Mode: ModeAudio, Audio: flac, Output: model.OutputConfig{Container: "flac"}, Streams: &model.StreamSelection{AudioTracks: []int{3}}},
+			want:   []int{3},
+		},
+		{
+			name:   "audio task language",
+			config: model.TranscodeConfig{Mode: ModeAudio, Audio: flac, Output: model.OutputConfig{Container: "mka"}, Streams: &model.StreamSelection{AudioLanguages: []string{"eng"}}},
+			want:   []int{1, 2},
+		},
+		{
+			name:   "single-track audio container",
+			config: model.TranscodeConfig{Mode: ModeAudio, Audio: flac, Output: model.OutputConfig{Container: "flac"}, Streams: &model.StreamSelection{AudioLanguages: []string{"eng"}}},
+			want:   []int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for _, stream := range loudnessTracks(&tt.config, source) {
+				got = append(got, stream.Index)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("loudnessTracks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLoudnormOutput(t *testing.T) {
+	block := func(i, thresh string) string {
+		return `[Parsed_loudnorm_0 @ 0x55d0c8a3f2c0]
+{
+	"input_i" : "` + i + `",
+	"input_tp" : "-4.47",
+	"input_lra" : "6.20",
+	"input_thresh" : "` + thresh + `",
+	"output_i" : "-23.02",
+	"output_tp" : "-1.00",
+	"output_lra" : "5.80",
+	"output_thresh" : "-33.21",
+	"normalization_type" : "dynamic",
+	"target_offset" : "0.02"
+}
+`
+	}
+
+	tests := []struct {
+		name    string
+		stderr  string
+		want    *model.LoudnessStats
+		wantErr bool
+	}{
+		{
+			name:   "stats",
+			stderr: "size=N/A time=00:10:00.00 bitrate=N/A speed= 512x\n" + block("-27.61", "-37.93"),
+			want:   &model.LoudnessStats{InputI: -27.61, InputTP: -4.47, InputLRA: 6.2, InputThresh: -37.93, TargetOffset: 0.02},
+		},
+		{name: "silence", stderr: block("-inf", "-inf"), wantErr: true},
+		{name: "no stats", stderr: "Output file is empty, nothing was encoded\n", wantErr: true},
+		{name: "truncated", stderr: `{"input_i" : "-27.61", "input_tp" : "-4.47"`, wantErr: true},
+		{name: "not a number", stderr: block("nan", "-37.93"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLoudnormOutput(tt.stderr)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseLoudnormOutput() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseLoudnormOutput() error: %v", err)
+			}
+			if *got != *tt.want {
+				t.Errorf("parseLoudnormOutput() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
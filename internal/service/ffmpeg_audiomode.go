@@ -0,0 +1,175 @@
+package service
+
+import (
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/pkg/ffprobe"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ModeAudio extracts or converts the audio tracks of the source into an audio file
+const ModeAudio = "audio"
+
+// audioContainers are the output containers of audio tasks
+var audioContainers = []string{"flac", "mp3", "m4a", "opus", "ogg", "mka"}
+
+// AudioMode reports whether the config is an audio task (no video output)
+func AudioMode(config *model.TranscodeConfig) bool {
+	return config.Mode == ModeAudio
+}
+
+// validateAudioMode checks the output of an audio task and the features that need video
+func validateAudioMode(config *model.TranscodeConfig) error {
+	if Packaged(config) {
+		return fmt.Errorf("HLS/DASH packages are not supported for audio tasks")
+	}
+	if config.Output.PathType == "overwrite" {
+		return fmt.Errorf("audio tasks write a new audio file and can't overwrite the source file")
+	}
+	container := outputContainer(&config.Output)
+	if !slices.Contains(audioContainers, container) {
+		return fmt.Errorf("%s is not an audio container (audio tasks write %s)", container, strings.Join(audioContainers, ", "))
+	}
+	if config.Audio.StereoCompat {
+		return fmt.Errorf("stereo compatibility tracks are not supported for audio tasks")
+	}
+	if config.Chunking != nil && config.Chunking.Enabled {
+		return fmt.Errorf("chunked encoding is not supported for audio tasks")
+	}
+	if QualityEnabled(config) {
+		return fmt.Errorf("quality scoring compares video and is not supported for audio tasks")
+	}
+	if ThumbnailsEnabled(config) {
+		return fmt.Errorf("thumbnails need video and are not supported for audio tasks")
+	}
+	return validateStreamSelection(config.Streams)
+}
+
+// CheckSource rejects sources a config can't work with:
+// encodes need a video track, audio tasks an audio track
+func CheckSource(config *model.TranscodeConfig, sourceVideoInfo *ffprobe.VideoInfo) error {
+	if (config.Mode == "advanced" && config.CustomCommand != "") || RemuxMode(config) || sourceVideoInfo == nil {
+		return nil
+	}
+	if AudioMode(config) {
+		if len(sourceVideoInfo.StreamsOfType("audio")) == 0 {
+			return fmt.Errorf("source has no audio track")
+		}
+		return nil
+	}
+	if !sourceVideoInfo.HasVideo() {
+		return fmt.Errorf("source has no video track (use an audio task for audio files)")
+	}
+	return nil
+}
+
+// audioTaskStreams returns the source audio tracks an audio task keeps and the cover art it carries over
+// Single-track containers keep the first selected track; cover art is the first attached picture.
+func audioTaskStreams(config *model.TranscodeConfig, sourceVideoInfo *ffprobe.VideoInfo) (audio, covers []ffprobe.StreamInfo) {
+	selected := selectStreams(config, sourceVideoInfo)
+	if selected == nil {
+		return nil, nil
+	}
+
+	support := containerSupports[outputContainer(&config.Output)]
+	for _, stream := range selected.Streams {
+		switch {
+		case stream.Type == "audio":
+			if support.singleAudio && len(audio) > 0 {
+				continue
+			}
+			audio = append(audio, stream)
+		case stream.Type == "video" && stream.AttachedPic && support.coverArt && len(covers) == 0 && codecAllowed(support.video, stream.Codec):
+			covers = append(covers, stream)
+		}
+	}
+	return audio, covers
+}
+
+// buildAudioModeArgs builds the arguments of an audio task: the kept audio tracks are
+// encoded with the audio settings (or copied), metadata and cover art are carried over.
+// Trims work as in simple mode; several segments are joined by the audio trim graph.
+func (fs *FFmpegService) buildAudioModeArgs(sourceFile string, config *model.TranscodeConfig, sourceVideoInfo *ffprobe.VideoInfo, opts *EncodeOptions) ([]string, []string) {
+	container := outputContainer(&config.Output)
+	support := containerSupports[container]
+	compat := CheckCompatibility(config, sourceVideoInfo)
+	probed := sourceVideoInfo != nil && len(sourceVideoInfo.Streams) > 0
+
+	trimRanges := TrimRanges(config, durationOf(sourceVideoInfo))
+	trimFilter := ""
+	if len(trimRanges) > 1 {
+		trimFilter = buildTrimFilter(trimRanges, "a")
+	}
+
+	// No hardware acceleration: only audio is decoded
+	inputArgs := []string{}
+	if opts.Sample != nil && opts.Sample.Start > 0 {
+		inputArgs = append(inputArgs, "-ss", formatFloat(opts.Sample.Start))
+	} else if len(trimRanges) == 1 && trimRanges[0].Start > 0 {
+		inputArgs = append(inputArgs, "-ss", formatFloat(trimRanges[0].Start))
+	}
+	inputArgs = append(inputArgs, "-i", sourceFile)
+
+	args := []string{}
+	var audioSource *ffprobe.VideoInfo
+	var covers []ffprobe.StreamInfo
+	if probed {
+		// Explicit maps in source order, the audio tracks come first
+		var audio []ffprobe.StreamInfo
+		audio, covers = audioTaskStreams(config, sourceVideoInfo)
+		for _, stream := range audio {
+			args = append(args, "-map", fmt.Sprintf("0:%d", stream.Index))
+		}
+		for _, stream := range covers {
+			args = append(args, "-map", fmt.Sprintf("0:%d", stream.Index))
+		}
+		audioSource = &ffprobe.VideoInfo{Duration: sourceVideoInfo.Duration, Streams: audio}
+	} else if support.singleAudio {
+		// Source unknown (e.g. command preview): the first audio track
+		args = append(args, "-map", "0:a:0")
+	} else {
+		args = append(args, "-map", "0:a")
+	}
+
+	if opts.Sample != nil && opts.Sample.Duration > 0 {
+		args = append(args, "-t", formatFloat(opts.Sample.Duration))
+	} else if len(trimRanges) == 1 && trimRanges[0].Duration > 0 {
+		args = append(args, "-t", formatFloat(trimRanges[0].Duration))
+	}
+
+	// Chapters can't be cut by the filter graph
+	if len(trimRanges) > 1 {
+		args = append(args, "-map_chapters", "-1")
+	}
+
+	// Tags (title, artist, album, ...) are kept
+	args = append(args, "-map_metadata", "0")
+
+	args = append(args, fs.buildAudioArgs(&config.Audio, audioSource, opts.Loudness, trimFilter)...)
+
+	// Audio the container can't hold is converted (auto compatibility policy)
+	args = append(args, compat.audioFixArgs(audioSource)...)
+	if compat.fixes() && compat.needsExperimental() {
+		args = append(args, "-strict", "experimental")
+	}
+
+	if len(covers) > 0 {
+		// Cover art is copied and marked as the attached picture
+		args = append(args, "-c:v", "copy", "-disposition:v:0", "attached_pic")
+	}
+
+	switch container {
+	case "mp3":
+		// ID3v2.3 is what most players and tag editors read
+		args = append(args, "-id3v2_version", "3")
+	case "m4a":
+		args = append(args, "-movflags", "+faststart")
+	}
+
+	if config.ExtraParams != "" {
+		args = append(args, parseExtraParams(config.ExtraParams)...)
+	}
+
+	return inputArgs, args
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/pkg/ffprobe"
+	"fmt"
+	"runtime"
+)
+
+// Chunked encoding defaults (ChunkConfig)
+const (
+	defaultChunkDuration = 120
+	defaultChunkRetries  = 2
+	minChunkParallel     = 2
+)
+
+// ChunkRange is a segment of the source encoded on its own
+type ChunkRange struct {
+	Start    float64 // Seconds from the start of the source (a keyframe)
+	Duration float64 // Seconds, 0 = until the end of the source
+}
+
+// ChunkingEnabled reports whether a config uses chunked parallel encoding
+func ChunkingEnabled(config *model.TranscodeConfig) bool {
+	if (config.Mode == "advanced" && config.CustomCommand != "") || RemuxMode(config) || AudioMode(config) {
+		return false
+	}
+	return config.Chunking != nil && config.Chunking.Enabled
+}
+
+// ChunkSettings returns the segment duration, parallelism and retries with defaults applied
+func ChunkSettings(config *model.TranscodeConfig) (duration float64, parallel, retries int) {
+	duration = defaultChunkDuration
+	parallel = max(runtime.NumCPU()/8, minChunkParallel)
+	retries = defaultChunkRetries
+
+	if chunking := config.Chunking; chunking != nil {
+		if chunking.Duration > 0 {
+			duration = float64(chunking.Duration)
+		}
+		if chunking.Parallel > 0 {
+			parallel = chunking.Parallel
+		}
+		if chunking.Retries > 0 {
+			retries = chunking.Retries
+		}
+	}
+
+	return duration, parallel, retries
+}
+
+// validateChunking checks the settings chunked encoding can't be combined with
+func validateChunking(config *model.TranscodeConfig) error {
+	if !ChunkingEnabled(config) {
+		return nil
+	}
+
+	// Segments start at 0, the subtitle timing wouldn't match
+	if config.Subtitle.Mode == "burn" {
+		return fmt.Errorf("subtitle burn-in is not supported with chunked encoding")
+	}
+	if rateControlMode(&config.Video) == RateControlVBR2Pass {
+		return fmt.Errorf("vbr-2pass rate control is not supported with chunked encoding (use cbr or target-size)")
+	}
+	return nil
+}
+
+// ProbeKeyframes returns the keyframe timestamps of the first video stream
+func (fs *FFmpegService) ProbeKeyframes(ctx context.Context, filePath string) ([]float64, error) {
+	return ffprobe.Keyframes(ctx, fs.ffprobePath, filePath)
+}
+
+// PlanChunks splits the source at keyframes into segments of at least targetDuration seconds
+// The last segment runs until the end; a short tail is merged into the previous segment.
+// Keyframes usually sit on scene cuts, so segment borders rarely fall inside a shot.
+func PlanChunks(keyframes []float64, totalDuration, targetDuration float64) []ChunkRange {
+	chunks := []ChunkRange{}
+	start := 0.0
+
+	for _, keyframe := range keyframes {
+		if keyframe-start < targetDuration {
+			continue
+		}
+		// Leave at least half a segment for the rest
+		if totalDuration > 0 && totalDuration-keyframe < targetDuration/2 {
+			break
+		}
+		chunks = append(chunks, ChunkRange{Start: start, Duration: keyframe - start})
+		start = keyframe
+	}
+
+	return append(chunks, ChunkRange{Start: start})
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"ffmpeg-web/internal/model"
+	"slices"
+	"testing"
+)
+
+func TestPlanChunks(t *testing.T) {
+	tests := []struct {
+		name      string
+		keyframes []float64
+		total     float64
+		target    float64
+		want      []ChunkRange
+	}{
+		{"no keyframes", nil, 600, 120, []ChunkRange{{Start: 0}}},
+		{"shorter than a segment", []float64{0, 2, 4}, 60, 120, []ChunkRange{{Start: 0}}},
+		{
+			name:      "regular keyframes",
+			keyframes: []float64{0, 60, 120, 180, 240, 300},
+			total:     360,
+			target:    120,
+			want:      []ChunkRange{{Start: 0, Duration: 120}, {Start: 120, Duration: 120}, {Start: 240}},
+		},
+		{
+			name:      "segments start at the next keyframe",
+			keyframes: []float64{0, 50, 125, 190, 260, 330},
+			total:     400,
+			target:    120,
+			want:      []ChunkRange{{Start: 0, Duration: 125}, {Start: 125, Duration: 135}, {Start: 260}},
+		},
+		{
+			name:      "short tail merged",
+			keyframes: []float64{0, 120, 240},
+			total:     280,
+			target:    120,
+			want:      []ChunkRange{{Start: 0, Duration: 120}, {Start: 120}},
+		},
+		{
+			name:      "unknown duration",
+			keyframes: []float64{0, 120, 240},
+			total:     0,
+			target:    120,
+			want:      []ChunkRange{{Start: 0, Duration: 120}, {Start: 120, Duration: 120}, {Start: 240}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PlanChunks(tt.keyframes, tt.total, tt.target)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("PlanChunks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunkSettings(t *testing.T) {
+	config := &model.TranscodeConfig{Chunking: &model.ChunkConfig{Enabled: true, Duration: 60, Parallel: 3, Retries: 5}}
+	duration, parallel, retries := ChunkSettings(config)
+	if duration != 60 || parallel != 3 || retries != 5 {
+		t.Errorf("ChunkSettings() = %v, %d, %d; want 60, 3, 5", duration, parallel, retries)
+	}
+
+	duration, parallel, retries = ChunkSettings(&model.TranscodeConfig{})
+	if duration != defaultChunkDuration || parallel < minChunkParallel || retries != defaultChunkRetries {
+		t.Errorf("ChunkSettings() defaults = %v, %d, %d", duration, parallel, retries)
+	}
+}
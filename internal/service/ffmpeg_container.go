@@ -0,0 +1,510 @@
+package service
+
+import (
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/pkg/ffprobe"
+	"fmt"
+	"strings"
+)
+
+// Container compatibility policies (OutputConfig.Compatibility)
+const (
+	CompatibilityAuto   = "auto"   // Convert incompatible streams, drop the ones that can't be converted
+	CompatibilityStrict = "strict" // Refuse to start the task when any stream is incompatible
+	CompatibilityWarn   = "warn"   // Only report problems, build the command as configured
+)
+
+// Actions taken for incompatible streams
+const (
+	CompatActionConvert      = "convert"      // Re-encoded to a codec the container supports
+	CompatActionDrop         = "drop"         // Removed from the output
+	CompatActionExperimental = "experimental" // Kept, muxed with -strict experimental
+	CompatActionNone         = "none"         // Can't be fixed, ffmpeg would fail
+)
+
+// containerSupport describes which streams a container can hold
+// nil codec lists mean any codec is accepted
+type containerSupport struct {
+	video []string
+	audio []string
+	// experimentalAudio can only be muxed with -strict experimental (older ffmpeg builds)
+	experimentalAudio []string
+	// audioFallback is the codec incompatible audio streams are converted to
+	audioFallback string
+	// textSubtitles lists the muxable text subtitle codecs, the first one is the conversion target
+	textSubtitles  []string
+	imageSubtitles bool
+	attachments    bool
+	data           bool
+	// singleAudio containers hold one audio track, audio tasks keep the first selected one
+	singleAudio bool
+	// coverArt containers carry embedded cover art (an attached picture video stream)
+	coverArt bool
+}
+
+var mp4Support = containerSupport{
+	video:             []string{"hevc", "h264", "av1", "vp9", "mpeg4", "mpeg2video", "mjpeg", "png"},
+	audio:             []string{"aac", "mp3", "ac3", "eac3", "alac", "dts", "mp2"},
+	experimentalAudio: []string{"opus", "flac", "truehd"},
+	audioFallback:     "aac",
+	textSubtitles:     []string{"mov_text"},
+	data:              true,
+}
+
+// containerSupports maps output containers to their stream support
+// Containers not listed here are not validated
+var containerSupports = map[string]containerSupport{
+	"mp4": mp4Support,
+	"m4v": mp4Support,
+	"mov": {
+		video:             append([]string{"prores"}, mp4Support.video...),
+		audio:             append([]string{"pcm_s16le", "pcm_s24le", "pcm_s32le", "pcm_f32le"}, mp4Support.audio...),
+		experimentalAudio: mp4Support.experimentalAudio,
+		audioFallback:     "aac",
+		textSubtitles:     []string{"mov_text"},
+		data:              true,
+	},
+	"mkv": {
+		textSubtitles:  []string{"subrip", "ass", "ssa", "webvtt", "text"},
+		imageSubtitles: true,
+		attachments:    true,
+	},
+	"webm": {
+		video:         []string{"vp8", "vp9", "av1"},
+		audio:         []string{"opus", "vorbis"},
+		audioFallback: "opus",
+		textSubtitles: []string{"webvtt"},
+	},
+	// MPEG-TS, also the segment format of HLS packages with ts segments
+	"ts": {
+		video:         []string{"h264", "hevc", "mpeg2video"},
+		audio:         []string{"aac", "mp3", "ac3", "eac3", "mp2"},
+		audioFallback: "aac",
+		textSubtitles: []string{}, // No text subtitle codec, they are dropped
+		data:          true,
+	},
+	// Audio containers (audio tasks), video streams are cover art only
+	"flac": {
+		video:         coverArtCodecs,
+		audio:         []string{"flac"},
+		audioFallback: "flac",
+		textSubtitles: []string{},
+		singleAudio:   true,
+		coverArt:      true,
+	},
+	"mp3": {
+		video:         coverArtCodecs,
+		audio:         []string{"mp3"},
+		audioFallback: "mp3",
+		textSubtitles: []string{},
+		singleAudio:   true,
+		coverArt:      true,
+	},
+	"m4a": {
+		video:         coverArtCodecs,
+		audio:         []string{"aac", "alac"},
+		audioFallback: "aac",
+		textSubtitles: []string{},
+		singleAudio:   true,
+		coverArt:      true,
+	},
+	"opus": {
+		video:         []string{},
+		audio:         []string{"opus"},
+		audioFallback: "libopus",
+		textSubtitles: []string{},
+		singleAudio:   true,
+	},
+	"ogg": {
+		video:         []string{},
+		audio:         []string{"vorbis", "opus", "flac"},
+		audioFallback: "libopus",
+		textSubtitles: []string{},
+		singleAudio:   true,
+	},
+	"mka": {
+		video:       []string{},
+		attachments: true,
+	},
+}
+
+// coverArtCodecs are the picture codecs of embedded cover art
+var coverArtCodecs = []string{"mjpeg", "png"}
+
+// videoCodecNames maps the configured encoder to the output codec name
+var videoCodecNames = map[string]string{
+	"":       "hevc",
+	"h265":   "hevc",
+	"hevc":   "hevc",
+	"av1":    "av1",
+	"h264":   "h264",
+	"avc":    "h264",
+	"vp9":    "vp9",
+	"prores": "prores",
+}
+
+// audioCodecNames maps configured audio encoders to output codec names
+var audioCodecNames = map[string]string{
+	"aac":        "aac",
+	"opus":       "opus",
+	"libopus":    "opus",
+	"mp3":        "mp3",
+	"libmp3lame": "mp3",
+	"flac":       "flac",
+	"alac":       "alac",
+	"libvorbis":  "vorbis",
+}
+
+// CompatibilityIssue describes a stream the output container can't hold as configured
+type CompatibilityIssue struct {
+	Stream  int    `json:"stream"` // Source stream index (-1 for streams added by the encode)
+	Type    string `json:"type"`   // video, audio, subtitle, attachment, data
+	Codec   string `json:"codec"`  // Output codec as configured
+	Action  string `json:"action"` // convert, drop, experimental, none
+	Target  string `json:"target,omitempty"`
+	Message string `json:"message"`
+
+	// outputIndex is the type-relative output stream index (audio conversions)
+	outputIndex int
+}
+
+// CompatibilityReport is the result of checking a transcode against its output container
+type CompatibilityReport struct {
+	Container string               `json:"container"`
+	Policy    string               `json:"policy"`
+	Issues    []CompatibilityIssue `json:"issues,omitempty"`
+}
+
+// Warnings returns a human readable message per issue
+func (r *CompatibilityReport) Warnings() []string {
+	warnings := make([]string, 0, len(r.Issues))
+	for _, issue := range r.Issues {
+		warnings = append(warnings, issue.Message)
+	}
+	return warnings
+}
+
+// Err returns an error when the task must not be started
+// Strict policy rejects any issue; other policies only reject issues that can't be fixed
+func (r *CompatibilityReport) Err() error {
+	for _, issue := range r.Issues {
+		if r.Policy == CompatibilityStrict || (r.Policy == CompatibilityAuto && issue.Action == CompatActionNone) {
+			return fmt.Errorf("incompatible with %s container: %s", r.Container, issue.Message)
+		}
+	}
+	return nil
+}
+
+// fixes reports whether incompatible streams are fixed in the generated command
+func (r *CompatibilityReport) fixes() bool {
+	return r.Policy == CompatibilityAuto
+}
+
+// needsExperimental reports whether the output needs -strict experimental
+func (r *CompatibilityReport) needsExperimental() bool {
+	for _, issue := range r.Issues {
+		if issue.Action == CompatActionExperimental {
+			return true
+		}
+	}
+	return false
+}
+
+// compatibilityPolicy returns the effective policy (auto by default)
+func compatibilityPolicy(output *model.OutputConfig) string {
+	switch output.Compatibility {
+	case CompatibilityStrict, CompatibilityWarn:
+		return output.Compatibility
+	default:
+		return CompatibilityAuto
+	}
+}
+
+// CheckCompatibility validates the configured output codecs and the probed source streams
+// against the output container. sourceVideoInfo can be nil (only the configured codecs are checked).
+func CheckCompatibility(config *model.TranscodeConfig, sourceVideoInfo *ffprobe.VideoInfo) *CompatibilityReport {
+	container := outputContainer(&config.Output)
+	report := &CompatibilityReport{
+		Container: container,
+		Policy:    compatibilityPolicy(&config.Output),
+	}
+
+	// Custom commands are passed through untouched
+	if config.Mode == "advanced" && config.CustomCommand != "" {
+		return report
+	}
+
+	support, ok := containerSupports[container]
+	if !ok {
+		return report
+	}
+
+	// Remuxes and audio tasks only carry the selected streams, remuxes copy all of them
+	remux, audioTask := RemuxMode(config), AudioMode(config)
+	if remux || audioTask {
+		sourceVideoInfo = selectStreams(config, sourceVideoInfo)
+	}
+
+	// Video (re-encoded with the configured encoder, remuxes check the source video below)
+	if videoCodec, ok := videoCodecNames[config.Encoder]; ok && !remux && !audioTask && !codecAllowed(support.video, videoCodec) {
+		report.Issues = append(report.Issues, CompatibilityIssue{
+			Stream:  -1,
+			Type:    "video",
+			Codec:   videoCodec,
+			Action:  CompatActionNone,
+			Message: fmt.Sprintf("%s video can't be stored in %s", videoCodec, container),
+		})
+	}
+
+	// Streams are unknown without a probe (the preview may pass HDR-only info)
+	probed := sourceVideoInfo != nil && len(sourceVideoInfo.Streams) > 0
+
+	if remux && probed {
+		for _, stream := range sourceVideoInfo.StreamsOfType("video") {
+			if !codecAllowed(support.video, stream.Codec) {
+				report.Issues = append(report.Issues, CompatibilityIssue{
+					Stream:  stream.Index,
+					Type:    "video",
+					Codec:   stream.Codec,
+					Action:  CompatActionNone,
+					Message: fmt.Sprintf("Stream #%d: %s video can't be stored in %s without re-encoding", stream.Index, stream.Codec, container),
+				})
+			}
+		}
+	}
+
+	if !probed {
+		// Source unknown: check the configured global codec only (remuxes copy whatever audio there is)
+		if codec, ok := audioCodecNames[config.Audio.Codec]; ok && !remux {
+			report.checkAudio(support, -1, -1, codec)
+		}
+	} else {
+		audioStreams := sourceVideoInfo.StreamsOfType("audio")
+		if Packaged(config) && len(audioStreams) > 1 {
+			// HLS/DASH packages only carry the first audio track
+			audioStreams = audioStreams[:1]
+		}
+		if audioTask && support.singleAudio && len(audioStreams) > 1 {
+			for _, stream := range audioStreams[1:] {
+				report.Issues = append(report.Issues, CompatibilityIssue{
+					Stream:  stream.Index,
+					Type:    "audio",
+					Codec:   stream.Codec,
+					Action:  CompatActionDrop,
+					Message: fmt.Sprintf("Stream #%d: %s holds a single audio track, only the first selected track is kept", stream.Index, container),
+				})
+			}
+			audioStreams = audioStreams[:1]
+		}
+		for i, stream := range audioStreams {
+			rule := matchAudioRule(&config.Audio, stream)
+			codec := stream.Codec
+			if rule.Codec != "" && rule.Codec != "copy" && !remux {
+				codec = audioCodecNames[rule.Codec]
+				if codec == "" {
+					codec = rule.Codec
+				}
+			}
+			report.checkAudio(support, stream.Index, i, codec)
+		}
+
+		// Stereo compatibility tracks are AAC, appended after the originals
+		if config.Audio.StereoCompat && !remux {
+			outputIndex := len(audioStreams)
+			for _, stream := range audioStreams {
+				if stream.Channels <= 2 {
+					continue
+				}
+				report.checkAudio(support, -1, outputIndex, "aac")
+				outputIndex++
+			}
+		}
+	}
+
+	// Cover art of audio tasks is kept when the container can embed it
+	if audioTask && probed && !support.coverArt {
+		for _, stream := range sourceVideoInfo.StreamsOfType("video") {
+			if !stream.AttachedPic {
+				continue
+			}
+			report.Issues = append(report.Issues, CompatibilityIssue{
+				Stream:  stream.Index,
+				Type:    "video",
+				Codec:   stream.Codec,
+				Action:  CompatActionDrop,
+				Message: fmt.Sprintf("Stream #%d: cover art can't be embedded in %s and will be dropped", stream.Index, container),
+			})
+			break
+		}
+	}
+
+	// Subtitles, attachments and data streams aren't packaged for HLS/DASH
+	// and audio tasks only write audio
+	if !probed || Packaged(config) || audioTask {
+		return report
+	}
+
+	// Subtitles left as-is by the subtitle mode must fit the container
+	if mode := config.Subtitle.Mode; mode == "" || mode == "copy" {
+		for _, stream := range sourceVideoInfo.StreamsOfType("subtitle") {
+			target := subtitleTarget(stream.Codec, container, "")
+			switch target {
+			case "copy":
+				continue
+			case "":
+				report.Issues = append(report.Issues, CompatibilityIssue{
+					Stream:  stream.Index,
+					Type:    "subtitle",
+					Codec:   stream.Codec,
+					Action:  CompatActionDrop,
+					Message: fmt.Sprintf("Stream #%d: %s subtitles can't be stored in %s and will be dropped", stream.Index, stream.Codec, container),
+				})
+			default:
+				report.Issues = append(report.Issues, CompatibilityIssue{
+					Stream:  stream.Index,
+					Type:    "subtitle",
+					Codec:   stream.Codec,
+					Action:  CompatActionConvert,
+					Target:  target,
+					Message: fmt.Sprintf("Stream #%d: %s subtitles will be converted to %s for %s", stream.Index, stream.Codec, target, container),
+				})
+			}
+		}
+	}
+
+	if !support.attachments {
+		for _, stream := range sourceVideoInfo.StreamsOfType("attachment") {
+			report.Issues = append(report.Issues, CompatibilityIssue{
+				Stream:  stream.Index,
+				Type:    "attachment",
+				Codec:   stream.Codec,
+				Action:  CompatActionDrop,
+				Message: fmt.Sprintf("Stream #%d: attachments (fonts) can't be stored in %s and will be dropped", stream.Index, container),
+			})
+		}
+	}
+
+	if !support.data {
+		for _, stream := range sourceVideoInfo.StreamsOfType("data") {
+			report.Issues = append(report.Issues, CompatibilityIssue{
+				Stream:  stream.Index,
+				Type:    "data",
+				Codec:   stream.Codec,
+				Action:  CompatActionDrop,
+				Message: fmt.Sprintf("Stream #%d: data streams can't be stored in %s and will be dropped", stream.Index, container),
+			})
+		}
+	}
+
+	return report
+}
+
+// checkAudio adds an issue if an output audio codec doesn't fit the container
+// outputIndex is the type-relative output index (-1 when the source streams are unknown)
+func (r *CompatibilityReport) checkAudio(support containerSupport, streamIndex, outputIndex int, codec string) {
+	if codec == "" || codecAllowed(support.audio, codec) {
+		return
+	}
+
+	stream := "Audio"
+	if streamIndex >= 0 {
+		stream = fmt.Sprintf("Stream #%d", streamIndex)
+	}
+
+	issue := CompatibilityIssue{
+		Stream:      streamIndex,
+		Type:        "audio",
+		Codec:       codec,
+		outputIndex: outputIndex,
+	}
+
+	switch {
+	case support.experimentalAudio != nil && codecAllowed(support.experimentalAudio, codec):
+		issue.Action = CompatActionExperimental
+		issue.Message = fmt.Sprintf("%s: %s audio in %s needs -strict experimental on older ffmpeg builds", stream, codec, r.Container)
+	case outputIndex >= 0 && support.audioFallback != "":
+		issue.Action = CompatActionConvert
+		issue.Target = support.audioFallback
+		issue.Message = fmt.Sprintf("%s: %s audio can't be stored in %s and will be converted to %s", stream, codec, r.Container, support.audioFallback)
+	default:
+		issue.Action = CompatActionNone
+		issue.Message = fmt.Sprintf("%s: %s audio can't be stored in %s", stream, codec, r.Container)
+	}
+
+	r.Issues = append(r.Issues, issue)
+}
+
+// audioFixArgs returns per-stream codec overrides for converted audio streams
+// They come after the regular audio arguments, the most specific -c:a:N wins
+func (r *CompatibilityReport) audioFixArgs(sourceVideoInfo *ffprobe.VideoInfo) []string {
+	args := []string{}
+	if !r.fixes() {
+		return args
+	}
+
+	channels := map[int]int{}
+	if sourceVideoInfo != nil {
+		for _, stream := range sourceVideoInfo.Streams {
+			channels[stream.Index] = stream.Channels
+		}
+	}
+
+	for _, issue := range r.Issues {
+		if issue.Type != "audio" || issue.Action != CompatActionConvert {
+			continue
+		}
+		spec := fmt.Sprintf("%d", issue.outputIndex)
+		args = append(args, "-c:a:"+spec, issue.Target, "-b:a:"+spec, compatAudioBitrate(channels[issue.Stream]))
+	}
+	return args
+}
+
+// compatAudioBitrate picks a bitrate for converted audio based on the channel count
+func compatAudioBitrate(channels int) string {
+	if channels <= 2 {
+		return defaultStereoCompatBitrate
+	}
+	return fmt.Sprintf("%dk", channels*80)
+}
+
+// streamMapArgs returns negative -map arguments for stream types the container can't hold
+func (r *CompatibilityReport) streamMapArgs() []string {
+	args := []string{}
+	if !r.fixes() {
+		return args
+	}
+
+	dropped := map[string]bool{}
+	for _, issue := range r.Issues {
+		if issue.Action == CompatActionDrop && (issue.Type == "attachment" || issue.Type == "data") {
+			dropped[issue.Type] = true
+		}
+	}
+	if dropped["attachment"] {
+		args = append(args, "-map", "-0:t")
+	}
+	if dropped["data"] {
+		args = append(args, "-map", "-0:d")
+	}
+	return args
+}
+
+// containerSupportsAttachments checks if a container can hold attachment streams
+// Unknown containers are assumed to (ffmpeg reports the error if not)
+func containerSupportsAttachments(container string) bool {
+	support, ok := containerSupports[container]
+	return !ok || support.attachments
+}
+
+// codecAllowed checks a codec against a container's codec list (nil = any)
+func codecAllowed(allowed []string, codec string) bool {
+	if allowed == nil {
+		return true
+	}
+	for _, c := range allowed {
+		if strings.EqualFold(c, codec) {
+			return true
+		}
+	}
+	return false
+}
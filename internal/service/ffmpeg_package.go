@@ -0,0 +1,350 @@
+package service
+
+import (
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/pkg/ffprobe"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Output kinds (OutputConfig.Kind)
+const (
+	OutputKindFile = "file" // A single output file (default)
+	OutputKindHLS  = "hls"  // HLS playlists and segments in a package directory
+	OutputKindDASH = "dash" // DASH manifest and segments in a package directory
+)
+
+// HLS segment types (StreamingConfig.SegmentType)
+const (
+	SegmentTypeFMP4 = "fmp4"
+	SegmentTypeTS   = "ts"
+)
+
+// defaultSegmentDuration is the target segment length in seconds
+const defaultSegmentDuration = 6
+
+// Entry points of HLS/DASH packages, written into the package directory
+const (
+	HLSMasterPlaylist = "master.m3u8"
+	DASHManifest      = "manifest.mpd"
+)
+
+// Packaged reports whether the output is an HLS/DASH package directory instead of a single file
+// Custom commands write whatever they are told and are never packaged.
+func Packaged(config *model.TranscodeConfig) bool {
+	if config.Mode == "advanced" && config.CustomCommand != "" {
+		return false
+	}
+	kind := config.Output.Kind
+	return kind == OutputKindHLS || kind == OutputKindDASH
+}
+
+// PackageEntry returns the master playlist or manifest of a package directory
+// Players (and verification) open the package through it.
+func PackageEntry(dir string, config *model.TranscodeConfig) string {
+	if config.Output.Kind == OutputKindDASH {
+		return filepath.Join(dir, DASHManifest)
+	}
+	return filepath.Join(dir, HLSMasterPlaylist)
+}
+
+// RemovePreviousPackage removes a package directory left by a previous run of the task
+// Anything else at the package path is kept and reported, it may be user data.
+func RemovePreviousPackage(dir string, config *model.TranscodeConfig) error {
+	info, err := os.Lstat(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("output path exists: %s", dir)
+	}
+	if _, err := os.Stat(PackageEntry(dir, config)); err != nil {
+		return fmt.Errorf("output path exists: %s", dir)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove previous package: %w", err)
+	}
+	return nil
+}
+
+// OutputSize returns the size of an output file, or the total size of all files of a package directory
+func OutputSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if !info.IsDir() {
+		return info.Size(), nil
+	}
+
+	var total int64
+	err = filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	return total, err
+}
+
+// segmentType returns the segment format of a package (DASH always uses fMP4)
+func segmentType(output *model.OutputConfig) string {
+	if output.Kind == OutputKindHLS && output.Streaming != nil && output.Streaming.SegmentType == SegmentTypeTS {
+		return SegmentTypeTS
+	}
+	return SegmentTypeFMP4
+}
+
+// segmentDuration returns the target segment length in seconds
+func segmentDuration(output *model.OutputConfig) int {
+	if output.Streaming != nil && output.Streaming.SegmentDuration > 0 {
+		return output.Streaming.SegmentDuration
+	}
+	return defaultSegmentDuration
+}
+
+// packageRenditions returns the video ladder of a packaged output (nil = a single unscaled rendition)
+func packageRenditions(config *model.TranscodeConfig) []model.Rendition {
+	if !Packaged(config) || config.Output.Streaming == nil {
+		return nil
+	}
+	return config.Output.Streaming.Renditions
+}
+
+// validatePackaging checks the HLS/DASH settings and the features a package can't be combined with
+func validatePackaging(config *model.TranscodeConfig) error {
+	output := &config.Output
+	switch output.Kind {
+	case "", OutputKindFile, OutputKindHLS, OutputKindDASH:
+	default:
+		return fmt.Errorf("unknown output kind: %s", output.Kind)
+	}
+	if !Packaged(config) {
+		return nil
+	}
+
+	if output.PathType == "overwrite" {
+		return fmt.Errorf("HLS/DASH packages are written to a directory and can't overwrite the source file")
+	}
+
+	if streaming := output.Streaming; streaming != nil {
+		switch streaming.SegmentType {
+		case "", SegmentTypeFMP4:
+		case SegmentTypeTS:
+			if output.Kind == OutputKindDASH {
+				return fmt.Errorf("DASH segments are always fMP4, MPEG-TS segments are only supported for HLS")
+			}
+		default:
+			return fmt.Errorf("unknown segment type: %s (use fmp4 or ts)", streaming.SegmentType)
+		}
+		if streaming.SegmentDuration < 0 {
+			return fmt.Errorf("segment duration can't be negative")
+		}
+
+		heights := map[int]bool{}
+		for i, rendition := range streaming.Renditions {
+			if rendition.Height <= 0 {
+				return fmt.Errorf("rendition %d needs a height", i+1)
+			}
+			if heights[rendition.Height] {
+				return fmt.Errorf("rendition height %d is used more than once", rendition.Height)
+			}
+			heights[rendition.Height] = true
+			if rendition.VideoBitrate != "" {
+				if _, ok := parseBitrate(rendition.VideoBitrate); !ok {
+					return fmt.Errorf("invalid bitrate for rendition %dp: %s", rendition.Height, rendition.VideoBitrate)
+				}
+			}
+		}
+	}
+
+	if config.Chunking != nil && config.Chunking.Enabled {
+		return fmt.Errorf("chunked encoding is not supported for HLS/DASH outputs")
+	}
+	if QualityEnabled(config) {
+		return fmt.Errorf("quality scoring is not supported for HLS/DASH outputs")
+	}
+	if config.Audio.StereoCompat && !RemuxMode(config) {
+		return fmt.Errorf("stereo compatibility tracks are not supported for HLS/DASH outputs (only the first audio track is packaged)")
+	}
+	if !RemuxMode(config) && output.Kind == OutputKindHLS && videoCodecNames[config.Encoder] == "vp9" {
+		return fmt.Errorf("VP9 video is not supported for HLS, use DASH instead")
+	}
+
+	if len(packageRenditions(config)) > 0 {
+		if RemuxMode(config) {
+			return fmt.Errorf("rendition ladders need a re-encode and are not supported in remux mode")
+		}
+		if hardwareBackend(config.HardwareAccel) == "vaapi" {
+			return fmt.Errorf("rendition ladders are not supported with VA-API encoders")
+		}
+		if mode := rateControlMode(&config.Video); mode == RateControlVBR2Pass || mode == RateControlTargetSize {
+			return fmt.Errorf("two-pass and target-size rate control are not supported with rendition ladders (set a bitrate per rendition instead)")
+		}
+	}
+	return nil
+}
+
+// packagedStreams keeps the streams a package carries: the main video and the first audio track
+func packagedStreams(streams []ffprobe.StreamInfo) []ffprobe.StreamInfo {
+	kept := []ffprobe.StreamInfo{}
+	video, audio := false, false
+	for _, stream := range streams {
+		switch {
+		case stream.Type == "video" && !video:
+			video = true
+		case stream.Type == "audio" && !audio:
+			audio = true
+		default:
+			continue
+		}
+		kept = append(kept, stream)
+	}
+	return kept
+}
+
+// packagedSource returns the probed source with only the streams a package carries
+func packagedSource(sourceVideoInfo *ffprobe.VideoInfo) *ffprobe.VideoInfo {
+	if sourceVideoInfo == nil {
+		return nil
+	}
+	packaged := *sourceVideoInfo
+	packaged.Streams = packagedStreams(sourceVideoInfo.Streams)
+	return &packaged
+}
+
+// buildLadderGraph builds the filter graph of a rendition ladder: the main video is filtered once,
+// split and scaled per rendition into the outputs [v0], [v1], ...
+// inputs are the graph input labels, e.g. [0:v:0] or [0:v:0][0:s:0] for an overlay in filters
+func buildLadderGraph(inputs string, filters []string, renditions []model.Rendition) string {
+	var outputs strings.Builder
+	for i := range renditions {
+		fmt.Fprintf(&outputs, "[r%d]", i)
+	}
+
+	head := append(append([]string{}, filters...), fmt.Sprintf("split=%d%s", len(renditions), outputs.String()))
+	graph := []string{inputs + strings.Join(head, ",")}
+	for i, rendition := range renditions {
+		graph = append(graph, fmt.Sprintf("[r%d]scale=-2:%d[v%d]", i, rendition.Height, i))
+	}
+	return strings.Join(graph, ";")
+}
+
+// renditionArgs returns the per-rendition bitrate arguments of a ladder
+// The bitrate is the average with bitrate rate control and caps quality-based rate control
+func renditionArgs(renditions []model.Rendition) []string {
+	args := []string{}
+	for i, rendition := range renditions {
+		if rendition.VideoBitrate == "" {
+			continue
+		}
+		index := strconv.Itoa(i)
+		args = append(args,
+			"-b:v:"+index, rendition.VideoBitrate,
+			"-maxrate:v:"+index, rendition.VideoBitrate,
+			"-bufsize:v:"+index, scaleBitrate(rendition.VideoBitrate, 2),
+		)
+	}
+	return args
+}
+
+// keyframeArgs forces keyframes on the segment boundaries so every segment starts independently
+func keyframeArgs(output *model.OutputConfig) []string {
+	return []string{"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", segmentDuration(output))}
+}
+
+// packageArgs returns the muxer arguments of an HLS/DASH package written into dir, ending with the output path
+// renditions is the video ladder (nil = single rendition), audio whether an audio track is packaged
+func packageArgs(dir string, config *model.TranscodeConfig, renditions []model.Rendition, audio bool) []string {
+	output := &config.Output
+	duration := strconv.Itoa(segmentDuration(output))
+
+	if output.Kind == OutputKindDASH {
+		sets := "id=0,streams=v"
+		if audio {
+			sets += " id=1,streams=a"
+		}
+		return []string{
+			"-f", "dash",
+			"-seg_duration", duration,
+			"-use_template", "1", "-use_timeline", "1",
+			"-init_seg_name", "init-$RepresentationID$.$ext$",
+			"-media_seg_name", "chunk-$RepresentationID$-$Number%05d$.$ext$",
+			"-adaptation_sets", sets,
+			filepath.Join(dir, DASHManifest),
+		}
+	}
+
+	args := []string{
+		"-f", "hls",
+		"-hls_time", duration,
+		"-hls_playlist_type", "vod",
+		"-hls_flags", "independent_segments",
+	}
+
+	extension := "m4s"
+	if segmentType(output) == SegmentTypeTS {
+		extension = "ts"
+		args = append(args, "-hls_segment_type", "mpegts")
+	} else {
+		args = append(args, "-hls_segment_type", "fmp4")
+	}
+
+	playlist, segments, init := "stream.m3u8", "segment_%05d."+extension, "init.mp4"
+	if len(renditions) > 0 {
+		// One media playlist per rendition, the audio track is shared by all of them
+		variants := []string{}
+		if audio {
+			variants = append(variants, "a:0,agroup:audio,name:audio")
+		}
+		for i, rendition := range renditions {
+			variant := fmt.Sprintf("v:%d,name:%dp", i, rendition.Height)
+			if audio {
+				variant += ",agroup:audio"
+			}
+			variants = append(variants, variant)
+		}
+		args = append(args, "-var_stream_map", strings.Join(variants, " "))
+		playlist, segments, init = "stream_%v.m3u8", "segment_%v_%05d."+extension, "init_%v.mp4"
+	}
+
+	if extension == "m4s" {
+		args = append(args, "-hls_fmp4_init_filename", init)
+	}
+	args = append(args,
+		"-hls_segment_filename", filepath.Join(dir, segments),
+		"-master_pl_name", HLSMasterPlaylist,
+		filepath.Join(dir, playlist),
+	)
+	return args
+}
+
+// outputTarget returns the arguments that end the command: the output file,
+// or the muxer arguments and playlist of a package directory
+func outputTarget(outputFile string, config *model.TranscodeConfig, sourceVideoInfo *ffprobe.VideoInfo) []string {
+	if !Packaged(config) {
+		return []string{outputFile}
+	}
+
+	// Unknown sources (e.g. command preview) are assumed to have audio
+	audio := sourceVideoInfo == nil || len(sourceVideoInfo.Streams) == 0 || len(sourceVideoInfo.StreamsOfType("audio")) > 0
+	if RemuxMode(config) && sourceVideoInfo != nil && len(sourceVideoInfo.Streams) > 0 {
+		audio = false
+		for _, stream := range remuxedStreams(config, sourceVideoInfo) {
+			audio = audio || stream.Type == "audio"
+		}
+	}
+	return packageArgs(outputFile, config, packageRenditions(config), audio)
+}
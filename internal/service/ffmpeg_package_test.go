@@ -0,0 +1,54 @@
+package service
+
+import (
+	"ffmpeg-web/internal/model"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemovePreviousPackage(t *testing.T) {
+	config := &model.TranscodeConfig{Output: model.OutputConfig{Kind: OutputKindHLS}}
+
+	tests := []struct {
+		name        string
+		setup       func(dir string)
+		wantErr     bool
+		wantRemoved bool
+	}{
+		{"no previous output", func(dir string) {}, false, true},
+		{
+			name: "previous package",
+			setup: func(dir string) {
+				os.Mkdir(dir, 0755)
+				os.WriteFile(filepath.Join(dir, HLSMasterPlaylist), nil, 0644)
+				os.WriteFile(filepath.Join(dir, "stream_0.ts"), nil, 0644)
+			},
+			wantRemoved: true,
+		},
+		{
+			name: "user directory",
+			setup: func(dir string) {
+				os.Mkdir(dir, 0755)
+				os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644)
+			},
+			wantErr: true,
+		},
+		{"file", func(dir string) { os.WriteFile(dir, nil, 0644) }, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "movie_hls")
+			tt.setup(dir)
+
+			err := RemovePreviousPackage(dir, config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RemovePreviousPackage() error = %v, want error %t", err, tt.wantErr)
+			}
+			if _, err := os.Stat(dir); os.IsNotExist(err) != tt.wantRemoved {
+				t.Errorf("RemovePreviousPackage() removed = %t, want %t", os.IsNotExist(err), tt.wantRemoved)
+			}
+		})
+	}
+}
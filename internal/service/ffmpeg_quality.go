@@ -0,0 +1,302 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/pkg/ffprobe"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Quality metrics
+const (
+	QualityVMAF = "vmaf"
+	QualitySSIM = "ssim"
+	QualityPSNR = "psnr"
+)
+
+// Quality sampling defaults
+const (
+	defaultQualitySamples        = 5
+	defaultQualitySampleDuration = 10
+)
+
+// qualityFilters maps metrics to the ffmpeg filter computing them
+var qualityFilters = map[string]string{
+	QualityVMAF: "libvmaf",
+	QualitySSIM: "ssim",
+	QualityPSNR: "psnr",
+}
+
+// qualityFallbacks is the order metrics are tried in when a filter is missing
+var qualityFallbacks = []string{QualityVMAF, QualitySSIM, QualityPSNR}
+
+// QualityEnabled reports whether the config asks for quality scoring
+func QualityEnabled(config *model.TranscodeConfig) bool {
+	return config.Quality != nil && config.Quality.Enabled
+}
+
+// validateQuality checks the quality scoring settings
+func validateQuality(quality *model.QualityConfig) error {
+	if quality == nil {
+		return nil
+	}
+	if _, ok := qualityFilters[quality.Metric]; quality.Metric != "" && !ok {
+		return fmt.Errorf("unknown quality metric: %s", quality.Metric)
+	}
+	if quality.Samples < 0 || quality.SampleDuration < 0 {
+		return fmt.Errorf("quality samples and sample duration can't be negative")
+	}
+	return nil
+}
+
+// SelectQualityMetric returns the requested metric, or the next one whose filter the ffmpeg build has
+// filters is the ffmpeg filter list (empty = unknown, the requested metric is used as-is)
+func SelectQualityMetric(metric string, filters []string) string {
+	if metric == "" {
+		metric = QualityVMAF
+	}
+	if len(filters) == 0 || slices.Contains(filters, qualityFilters[metric]) {
+		return metric
+	}
+
+	for _, fallback := range qualityFallbacks[slices.Index(qualityFallbacks, metric)+1:] {
+		if slices.Contains(filters, qualityFilters[fallback]) {
+			return fallback
+		}
+	}
+	return metric
+}
+
+// QualitySamples spreads count segments of length seconds evenly over a duration
+// Short files are scored as a single segment.
+func QualitySamples(duration float64, count, length int) []ChunkRange {
+	if count <= 0 {
+		count = defaultQualitySamples
+	}
+	if length <= 0 {
+		length = defaultQualitySampleDuration
+	}
+
+	sampleLength := float64(length)
+	if duration <= 0 || duration <= float64(count)*sampleLength {
+		return []ChunkRange{{Start: 0, Duration: 0}}
+	}
+
+	samples := make([]ChunkRange, 0, count)
+	for i := 0; i < count; i++ {
+		center := duration * (float64(i) + 0.5) / float64(count)
+		start := math.Max(0, math.Min(center-sampleLength/2, duration-sampleLength))
+		samples = append(samples, ChunkRange{Start: start, Duration: sampleLength})
+	}
+	return samples
+}
+
+// qualitySample is a scored segment of the output and where it starts in the source
+type qualitySample struct {
+	output      ChunkRange
+	sourceStart float64
+}
+
+// MeasureQuality scores the output against the source on sampled segments with the given metric
+// The source gets the encode's deinterlacing and frame rate conversion (scan is the worker's
+// scan analysis, nil = from the metadata) and is scaled to the output resolution;
+// both sides are compared as yuv420p.
+// Samples of trimmed outputs are compared with the source range they were cut from.
+func (fs *FFmpegService) MeasureQuality(ctx context.Context, sourceFile, outputFile string, config *model.TranscodeConfig, metric string, source *ffprobe.VideoInfo, scan *ffprobe.ScanInfo) (*model.QualityScore, error) {
+	ranges := TrimRanges(config, source.Duration)
+	duration := TrimmedDuration(config, source.Duration)
+
+	samples := []qualitySample{}
+	for _, output := range QualitySamples(duration, config.Quality.Samples, config.Quality.SampleDuration) {
+		sample := qualitySample{output: output, sourceStart: output.Start}
+		if ranges != nil {
+			sample.output, sample.sourceStart = trimSourceRange(ranges, output)
+		}
+		samples = append(samples, sample)
+	}
+	return fs.measureQuality(ctx, sourceFile, outputFile, metric, samples, referenceFilters(config, scanOf(source, &EncodeOptions{Scan: scan})))
+}
+
+// MeasureSampleQuality scores a sample encode against the source range it was encoded from
+func (fs *FFmpegService) MeasureSampleQuality(ctx context.Context, sourceFile, outputFile string, config *model.TranscodeConfig, metric string, sample ChunkRange, source *ffprobe.VideoInfo, scan *ffprobe.ScanInfo) (*model.QualityScore, error) {
+	return fs.measureQuality(ctx, sourceFile, outputFile, metric, []qualitySample{
+		{output: ChunkRange{Duration: sample.Duration}, sourceStart: sample.Start},
+	}, referenceFilters(config, scanOf(source, &EncodeOptions{Scan: scan})))
+}
+
+// measureQuality scores the given output segments against the source
+// reference holds the filters applied to the source before scaling
+func (fs *FFmpegService) measureQuality(ctx context.Context, sourceFile, outputFile, metric string, samples []qualitySample, reference []string) (*model.QualityScore, error) {
+	output, err := fs.ProbeFile(ctx, outputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe output: %w", err)
+	}
+	if output.Width == 0 || output.Height == 0 {
+		return nil, fmt.Errorf("output has no video stream to score")
+	}
+
+	statsDir, err := os.MkdirTemp("", "ffforge-quality-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create quality stats directory: %w", err)
+	}
+	defer os.RemoveAll(statsDir)
+
+	scores := []float64{}
+	for i, sample := range samples {
+		statsFile := filepath.Join(statsDir, fmt.Sprintf("sample%d.log", i))
+		frames, err := fs.scoreSample(ctx, sourceFile, outputFile, metric, sample, output, reference, statsFile)
+		if err != nil {
+			return nil, err
+		}
+		scores = append(scores, frames...)
+	}
+
+	if len(scores) == 0 {
+		return nil, fmt.Errorf("no frames were scored")
+	}
+
+	return summarizeScores(metric, scores, len(samples)), nil
+}
+
+// scoreSample runs the metric filter on one segment and returns the per-frame scores
+func (fs *FFmpegService) scoreSample(ctx context.Context, sourceFile, outputFile, metric string, sample qualitySample, output *ffprobe.VideoInfo, reference []string, statsFile string) ([]float64, error) {
+	var filter string
+	switch metric {
+	case QualityVMAF:
+		filter = fmt.Sprintf("libvmaf=log_fmt=json:log_path='%s'", escapeFilterPath(statsFile))
+	default:
+		filter = fmt.Sprintf("%s=stats_file='%s'", metric, escapeFilterPath(statsFile))
+	}
+
+	// The distorted output is the first filter input, the reference source the second
+	referenceChain := fmt.Sprintf("scale=%d:%d:flags=bicubic,format=yuv420p,setpts=PTS-STARTPTS", output.Width, output.Height)
+	if len(reference) > 0 {
+		referenceChain = strings.Join(reference, ",") + "," + referenceChain
+	}
+	graph := fmt.Sprintf("[0:v:0]format=yuv420p,setpts=PTS-STARTPTS[dist];"+
+		"[1:v:0]%s[ref];"+
+		"[dist][ref]%s", referenceChain, filter)
+
+	args := []string{"-hide_banner", "-nostdin", "-nostats"}
+	for _, input := range []struct {
+		file  string
+		start float64
+	}{
+		{outputFile, sample.output.Start},
+		{sourceFile, sample.sourceStart},
+	} {
+		if input.start > 0 {
+			args = append(args, "-ss", formatFloat(input.start))
+		}
+		if sample.output.Duration > 0 {
+			args = append(args, "-t", formatFloat(sample.output.Duration))
+		}
+		args = append(args, "-i", input.file)
+	}
+	args = append(args, "-lavfi", graph, "-f", "null", "-")
+
+	cmd := exec.CommandContext(ctx, fs.ffmpegPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%s measurement failed: %w: %s", metric, err, lastLine(stderr.String()))
+	}
+
+	data, err := os.ReadFile(statsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s stats: %w", metric, err)
+	}
+	return parseQualityStats(metric, data)
+}
+
+// vmafLog is the part of libvmaf's JSON log holding the per-frame scores
+type vmafLog struct {
+	Frames []struct {
+		Metrics struct {
+			VMAF float64 `json:"vmaf"`
+		} `json:"metrics"`
+	} `json:"frames"`
+}
+
+// parseQualityStats extracts per-frame scores from a libvmaf JSON log or an ssim/psnr stats file
+// ssim lines look like "n:1 Y:0.99 U:0.99 V:0.99 All:0.992 (20.9)",
+// psnr lines like "n:1 mse_avg:0.51 ... psnr_avg:51.05 ..." (inf for identical frames)
+func parseQualityStats(metric string, data []byte) ([]float64, error) {
+	if metric == QualityVMAF {
+		var log vmafLog
+		if err := json.Unmarshal(data, &log); err != nil {
+			return nil, fmt.Errorf("failed to parse vmaf log: %w", err)
+		}
+		scores := make([]float64, 0, len(log.Frames))
+		for _, frame := range log.Frames {
+			scores = append(scores, frame.Metrics.VMAF)
+		}
+		return scores, nil
+	}
+
+	key := "All:"
+	if metric == QualityPSNR {
+		key = "psnr_avg:"
+	}
+
+	scores := []float64{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		for _, field := range strings.Fields(scanner.Text()) {
+			value, ok := strings.CutPrefix(field, key)
+			if !ok {
+				continue
+			}
+			if value == "inf" {
+				scores = append(scores, 100) // Identical frames
+			} else if score, err := strconv.ParseFloat(value, 64); err == nil {
+				scores = append(scores, score)
+			}
+		}
+	}
+	return scores, scanner.Err()
+}
+
+// summarizeScores computes the mean, minimum and 5th percentile of per-frame scores
+func summarizeScores(metric string, scores []float64, samples int) *model.QualityScore {
+	sorted := slices.Clone(scores)
+	slices.Sort(sorted)
+
+	sum := 0.0
+	for _, score := range sorted {
+		sum += score
+	}
+
+	return &model.QualityScore{
+		Metric:  metric,
+		Mean:    roundScore(sum / float64(len(sorted))),
+		Min:     roundScore(sorted[0]),
+		P5:      roundScore(sorted[int(float64(len(sorted)-1)*0.05)]),
+		Frames:  len(sorted),
+		Samples: samples,
+	}
+}
+
+// roundScore rounds a score to 4 decimals (SSIM needs more than 2)
+func roundScore(score float64) float64 {
+	return math.Round(score*10000) / 10000
+}
+
+// lastLine returns the last non-empty line of an ffmpeg error output
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
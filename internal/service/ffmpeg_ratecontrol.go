@@ -0,0 +1,339 @@
+package service
+
+import (
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/pkg/ffprobe"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rate control modes (VideoConfig.RateControl)
+const (
+	RateControlCRF        = "crf"         // Constant quality (default)
+	RateControlCBR        = "cbr"         // Constant bitrate
+	RateControlVBR2Pass   = "vbr-2pass"   // Average bitrate, two passes where the encoder supports it
+	RateControlTargetSize = "target-size" // Average bitrate computed from the target file size
+)
+
+// PassLogName is the base name of two-pass statistics files
+// ffmpeg runs inside the pass log directory, so the name is relative
+// (x265-params can't hold paths with colons, e.g. Windows drive letters)
+const PassLogName = "ffforge2pass"
+
+// minTargetVideoBitrate is the lowest video bitrate accepted for target-size encodes
+const minTargetVideoBitrate = 100 * 1000
+
+// containerOverhead is the share of the target size reserved for muxing overhead
+const containerOverhead = 0.02
+
+// defaultAudioBitratePerChannel estimates audio streams without a known bitrate
+const defaultAudioBitratePerChannel = 96 * 1000
+
+// rateControlMode returns the effective rate control mode (crf by default)
+func rateControlMode(video *model.VideoConfig) string {
+	if video.RateControl == "" {
+		return RateControlCRF
+	}
+	return video.RateControl
+}
+
+// UsesTwoPass reports whether a task runs two separate ffmpeg passes
+// Encoders without two-pass support in ffmpeg use single-pass ABR instead;
+// NVENC does its multipass analysis inside a single run
+func (fs *FFmpegService) UsesTwoPass(config *model.TranscodeConfig) bool {
+	// Advanced mode runs the custom command, remuxes don't encode, audio tasks have no video,
+	// chunked segments are encoded in one pass
+	if (config.Mode == "advanced" && config.CustomCommand != "") || RemuxMode(config) || AudioMode(config) || ChunkingEnabled(config) {
+		return false
+	}
+
+	mode := rateControlMode(&config.Video)
+	if mode != RateControlVBR2Pass && mode != RateControlTargetSize {
+		return false
+	}
+
+	codec, err := selectVideoCodec(config.Encoder, config.HardwareAccel)
+	if err != nil {
+		return false
+	}
+	return supportsTwoPass(codec)
+}
+
+// supportsTwoPass checks if an encoder can run separate analysis and encode passes
+func supportsTwoPass(codec string) bool {
+	switch codec {
+	case "libx265", "libx264", "libvpx-vp9":
+		return true
+	}
+	return false
+}
+
+// validateRateControl checks the rate control settings of a video configuration
+func validateRateControl(config *model.TranscodeConfig, codec string) error {
+	video := &config.Video
+	mode := rateControlMode(video)
+
+	// ProRes bitrate is fixed by its profile
+	if codec == "prores_ks" && mode != RateControlCRF {
+		return fmt.Errorf("%s rate control is not supported by prores_ks (use the profile preset)", mode)
+	}
+
+	switch mode {
+	case RateControlCRF:
+		return nil
+
+	case RateControlCBR, RateControlVBR2Pass:
+		if _, ok := parseBitrate(video.Bitrate); !ok {
+			return fmt.Errorf("%s rate control requires a video bitrate (e.g. 8M)", video.RateControl)
+		}
+		if video.RateControl == RateControlCBR && codec == "libsvtav1" {
+			return fmt.Errorf("cbr rate control is not supported by libsvtav1")
+		}
+		return nil
+
+	case RateControlTargetSize:
+		if _, ok := parseSize(video.TargetSize); !ok {
+			return fmt.Errorf("target-size rate control requires a target size (e.g. 4G, 700M)")
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown rate control mode: %s", video.RateControl)
+	}
+}
+
+// TargetVideoBitrate computes the video bitrate (bits/s) needed to reach the configured
+// target size, from the probed (trimmed) duration minus the audio budget and muxing overhead
+func TargetVideoBitrate(config *model.TranscodeConfig, sourceVideoInfo *ffprobe.VideoInfo) (int64, error) {
+	targetSize, ok := parseSize(config.Video.TargetSize)
+	if !ok {
+		return 0, fmt.Errorf("invalid target size: %q", config.Video.TargetSize)
+	}
+	if sourceVideoInfo == nil || sourceVideoInfo.Duration <= 0 {
+		return 0, fmt.Errorf("source duration unknown, cannot compute bitrate for target size")
+	}
+
+	duration := TrimmedDuration(config, sourceVideoInfo.Duration)
+	if duration <= 0 {
+		return 0, fmt.Errorf("trimmed duration is empty, cannot compute bitrate for target size")
+	}
+
+	totalBitrate := float64(targetSize) * 8 * (1 - containerOverhead) / duration
+	videoBitrate := int64(totalBitrate) - estimateAudioBitrate(&config.Audio, sourceVideoInfo)
+
+	if videoBitrate < minTargetVideoBitrate {
+		return 0, fmt.Errorf("target size %s is too small for a %.0fs source (video bitrate would be %dk)",
+			config.Video.TargetSize, duration, videoBitrate/1000)
+	}
+
+	return videoBitrate, nil
+}
+
+// estimateAudioBitrate sums the expected bitrate (bits/s) of all output audio streams
+func estimateAudioBitrate(audio *model.AudioConfig, sourceVideoInfo *ffprobe.VideoInfo) int64 {
+	total := int64(0)
+	for _, stream := range sourceVideoInfo.StreamsOfType("audio") {
+		rule := matchAudioRule(audio, stream)
+
+		if rule.Codec == "copy" || rule.Codec == "" {
+			if stream.Bitrate > 0 {
+				total += stream.Bitrate
+			} else {
+				total += estimateChannelsBitrate(stream.Channels)
+			}
+			continue
+		}
+
+		if bitrate, ok := parseBitrate(resolveAudioBitrate(rule, stream)); ok {
+			total += bitrate
+			continue
+		}
+
+		channels := rule.Channels
+		if channels <= 0 {
+			channels = stream.Channels
+		}
+		total += estimateChannelsBitrate(channels)
+	}
+
+	// Stereo compatibility tracks
+	if audio.StereoCompat {
+		bitrate, ok := parseBitrate(audio.StereoCompatBitrate)
+		if !ok {
+			bitrate, _ = parseBitrate(defaultStereoCompatBitrate)
+		}
+		for _, stream := range sourceVideoInfo.StreamsOfType("audio") {
+			if stream.Channels > 2 {
+				total += bitrate
+			}
+		}
+	}
+
+	return total
+}
+
+// estimateChannelsBitrate estimates an audio bitrate from the channel count
+func estimateChannelsBitrate(channels int) int64 {
+	if channels <= 0 {
+		channels = 2
+	}
+	return int64(channels) * defaultAudioBitratePerChannel
+}
+
+// buildRateControlArgs builds the rate control arguments for the selected encoder
+// Returns the arguments and encoder-specific params (for -x265-params/-svtav1-params)
+func buildRateControlArgs(codec string, config *model.TranscodeConfig, opts *EncodeOptions) ([]string, []string) {
+	args := []string{}
+	params := []string{}
+	video := &config.Video
+
+	switch rateControlMode(video) {
+	case RateControlCBR:
+		bitrate := video.Bitrate
+		bufsize := scaleBitrate(bitrate, 2)
+
+		switch {
+		case strings.HasSuffix(codec, "_nvenc"), strings.HasSuffix(codec, "_amf"):
+			args = append(args, "-rc", "cbr")
+		case strings.HasSuffix(codec, "_vaapi"):
+			args = append(args, "-rc_mode", "CBR")
+		case codec == "libx265":
+			args = append(args, "-minrate", bitrate)
+			params = append(params, "strict-cbr=1")
+		case codec == "libx264":
+			args = append(args, "-minrate", bitrate)
+			params = append(params, "nal-hrd=cbr")
+		case codec == "libvpx-vp9":
+			// libvpx switches to CBR when min and max rate equal the target
+			args = append(args, "-minrate", bitrate)
+		}
+		args = append(args, "-b:v", bitrate, "-maxrate", bitrate, "-bufsize", bufsize)
+
+	case RateControlVBR2Pass, RateControlTargetSize:
+		bitrate := video.Bitrate
+		if video.RateControl == RateControlTargetSize {
+			bitrate = opts.VideoBitrate
+		}
+		if bitrate == "" {
+			// Target-size bitrate is only known once the source is probed
+			return args, params
+		}
+
+		switch {
+		case strings.HasSuffix(codec, "_nvenc"):
+			// NVENC runs its two-pass analysis within a single encode
+			args = append(args, "-rc", "vbr", "-multipass", "fullres")
+		case strings.HasSuffix(codec, "_amf"):
+			args = append(args, "-rc", "vbr_peak", "-maxrate", scaleBitrate(bitrate, 1.5))
+		case strings.HasSuffix(codec, "_vaapi"):
+			args = append(args, "-rc_mode", "VBR", "-maxrate", scaleBitrate(bitrate, 1.5))
+		}
+		args = append(args, "-b:v", bitrate)
+
+		if opts.Pass > 0 && supportsTwoPass(codec) {
+			if codec == "libx265" {
+				// libx265 ignores -pass, passes are set through x265-params
+				params = append(params, "pass="+strconv.Itoa(opts.Pass), "stats="+PassLogName+".log")
+			} else {
+				args = append(args, "-pass", strconv.Itoa(opts.Pass), "-passlogfile", PassLogName)
+			}
+		}
+
+	default:
+		// ProRes quality is set by the profile (see buildVideoArgs)
+		if codec == "prores_ks" {
+			return args, params
+		}
+
+		// VA-API: constant QP, or VBR when a bitrate is set
+		if strings.HasSuffix(codec, "_vaapi") {
+			if video.Bitrate != "" {
+				args = append(args, "-rc_mode", "VBR", "-b:v", video.Bitrate)
+			} else if video.CRF > 0 {
+				args = append(args, "-rc_mode", "CQP", "-global_quality", vaapiQuality(codec, video.CRF))
+			}
+			return args, params
+		}
+
+		// Constant quality, the flag depends on the encoder family
+		if video.CRF > 0 {
+			crf := strconv.Itoa(video.CRF)
+			switch {
+			case strings.HasSuffix(codec, "_nvenc"):
+				args = append(args, "-cq", crf)
+			case strings.HasSuffix(codec, "_qsv"):
+				args = append(args, "-global_quality", crf)
+			case strings.HasSuffix(codec, "_amf"):
+				args = append(args, "-qp_i", crf)
+			default:
+				args = append(args, "-crf", crf)
+			}
+		}
+
+		// Bitrate (if specified)
+		if video.Bitrate != "" {
+			args = append(args, "-b:v", video.Bitrate)
+		} else if codec == "libvpx-vp9" && video.CRF > 0 {
+			// libvpx-vp9 only uses constant quality mode with -b:v 0
+			args = append(args, "-b:v", "0")
+		}
+	}
+
+	return args, params
+}
+
+// encoderParamsKey returns the option holding encoder-specific params for a codec
+func encoderParamsKey(codec string) string {
+	switch codec {
+	case "libx265":
+		return "-x265-params"
+	case "libx264":
+		return "-x264-params"
+	case "libsvtav1":
+		return "-svtav1-params"
+	}
+	return ""
+}
+
+// scaleBitrate multiplies a bitrate string, returning it in kbit/s
+func scaleBitrate(bitrate string, factor float64) string {
+	value, ok := parseBitrate(bitrate)
+	if !ok {
+		return bitrate
+	}
+	return fmt.Sprintf("%dk", int64(float64(value)*factor)/1000)
+}
+
+// parseSize parses a file size like "4G", "700MB", "4.5GiB" or "1048576" into bytes
+// Units are binary (1K = 1024 bytes)
+func parseSize(s string) (int64, bool) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimSuffix(s, "IB")
+	s = strings.TrimSuffix(s, "B")
+	if s == "" {
+		return 0, false
+	}
+
+	multiplier := 1.0
+	switch s[len(s)-1] {
+	case 'K':
+		multiplier = 1 << 10
+	case 'M':
+		multiplier = 1 << 20
+	case 'G':
+		multiplier = 1 << 30
+	case 'T':
+		multiplier = 1 << 40
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || value <= 0 {
+		return 0, false
+	}
+
+	return int64(value * multiplier), true
+}
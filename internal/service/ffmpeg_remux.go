@@ -0,0 +1,231 @@
+package service
+
+import (
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/pkg/ffprobe"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// ModeRemux copies the selected streams into a new container without re-encoding
+const ModeRemux = "remux"
+
+// fastStartContainers get their index moved to the front for progressive playback
+var fastStartContainers = []string{"mp4", "m4v", "mov"}
+
+// RemuxMode reports whether the config only remuxes the source
+func RemuxMode(config *model.TranscodeConfig) bool {
+	return config.Mode == ModeRemux
+}
+
+// validateRemux checks the settings that need a re-encode
+func validateRemux(config *model.TranscodeConfig) error {
+	if config.Subtitle.Mode == "burn" {
+		return fmt.Errorf("subtitle burn-in needs a re-encode and is not supported in remux mode")
+	}
+	if config.Chunking != nil && config.Chunking.Enabled {
+		return fmt.Errorf("chunked encoding is not supported in remux mode")
+	}
+	if trimJoinsSegments(config) {
+		return fmt.Errorf("cutting several trim segments needs a re-encode and is not supported in remux mode")
+	}
+	return validateStreamSelection(config.Streams)
+}
+
+// validateStreamSelection checks the stream indexes of a stream selection
+func validateStreamSelection(selection *model.StreamSelection) error {
+	if selection == nil {
+		return nil
+	}
+	for _, index := range selection.Drop {
+		if index < 0 {
+			return fmt.Errorf("invalid stream index to drop: %d", index)
+		}
+	}
+	for _, index := range selection.AudioTracks {
+		if index < 0 {
+			return fmt.Errorf("invalid audio track index: %d", index)
+		}
+	}
+	return nil
+}
+
+// selectStreams returns the probed source with only the streams the stream selection keeps
+// Video streams are never filtered by language; the selection can still drop them by index.
+func selectStreams(config *model.TranscodeConfig, sourceVideoInfo *ffprobe.VideoInfo) *ffprobe.VideoInfo {
+	selection := config.Streams
+	if selection == nil || sourceVideoInfo == nil {
+		return sourceVideoInfo
+	}
+
+	selected := *sourceVideoInfo
+	selected.Streams = nil
+	for _, stream := range sourceVideoInfo.Streams {
+		if slices.Contains(selection.Drop, stream.Index) {
+			continue
+		}
+		if stream.Type == "audio" && !languageSelected(selection.AudioLanguages, stream.Language) {
+			continue
+		}
+		if stream.Type == "audio" && len(selection.AudioTracks) > 0 && !slices.Contains(selection.AudioTracks, stream.Index) {
+			continue
+		}
+		if stream.Type == "subtitle" && !languageSelected(selection.SubtitleLanguages, stream.Language) {
+			continue
+		}
+		if stream.Type == "data" && selection.DropData {
+			continue
+		}
+		selected.Streams = append(selected.Streams, stream)
+	}
+	return &selected
+}
+
+// languageSelected checks a stream language against a language filter (empty = all languages)
+func languageSelected(languages []string, language string) bool {
+	return len(languages) == 0 || language == "" || containsFold(languages, language)
+}
+
+// remuxedStreams returns the source streams that end up in the remuxed output
+// (the stream selection minus the streams the container can't hold; HLS/DASH packages
+// keep the main video and the first audio track of it)
+func remuxedStreams(config *model.TranscodeConfig, sourceVideoInfo *ffprobe.VideoInfo) []ffprobe.StreamInfo {
+	selected := selectStreams(config, sourceVideoInfo)
+	compat := CheckCompatibility(config, sourceVideoInfo)
+	container := outputContainer(&config.Output)
+
+	dropped := map[int]bool{}
+	if compat.fixes() {
+		for _, issue := range compat.Issues {
+			if issue.Action == CompatActionDrop {
+				dropped[issue.Stream] = true
+			}
+		}
+	}
+
+	streams := []ffprobe.StreamInfo{}
+	for _, stream := range selected.Streams {
+		if dropped[stream.Index] {
+			continue
+		}
+		if stream.Type == "subtitle" && (config.Subtitle.Mode == "none" ||
+			(config.Subtitle.Mode == "convert" && subtitleTarget(stream.Codec, container, config.Subtitle.Format) == "")) {
+			continue
+		}
+		streams = append(streams, stream)
+	}
+	if Packaged(config) {
+		return packagedStreams(streams)
+	}
+	return streams
+}
+
+// buildRemuxArgs builds the arguments of a remux: every kept stream is copied,
+// only audio and subtitles the container can't hold are converted.
+// Timestamps are regenerated for sources with broken ones (e.g. cut .ts captures);
+// ADTS AAC gets the bitstream filter mp4-style containers (and fMP4 segments) need,
+// mp4-style files also get +faststart.
+func (fs *FFmpegService) buildRemuxArgs(sourceFile string, config *model.TranscodeConfig, sourceVideoInfo *ffprobe.VideoInfo, opts *EncodeOptions) ([]string, []string) {
+	container := outputContainer(&config.Output)
+	compat := CheckCompatibility(config, sourceVideoInfo)
+	probed := sourceVideoInfo != nil && len(sourceVideoInfo.Streams) > 0
+
+	// No hardware acceleration: nothing is decoded
+	inputArgs := []string{"-fflags", "+genpts"}
+	trimRanges := TrimRanges(config, durationOf(sourceVideoInfo))
+	if opts.Sample != nil && opts.Sample.Start > 0 {
+		inputArgs = append(inputArgs, "-ss", formatFloat(opts.Sample.Start))
+	} else if len(trimRanges) == 1 && trimRanges[0].Start > 0 {
+		// Stream copies can only start at a keyframe
+		inputArgs = append(inputArgs, "-ss", formatFloat(trimRanges[0].Start))
+	}
+	inputArgs = append(inputArgs, "-i", sourceFile)
+
+	args := []string{}
+	var streams []ffprobe.StreamInfo
+	if probed {
+		// Explicit maps in source order, output indexes follow the kept streams
+		streams = remuxedStreams(config, sourceVideoInfo)
+		for _, stream := range streams {
+			args = append(args, "-map", fmt.Sprintf("0:%d", stream.Index))
+		}
+	} else if Packaged(config) {
+		// Source unknown: the main video and the first audio track
+		args = append(args, "-map", "0:v:0", "-map", "0:a:0?")
+	} else {
+		// Source unknown (e.g. command preview): everything except the dropped indexes
+		args = append(args, "-map", "0")
+		if config.Streams != nil {
+			for _, index := range config.Streams.Drop {
+				args = append(args, "-map", fmt.Sprintf("-0:%d", index))
+			}
+			if config.Streams.DropData {
+				args = append(args, "-map", "-0:d?")
+			}
+		}
+		if config.Subtitle.Mode == "none" {
+			args = append(args, "-map", "-0:s?")
+		}
+	}
+
+	if opts.Sample != nil && opts.Sample.Duration > 0 {
+		args = append(args, "-t", formatFloat(opts.Sample.Duration))
+	} else if len(trimRanges) == 1 && trimRanges[0].Duration > 0 {
+		args = append(args, "-t", formatFloat(trimRanges[0].Duration))
+	}
+
+	args = append(args, "-map_metadata", "0", "-c", "copy")
+
+	// Audio the container can't hold is converted (auto compatibility policy)
+	args = append(args, compat.audioFixArgs(selectStreams(config, sourceVideoInfo))...)
+	if compat.fixes() && compat.needsExperimental() {
+		args = append(args, "-strict", "experimental")
+	}
+
+	mp4Style := slices.Contains(fastStartContainers, container)
+	audioIndex, subtitleIndex := 0, 0
+	for _, stream := range streams {
+		switch stream.Type {
+		case "audio":
+			// ADTS AAC (MPEG-TS, raw .aac) needs the ASC header in mp4-style containers
+			if mp4Style && stream.Codec == "aac" {
+				args = append(args, "-bsf:a:"+strconv.Itoa(audioIndex), "aac_adtstoasc")
+			}
+			audioIndex++
+		case "subtitle":
+			// Text subtitles are converted when the container needs another format
+			format := ""
+			if config.Subtitle.Mode == "convert" {
+				format = config.Subtitle.Format
+			}
+			if compat.fixes() || format != "" {
+				if target := subtitleTarget(stream.Codec, container, format); target != "" && target != "copy" {
+					args = append(args, "-c:s:"+strconv.Itoa(subtitleIndex), target)
+				}
+			}
+			subtitleIndex++
+		}
+	}
+
+	// Packages are segmented, the HLS/DASH muxers write their own fragmented mp4
+	if mp4Style && !Packaged(config) {
+		args = append(args, "-movflags", "+faststart")
+	}
+	// Shift negative start timestamps (common after cutting) to zero
+	args = append(args, "-avoid_negative_ts", "make_zero")
+
+	if config.ExtraParams != "" {
+		args = append(args, parseExtraParams(config.ExtraParams)...)
+	}
+
+	return inputArgs, args
+}
+
+// durationOf returns the probed duration (0 = unknown)
+func durationOf(sourceVideoInfo *ffprobe.VideoInfo) float64 {
+	if sourceVideoInfo == nil {
+		return 0
+	}
+	return sourceVideoInfo.Duration
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/pkg/ffprobe"
+	"fmt"
+	"math"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+// Deinterlace settings (VideoConfig.Deinterlace, empty = keep the source fields)
+const (
+	DeinterlaceAuto = "auto"        // Deinterlace or inverse telecine what the scan analysis finds
+	DeinterlaceOn   = "deinterlace" // Always deinterlace
+	DeinterlaceIVTC = "ivtc"        // Always inverse telecine
+)
+
+// Frame rate modes (VideoConfig.FrameRateMode, empty = ffmpeg's default for the container)
+const (
+	FrameRateAuto = "auto" // Constant frame rate output for variable frame rate sources
+	FrameRateCFR  = "cfr"  // Always constant frame rate output
+)
+
+// deinterlaceFilter deinterlaces every frame, one output frame per input frame
+const deinterlaceFilter = "bwdif=mode=send_frame"
+
+// ivtcFilter rebuilds the film frames of hard telecined video: fields are matched back
+// into frames, the few left combed are deinterlaced and the duplicate of every 5 is dropped
+const ivtcFilter = "fieldmatch,bwdif=mode=send_frame:deint=interlaced,decimate"
+
+// idetSampleDuration is the length in seconds of the sample idet classifies
+const idetSampleDuration = 60
+
+// idet classification thresholds (shares of the classified frames)
+const (
+	idetProgressiveMax = 0.1 // Fewer combed frames: progressive (noise, fades)
+	idetTelecineMax    = 0.6 // Fewer combed frames: telecined (3:2 pulldown combs 2 of 5 frames)
+	idetRepeatedMin    = 0.1 // More repeated fields: telecined
+)
+
+// cfrRates are the standard frame rates a variable frame rate source is snapped to
+var cfrRates = []string{"24000/1001", "24", "25", "30000/1001", "30", "50", "60000/1001", "60"}
+
+// idetMultiFrame and idetRepeated match the summary lines idet prints at the end
+var (
+	idetMultiFrame = regexp.MustCompile(`Multi frame detection: TFF:\s*(\d+)\s+BFF:\s*(\d+)\s+Progressive:\s*(\d+)\s+Undetermined:\s*(\d+)`)
+	idetRepeated   = regexp.MustCompile(`Repeated Fields: Neither:\s*\d+\s+Top:\s*(\d+)\s+Bottom:\s*(\d+)`)
+)
+
+// validateScanSettings checks the deinterlace and frame rate mode settings
+func validateScanSettings(video *model.VideoConfig) error {
+	switch video.Deinterlace {
+	case "", DeinterlaceAuto, DeinterlaceOn, DeinterlaceIVTC:
+	default:
+		return fmt.Errorf("unknown deinterlace setting: %s (use auto, deinterlace or ivtc)", video.Deinterlace)
+	}
+	switch video.FrameRateMode {
+	case "", FrameRateAuto, FrameRateCFR:
+	default:
+		return fmt.Errorf("unknown frame rate mode: %s (use auto or cfr)", video.FrameRateMode)
+	}
+	return nil
+}
+
+// AnalyzeScan classifies the source scan for a task from the probed metadata
+// With "auto" deinterlacing, sources whose metadata is interlaced or unknown are
+// sampled with idet, which tells hard telecine from interlaced video.
+// Returns nil when the config doesn't use the scan or the source has no video.
+func (fs *FFmpegService) AnalyzeScan(ctx context.Context, filePath string, config *model.TranscodeConfig, info *ffprobe.VideoInfo) (*ffprobe.ScanInfo, error) {
+	if !usesScan(config) {
+		return nil, nil
+	}
+	scan := ffprobe.AnalyzeScan(info)
+	if scan == nil || config.Video.Deinterlace != DeinterlaceAuto {
+		return scan, nil
+	}
+	if scan.Type != ffprobe.ScanInterlaced && scan.Type != "" {
+		return scan, nil
+	}
+	return fs.DetectScan(ctx, filePath, info)
+}
+
+// usesScan reports whether the output depends on the source scan analysis
+func usesScan(config *model.TranscodeConfig) bool {
+	if (config.Mode == "advanced" && config.CustomCommand != "") || RemuxMode(config) || AudioMode(config) {
+		return false
+	}
+	return config.Video.Deinterlace == DeinterlaceAuto || config.Video.FrameRateMode != ""
+}
+
+// DetectScan classifies the main video stream with the idet filter on a sample
+// The sample starts a third into the file, past intros and logos, and lasts
+// idetSampleDuration seconds. The metadata analysis is returned with the idet counts;
+// soft telecine (already detected from the frame rates) is kept as is.
+func (fs *FFmpegService) DetectScan(ctx context.Context, filePath string, info *ffprobe.VideoInfo) (*ffprobe.ScanInfo, error) {
+	scan := ffprobe.AnalyzeScan(info)
+	if scan == nil {
+		return nil, fmt.Errorf("file has no video stream")
+	}
+
+	args := []string{"-hide_banner", "-nostats"}
+	if info.Duration > 2*idetSampleDuration {
+		args = append(args, "-ss", formatFloat(math.Floor(info.Duration/3)))
+	}
+	args = append(args,
+		"-i", filePath,
+		"-map", "0:v:0",
+		"-t", strconv.Itoa(idetSampleDuration),
+		"-an", "-sn", "-dn",
+		"-filter:v", "idet",
+		"-f", "null", "-",
+	)
+
+	cmd := exec.CommandContext(ctx, fs.ffmpegPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("interlace detection failed: %w", err)
+	}
+
+	counts, err := parseIdetOutput(stderr.String())
+	if err != nil {
+		return nil, err
+	}
+	scan.Idet = counts
+
+	if scan.Pulldown != ffprobe.PulldownSoft {
+		if scanType := classifyIdet(counts); scanType != "" {
+			scan.Type = scanType
+			if scanType == ffprobe.ScanTelecined {
+				scan.Pulldown = ffprobe.PulldownHard
+			}
+		}
+	}
+	return scan, nil
+}
+
+// parseIdetOutput extracts the idet frame counts from ffmpeg's stderr
+// idet prints its summary when the filter closes, the last one counts
+func parseIdetOutput(stderr string) (*ffprobe.IdetCounts, error) {
+	multi := idetMultiFrame.FindAllStringSubmatch(stderr, -1)
+	if len(multi) == 0 {
+		return nil, fmt.Errorf("idet stats not found in ffmpeg output")
+	}
+	values := multi[len(multi)-1]
+
+	counts := &ffprobe.IdetCounts{}
+	counts.TFF, _ = strconv.Atoi(values[1])
+	counts.BFF, _ = strconv.Atoi(values[2])
+	counts.Progressive, _ = strconv.Atoi(values[3])
+	counts.Undetermined, _ = strconv.Atoi(values[4])
+
+	if repeated := idetRepeated.FindAllStringSubmatch(stderr, -1); len(repeated) > 0 {
+		values = repeated[len(repeated)-1]
+		counts.RepeatedTop, _ = strconv.Atoi(values[1])
+		counts.RepeatedBottom, _ = strconv.Atoi(values[2])
+	}
+	return counts, nil
+}
+
+// classifyIdet turns idet frame counts into a scan type ("" = too few classified frames)
+// Interlaced video combs nearly every frame; hard telecine combs 2 of every 5 frames
+// and repeats fields; progressive video combs (almost) none.
+func classifyIdet(counts *ffprobe.IdetCounts) string {
+	interlaced := counts.TFF + counts.BFF
+	classified := interlaced + counts.Progressive
+	if classified == 0 {
+		return ""
+	}
+
+	combed := float64(interlaced) / float64(classified)
+	repeated := float64(counts.RepeatedTop+counts.RepeatedBottom) / float64(classified+counts.Undetermined)
+	switch {
+	case combed < idetProgressiveMax:
+		return ffprobe.ScanProgressive
+	case combed < idetTelecineMax || repeated >= idetRepeatedMin:
+		return ffprobe.ScanTelecined
+	default:
+		return ffprobe.ScanInterlaced
+	}
+}
+
+// scanOf returns the scan analysis of the source: the one of the worker (which may
+// include idet) or the metadata analysis (e.g. command previews)
+func scanOf(sourceVideoInfo *ffprobe.VideoInfo, opts *EncodeOptions) *ffprobe.ScanInfo {
+	if opts != nil && opts.Scan != nil {
+		return opts.Scan
+	}
+	return ffprobe.AnalyzeScan(sourceVideoInfo)
+}
+
+// buildScanFilter returns the deinterlace or inverse telecine filters for the source ("" = none)
+// Soft telecined sources decode to film frames already and need no filter.
+func buildScanFilter(config *model.TranscodeConfig, scan *ffprobe.ScanInfo) string {
+	switch config.Video.Deinterlace {
+	case DeinterlaceOn:
+		return deinterlaceFilter
+	case DeinterlaceIVTC:
+		if scan != nil && scan.Pulldown == ffprobe.PulldownSoft {
+			return ""
+		}
+		return ivtcFilter
+	case DeinterlaceAuto:
+		switch {
+		case scan == nil:
+		case scan.Type == ffprobe.ScanInterlaced:
+			return deinterlaceFilter
+		case scan.Type == ffprobe.ScanTelecined && scan.Pulldown == ffprobe.PulldownHard:
+			return ivtcFilter
+		}
+	}
+	return ""
+}
+
+// buildFrameRateArgs builds the output frame rate arguments
+// An explicit FPS always applies. Constant frame rate output (cfr, or auto on a VFR
+// source) runs at the source's average rate snapped to a standard rate, and
+// inverse telecined soft telecine runs at the film rate.
+func buildFrameRateArgs(config *model.TranscodeConfig, scan *ffprobe.ScanInfo) []string {
+	args := []string{}
+	rate := ""
+	if config.Video.FPS != "" && config.Video.FPS != "original" {
+		rate = config.Video.FPS
+	}
+
+	vfr := scan != nil && scan.VFR
+	if config.Video.FrameRateMode == FrameRateCFR || (config.Video.FrameRateMode == FrameRateAuto && vfr) {
+		args = append(args, "-fps_mode", "cfr")
+		if rate == "" && vfr {
+			rate = cfrRate(ffprobe.ParseFrameRate(scan.AvgFrameRate))
+		}
+	}
+
+	if rate == "" && scan != nil && scan.Pulldown == ffprobe.PulldownSoft &&
+		(config.Video.Deinterlace == DeinterlaceAuto || config.Video.Deinterlace == DeinterlaceIVTC) {
+		// The lower of both rates is the film rate
+		base, avg := ffprobe.ParseFrameRate(scan.FrameRate), ffprobe.ParseFrameRate(scan.AvgFrameRate)
+		rate = cfrRate(min(base, avg))
+	}
+
+	if rate != "" {
+		args = append(args, "-r", rate)
+	}
+	return args
+}
+
+// referenceFilters returns the filters that give the source the frames of the encode,
+// so quality scoring compares matching frames: the deinterlace or inverse telecine filter
+// (which may drop frames) and the output frame rate conversion
+func referenceFilters(config *model.TranscodeConfig, scan *ffprobe.ScanInfo) []string {
+	if (config.Mode == "advanced" && config.CustomCommand != "") || RemuxMode(config) {
+		return nil
+	}
+
+	var filters []string
+	if scanFilter := buildScanFilter(config, scan); scanFilter != "" {
+		filters = append(filters, scanFilter)
+	}
+	if args := buildFrameRateArgs(config, scan); slices.Contains(args, "-r") {
+		filters = append(filters, "fps="+args[slices.Index(args, "-r")+1])
+	}
+	return filters
+}
+
+// cfrRate returns the standard frame rate closest to fps, or fps rounded to 3 decimals
+// when no standard rate is within 1.5% ("" = unknown)
+func cfrRate(fps float64) string {
+	if fps <= 0 {
+		return ""
+	}
+	best, bestDiff := "", math.Inf(1)
+	for _, rate := range cfrRates {
+		diff := math.Abs(ffprobe.ParseFrameRate(rate)-fps) / fps
+		if diff < bestDiff {
+			best, bestDiff = rate, diff
+		}
+	}
+	if bestDiff <= 0.015 {
+		return best
+	}
+	return formatFloat(math.Round(fps*1000) / 1000)
+}
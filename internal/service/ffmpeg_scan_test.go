@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/pkg/ffprobe"
+	"slices"
+	"testing"
+)
+
+func TestParseIdetOutput(t *testing.T) {
+	stderr := `[Parsed_idet_0 @ 0x5611d0a0b340] Repeated Fields: Neither:  1201 Top:    95 Bottom:   102
+[Parsed_idet_0 @ 0x5611d0a0b340] Single frame detection: TFF:   412 BFF:     0 Progressive:   903 Undetermined:    83
+[Parsed_idet_0 @ 0x5611d0a0b340] Multi frame detection: TFF:   540 BFF:     0 Progressive:   851 Undetermined:     7
+`
+
+	tests := []struct {
+		name    string
+		stderr  string
+		want    *ffprobe.IdetCounts
+		wantErr bool
+	}{
+		{
+			name:   "summary",
+			stderr: stderr,
+			want:   &ffprobe.IdetCounts{TFF: 540, Progressive: 851, Undetermined: 7, RepeatedTop: 95, RepeatedBottom: 102},
+		},
+		{
+			name: "last summary counts",
+			stderr: "[Parsed_idet_0 @ 0x1] Multi frame detection: TFF: 1 BFF: 2 Progressive: 3 Undetermined: 4\n" +
+				"[Parsed_idet_0 @ 0x1] Multi frame detection: TFF: 10 BFF: 20 Progressive: 30 Undetermined: 40\n",
+			want: &ffprobe.IdetCounts{TFF: 10, BFF: 20, Progressive: 30, Undetermined: 40},
+		},
+		{name: "no summary", stderr: "Output file is empty, nothing was encoded\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseIdetOutput(tt.stderr)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseIdetOutput() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseIdetOutput() error: %v", err)
+			}
+			if *got != *tt.want {
+				t.Errorf("parseIdetOutput() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyIdet(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts ffprobe.IdetCounts
+		want   string
+	}{
+		{"nothing classified", ffprobe.IdetCounts{Undetermined: 1500}, ""},
+		{"progressive", ffprobe.IdetCounts{TFF: 20, Progressive: 1480}, ffprobe.ScanProgressive},
+		{"interlaced", ffprobe.IdetCounts{TFF: 1450, Progressive: 50}, ffprobe.ScanInterlaced},
+		{"telecined combs", ffprobe.IdetCounts{TFF: 600, Progressive: 900}, ffprobe.ScanTelecined},
+		{"telecined repeats", ffprobe.IdetCounts{BFF: 1200, Progressive: 300, RepeatedTop: 150, RepeatedBottom: 150}, ffprobe.ScanTelecined},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyIdet(&tt.counts); got != tt.want {
+				t.Errorf("classifyIdet(%+v) = %q, want %q", tt.counts, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeScan(t *testing.T) {
+	interlaced := &ffprobe.VideoInfo{
+		Streams:    []ffprobe.StreamInfo{{Index: 0, Type: "video"}},
+		FieldOrder: "tt", FrameRate: "25", AvgFrameRate: "25",
+	}
+	progressive := &ffprobe.VideoInfo{
+		Streams:    []ffprobe.StreamInfo{{Index: 0, Type: "video"}},
+		FieldOrder: "progressive", FrameRate: "25", AvgFrameRate: "25",
+	}
+
+	tests := []struct {
+		name   string
+		config model.TranscodeConfig
+		info   *ffprobe.VideoInfo
+		want   string // scan type, "-" = no analysis
+	}{
+		{"scan not used", model.TranscodeConfig{}, interlaced, "-"},
+		{"custom command", model.TranscodeConfig{Mode: "advanced", CustomCommand: "-c:v libx264", Video: model.VideoConfig{Deinterlace: DeinterlaceAuto}}, interlaced, "-"},
+		{"frame rate mode", model.TranscodeConfig{Video: model.VideoConfig{FrameRateMode: FrameRateAuto}}, interlaced, ffprobe.ScanInterlaced},
+		{"forced deinterlace", model.TranscodeConfig{Video: model.VideoConfig{Deinterlace: DeinterlaceOn, FrameRateMode: FrameRateCFR}}, interlaced, ffprobe.ScanInterlaced},
+		{"auto on progressive metadata", model.TranscodeConfig{Video: model.VideoConfig{Deinterlace: DeinterlaceAuto}}, progressive, ffprobe.ScanProgressive},
+	}
+
+	// None of the cases samples the file with idet
+	fs := &FFmpegService{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scan, err := fs.AnalyzeScan(context.Background(), "/data/movie.mkv", &tt.config, tt.info)
+			if err != nil {
+				t.Fatalf("AnalyzeScan() error: %v", err)
+			}
+			got := "-"
+			if scan != nil {
+				got = scan.Type
+			}
+			if got != tt.want {
+				t.Errorf("AnalyzeScan() type = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildScanFilter(t *testing.T) {
+	interlaced := &ffprobe.ScanInfo{Type: ffprobe.ScanInterlaced}
+	hard := &ffprobe.ScanInfo{Type: ffprobe.ScanTelecined, Pulldown: ffprobe.PulldownHard}
+	soft := &ffprobe.ScanInfo{Type: ffprobe.ScanTelecined, Pulldown: ffprobe.PulldownSoft}
+	progressive := &ffprobe.ScanInfo{Type: ffprobe.ScanProgressive}
+
+	tests := []struct {
+		name        string
+		deinterlace string
+		scan        *ffprobe.ScanInfo
+		want        string
+	}{
+		{"keep fields", "", interlaced, ""},
+		{"forced", DeinterlaceOn, progressive, deinterlaceFilter},
+		{"forced ivtc", DeinterlaceIVTC, nil, ivtcFilter},
+		{"ivtc on soft telecine", DeinterlaceIVTC, soft, ""},
+		{"auto interlaced", DeinterlaceAuto, interlaced, deinterlaceFilter},
+		{"auto hard telecine", DeinterlaceAuto, hard, ivtcFilter},
+		{"auto soft telecine", DeinterlaceAuto, soft, ""},
+		{"auto progressive", DeinterlaceAuto, progressive, ""},
+		{"auto unknown", DeinterlaceAuto, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &model.TranscodeConfig{Video: model.VideoConfig{Deinterlace: tt.deinterlace}}
+			if got := buildScanFilter(config, tt.scan); got != tt.want {
+				t.Errorf("buildScanFilter() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildFrameRateArgs(t *testing.T) {
+	vfr := &ffprobe.ScanInfo{Type: ffprobe.ScanProgressive, FrameRate: "60", AvgFrameRate: "2997/100", VFR: true}
+	soft := &ffprobe.ScanInfo{Type: ffprobe.ScanTelecined, Pulldown: ffprobe.PulldownSoft, FrameRate: "30000/1001", AvgFrameRate: "24000/1001"}
+
+	tests := []struct {
+		name  string
+		video model.VideoConfig
+		scan  *ffprobe.ScanInfo
+		want  []string
+	}{
+		{"container default", model.VideoConfig{}, vfr, []string{}},
+		{"explicit fps", model.VideoConfig{FPS: "30"}, nil, []string{"-r", "30"}},
+		{"original fps", model.VideoConfig{FPS: "original"}, nil, []string{}},
+		{"auto on vfr", model.VideoConfig{FrameRateMode: FrameRateAuto}, vfr, []string{"-fps_mode", "cfr", "-r", "30000/1001"}},
+		{"auto on cfr", model.VideoConfig{FrameRateMode: FrameRateAuto}, &ffprobe.ScanInfo{FrameRate: "25", AvgFrameRate: "25"}, []string{}},
+		{"forced cfr", model.VideoConfig{FrameRateMode: FrameRateCFR}, nil, []string{"-fps_mode", "cfr"}},
+		{"soft telecine film rate", model.VideoConfig{Deinterlace: DeinterlaceAuto}, soft, []string{"-r", "24000/1001"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildFrameRateArgs(&model.TranscodeConfig{Video: tt.video}, tt.scan)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("buildFrameRateArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCfrRate(t *testing.T) {
+	tests := []struct {
+		fps  float64
+		want string
+	}{
+		{0, ""},
+		{23.9, "24000/1001"},
+		{29.97, "30000/1001"},
+		{50.2, "50"},
+		{12.5, "12.5"},
+	}
+
+	for _, tt := range tests {
+		if got := cfrRate(tt.fps); got != tt.want {
+			t.Errorf("cfrRate(%v) = %q, want %q", tt.fps, got, tt.want)
+		}
+	}
+}
+
+func TestReferenceFilters(t *testing.T) {
+	hard := &ffprobe.ScanInfo{Type: ffprobe.ScanTelecined, Pulldown: ffprobe.PulldownHard, FrameRate: "30000/1001", AvgFrameRate: "30000/1001"}
+	vfr := &ffprobe.ScanInfo{Type: ffprobe.ScanProgressive, FrameRate: "60", AvgFrameRate: "2997/100", VFR: true}
+
+	tests := []struct {
+		name   string
+		config model.TranscodeConfig
+		scan   *ffprobe.ScanInfo
+		want   []string
+	}{
+		{"untouched", model.TranscodeConfig{}, hard, nil},
+		{"inverse telecine", model.TranscodeConfig{Video: model.VideoConfig{Deinterlace: DeinterlaceAuto}}, hard, []string{ivtcFilter}},
+		{"vfr to cfr", model.TranscodeConfig{Video: model.VideoConfig{FrameRateMode: FrameRateAuto}}, vfr, []string{"fps=30000/1001"}},
+		{"deinterlace and fps", model.TranscodeConfig{Video: model.VideoConfig{Deinterlace: DeinterlaceOn, FPS: "25"}}, nil, []string{deinterlaceFilter, "fps=25"}},
+		{"remux", model.TranscodeConfig{Mode: ModeRemux, Video: model.VideoConfig{Deinterlace: DeinterlaceOn}}, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := referenceFilters(&tt.config, tt.scan); !slices.Equal(got, tt.want) {
+				t.Errorf("referenceFilters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
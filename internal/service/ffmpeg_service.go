@@ -6,38 +6,131 @@ import (
 	"ffmpeg-web/internal/model"
 	"ffmpeg-web/pkg/ffprobe"
 	"fmt"
+	"log"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// DefaultProbeTimeout bounds a single ffprobe run
+const DefaultProbeTimeout = 60 * time.Second
+
 // FFmpegService handles FFmpeg operations
 type FFmpegService struct {
-	ffmpegPath  string
-	ffprobePath string
-	outputPath  string
+	ffmpegPath   string
+	ffprobePath  string
+	outputPath   string
+	probeTimeout time.Duration
+	probeCache   *ProbeCache
 }
 
 // NewFFmpegService creates a new FFmpeg service
 func NewFFmpegService(ffmpegPath, ffprobePath, outputPath string) *FFmpegService {
 	return &FFmpegService{
-		ffmpegPath:  ffmpegPath,
-		ffprobePath: ffprobePath,
-		outputPath:  outputPath,
+		ffmpegPath:   ffmpegPath,
+		ffprobePath:  ffprobePath,
+		outputPath:   outputPath,
+		probeTimeout: DefaultProbeTimeout,
+	}
+}
+
+// SetProbeTimeout sets how long ffprobe may run before it is killed (0 keeps the default)
+func (fs *FFmpegService) SetProbeTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		fs.probeTimeout = timeout
+	}
+}
+
+// SetProbeCache makes ProbeFile reuse the results of unchanged files
+func (fs *FFmpegService) SetProbeCache(cache *ProbeCache) {
+	fs.probeCache = cache
+}
+
+// ProbeFile gets media information using ffprobe, from the probe cache when the file didn't change
+// ffprobe is killed when ctx ends or the probe timeout passes.
+func (fs *FFmpegService) ProbeFile(ctx context.Context, filePath string) (*ffprobe.VideoInfo, error) {
+	if fs.probeCache == nil {
+		return fs.probe(ctx, filePath)
+	}
+
+	path, err := filepath.Abs(filePath)
+	if err != nil {
+		return fs.probe(ctx, filePath)
+	}
+	stat, err := os.Stat(path)
+	if err != nil || stat.IsDir() {
+		// ffprobe reports the error
+		return fs.probe(ctx, filePath)
+	}
+
+	if info := fs.probeCache.Get(path, stat.Size(), stat.ModTime()); info != nil {
+		return info, nil
+	}
+	info, err := fs.probe(ctx, path)
+	if err != nil {
+		return nil, err
 	}
+	fs.probeCache.Put(path, stat.Size(), stat.ModTime(), info)
+	return info, nil
 }
 
-// ProbeFile gets video information using ffprobe
-func (fs *FFmpegService) ProbeFile(filePath string) (*ffprobe.VideoInfo, error) {
-	return ffprobe.Probe(fs.ffprobePath, filePath)
+// CachedProbe returns the cached probe result of a file version without running ffprobe (nil = not cached)
+func (fs *FFmpegService) CachedProbe(path string, size int64, modTime time.Time) *ffprobe.VideoInfo {
+	if fs.probeCache == nil {
+		return nil
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+	return fs.probeCache.Get(path, size, modTime)
+}
+
+// probe runs ffprobe with the probe timeout
+func (fs *FFmpegService) probe(ctx context.Context, filePath string) (*ffprobe.VideoInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, fs.probeTimeout)
+	defer cancel()
+	return ffprobe.Probe(ctx, fs.ffprobePath, filePath)
+}
+
+// EncodeOptions carries values resolved by the worker right before encoding,
+// as opposed to TranscodeConfig which is fixed when the task is created
+type EncodeOptions struct {
+	// Loudness holds the loudnorm measurement pass results per source audio track
+	// (tracks without one use single-pass loudnorm)
+	Loudness []model.LoudnessStats
+	// VideoBitrate is the bitrate computed for target-size rate control (e.g. "3500k")
+	VideoBitrate string
+	// Pass is the two-pass encoding pass (1 = analysis, 2 = final encode, 0 = single pass)
+	Pass int
+	// PassLogDir is the directory ffmpeg runs in for two-pass encoding (holds the stats files)
+	PassLogDir string
+	// Device is the GPU picked by the worker pool for "auto" device selection
+	Device string
+	// Chunk limits the command to a video-only encode of one segment (chunked encoding)
+	Chunk *ChunkRange
+	// ConcatList is the concat demuxer list of encoded segments; the command then muxes
+	// the joined video with the audio, subtitles and attachments of the source
+	ConcatList string
+	// Sample limits a regular encode (all streams) to a range of the source (sample encodes)
+	Sample *ChunkRange
+	// Scan is the scan analysis of the source (nil = analyzed from the probed metadata)
+	Scan *ffprobe.ScanInfo
 }
 
 // BuildCommand builds an FFmpeg command based on configuration
 // sourceVideoInfo contains metadata about the source file, used for dynamic HDR handling
-func (fs *FFmpegService) BuildCommand(ctx context.Context, sourceFile, outputFile string, config *model.TranscodeConfig, sourceVideoInfo *ffprobe.VideoInfo) *exec.Cmd {
+// opts may be nil when no run-time values are needed
+// Returns an error when the configuration can't be turned into a command (e.g. unknown encoder)
+func (fs *FFmpegService) BuildCommand(ctx context.Context, sourceFile, outputFile string, config *model.TranscodeConfig, sourceVideoInfo *ffprobe.VideoInfo, opts *EncodeOptions) (*exec.Cmd, error) {
 	args := []string{}
+	if opts == nil {
+		opts = &EncodeOptions{}
+	}
 
 	// Check if using advanced mode (custom CLI)
 	if config.Mode == "advanced" && config.CustomCommand != "" {
@@ -63,22 +156,24 @@ func (fs *FFmpegService) BuildCommand(ctx context.Context, sourceFile, outputFil
 			args = append(args, "-progress", "pipe:2")
 			args = append(args, outputFile)
 		}
+	} else if RemuxMode(config) {
+		// Remux mode: streams are copied, nothing is decoded
+		inputArgs, outputArgs := fs.buildRemuxArgs(sourceFile, config, sourceVideoInfo, opts)
+		args = append(args, inputArgs...)
+		args = append(args, "-y") // Overwrite output file
+		args = append(args, outputArgs...)
+		args = append(args, "-progress", "pipe:2")
+		args = append(args, outputTarget(outputFile, config, sourceVideoInfo)...)
+	} else if AudioMode(config) {
+		// Audio task: only audio tracks (and cover art) end up in the output
+		inputArgs, outputArgs := fs.buildAudioModeArgs(sourceFile, config, sourceVideoInfo, opts)
+		args = append(args, inputArgs...)
+		args = append(args, "-y") // Overwrite output file
+		args = append(args, outputArgs...)
+		args = append(args, "-progress", "pipe:2")
+		args = append(args, outputFile)
 	} else {
 		// Simple mode: use UI-based configuration
-		// IMPORTANT: Hardware acceleration flags must come BEFORE -i input file
-		args = append(args, fs.buildHardwareAccelArgs(config.HardwareAccel)...)
-
-		// Add input file
-		args = append(args, "-i", sourceFile)
-		args = append(args, "-y") // Overwrite output file
-
-		// Map all streams by default to preserve multiple audio tracks, subtitles, attachments
-		args = append(args, "-map", "0")
-
-		// Preserve metadata from source
-		args = append(args, "-map_metadata", "0")
-
-		// Determine if source is HDR
 		sourceIsHDR := sourceVideoInfo != nil && sourceVideoInfo.IsHDR
 
 		if sourceIsHDR {
@@ -89,57 +184,111 @@ func (fs *FFmpegService) BuildCommand(ctx context.Context, sourceFile, outputFil
 				sourceFile, sourceVideoInfo.ColorSpace, sourceVideoInfo.ColorTransfer)
 		}
 
-		// Add video encoding args (with HDR handling)
-		// Returns args and any encoder-specific params string (for x265-params/svtav1-params)
-		videoArgs, encoderParamKey, encoderParamValue := fs.buildVideoArgs(config, sourceVideoInfo)
-		args = append(args, videoArgs...)
+		inputArgs, outputArgs, err := fs.buildSimpleModeArgs(sourceFile, config, sourceVideoInfo, opts)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, inputArgs...)
+		args = append(args, "-y") // Overwrite output file
+		args = append(args, outputArgs...)
 
-		// Add audio encoding args
-		args = append(args, fs.buildAudioArgs(&config.Audio)...)
+		// Add progress reporting
+		args = append(args, "-progress", "pipe:2")
 
-		// Preserve subtitles (copy all subtitle streams)
-		args = append(args, "-c:s", "copy")
+		if opts.Pass == 1 {
+			// Analysis pass: only the video stream is encoded, output is discarded
+			args = append(args, "-an", "-sn", "-dn", "-map", "-0:t", "-f", "null", os.DevNull)
+		} else {
+			// Output file (or package directory)
+			args = append(args, outputTarget(outputFile, config, sourceVideoInfo)...)
+		}
+	}
 
-		// Preserve attachments (fonts for subtitles, etc.)
-		args = append(args, "-c:t", "copy")
+	cmd := exec.CommandContext(ctx, fs.ffmpegPath, args...)
+	if opts.Pass > 0 {
+		// Two-pass stats files are referenced relative to the pass log directory
+		cmd.Dir = opts.PassLogDir
+	}
+	return cmd, nil
+}
 
-		// Handle extra parameters with encoder params merging
-		if config.ExtraParams != "" {
-			// Try to merge encoder-specific params if both HDR and extra params have them
-			mergedExtra, merged := mergeEncoderParams(config.ExtraParams, encoderParamKey, encoderParamValue)
-			if merged {
-				extraArgs := parseExtraParams(mergedExtra)
-				args = append(args, extraArgs...)
-			} else {
-				// No merging needed, add HDR encoder params first, then extra params
-				if encoderParamKey != "" && encoderParamValue != "" {
-					args = append(args, encoderParamKey, encoderParamValue)
-				}
-				extraArgs := parseExtraParams(config.ExtraParams)
-				args = append(args, extraArgs...)
-			}
-		} else {
-			// No extra params, just add HDR encoder params if any
-			if encoderParamKey != "" && encoderParamValue != "" {
-				args = append(args, encoderParamKey, encoderParamValue)
-			}
+// ValidateTranscodeConfig checks a transcode configuration for settings that can never work
+// It is called when tasks are created so invalid configs are rejected up front
+func ValidateTranscodeConfig(config *model.TranscodeConfig) error {
+	if err := validateSkipRules(config.Skip); err != nil {
+		return err
+	}
+	if err := validateSizePolicy(config.SizePolicy); err != nil {
+		return err
+	}
+	if err := validateVerify(config.Verify); err != nil {
+		return err
+	}
+	if err := validateQuality(config.Quality); err != nil {
+		return err
+	}
+	if err := validatePackaging(config); err != nil {
+		return err
+	}
+	if err := validateThumbnails(config); err != nil {
+		return err
+	}
+
+	if config.Mode == "advanced" && config.CustomCommand != "" {
+		return nil
+	}
+
+	// Audio tasks have no video, the encoder settings are ignored
+	if AudioMode(config) {
+		if err := validateAudioMode(config); err != nil {
+			return err
 		}
+		if err := validateTrim(config); err != nil {
+			return err
+		}
+		return validateLoudnorm(&config.Audio)
+	}
 
-		// Add progress reporting
-		args = append(args, "-progress", "pipe:2")
+	// Remuxes don't encode, the encoder settings are ignored
+	if RemuxMode(config) {
+		if err := validateRemux(config); err != nil {
+			return err
+		}
+		return validateTrim(config)
+	}
 
-		// Output file
-		args = append(args, outputFile)
+	codec, err := selectVideoCodec(config.Encoder, config.HardwareAccel)
+	if err != nil {
+		return err
 	}
 
-	cmd := exec.CommandContext(ctx, fs.ffmpegPath, args...)
-	return cmd
+	if err := validateRateControl(config, codec); err != nil {
+		return err
+	}
+
+	if err := validateScanSettings(&config.Video); err != nil {
+		return err
+	}
+
+	if err := validateDevice(config.Device, config.HardwareAccel); err != nil {
+		return err
+	}
+
+	if err := validateChunking(config); err != nil {
+		return err
+	}
+
+	if err := validateTrim(config); err != nil {
+		return err
+	}
+
+	return validateLoudnorm(&config.Audio)
 }
 
 // BuildCommandPreview generates FFmpeg command arguments for preview display
 // Unlike BuildCommand, this doesn't require a context and returns args directly for display
 // sourceVideoInfo can be nil for preview (assumes HDR mode applies when hdrMode is set)
-func (fs *FFmpegService) BuildCommandPreview(sourceFile string, config *model.TranscodeConfig) []string {
+func (fs *FFmpegService) BuildCommandPreview(sourceFile string, config *model.TranscodeConfig, sourceVideoInfo *ffprobe.VideoInfo) ([]string, error) {
 	// Generate output file path
 	outputFile := fs.GenerateOutputPath(sourceFile, config)
 
@@ -164,50 +313,264 @@ func (fs *FFmpegService) BuildCommandPreview(sourceFile string, config *model.Tr
 			args = append(args, outputFile)
 		}
 
-		return args
+		return args, nil
 	}
 
-	// Simple mode: use UI-based configuration
-	args = append(args, fs.buildHardwareAccelArgs(config.HardwareAccel)...)
-
-	// Add input file
-	args = append(args, "-i", sourceFile)
-
-	// Map all streams
-	args = append(args, "-map", "0")
+	if RemuxMode(config) {
+		inputArgs, outputArgs := fs.buildRemuxArgs(sourceFile, config, sourceVideoInfo, &EncodeOptions{})
+		args = append(args, inputArgs...)
+		args = append(args, outputArgs...)
+		return append(args, outputTarget(outputFile, config, sourceVideoInfo)...), nil
+	}
 
-	// Preserve metadata
-	args = append(args, "-map_metadata", "0")
+	if AudioMode(config) {
+		inputArgs, outputArgs := fs.buildAudioModeArgs(sourceFile, config, sourceVideoInfo, &EncodeOptions{})
+		args = append(args, inputArgs...)
+		args = append(args, outputArgs...)
+		return append(args, outputFile), nil
+	}
 
-	// For preview, assume HDR applies when hdrMode is set (we don't have actual video info)
+	// Without actual video info, assume HDR applies when hdrMode is set
 	// Create a mock VideoInfo based on hdrMode setting
-	var mockVideoInfo *ffprobe.VideoInfo
-	if len(config.Video.HdrMode) > 0 && config.Video.HdrMode[0] == "auto" {
+	if sourceVideoInfo == nil && len(config.Video.HdrMode) > 0 && config.Video.HdrMode[0] == "auto" {
 		// For preview, assume HDR10 (PQ) as default when HDR mode is enabled
-		mockVideoInfo = &ffprobe.VideoInfo{
+		sourceVideoInfo = &ffprobe.VideoInfo{
 			IsHDR:         true,
 			ColorTransfer: "smpte2084", // Default to PQ for preview
 		}
 	}
 
-	// Build video args
-	videoArgs, encoderParamKey, encoderParamValue := fs.buildVideoArgs(config, mockVideoInfo)
-	args = append(args, videoArgs...)
+	// Two-pass encodes are shown with their final pass
+	opts := &EncodeOptions{}
+	if fs.UsesTwoPass(config) {
+		opts.Pass = 2
+	}
+	if rateControlMode(&config.Video) == RateControlTargetSize && sourceVideoInfo != nil {
+		if bitrate, err := TargetVideoBitrate(config, sourceVideoInfo); err == nil {
+			opts.VideoBitrate = fmt.Sprintf("%dk", bitrate/1000)
+		}
+	}
+
+	// Simple mode: use UI-based configuration
+	inputArgs, outputArgs, err := fs.buildSimpleModeArgs(sourceFile, config, sourceVideoInfo, opts)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, inputArgs...)
+	args = append(args, outputArgs...)
+
+	// Output file or package directory (no progress pipe for preview)
+	args = append(args, outputTarget(outputFile, config, sourceVideoInfo)...)
+
+	return args, nil
+}
+
+// buildSimpleModeArgs builds the arguments for simple (UI-based) mode
+// Returns the input arguments (hardware acceleration flags and -i, which must come first)
+// and the output arguments (mapping, codecs, filters, extra params) without the output file.
+func (fs *FFmpegService) buildSimpleModeArgs(sourceFile string, config *model.TranscodeConfig, sourceVideoInfo *ffprobe.VideoInfo, opts *EncodeOptions) ([]string, []string, error) {
+	// Select codec based on encoder and hardware acceleration
+	codec, err := selectVideoCodec(config.Encoder, config.HardwareAccel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	subtitles := planSubtitles(sourceFile, config, sourceVideoInfo)
+	compat := CheckCompatibility(config, sourceVideoInfo)
+
+	// HLS/DASH packages carry the main video (or its rendition ladder) and the first audio track
+	packaged := Packaged(config)
+	renditions := packageRenditions(config)
+
+	// Kept source ranges: one range is cut by seeking, several are cut and joined by filter graphs
+	sourceDuration := 0.0
+	if sourceVideoInfo != nil {
+		sourceDuration = sourceVideoInfo.Duration
+	}
+	trimRanges := TrimRanges(config, sourceDuration)
+	audioTrimFilter := ""
+	if len(trimRanges) > 1 {
+		audioTrimFilter = buildTrimFilter(trimRanges, "a")
+	}
+	// Where the input is seeked to (sample encodes and single-range trims)
+	inputSeek := 0.0
+	if opts.Sample != nil {
+		inputSeek = opts.Sample.Start
+	} else if len(trimRanges) == 1 {
+		inputSeek = trimRanges[0].Start
+	}
+
+	// Deinterlacing and inverse telecine work on the source fields, so they come first
+	// (image subtitles are overlaid onto the deinterlaced frames)
+	scanFilter := buildScanFilter(config, scanOf(sourceVideoInfo, opts))
+	mainVideo := "[0:v:0]"
+	if scanFilter != "" && subtitles.burnOverlay >= 0 {
+		mainVideo = "[0:v:0]" + scanFilter + "[src];[src]"
+	}
+
+	// Video filters applied to the main video stream
+	var videoFilters []string
+	if scanFilter != "" && subtitles.burnOverlay < 0 {
+		videoFilters = append(videoFilters, scanFilter)
+	}
+	if subtitles.burnFilter != "" {
+		videoFilters = append(videoFilters, seekedBurnFilter(subtitles.burnFilter, inputSeek))
+	}
+	if len(trimRanges) > 1 {
+		// Cut after the burn-in, which needs the source timestamps
+		videoFilters = append(videoFilters, buildTrimFilter(trimRanges, "v"))
+	}
+	if hardwareBackend(config.HardwareAccel) == "vaapi" {
+		// VA-API encoders take GPU frames, scaling is done with scale_vaapi
+		videoFilters = append(videoFilters, buildVAAPIFilters(config, codec, sourceVideoInfo)...)
+	}
+
+	var inputArgs []string
+	if opts.ConcatList != "" {
+		// Chunked encoding mux: the encoded video comes from the joined segments (input 1)
+		inputArgs = []string{"-i", sourceFile, "-f", "concat", "-safe", "0", "-i", opts.ConcatList}
+	} else {
+		// IMPORTANT: Hardware acceleration flags must come BEFORE -i input file
+		// Software filters (e.g. subtitle burn-in) need decoded frames in system memory
+		// (as do the scalers of a rendition ladder and the deinterlacers)
+		inputArgs = fs.buildHardwareAccelArgs(config.HardwareAccel, effectiveDevice(config, opts), subtitles.needsSoftwareFrames() || len(renditions) > 0 || scanFilter != "")
+		if opts.Chunk != nil {
+			// Input seeking to the segment's keyframe
+			inputArgs = append(inputArgs, "-ss", formatFloat(opts.Chunk.Start))
+		} else if inputSeek > 0 {
+			// Accurate input seeking: decoding starts at the previous keyframe, frames before the start are dropped
+			inputArgs = append(inputArgs, "-ss", formatFloat(inputSeek))
+		}
+		inputArgs = append(inputArgs, "-i", sourceFile)
+	}
+
+	args := []string{}
+
+	if opts.Chunk != nil {
+		// Segment encode: only the main video stream
+		args = append(args, "-map", "0:v:0")
+		if opts.Chunk.Duration > 0 {
+			args = append(args, "-t", formatFloat(opts.Chunk.Duration))
+		}
+		if len(videoFilters) > 0 {
+			args = append(args, "-filter:v:0", strings.Join(videoFilters, ","))
+		}
+	} else if opts.ConcatList != "" {
+		// Joined video first, then every non-video stream of the source
+		args = append(args, "-map", "1:v:0", "-map", "0", "-map", "-0:v")
+	} else if len(renditions) > 0 {
+		// Rendition ladder: the main video is filtered once, then split and scaled per rendition
+		inputs, filters := mainVideo, videoFilters
+		if subtitles.burnOverlay >= 0 {
+			inputs = fmt.Sprintf("%s[0:s:%d]", mainVideo, subtitles.burnOverlay)
+			filters = append([]string{"overlay"}, videoFilters...)
+		}
+		args = append(args, "-filter_complex", buildLadderGraph(inputs, filters, renditions))
+		for i := range renditions {
+			args = append(args, "-map", fmt.Sprintf("[v%d]", i))
+		}
+		args = append(args, "-map", "0:a:0?")
+	} else if subtitles.burnOverlay >= 0 {
+		// Image subtitles are burned in with overlay, which needs a complex filtergraph;
+		// the filtered video replaces the source video stream
+		graph := fmt.Sprintf("%s[0:s:%d]overlay", mainVideo, subtitles.burnOverlay)
+		if len(videoFilters) > 0 {
+			graph += "," + strings.Join(videoFilters, ",")
+		}
+		args = append(args, "-filter_complex", graph+"[vout]")
+		if packaged {
+			args = append(args, "-map", "[vout]", "-map", "0:a:0?")
+		} else {
+			args = append(args, "-map", "[vout]", "-map", "0:a?", "-map", "0:s?", "-map", "0:t?", "-map", "0:d?")
+		}
+	} else {
+		if packaged {
+			args = append(args, "-map", "0:v:0", "-map", "0:a:0?")
+		} else {
+			// Map all streams by default to preserve multiple audio tracks, subtitles, attachments
+			args = append(args, "-map", "0")
+		}
+		if len(videoFilters) > 0 {
+			args = append(args, "-filter:v:0", strings.Join(videoFilters, ","))
+		}
+	}
+
+	if opts.Sample != nil && opts.Sample.Duration > 0 {
+		args = append(args, "-t", formatFloat(opts.Sample.Duration))
+	} else if len(trimRanges) == 1 && trimRanges[0].Duration > 0 {
+		args = append(args, "-t", formatFloat(trimRanges[0].Duration))
+	}
+
+	if opts.Chunk == nil && !packaged {
+		// Remove subtitle streams that are dropped or burned in
+		args = append(args, subtitles.mapArgs()...)
+
+		// Remove attachment/data streams the container can't hold
+		args = append(args, compat.streamMapArgs()...)
 
-	// Build audio args
-	args = append(args, fs.buildAudioArgs(&config.Audio)...)
+		// Subtitles, data streams and chapters can't be cut by the filter graph
+		if len(trimRanges) > 1 {
+			args = append(args, "-sn", "-dn", "-map_chapters", "-1")
+		}
+	}
+
+	// Preserve metadata from source
+	args = append(args, "-map_metadata", "0")
 
-	// Preserve subtitles and attachments
-	args = append(args, "-c:s", "copy")
-	args = append(args, "-c:t", "copy")
+	// Add video encoding args (with HDR handling)
+	// Returns args and any encoder-specific params string (for x265-params/svtav1-params)
+	encoderParamKey, encoderParamValue := "", ""
+	if opts.ConcatList != "" {
+		// Segments are already encoded
+		args = append(args, "-c:v", "copy")
+	} else {
+		var videoArgs []string
+		videoArgs, encoderParamKey, encoderParamValue = fs.buildVideoArgs(config, codec, sourceVideoInfo, opts)
+		args = append(args, videoArgs...)
+	}
+
+	if packaged {
+		// Rendition bitrates, keyframes on the segment boundaries
+		args = append(args, renditionArgs(renditions)...)
+		args = append(args, keyframeArgs(&config.Output)...)
+	}
+
+	if opts.Chunk == nil {
+		// Packages only carry the first audio track
+		audioSource := sourceVideoInfo
+		if packaged {
+			audioSource = packagedSource(sourceVideoInfo)
+		}
+
+		// Add audio encoding args
+		args = append(args, fs.buildAudioArgs(&config.Audio, audioSource, opts.Loudness, audioTrimFilter)...)
+
+		// Convert audio streams the container can't hold
+		args = append(args, compat.audioFixArgs(audioSource)...)
+		if compat.fixes() && compat.needsExperimental() && !containsArg(args, "-strict") {
+			args = append(args, "-strict", "experimental")
+		}
+
+		if !packaged {
+			// Subtitle codecs (copy or convert for the target container)
+			args = append(args, subtitles.codecArgs()...)
+
+			// Preserve attachments (fonts for subtitles, etc.) where the container supports them
+			if containerSupportsAttachments(outputContainer(&config.Output)) {
+				args = append(args, "-c:t", "copy")
+			}
+		}
+	}
 
 	// Handle extra parameters with encoder params merging
 	if config.ExtraParams != "" {
+		// Try to merge encoder-specific params if both HDR and extra params have them
 		mergedExtra, merged := mergeEncoderParams(config.ExtraParams, encoderParamKey, encoderParamValue)
 		if merged {
 			extraArgs := parseExtraParams(mergedExtra)
 			args = append(args, extraArgs...)
 		} else {
+			// No merging needed, add HDR encoder params first, then extra params
 			if encoderParamKey != "" && encoderParamValue != "" {
 				args = append(args, encoderParamKey, encoderParamValue)
 			}
@@ -215,15 +578,13 @@ func (fs *FFmpegService) BuildCommandPreview(sourceFile string, config *model.Tr
 			args = append(args, extraArgs...)
 		}
 	} else {
+		// No extra params, just add HDR encoder params if any
 		if encoderParamKey != "" && encoderParamValue != "" {
 			args = append(args, encoderParamKey, encoderParamValue)
 		}
 	}
 
-	// Output file (no progress pipe for preview)
-	args = append(args, outputFile)
-
-	return args
+	return inputArgs, args, nil
 }
 
 // Returns the merged extra params string and whether merging occurred.
@@ -329,62 +690,116 @@ func parseExtraParams(params string) []string {
 	return args
 }
 
+// containsArg checks if an argument list contains the given option
+func containsArg(args []string, option string) bool {
+	for _, arg := range args {
+		if arg == option {
+			return true
+		}
+	}
+	return false
+}
+
 // buildHardwareAccelArgs builds hardware acceleration arguments
-func (fs *FFmpegService) buildHardwareAccelArgs(hwAccel string) []string {
+// softwareFrames keeps decoded frames in system memory (no -hwaccel_output_format),
+// which is required when software filters such as subtitle burn-in are used
+// device selects the GPU ("" = ffmpeg default, see TranscodeConfig.Device)
+func (fs *FFmpegService) buildHardwareAccelArgs(hwAccel, device string, softwareFrames bool) []string {
 	args := []string{}
 
-	switch hwAccel {
+	switch hardwareBackend(hwAccel) {
 	case "nvidia":
-		args = append(args, "-hwaccel", "cuda", "-hwaccel_output_format", "cuda")
+		args = append(args, "-hwaccel", "cuda")
+		if index, ok := nvidiaDeviceIndex(device); ok {
+			args = append(args, "-hwaccel_device", index)
+		}
+		if !softwareFrames {
+			args = append(args, "-hwaccel_output_format", "cuda")
+		}
 	case "intel":
-		args = append(args, "-hwaccel", "qsv", "-hwaccel_output_format", "qsv")
+		if device != "" {
+			args = append(args, "-qsv_device", device)
+		}
+		args = append(args, "-hwaccel", "qsv")
+		if !softwareFrames {
+			args = append(args, "-hwaccel_output_format", "qsv")
+		}
 	case "amd":
 		// AMD AMF typically doesn't need input hardware acceleration
+	case "vaapi":
+		// -vaapi_device also provides the device for hwupload in the filter chain
+		if device == "" {
+			device = vaapiDevice(hwAccel)
+		}
+		args = append(args, "-vaapi_device", device, "-hwaccel", "vaapi")
+		if !softwareFrames {
+			args = append(args, "-hwaccel_output_format", "vaapi")
+		}
 	}
 
 	return args
 }
 
-// buildVideoArgs builds video encoding arguments
+// buildVideoArgs builds video encoding arguments for the selected codec
 // Returns:
 //   - args: list of ffmpeg arguments (excluding encoder-specific params that need merging)
 //   - encoderParamKey: e.g., "-x265-params" or "-svtav1-params" (empty if not applicable)
 //   - encoderParamValue: the param value string (empty if not applicable)
 //
 // Preset parameter usage varies by encoder:
-//   - libx265 (H.265 CPU): uses -preset with values: ultrafast, superfast, veryfast, faster, fast, medium, slow, slower, veryslow
+//   - libx265/libx264 (CPU): uses -preset with values: ultrafast, superfast, veryfast, faster, fast, medium, slow, slower, veryslow
 //   - libsvtav1 (AV1 CPU): uses -preset with values 0-13 (0=slowest/best quality, 13=fastest/lower quality)
+//   - libvpx-vp9 (VP9 CPU): preset is the -cpu-used value 0-8 (0=slowest/best quality, 8=fastest)
+//   - prores_ks (ProRes CPU): preset is the profile: proxy, lt, standard, hq, 4444, 4444xq
 //   - NVIDIA NVENC: uses -preset with values: p1-p7, or quality presets (slow, medium, fast)
 //   - Intel QSV: uses -preset with standard values (slow, medium, fast, etc.)
 //   - AMD AMF: uses -quality with values: quality, balanced, speed
-func (fs *FFmpegService) buildVideoArgs(config *model.TranscodeConfig, sourceVideoInfo *ffprobe.VideoInfo) ([]string, string, string) {
+//   - VA-API: uses -compression_level 1-7 (driver-specific), AMF quality values are mapped
+func (fs *FFmpegService) buildVideoArgs(config *model.TranscodeConfig, codec string, sourceVideoInfo *ffprobe.VideoInfo, opts *EncodeOptions) ([]string, string, string) {
 	args := []string{}
 	encoderParamKey := ""
 	encoderParamValue := ""
 
-	sourceIsHDR := sourceVideoInfo != nil && sourceVideoInfo.IsHDR
-
-	// Select codec based on encoder and hardware acceleration
-	codec := fs.selectVideoCodec(config.Encoder, config.HardwareAccel)
 	args = append(args, "-c:v", codec)
 
 	// Add encoder-specific parameters
-	switch config.HardwareAccel {
+	backend := hardwareBackend(config.HardwareAccel)
+	switch backend {
 	case "cpu":
-		// CPU encoding - both libx265 and libsvtav1 use -preset
-		if config.Video.Preset != "" {
-			args = append(args, "-preset", config.Video.Preset)
-		} else {
-			// Default presets
-			if config.Encoder == "av1" {
-				args = append(args, "-preset", "6") // SVT-AV1 default: balanced
+		switch codec {
+		case "libvpx-vp9":
+			// libvpx-vp9 has no -preset, speed is controlled by -cpu-used
+			cpuUsed := config.Video.Preset
+			if cpuUsed == "" {
+				cpuUsed = "2"
+			}
+			args = append(args, "-deadline", "good", "-cpu-used", cpuUsed, "-row-mt", "1")
+
+		case "prores_ks":
+			// ProRes quality is set by the profile
+			profile, ok := proresProfiles[config.Video.Preset]
+			if !ok {
+				profile = proresProfiles["hq"]
+			}
+			args = append(args, "-profile:v", strconv.Itoa(profile), "-vendor", "apl0")
+			if profile >= proresProfiles["4444"] {
+				args = append(args, "-pix_fmt", "yuv444p10le")
 			} else {
-				args = append(args, "-preset", "medium") // libx265 default
+				args = append(args, "-pix_fmt", "yuv422p10le")
 			}
-		}
 
-		if config.Video.CRF > 0 {
-			args = append(args, "-crf", strconv.Itoa(config.Video.CRF))
+		default:
+			// libx265, libx264 and libsvtav1 use -preset
+			if config.Video.Preset != "" {
+				args = append(args, "-preset", config.Video.Preset)
+			} else {
+				// Default presets
+				if codec == "libsvtav1" {
+					args = append(args, "-preset", "6") // SVT-AV1 default: balanced
+				} else {
+					args = append(args, "-preset", "medium") // libx265/libx264 default
+				}
+			}
 		}
 
 	case "nvidia":
@@ -394,8 +809,9 @@ func (fs *FFmpegService) buildVideoArgs(config *model.TranscodeConfig, sourceVid
 		} else {
 			args = append(args, "-preset", "p4") // Default balanced
 		}
-		if config.Video.CRF > 0 {
-			args = append(args, "-cq", strconv.Itoa(config.Video.CRF))
+		// NVENC picks its GPU separately from the CUDA decoder
+		if index, ok := nvidiaDeviceIndex(effectiveDevice(config, opts)); ok {
+			args = append(args, "-gpu", index)
 		}
 
 	case "intel":
@@ -405,9 +821,6 @@ func (fs *FFmpegService) buildVideoArgs(config *model.TranscodeConfig, sourceVid
 		} else {
 			args = append(args, "-preset", "medium")
 		}
-		if config.Video.CRF > 0 {
-			args = append(args, "-global_quality", strconv.Itoa(config.Video.CRF))
-		}
 
 	case "amd":
 		// AMD AMF - uses quality/balanced/speed
@@ -416,32 +829,27 @@ func (fs *FFmpegService) buildVideoArgs(config *model.TranscodeConfig, sourceVid
 		} else {
 			args = append(args, "-quality", "balanced")
 		}
-		if config.Video.CRF > 0 {
-			args = append(args, "-qp_i", strconv.Itoa(config.Video.CRF))
-		}
+
+	case "vaapi":
+		// VA-API - driver default compression level unless set
+		args = append(args, vaapiPresetArgs(config.Video.Preset)...)
 	}
 
-	// Resolution
-	if config.Video.Resolution != "" && config.Video.Resolution != "original" {
+	// Resolution (VA-API scales in the filter chain, rendition ladders in their filter graph)
+	if config.Video.Resolution != "" && config.Video.Resolution != "original" && backend != "vaapi" && len(packageRenditions(config)) == 0 {
 		args = append(args, "-s", config.Video.Resolution)
 	}
 
-	// Frame rate
-	if config.Video.FPS != "" && config.Video.FPS != "original" {
-		args = append(args, "-r", config.Video.FPS)
-	}
+	// Frame rate and frame timing (constant frame rate output, film rate of telecined sources)
+	args = append(args, buildFrameRateArgs(config, scanOf(sourceVideoInfo, opts))...)
 
-	// Bitrate (if specified)
-	if config.Video.Bitrate != "" {
-		args = append(args, "-b:v", config.Video.Bitrate)
-	}
+	// Rate control (CRF, CBR, two-pass/target-size ABR)
+	rateControlArgs, rateControlParams := buildRateControlArgs(codec, config, opts)
+	args = append(args, rateControlArgs...)
 
 	// HDR handling based on source video and user preference
 	// "auto" mode: preserve HDR metadata when source is HDR, otherwise passthrough
-	if len(config.Video.HdrMode) > 0 && config.Video.HdrMode[0] == "auto" && sourceIsHDR {
-		// HDR -> HDR: preserve HDR metadata
-		args = append(args, "-pix_fmt", "yuv420p10le")
-
+	if preservesHDR(config, sourceVideoInfo) {
 		// Determine transfer function (PQ vs HLG)
 		colorTransfer := "smpte2084" // default to PQ
 		transferCharacteristic := "16"
@@ -450,109 +858,172 @@ func (fs *FFmpegService) buildVideoArgs(config *model.TranscodeConfig, sourceVid
 			transferCharacteristic = "18"
 		}
 
+		// Color metadata flags for encoders without their own HDR params
+		colorArgs := []string{
+			"-color_primaries", "bt2020",
+			"-color_trc", colorTransfer, // Dynamic: smpte2084 or arib-std-b67
+			"-colorspace", "bt2020nc",
+		}
+
 		// Add encoder-specific HDR parameters
-		switch config.HardwareAccel {
-		case "cpu":
-			if config.Encoder == "h265" || config.Encoder == "hevc" {
+		switch {
+		case codec == "libx265":
+			// HDR -> HDR: preserve HDR metadata
+			args = append(args, "-pix_fmt", "yuv420p10le")
+			args = append(args, "-profile:v", "main10")
+			// Build x265-params with HDR metadata
+			x265Params := fmt.Sprintf("hdr-opt=1:repeat-headers=1:colorprim=bt2020:transfer=%s:colormatrix=bt2020nc", colorTransfer)
+			// Add mastering display if available
+			if sourceVideoInfo.MasteringDisplay != "" {
+				x265Params += ":master-display=" + sourceVideoInfo.MasteringDisplay
+			}
+			// Add max-cll if available
+			if sourceVideoInfo.MaxCLL > 0 || sourceVideoInfo.MaxFALL > 0 {
+				x265Params += fmt.Sprintf(":max-cll=%d,%d", sourceVideoInfo.MaxCLL, sourceVideoInfo.MaxFALL)
+			}
+			encoderParamKey = "-x265-params"
+			encoderParamValue = x265Params
+
+		case codec == "libsvtav1":
+			args = append(args, "-pix_fmt", "yuv420p10le")
+			// Build svtav1-params with HDR metadata
+			svtav1Params := fmt.Sprintf("color-primaries=9:transfer-characteristics=%s:matrix-coefficients=9", transferCharacteristic)
+			// Add mastering display if available
+			if sourceVideoInfo.MasteringDisplay != "" {
+				svtav1Params += ":mastering-display=" + sourceVideoInfo.MasteringDisplay
+			}
+			// Add content-light if available
+			if sourceVideoInfo.MaxCLL > 0 || sourceVideoInfo.MaxFALL > 0 {
+				svtav1Params += fmt.Sprintf(":content-light=%d,%d", sourceVideoInfo.MaxCLL, sourceVideoInfo.MaxFALL)
+			}
+			encoderParamKey = "-svtav1-params"
+			encoderParamValue = svtav1Params
+
+		case codec == "libvpx-vp9":
+			// VP9 profile 2 carries 10-bit HDR
+			args = append(args, "-pix_fmt", "yuv420p10le", "-profile:v", "2")
+			args = append(args, colorArgs...)
+
+		case codec == "prores_ks":
+			// ProRes is already 10-bit, only the color metadata is needed
+			args = append(args, colorArgs...)
+
+		case strings.HasPrefix(codec, "h264") || codec == "libx264":
+			// H.264 is used for compatibility copies, 10-bit HDR H.264 is barely supported by players
+			log.Printf("HDR metadata is not preserved for H.264 output (%s)", codec)
+
+		case codec == "vp9_vaapi":
+			// Frames are uploaded as p010 (see buildVAAPIFilters)
+			args = append(args, "-profile:v", "2")
+			args = append(args, colorArgs...)
+
+		case strings.HasSuffix(codec, "_vaapi"):
+			// The pixel format is set by the upload filters
+			if codec == "hevc_vaapi" {
 				args = append(args, "-profile:v", "main10")
-				// Build x265-params with HDR metadata
-				x265Params := fmt.Sprintf("hdr-opt=1:repeat-headers=1:colorprim=bt2020:transfer=%s:colormatrix=bt2020nc", colorTransfer)
-				// Add mastering display if available
-				if sourceVideoInfo.MasteringDisplay != "" {
-					x265Params += ":master-display=" + sourceVideoInfo.MasteringDisplay
-				}
-				// Add max-cll if available
-				if sourceVideoInfo.MaxCLL > 0 || sourceVideoInfo.MaxFALL > 0 {
-					x265Params += fmt.Sprintf(":max-cll=%d,%d", sourceVideoInfo.MaxCLL, sourceVideoInfo.MaxFALL)
-				}
-				encoderParamKey = "-x265-params"
-				encoderParamValue = x265Params
-			} else if config.Encoder == "av1" {
-				// Build svtav1-params with HDR metadata
-				svtav1Params := fmt.Sprintf("color-primaries=9:transfer-characteristics=%s:matrix-coefficients=9", transferCharacteristic)
-				// Add mastering display if available
-				if sourceVideoInfo.MasteringDisplay != "" {
-					svtav1Params += ":mastering-display=" + sourceVideoInfo.MasteringDisplay
-				}
-				// Add content-light if available
-				if sourceVideoInfo.MaxCLL > 0 || sourceVideoInfo.MaxFALL > 0 {
-					svtav1Params += fmt.Sprintf(":content-light=%d,%d", sourceVideoInfo.MaxCLL, sourceVideoInfo.MaxFALL)
-				}
-				encoderParamKey = "-svtav1-params"
-				encoderParamValue = svtav1Params
 			}
-		case "nvidia", "intel", "amd":
-			// Hardware encoders: use profile main10 and color metadata
+			args = append(args, colorArgs...)
+
+		case codec == "vp9_qsv":
+			args = append(args, "-pix_fmt", "yuv420p10le")
+			args = append(args, colorArgs...)
+
+		default:
+			// Hardware HEVC/AV1 encoders: use profile main10 and color metadata
+			args = append(args, "-pix_fmt", "yuv420p10le")
 			args = append(args, "-profile:v", "main10")
-			args = append(args, "-color_primaries", "bt2020")
-			args = append(args, "-color_trc", colorTransfer) // Dynamic: smpte2084 or arib-std-b67
-			args = append(args, "-colorspace", "bt2020nc")
+			args = append(args, colorArgs...)
+		}
+	}
+
+	// Encoder params from rate control are combined with the HDR params
+	if len(rateControlParams) > 0 {
+		if encoderParamKey == "" {
+			encoderParamKey = encoderParamsKey(codec)
+			encoderParamValue = strings.Join(rateControlParams, ":")
+		} else {
+			encoderParamValue += ":" + strings.Join(rateControlParams, ":")
 		}
 	}
 
 	return args, encoderParamKey, encoderParamValue
 }
 
-// buildAudioArgs builds audio encoding arguments
-func (fs *FFmpegService) buildAudioArgs(audio *model.AudioConfig) []string {
-	args := []string{}
-
-	// Audio codec
-	if audio.Codec == "copy" {
-		args = append(args, "-c:a", "copy")
-	} else {
-		args = append(args, "-c:a", audio.Codec)
-
-		// Add -strict -2 for opus encoder (experimental feature flag)
-		if audio.Codec == "opus" {
-			args = append(args, "-strict", "-2")
-		}
+// proresProfiles maps ProRes profile names to prores_ks profile numbers
+var proresProfiles = map[string]int{
+	"proxy":    0,
+	"lt":       1,
+	"standard": 2,
+	"hq":       3,
+	"4444":     4,
+	"4444xq":   5,
+}
 
-		// Audio bitrate
-		if audio.Bitrate != "" {
-			args = append(args, "-b:a", audio.Bitrate)
-		}
+// videoEncoders maps encoder families and hardware acceleration to ffmpeg encoders
+var videoEncoders = map[string]map[string]string{
+	"h265": {
+		"cpu":    "libx265",
+		"nvidia": "hevc_nvenc",
+		"intel":  "hevc_qsv",
+		"amd":    "hevc_amf",
+		"vaapi":  "hevc_vaapi",
+	},
+	"av1": {
+		// libsvtav1 (SVT-AV1) - faster than libaom-av1 with good quality
+		"cpu":    "libsvtav1",
+		"nvidia": "av1_nvenc",
+		"intel":  "av1_qsv",
+		"amd":    "av1_amf",
+		"vaapi":  "av1_vaapi",
+	},
+	"h264": {
+		"cpu":    "libx264",
+		"nvidia": "h264_nvenc",
+		"intel":  "h264_qsv",
+		"amd":    "h264_amf",
+		"vaapi":  "h264_vaapi",
+	},
+	"vp9": {
+		"cpu":   "libvpx-vp9",
+		"intel": "vp9_qsv",
+		"vaapi": "vp9_vaapi",
+	},
+	"prores": {
+		"cpu": "prores_ks",
+	},
+}
 
-		// Audio channels
-		if audio.Channels > 0 {
-			args = append(args, "-ac", strconv.Itoa(audio.Channels))
-		}
+// encoderFamily resolves an encoder setting and its aliases to the encoder family (H.265 by default)
+func encoderFamily(encoder string) string {
+	switch encoder {
+	case "", "hevc":
+		return "h265"
+	case "avc":
+		return "h264"
 	}
-
-	return args
+	return encoder
 }
 
-// selectVideoCodec selects the appropriate video codec
-func (fs *FFmpegService) selectVideoCodec(encoder, hwAccel string) string {
-	if encoder == "h265" || encoder == "hevc" {
-		switch hwAccel {
-		case "nvidia":
-			return "hevc_nvenc"
-		case "intel":
-			return "hevc_qsv"
-		case "amd":
-			return "hevc_amf"
-		default:
-			return "libx265"
-		}
+// selectVideoCodec selects the ffmpeg encoder for an encoder family and hardware acceleration
+func selectVideoCodec(encoder, hwAccel string) (string, error) {
+	encoder = encoderFamily(encoder)
+	hwAccel = hardwareBackend(hwAccel)
+
+	encoders, ok := videoEncoders[encoder]
+	if !ok {
+		return "", fmt.Errorf("unknown encoder: %s", encoder)
 	}
 
-	if encoder == "av1" {
-		switch hwAccel {
-		case "nvidia":
-			return "av1_nvenc"
-		case "intel":
-			return "av1_qsv"
-		default:
-			// Use libsvtav1 (SVT-AV1) - faster than libaom-av1 with good quality
-			return "libsvtav1"
-		}
+	codec, ok := encoders[hwAccel]
+	if !ok {
+		return "", fmt.Errorf("%s encoding is not supported with %s hardware acceleration", encoder, hwAccel)
 	}
 
-	return "libx265" // Default
+	return codec, nil
 }
 
 // GenerateOutputPath generates an output file path based on config
+// HLS/DASH outputs get the path of their package directory instead
 func (fs *FFmpegService) GenerateOutputPath(sourceFile string, config *model.TranscodeConfig) string {
 	dir := filepath.Dir(sourceFile)
 	filename := filepath.Base(sourceFile)
@@ -565,10 +1036,7 @@ func (fs *FFmpegService) GenerateOutputPath(sourceFile string, config *model.Tra
 	}
 
 	// Add suffix
-	suffix := config.Output.Suffix
-	if suffix == "" {
-		suffix = "_transcoded"
-	}
+	suffix := outputSuffix(config)
 
 	// Determine output extension
 	outputExt := "." + config.Output.Container
@@ -577,6 +1045,10 @@ func (fs *FFmpegService) GenerateOutputPath(sourceFile string, config *model.Tra
 	}
 
 	outputFilename := nameWithoutExt + suffix + outputExt
+	if Packaged(config) {
+		// HLS/DASH packages are a directory named after the source
+		outputFilename = nameWithoutExt + suffix
+	}
 
 	// Determine output directory based on PathType
 	var outputDir string
@@ -603,6 +1075,32 @@ func (fs *FFmpegService) GenerateOutputPath(sourceFile string, config *model.Tra
 	return outputPath
 }
 
+// outputSuffix returns the suffix appended to output file names
+func outputSuffix(config *model.TranscodeConfig) string {
+	if config.Output.Suffix == "" {
+		return "_transcoded"
+	}
+	return config.Output.Suffix
+}
+
+// hasOutputSuffix reports whether a file name (without extension) ends with the config's output suffix
+func hasOutputSuffix(name string, config *model.TranscodeConfig) bool {
+	return strings.HasSuffix(strings.TrimSuffix(name, filepath.Ext(name)), outputSuffix(config))
+}
+
+// IsOutputFile reports whether a file is named like an output of the config:
+// the file, or the HLS/DASH package directory it sits in, carries the output suffix.
+// Outputs that overwrite their source keep the source name and can't be recognized.
+func IsOutputFile(path string, config *model.TranscodeConfig) bool {
+	if config.Output.PathType == "overwrite" {
+		return false
+	}
+	if hasOutputSuffix(filepath.Base(path), config) {
+		return true
+	}
+	return Packaged(config) && strings.HasSuffix(filepath.Base(filepath.Dir(path)), outputSuffix(config))
+}
+
 // ProgressUpdate represents a progress update from FFmpeg
 type ProgressUpdate struct {
 	Frame     int
@@ -652,6 +1150,7 @@ func ParseProgress(line string) (*ProgressUpdate, error) {
 }
 
 // CalculateProgress calculates percentage and ETA
+// totalDuration is the output duration (see TrimmedDuration), ffmpeg's out_time counts from 0
 func CalculateProgress(currentTime, totalDuration, speed float64) (progress float64, eta int64) {
 	if totalDuration <= 0 {
 		return 0, 0
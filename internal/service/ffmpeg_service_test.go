@@ -0,0 +1,32 @@
+package service
+
+import (
+	"ffmpeg-web/internal/model"
+	"testing"
+)
+
+func TestIsOutputFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		output model.OutputConfig
+		want   bool
+	}{
+		{"source", "/data/in/movie.mkv", model.OutputConfig{PathType: "source"}, false},
+		{"default suffix", "/data/in/movie_transcoded.mp4", model.OutputConfig{PathType: "source"}, true},
+		{"custom suffix", "/data/in/movie_h265.mkv", model.OutputConfig{PathType: "source", Suffix: "_h265"}, true},
+		{"other suffix", "/data/in/movie_transcoded.mkv", model.OutputConfig{PathType: "source", Suffix: "_h265"}, false},
+		{"overwrite", "/data/in/movie_transcoded.mkv", model.OutputConfig{PathType: "overwrite"}, false},
+		{"package segment", "/data/in/movie_hls/stream_0.ts", model.OutputConfig{PathType: "source", Suffix: "_hls", Kind: "hls"}, true},
+		{"segment outside a package", "/data/in/movie_hls/stream_0.ts", model.OutputConfig{PathType: "source", Suffix: "_hls"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &model.TranscodeConfig{Output: tt.output}
+			if got := IsOutputFile(tt.path, config); got != tt.want {
+				t.Errorf("IsOutputFile(%q) = %t, want %t", tt.path, got, tt.want)
+			}
+		})
+	}
+}
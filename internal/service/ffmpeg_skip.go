@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/pkg/ffprobe"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// probedCodecs maps encoder families to the codec names ffprobe reports
+var probedCodecs = map[string]string{
+	"h265":   "hevc",
+	"h264":   "h264",
+	"av1":    "av1",
+	"vp9":    "vp9",
+	"prores": "prores",
+}
+
+// validateSkipRules checks the skip rule values
+func validateSkipRules(rules *model.SkipRules) error {
+	if rules == nil {
+		return nil
+	}
+	if rules.BitrateBelow != "" {
+		if _, ok := parseBitrate(rules.BitrateBelow); !ok {
+			return fmt.Errorf("invalid skip bitrate: %s", rules.BitrateBelow)
+		}
+	}
+	if rules.HeightBelow < 0 {
+		return fmt.Errorf("skip height can't be negative")
+	}
+	return nil
+}
+
+// CheckSkipRules returns why a source file matches the config's skip rules, or nil if it should be transcoded
+// The output suffix is checked first so previous outputs are skipped without probing.
+// Files that can't be probed are not skipped; their task reports the probe error.
+func (fs *FFmpegService) CheckSkipRules(ctx context.Context, sourceFile string, config *model.TranscodeConfig) *model.SkippedFile {
+	rules := config.Skip
+	if rules == nil {
+		return nil
+	}
+
+	if rules.OutputSuffix && config.Output.PathType != "overwrite" {
+		if hasOutputSuffix(filepath.Base(sourceFile), config) {
+			return skipped(sourceFile, model.SkipReasonOutputSuffix, "file name ends with the output suffix %s", outputSuffix(config))
+		}
+	}
+
+	if sameCodecTarget(config) == "" && rules.BitrateBelow == "" && rules.HeightBelow == 0 {
+		return nil
+	}
+
+	info, err := fs.ProbeFile(ctx, sourceFile)
+	if err != nil {
+		log.Printf("Skip rules: failed to probe %s: %v", sourceFile, err)
+		return nil
+	}
+	return checkProbedSkipRules(sourceFile, config, info)
+}
+
+// checkProbedSkipRules returns why a probed source file matches the codec, bitrate or height rules
+func checkProbedSkipRules(sourceFile string, config *model.TranscodeConfig, info *ffprobe.VideoInfo) *model.SkippedFile {
+	rules := config.Skip
+	if target := sameCodecTarget(config); target != "" && strings.EqualFold(info.Codec, target) {
+		return skipped(sourceFile, model.SkipReasonSameCodec, "video is already %s", info.Codec)
+	}
+
+	if rules.BitrateBelow != "" && info.Bitrate > 0 {
+		if threshold, ok := parseBitrate(rules.BitrateBelow); ok && info.Bitrate < threshold {
+			return skipped(sourceFile, model.SkipReasonLowBitrate, "bitrate %d kb/s is below %s", info.Bitrate/1000, rules.BitrateBelow)
+		}
+	}
+
+	if rules.HeightBelow > 0 && info.Height > 0 && info.Height < rules.HeightBelow {
+		return skipped(sourceFile, model.SkipReasonLowHeight, "resolution %dx%d is below %d lines", info.Width, info.Height, rules.HeightBelow)
+	}
+
+	return nil
+}
+
+// sameCodecTarget returns the ffprobe codec name the same-codec rule compares against ("" = rule off)
+// Advanced mode commands don't declare their encoder, remuxes keep the codec and audio tasks
+// have no video, so the rule doesn't apply to them.
+func sameCodecTarget(config *model.TranscodeConfig) string {
+	if !config.Skip.SameCodec || (config.Mode == "advanced" && config.CustomCommand != "") || RemuxMode(config) || AudioMode(config) {
+		return ""
+	}
+
+	return probedCodecs[encoderFamily(config.Encoder)]
+}
+
+// skipped builds a skipped file entry
+func skipped(file, reason, format string, args ...interface{}) *model.SkippedFile {
+	return &model.SkippedFile{
+		File:    file,
+		Reason:  reason,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Size policy actions
+const (
+	SizeActionDiscard = "discard"
+	SizeActionKeep    = "keep"
+)
+
+// validateSizePolicy checks the size policy values
+func validateSizePolicy(policy *model.SizePolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if policy.MaxPercent < 0 {
+		return fmt.Errorf("size policy percentage can't be negative")
+	}
+	switch policy.Action {
+	case "", SizeActionDiscard, SizeActionKeep:
+		return nil
+	default:
+		return fmt.Errorf("unknown size policy action: %s", policy.Action)
+	}
+}
+
+// CheckOutputSize compares an output with the config's size policy
+// Returns the output size in percent of the source and whether it exceeds the limit
+// (never when the policy is off or the source size is unknown).
+func CheckOutputSize(config *model.TranscodeConfig, sourceSize, outputSize int64) (float64, bool) {
+	policy := config.SizePolicy
+	if policy == nil || policy.MaxPercent <= 0 || sourceSize <= 0 {
+		return 0, false
+	}
+
+	percent := float64(outputSize) * 100 / float64(sourceSize)
+	return percent, percent > float64(policy.MaxPercent)
+}
+
+// DiscardsLargerOutput reports whether outputs exceeding the size policy are deleted
+func DiscardsLargerOutput(config *model.TranscodeConfig) bool {
+	return config.SizePolicy != nil && config.SizePolicy.Action != SizeActionKeep
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/pkg/ffprobe"
+	"testing"
+)
+
+// skipReason returns the reason of a skipped file ("" = not skipped)
+func skipReason(skip *model.SkippedFile) string {
+	if skip == nil {
+		return ""
+	}
+	return skip.Reason
+}
+
+func TestCheckSkipRulesOutputSuffix(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		output model.OutputConfig
+		want   string
+	}{
+		{"previous output", "/data/movie_transcoded.mkv", model.OutputConfig{PathType: "source"}, model.SkipReasonOutputSuffix},
+		{"custom suffix", "/data/movie_av1.mkv", model.OutputConfig{PathType: "source", Suffix: "_av1"}, model.SkipReasonOutputSuffix},
+		{"source file", "/data/movie.mkv", model.OutputConfig{PathType: "source"}, ""},
+		{"overwrite", "/data/movie_transcoded.mkv", model.OutputConfig{PathType: "overwrite"}, ""},
+	}
+
+	// Only the suffix rule is enabled, so no file is probed
+	fs := &FFmpegService{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &model.TranscodeConfig{Output: tt.output, Skip: &model.SkipRules{OutputSuffix: true}}
+			if got := skipReason(fs.CheckSkipRules(context.Background(), tt.file, config)); got != tt.want {
+				t.Errorf("CheckSkipRules(%q) = %q, want %q", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSameCodecTarget(t *testing.T) {
+	tests := []struct {
+		name   string
+		config model.TranscodeConfig
+		want   string
+	}{
+		{"default encoder", model.TranscodeConfig{}, "hevc"},
+		{"hevc alias", model.TranscodeConfig{Encoder: "hevc"}, "hevc"},
+		{"h265", model.TranscodeConfig{Encoder: "h265"}, "hevc"},
+		{"avc alias", model.TranscodeConfig{Encoder: "avc"}, "h264"},
+		{"av1", model.TranscodeConfig{Encoder: "av1"}, "av1"},
+		{"advanced without a command", model.TranscodeConfig{Mode: "advanced", Encoder: "vp9"}, "vp9"},
+		{"custom command", model.TranscodeConfig{Mode: "advanced", CustomCommand: "-c:v libx265"}, ""},
+		{"remux", model.TranscodeConfig{Mode: ModeRemux}, ""},
+		{"audio task", model.TranscodeConfig{Mode: ModeAudio}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Skip = &model.SkipRules{SameCodec: true}
+			if got := sameCodecTarget(&tt.config); got != tt.want {
+				t.Errorf("sameCodecTarget() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := sameCodecTarget(&model.TranscodeConfig{Skip: &model.SkipRules{}}); got != "" {
+		t.Errorf("sameCodecTarget() with the rule off = %q, want \"\"", got)
+	}
+}
+
+func TestCheckProbedSkipRules(t *testing.T) {
+	source := &ffprobe.VideoInfo{Codec: "hevc", Bitrate: 3000000, Width: 1280, Height: 720}
+
+	tests := []struct {
+		name  string
+		rules model.SkipRules
+		info  *ffprobe.VideoInfo
+		want  string
+	}{
+		{"same codec", model.SkipRules{SameCodec: true}, source, model.SkipReasonSameCodec},
+		{"other codec", model.SkipRules{SameCodec: true}, &ffprobe.VideoInfo{Codec: "h264"}, ""},
+		{"bitrate below", model.SkipRules{BitrateBelow: "4M"}, source, model.SkipReasonLowBitrate},
+		{"bitrate above", model.SkipRules{BitrateBelow: "2500k"}, source, ""},
+		{"unknown bitrate", model.SkipRules{BitrateBelow: "4M"}, &ffprobe.VideoInfo{Codec: "h264"}, ""},
+		{"height below", model.SkipRules{HeightBelow: 1080}, source, model.SkipReasonLowHeight},
+		{"height at threshold", model.SkipRules{HeightBelow: 720}, source, ""},
+		{"unknown height", model.SkipRules{HeightBelow: 1080}, &ffprobe.VideoInfo{Codec: "h264"}, ""},
+		{"codec before bitrate", model.SkipRules{SameCodec: true, BitrateBelow: "4M"}, source, model.SkipReasonSameCodec},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &model.TranscodeConfig{Skip: &tt.rules}
+			if got := skipReason(checkProbedSkipRules("/data/movie.mkv", config, tt.info)); got != tt.want {
+				t.Errorf("checkProbedSkipRules() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
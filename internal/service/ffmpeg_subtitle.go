@@ -0,0 +1,258 @@
+package service
+
+import (
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/pkg/ffprobe"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// subtitleFormatCodecs maps user-facing subtitle format names to ffmpeg codec names
+var subtitleFormatCodecs = map[string]string{
+	"srt":      "subrip",
+	"subrip":   "subrip",
+	"ass":      "ass",
+	"ssa":      "ssa",
+	"webvtt":   "webvtt",
+	"mov_text": "mov_text",
+}
+
+// isImageSubtitle checks if a subtitle codec is bitmap-based
+func isImageSubtitle(codec string) bool {
+	return ffprobe.IsImageSubtitle(codec)
+}
+
+// containerSupportsImageSubtitles checks if a container can mux bitmap subtitles
+// Unknown containers are assumed to (ffmpeg reports the error if not)
+func containerSupportsImageSubtitles(container string) bool {
+	support, ok := containerSupports[container]
+	return !ok || support.imageSubtitles
+}
+
+// outputContainer returns the effective output container (GenerateOutputPath defaults to mp4)
+func outputContainer(output *model.OutputConfig) string {
+	// HLS/DASH packages hold their streams in the segments
+	if output.Kind == OutputKindHLS || output.Kind == OutputKindDASH {
+		if segmentType(output) == SegmentTypeTS {
+			return "ts"
+		}
+		return "mp4"
+	}
+	if output.Container == "" {
+		return "mp4"
+	}
+	return strings.ToLower(output.Container)
+}
+
+// subtitlePlan describes how subtitle streams are mapped and encoded
+type subtitlePlan struct {
+	// dropAll removes every subtitle stream from the output
+	dropAll bool
+	// drop lists subtitle-relative source indexes removed from the output
+	drop []int
+	// codecs holds the codec per output subtitle stream (in output order) when the source is probed
+	codecs []string
+	// globalCodec applies to all subtitle streams when the source streams are unknown
+	globalCodec string
+	// burnFilter is the subtitles filter for text burn-in (empty if none)
+	burnFilter string
+	// burnOverlay is the subtitle-relative index burned in with overlay (-1 if none)
+	burnOverlay int
+}
+
+// planSubtitles decides which subtitle streams are kept, converted, dropped or burned in
+func planSubtitles(sourceFile string, config *model.TranscodeConfig, sourceVideoInfo *ffprobe.VideoInfo) *subtitlePlan {
+	plan := &subtitlePlan{burnOverlay: -1}
+	subtitle := &config.Subtitle
+	container := outputContainer(&config.Output)
+	fixIncompatible := compatibilityPolicy(&config.Output) == CompatibilityAuto
+
+	var streams []ffprobe.StreamInfo
+	if sourceVideoInfo != nil {
+		streams = sourceVideoInfo.StreamsOfType("subtitle")
+	}
+
+	mode := subtitle.Mode
+	if mode == "" {
+		mode = "copy"
+	}
+
+	burnIndex := -1
+	switch mode {
+	case "none":
+		plan.dropAll = true
+		return plan
+
+	case "burn":
+		burnIndex = subtitle.BurnStream
+		// Skipped when the source was probed (preview info may have no streams)
+		if sourceVideoInfo != nil && len(sourceVideoInfo.Streams) > 0 && burnIndex >= len(streams) {
+			log.Printf("Subtitle track %d not found in %s (%d subtitle tracks), skipping burn-in",
+				burnIndex, sourceFile, len(streams))
+			burnIndex = -1
+		}
+
+		if burnIndex >= 0 {
+			if burnIndex < len(streams) && isImageSubtitle(streams[burnIndex].Codec) {
+				plan.burnOverlay = burnIndex
+			} else {
+				// Text subtitles (or unknown source in preview) go through libass
+				plan.burnFilter = buildSubtitlesFilter(sourceFile, burnIndex)
+			}
+		}
+
+		if !subtitle.KeepOthers {
+			plan.dropAll = true
+			return plan
+		}
+
+		// Remaining tracks are converted for the container like in convert mode
+		mode = "convert"
+		if burnIndex >= 0 {
+			plan.drop = append(plan.drop, burnIndex)
+		}
+	}
+
+	// Source streams unknown (e.g. command preview): one codec for all subtitle streams
+	if len(streams) == 0 {
+		plan.globalCodec = "copy"
+		if mode == "convert" {
+			if subtitle.Format != "" {
+				plan.globalCodec = subtitleTarget("", container, subtitle.Format)
+			} else if support, ok := containerSupports[container]; ok && len(support.textSubtitles) == 1 {
+				// Containers with a single text format need conversion whatever the source
+				plan.globalCodec = support.textSubtitles[0]
+			}
+		}
+		return plan
+	}
+
+	for i, stream := range streams {
+		if i == burnIndex {
+			continue
+		}
+
+		// Copy mode keeps streams as-is, unless the compatibility policy fixes
+		// the ones the container can't hold
+		if mode == "copy" && !fixIncompatible {
+			plan.codecs = append(plan.codecs, "copy")
+			continue
+		}
+
+		format := subtitle.Format
+		if mode == "copy" {
+			format = ""
+		}
+		target := subtitleTarget(stream.Codec, container, format)
+		if target == "" {
+			log.Printf("Dropping subtitle track %d (%s): not supported by %s", i, stream.Codec, container)
+			plan.drop = append(plan.drop, i)
+			continue
+		}
+		plan.codecs = append(plan.codecs, target)
+	}
+
+	return plan
+}
+
+// subtitleTarget returns the output codec for a source subtitle codec in convert mode
+// Returns "copy" when the stream can be kept as-is and "" when it must be dropped
+func subtitleTarget(codec, container, format string) string {
+	if isImageSubtitle(codec) {
+		if containerSupportsImageSubtitles(container) {
+			return "copy"
+		}
+		return ""
+	}
+
+	support, known := containerSupports[container]
+
+	// Explicitly requested format
+	if format != "" {
+		target := subtitleFormatCodecs[format]
+		if target == "" {
+			target = format
+		}
+		if target == codec {
+			return "copy"
+		}
+		return target
+	}
+
+	if !known {
+		return "copy"
+	}
+	if codecAllowed(support.textSubtitles, codec) {
+		return "copy"
+	}
+	if len(support.textSubtitles) == 0 {
+		return ""
+	}
+	return support.textSubtitles[0]
+}
+
+// needsSoftwareFrames reports whether the plan uses software video filters
+func (p *subtitlePlan) needsSoftwareFrames() bool {
+	return p.burnFilter != "" || p.burnOverlay >= 0
+}
+
+// mapArgs returns negative -map arguments for removed subtitle streams
+// Must come after the positive -map arguments
+func (p *subtitlePlan) mapArgs() []string {
+	if p.dropAll {
+		return []string{"-map", "-0:s"}
+	}
+
+	args := []string{}
+	for _, index := range p.drop {
+		args = append(args, "-map", fmt.Sprintf("-0:s:%d", index))
+	}
+	return args
+}
+
+// codecArgs returns the subtitle codec arguments
+func (p *subtitlePlan) codecArgs() []string {
+	if p.dropAll {
+		return nil
+	}
+
+	if p.codecs == nil {
+		if p.globalCodec == "" {
+			return nil
+		}
+		return []string{"-c:s", p.globalCodec}
+	}
+
+	args := []string{}
+	for i, codec := range p.codecs {
+		args = append(args, "-c:s:"+strconv.Itoa(i), codec)
+	}
+	return args
+}
+
+// buildSubtitlesFilter builds a libass subtitles filter burning in the given subtitle track
+// Fonts embedded as attachments (common with ASS in mkv) are loaded by the filter from the source file
+func buildSubtitlesFilter(sourceFile string, subtitleIndex int) string {
+	return fmt.Sprintf("subtitles=filename='%s':si=%d", escapeFilterPath(sourceFile), subtitleIndex)
+}
+
+// seekedBurnFilter adapts a subtitles filter to an input seeked to seek seconds
+// Input seeking resets the timestamps to 0, while the subtitles filter reads the source file
+// and times the text by frame PTS; the frames are shifted to source time and back around it.
+func seekedBurnFilter(burnFilter string, seek float64) string {
+	if burnFilter == "" || seek <= 0 {
+		return burnFilter
+	}
+	return fmt.Sprintf("setpts=PTS+%s/TB,%s,setpts=PTS-STARTPTS", formatFloat(seek), burnFilter)
+}
+
+// escapeFilterPath escapes a file path for use as a quoted filter option value
+// See https://ffmpeg.org/ffmpeg-filters.html#Notes-on-filtergraph-escaping
+func escapeFilterPath(path string) string {
+	path = strings.ReplaceAll(path, "\\", "/")
+	path = strings.ReplaceAll(path, ":", "\\:")
+	path = strings.ReplaceAll(path, "'", `'\\\''`)
+	return path
+}
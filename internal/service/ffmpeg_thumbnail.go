@@ -0,0 +1,335 @@
+package service
+
+import (
+	"context"
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/pkg/ffprobe"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Thumbnail kinds
+const (
+	ThumbnailPoster    = "poster"    // A single frame
+	ThumbnailSheet     = "sheet"     // Frames spread over the whole duration in a grid
+	ThumbnailTrickplay = "trickplay" // WebVTT seek previews with sprite images
+)
+
+// Thumbnail defaults and limits
+const (
+	DefaultPosterAt          = 10 // Percent of the duration
+	DefaultTrickplayInterval = 10 // Seconds between trickplay frames
+	defaultPosterWidth       = 640
+	defaultSheetWidth        = 320 // Tile width
+	defaultSheetGrid         = 4
+	defaultTrickplayWidth    = 160 // Tile width
+	defaultTrickplayGrid     = 10
+	maxThumbnailWidth        = 1920
+	maxThumbnailGrid         = 10
+)
+
+// Files of a trickplay set: the WebVTT index and its numbered sprite images
+const (
+	TrickplayIndex   = "trickplay.vtt"
+	trickplaySprites = "sprite_%03d.jpg"
+)
+
+// ThumbnailOptions describes an image generated from a video (zero values use the defaults)
+type ThumbnailOptions struct {
+	Kind     string  // poster, sheet or trickplay
+	At       float64 // Poster position in percent of the duration
+	Width    int     // Poster width, tile width of sheets and sprites
+	Columns  int     // Tiles per row of a sheet or sprite
+	Rows     int     // Tile rows of a sheet or sprite
+	Interval float64 // Seconds between trickplay frames
+}
+
+// normalized fills in the defaults and checks the limits
+func (o ThumbnailOptions) normalized() (ThumbnailOptions, error) {
+	grid := defaultSheetGrid
+	switch o.Kind {
+	case ThumbnailPoster:
+		if o.At == 0 {
+			o.At = DefaultPosterAt
+		}
+		if o.Width == 0 {
+			o.Width = defaultPosterWidth
+		}
+	case ThumbnailSheet:
+		if o.Width == 0 {
+			o.Width = defaultSheetWidth
+		}
+	case ThumbnailTrickplay:
+		grid = defaultTrickplayGrid
+		if o.Width == 0 {
+			o.Width = defaultTrickplayWidth
+		}
+		if o.Interval == 0 {
+			o.Interval = DefaultTrickplayInterval
+		}
+	default:
+		return o, fmt.Errorf("unknown thumbnail kind: %s (use poster, sheet or trickplay)", o.Kind)
+	}
+	if o.Kind != ThumbnailPoster {
+		if o.Columns == 0 {
+			o.Columns = grid
+		}
+		if o.Rows == 0 {
+			o.Rows = grid
+		}
+	}
+
+	if o.At < 0 || o.At > 100 {
+		return o, fmt.Errorf("thumbnail position must be between 0 and 100 percent")
+	}
+	if o.Width < 16 || o.Width > maxThumbnailWidth {
+		return o, fmt.Errorf("thumbnail width must be between 16 and %d", maxThumbnailWidth)
+	}
+	if o.Columns < 0 || o.Columns > maxThumbnailGrid || o.Rows < 0 || o.Rows > maxThumbnailGrid {
+		return o, fmt.Errorf("thumbnail grid can have at most %d columns and rows", maxThumbnailGrid)
+	}
+	if o.Interval < 0 {
+		return o, fmt.Errorf("trickplay interval can't be negative")
+	}
+	return o, nil
+}
+
+// Validate checks the options of a thumbnail
+func (o ThumbnailOptions) Validate() error {
+	_, err := o.normalized()
+	return err
+}
+
+// ThumbnailsEnabled reports whether preview images of the output are written after the encode
+func ThumbnailsEnabled(config *model.TranscodeConfig) bool {
+	thumbnails := config.Thumbnails
+	return thumbnails != nil && (thumbnails.Poster || thumbnails.ContactSheet || thumbnails.Trickplay)
+}
+
+// validateThumbnails checks the post-encode thumbnail settings
+func validateThumbnails(config *model.TranscodeConfig) error {
+	if !ThumbnailsEnabled(config) {
+		return nil
+	}
+	thumbnails := config.Thumbnails
+	if thumbnails.PosterAt < 0 || thumbnails.PosterAt > 100 {
+		return fmt.Errorf("poster position must be between 0 and 100 percent")
+	}
+	if thumbnails.Interval < 0 {
+		return fmt.Errorf("trickplay interval can't be negative")
+	}
+	return nil
+}
+
+// thumbnailSize returns the tile size of a video scaled to width (even, 16:9 when the size is unknown)
+func thumbnailSize(info *ffprobe.VideoInfo, width int) (int, int) {
+	height := float64(width) * 9 / 16
+	if info != nil && info.Width > 0 && info.Height > 0 {
+		height = float64(width) * float64(info.Height) / float64(info.Width)
+	}
+	return width, max(2, int(math.Round(height/2))*2)
+}
+
+// Poster writes a single frame at opts.At percent of the duration to outputFile (JPEG)
+func (fs *FFmpegService) Poster(ctx context.Context, sourceFile string, info *ffprobe.VideoInfo, opts ThumbnailOptions, outputFile string) error {
+	opts, err := opts.normalized()
+	if err != nil {
+		return err
+	}
+
+	args := []string{}
+	if duration := durationOf(info); duration > 0 {
+		args = append(args, "-ss", formatFloat(math.Round(duration*opts.At)/100))
+	}
+	args = append(args,
+		"-i", sourceFile,
+		"-map", "0:v:0",
+		"-vf", fmt.Sprintf("scale=%d:-2", opts.Width),
+		"-frames:v", "1", "-update", "1", "-q:v", "3",
+		outputFile,
+	)
+	return fs.runThumbnailCommand(ctx, args)
+}
+
+// ContactSheet writes a grid of frames spread evenly over the duration to outputFile (JPEG)
+// Every frame is a separate seek, so long files don't have to be decoded.
+func (fs *FFmpegService) ContactSheet(ctx context.Context, sourceFile string, info *ffprobe.VideoInfo, opts ThumbnailOptions, outputFile string) error {
+	opts, err := opts.normalized()
+	if err != nil {
+		return err
+	}
+	duration := durationOf(info)
+	if duration <= 0 {
+		return fmt.Errorf("contact sheets need a known source duration")
+	}
+
+	width, height := thumbnailSize(info, opts.Width)
+	frames := opts.Columns * opts.Rows
+	args := []string{}
+	graph := []string{}
+	var tiles strings.Builder
+	for i := 0; i < frames; i++ {
+		at := duration * (float64(i) + 0.5) / float64(frames)
+		args = append(args, "-ss", formatFloat(math.Round(at*100)/100), "-i", sourceFile)
+		graph = append(graph, fmt.Sprintf("[%d:v:0]trim=end_frame=1,setpts=PTS-STARTPTS,scale=%d:%d,setsar=1[f%d]", i, width, height, i))
+		fmt.Fprintf(&tiles, "[f%d]", i)
+	}
+	graph = append(graph, fmt.Sprintf("%sconcat=n=%d:v=1:a=0,tile=%dx%d", tiles.String(), frames, opts.Columns, opts.Rows))
+
+	args = append(args,
+		"-filter_complex", strings.Join(graph, ";"),
+		"-frames:v", "1", "-update", "1", "-q:v", "3",
+		outputFile,
+	)
+	return fs.runThumbnailCommand(ctx, args)
+}
+
+// Trickplay writes sprite images of a frame every opts.Interval seconds into spriteDir and the WebVTT
+// index pointing into them to indexFile. Cues reference the sprites as spritePrefix + file name
+// with the tile as a #xywh media fragment. Only keyframes are decoded, frames snap to the nearest one.
+func (fs *FFmpegService) Trickplay(ctx context.Context, sourceFile string, info *ffprobe.VideoInfo, opts ThumbnailOptions, indexFile, spriteDir, spritePrefix string) error {
+	opts, err := opts.normalized()
+	if err != nil {
+		return err
+	}
+	duration := durationOf(info)
+	if duration <= 0 {
+		return fmt.Errorf("trickplay images need a known source duration")
+	}
+	if err := os.MkdirAll(spriteDir, 0755); err != nil {
+		return fmt.Errorf("failed to create sprite directory: %w", err)
+	}
+
+	width, height := thumbnailSize(info, opts.Width)
+	args := []string{
+		"-skip_frame", "nokey",
+		"-i", sourceFile,
+		"-map", "0:v:0",
+		"-vf", fmt.Sprintf("fps=1/%s,scale=%d:%d,setsar=1,tile=%dx%d", formatFloat(opts.Interval), width, height, opts.Columns, opts.Rows),
+		"-q:v", "5", "-start_number", "0",
+		filepath.Join(spriteDir, trickplaySprites),
+	}
+	if err := fs.runThumbnailCommand(ctx, args); err != nil {
+		return err
+	}
+
+	// Cues only point at the sprites that were written
+	perSprite := opts.Columns * opts.Rows
+	sprites := 0
+	for {
+		if _, err := os.Stat(filepath.Join(spriteDir, fmt.Sprintf(trickplaySprites, sprites))); err != nil {
+			break
+		}
+		sprites++
+	}
+	frames := min(int(math.Ceil(duration/opts.Interval)), sprites*perSprite)
+	if frames == 0 {
+		return fmt.Errorf("no trickplay frames were written")
+	}
+
+	var vtt strings.Builder
+	vtt.WriteString("WEBVTT\n")
+	for i := 0; i < frames; i++ {
+		start := float64(i) * opts.Interval
+		end := math.Min(start+opts.Interval, duration)
+		tile := i % perSprite
+		fmt.Fprintf(&vtt, "\n%s --> %s\n%s%s#xywh=%d,%d,%d,%d\n",
+			vttTimestamp(start), vttTimestamp(end),
+			spritePrefix, fmt.Sprintf(trickplaySprites, i/perSprite),
+			(tile%opts.Columns)*width, (tile/opts.Columns)*height, width, height)
+	}
+	if err := os.WriteFile(indexFile, []byte(vtt.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write trickplay index: %w", err)
+	}
+	return nil
+}
+
+// vttTimestamp formats seconds as a WebVTT timestamp (hh:mm:ss.mmm)
+func vttTimestamp(seconds float64) string {
+	d := time.Duration(math.Round(seconds*1000)) * time.Millisecond
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60, d.Milliseconds()%1000)
+}
+
+// runThumbnailCommand runs ffmpeg with the arguments of an image, overwriting existing files
+func (fs *FFmpegService) runThumbnailCommand(ctx context.Context, args []string) error {
+	args = append([]string{"-hide_banner", "-nostdin", "-nostats", "-y"}, args...)
+	if err := runFFmpegCommand(exec.CommandContext(ctx, fs.ffmpegPath, args...)); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+// thumbnailBase returns the path output images are named after: the output without
+// its extension, e.g. movie.mkv -> movie.jpg, movie_sheet.jpg, movie_trickplay.vtt
+// Package images sit next to the package directory so they don't count as package files.
+func thumbnailBase(outputFile string) string {
+	if info, err := os.Stat(outputFile); err == nil && info.IsDir() {
+		return filepath.Clean(outputFile)
+	}
+	return strings.TrimSuffix(outputFile, filepath.Ext(outputFile))
+}
+
+// WriteThumbnails writes the preview images of a finished output next to it
+// Returns the written files (trickplay as its WebVTT index and sprite directory) and a warning per failed image.
+func (fs *FFmpegService) WriteThumbnails(ctx context.Context, outputFile string, config *model.TranscodeConfig) ([]string, []string) {
+	thumbnails := config.Thumbnails
+	input := outputFile
+	if Packaged(config) {
+		input = PackageEntry(outputFile, config)
+	}
+	info, err := fs.ProbeFile(ctx, input)
+	if err != nil {
+		return nil, []string{"thumbnails failed: failed to probe output: " + err.Error()}
+	}
+
+	base := thumbnailBase(outputFile)
+	written, warnings := []string{}, []string{}
+	write := func(kind, file string, generate func() error) {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := generate(); err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Thumbnail %s of %s failed: %v", kind, outputFile, err)
+				warnings = append(warnings, fmt.Sprintf("%s thumbnail failed: %v", kind, err))
+			}
+			return
+		}
+		written = append(written, file)
+	}
+
+	if thumbnails.Poster {
+		file := base + ".jpg"
+		write(ThumbnailPoster, file, func() error {
+			return fs.Poster(ctx, input, info, ThumbnailOptions{Kind: ThumbnailPoster, At: thumbnails.PosterAt}, file)
+		})
+	}
+	if thumbnails.ContactSheet {
+		file := base + "_sheet.jpg"
+		write(ThumbnailSheet, file, func() error {
+			return fs.ContactSheet(ctx, input, info, ThumbnailOptions{Kind: ThumbnailSheet}, file)
+		})
+	}
+	if thumbnails.Trickplay {
+		file, dir := base+"_trickplay.vtt", base+"_trickplay"
+		write(ThumbnailTrickplay, file, func() error {
+			// A previous run's sprites would be mixed into the new set
+			os.RemoveAll(dir)
+			opts := ThumbnailOptions{Kind: ThumbnailTrickplay, Interval: thumbnails.Interval}
+			return fs.Trickplay(ctx, input, info, opts, file, dir, filepath.Base(dir)+"/")
+		})
+		if len(written) > 0 && written[len(written)-1] == file {
+			written = append(written, dir)
+		}
+	}
+	return written, warnings
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"ffmpeg-web/internal/model"
+	"fmt"
+	"strings"
+)
+
+// TrimEnabled reports whether the config keeps only part of the source
+// Custom commands are passed through as-is and never trimmed.
+func TrimEnabled(config *model.TranscodeConfig) bool {
+	if config.Mode == "advanced" && config.CustomCommand != "" {
+		return false
+	}
+	trim := config.Trim
+	return trim != nil && (trim.Start > 0 || trim.End > 0 || len(trim.Segments) > 0)
+}
+
+// trimJoinsSegments reports whether several segments are cut and joined with a filter graph
+func trimJoinsSegments(config *model.TranscodeConfig) bool {
+	return config.Trim != nil && len(config.Trim.Segments) > 1
+}
+
+// validateTrim checks the trim ranges and the settings a multi-segment cut can't work with
+func validateTrim(config *model.TranscodeConfig) error {
+	if !TrimEnabled(config) {
+		return nil
+	}
+	trim := config.Trim
+
+	if trim.Start < 0 || trim.End < 0 {
+		return fmt.Errorf("trim start and end can't be negative")
+	}
+	if trim.End > 0 && trim.End <= trim.Start {
+		return fmt.Errorf("trim end must be after the start")
+	}
+	if len(trim.Segments) > 0 && (trim.Start > 0 || trim.End > 0) {
+		return fmt.Errorf("use either trim start/end or trim segments, not both")
+	}
+
+	previousEnd := 0.0
+	for i, segment := range trim.Segments {
+		if segment.Start < previousEnd {
+			return fmt.Errorf("trim segment %d starts before the previous one ends (segments must be in order)", i+1)
+		}
+		if segment.End == 0 && i < len(trim.Segments)-1 {
+			return fmt.Errorf("only the last trim segment can run until the end of the source")
+		}
+		if segment.End != 0 && segment.End <= segment.Start {
+			return fmt.Errorf("trim segment %d must end after it starts", i+1)
+		}
+		previousEnd = segment.End
+	}
+
+	if ChunkingEnabled(config) {
+		return fmt.Errorf("trimming is not supported with chunked encoding")
+	}
+
+	// Filtered audio can't be stream-copied
+	if trimJoinsSegments(config) {
+		copies := config.Audio.Codec == "copy" || config.Audio.Codec == ""
+		for _, rule := range config.Audio.Rules {
+			if rule.Codec == "copy" || rule.Codec == "" {
+				copies = true
+			}
+		}
+		if copies {
+			return fmt.Errorf("cutting several trim segments requires audio re-encoding (audio codec cannot be copy)")
+		}
+	}
+	return nil
+}
+
+// TrimRanges returns the kept source ranges (nil = the whole source)
+// duration is the probed source duration; a range running until the end has Duration 0 when it's unknown.
+func TrimRanges(config *model.TranscodeConfig, duration float64) []ChunkRange {
+	if !TrimEnabled(config) {
+		return nil
+	}
+
+	segments := config.Trim.Segments
+	if len(segments) == 0 {
+		segments = []model.TrimSegment{{Start: config.Trim.Start, End: config.Trim.End}}
+	}
+
+	ranges := make([]ChunkRange, 0, len(segments))
+	for _, segment := range segments {
+		end := segment.End
+		if duration > 0 && (end == 0 || end > duration) {
+			end = duration
+		}
+		length := 0.0
+		if end > segment.Start {
+			length = end - segment.Start
+		}
+		ranges = append(ranges, ChunkRange{Start: segment.Start, Duration: length})
+	}
+	return ranges
+}
+
+// TrimmedDuration returns the output duration after trimming (the source duration without trimming)
+func TrimmedDuration(config *model.TranscodeConfig, duration float64) float64 {
+	ranges := TrimRanges(config, duration)
+	if ranges == nil || duration <= 0 {
+		return duration
+	}
+
+	total := 0.0
+	for _, r := range ranges {
+		total += r.Duration
+	}
+	return total
+}
+
+// trimSourceRange maps a range of the trimmed output to the source
+// Returns the range clipped to the kept segment it starts in, and where that range starts in the source.
+func trimSourceRange(ranges []ChunkRange, output ChunkRange) (ChunkRange, float64) {
+	offset := 0.0
+	for i, r := range ranges {
+		if i < len(ranges)-1 && output.Start >= offset+r.Duration {
+			offset += r.Duration
+			continue
+		}
+
+		inside := output.Start - offset
+		if r.Duration > 0 {
+			remaining := r.Duration - inside
+			if output.Duration == 0 || output.Duration > remaining {
+				output.Duration = remaining
+			}
+		}
+		return output, r.Start + inside
+	}
+	return output, output.Start
+}
+
+// buildTrimFilter builds a filter graph that cuts the kept ranges out of one stream and joins them
+// media is "v" or "a"; the graph has a single input and output, so it fits -filter:v / -filter:a
+// e.g. split=2[seg0][seg1];[seg0]trim=start=0:end=60,setpts=PTS-STARTPTS[cut0];...;[cut0][cut1]concat=n=2:v=1:a=0
+func buildTrimFilter(ranges []ChunkRange, media string) string {
+	split, trim, setpts, concat := "split", "trim", "setpts", "v=1:a=0"
+	if media == "a" {
+		split, trim, setpts, concat = "asplit", "atrim", "asetpts", "v=0:a=1"
+	}
+
+	var outputs, cuts strings.Builder
+	chains := []string{}
+	for i, r := range ranges {
+		fmt.Fprintf(&outputs, "[seg%d]", i)
+		fmt.Fprintf(&cuts, "[cut%d]", i)
+
+		bounds := "start=" + formatFloat(r.Start)
+		if r.Duration > 0 {
+			bounds += ":end=" + formatFloat(r.Start+r.Duration)
+		}
+		chains = append(chains, fmt.Sprintf("[seg%d]%s=%s,%s=PTS-STARTPTS[cut%d]", i, trim, bounds, setpts, i))
+	}
+
+	graph := []string{fmt.Sprintf("%s=%d%s", split, len(ranges), outputs.String())}
+	graph = append(graph, chains...)
+	graph = append(graph, fmt.Sprintf("%sconcat=n=%d:%s", cuts.String(), len(ranges), concat))
+	return strings.Join(graph, ";")
+}
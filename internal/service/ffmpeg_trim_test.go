@@ -0,0 +1,124 @@
+package service
+
+import (
+	"ffmpeg-web/internal/model"
+	"slices"
+	"testing"
+)
+
+func TestTrimRanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   model.TranscodeConfig
+		duration float64
+		want     []ChunkRange
+	}{
+		{"no trim", model.TranscodeConfig{}, 600, nil},
+		{"empty trim", model.TranscodeConfig{Trim: &model.TrimConfig{}}, 600, nil},
+		{
+			name:     "custom command",
+			config:   model.TranscodeConfig{Mode: "advanced", CustomCommand: "-c copy", Trim: &model.TrimConfig{Start: 10}},
+			duration: 600,
+			want:     nil,
+		},
+		{"start only", model.TranscodeConfig{Trim: &model.TrimConfig{Start: 10}}, 600, []ChunkRange{{Start: 10, Duration: 590}}},
+		{"start and end", model.TranscodeConfig{Trim: &model.TrimConfig{Start: 10, End: 70}}, 600, []ChunkRange{{Start: 10, Duration: 60}}},
+		{"end past the source", model.TranscodeConfig{Trim: &model.TrimConfig{End: 900}}, 600, []ChunkRange{{Start: 0, Duration: 600}}},
+		{"unknown duration", model.TranscodeConfig{Trim: &model.TrimConfig{Start: 10}}, 0, []ChunkRange{{Start: 10, Duration: 0}}},
+		{
+			name:     "segments",
+			config:   model.TranscodeConfig{Trim: &model.TrimConfig{Segments: []model.TrimSegment{{Start: 0, End: 60}, {Start: 120, End: 180}, {Start: 300}}}},
+			duration: 600,
+			want:     []ChunkRange{{Start: 0, Duration: 60}, {Start: 120, Duration: 60}, {Start: 300, Duration: 300}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TrimRanges(&tt.config, tt.duration)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("TrimRanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrimmedDuration(t *testing.T) {
+	config := &model.TranscodeConfig{Trim: &model.TrimConfig{Segments: []model.TrimSegment{{Start: 0, End: 60}, {Start: 120}}}}
+	if got := TrimmedDuration(config, 600); got != 540 {
+		t.Errorf("TrimmedDuration() = %v, want 540", got)
+	}
+	if got := TrimmedDuration(&model.TranscodeConfig{}, 600); got != 600 {
+		t.Errorf("TrimmedDuration() without trim = %v, want 600", got)
+	}
+}
+
+func TestTrimSourceRange(t *testing.T) {
+	ranges := []ChunkRange{{Start: 0, Duration: 60}, {Start: 120, Duration: 60}, {Start: 300, Duration: 0}}
+
+	tests := []struct {
+		name       string
+		output     ChunkRange
+		want       ChunkRange
+		wantSource float64
+	}{
+		{"first segment", ChunkRange{Start: 10, Duration: 20}, ChunkRange{Start: 10, Duration: 20}, 10},
+		{"clipped to the segment", ChunkRange{Start: 50, Duration: 20}, ChunkRange{Start: 50, Duration: 10}, 50},
+		{"second segment", ChunkRange{Start: 70, Duration: 20}, ChunkRange{Start: 70, Duration: 20}, 130},
+		{"open last segment", ChunkRange{Start: 130, Duration: 30}, ChunkRange{Start: 130, Duration: 30}, 310},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, source := trimSourceRange(ranges, tt.output)
+			if got != tt.want || source != tt.wantSource {
+				t.Errorf("trimSourceRange(%v) = %v, %v; want %v, %v", tt.output, got, source, tt.want, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestBuildTrimFilter(t *testing.T) {
+	ranges := []ChunkRange{{Start: 0, Duration: 60}, {Start: 120.5, Duration: 0}}
+
+	tests := []struct {
+		media string
+		want  string
+	}{
+		{"v", "split=2[seg0][seg1];" +
+			"[seg0]trim=start=0:end=60,setpts=PTS-STARTPTS[cut0];" +
+			"[seg1]trim=start=120.5,setpts=PTS-STARTPTS[cut1];" +
+			"[cut0][cut1]concat=n=2:v=1:a=0"},
+		{"a", "asplit=2[seg0][seg1];" +
+			"[seg0]atrim=start=0:end=60,asetpts=PTS-STARTPTS[cut0];" +
+			"[seg1]atrim=start=120.5,asetpts=PTS-STARTPTS[cut1];" +
+			"[cut0][cut1]concat=n=2:v=0:a=1"},
+	}
+
+	for _, tt := range tests {
+		if got := buildTrimFilter(ranges, tt.media); got != tt.want {
+			t.Errorf("buildTrimFilter(%q)\n got: %s\nwant: %s", tt.media, got, tt.want)
+		}
+	}
+}
+
+func TestSeekedBurnFilter(t *testing.T) {
+	burn := "subtitles=filename='/data/movie.mkv':si=0"
+
+	tests := []struct {
+		seek float64
+		want string
+	}{
+		{0, burn},
+		{90.5, "setpts=PTS+90.5/TB," + burn + ",setpts=PTS-STARTPTS"},
+	}
+
+	for _, tt := range tests {
+		if got := seekedBurnFilter(burn, tt.seek); got != tt.want {
+			t.Errorf("seekedBurnFilter(%v) = %s, want %s", tt.seek, got, tt.want)
+		}
+	}
+	if got := seekedBurnFilter("", 90); got != "" {
+		t.Errorf("seekedBurnFilter() without burn-in = %q, want empty", got)
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/pkg/ffprobe"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultVAAPIDevice is the render node used when no render node can be found
+const DefaultVAAPIDevice = "/dev/dri/renderD128"
+
+// drmVendorIDs maps hardware acceleration names to PCI vendor IDs of DRM render nodes
+var drmVendorIDs = map[string]string{
+	"amd":   "0x1002",
+	"intel": "0x8086",
+}
+
+// vaapiCompressionLevels maps AMF quality presets (used by "amd") to VA-API compression levels
+// Lower levels are slower with better quality, the exact meaning depends on the driver
+var vaapiCompressionLevels = map[string]string{
+	"quality":  "1",
+	"balanced": "4",
+	"speed":    "7",
+}
+
+// hardwareBackend returns the encoding backend for a hardware acceleration setting
+// AMF is not available in Linux ffmpeg builds, AMD GPUs use VA-API (Mesa) there
+func hardwareBackend(hwAccel string) string {
+	switch hwAccel {
+	case "":
+		return "cpu"
+	case "amd":
+		if runtime.GOOS == "linux" {
+			return "vaapi"
+		}
+	}
+	return hwAccel
+}
+
+// vaapiDevice returns the DRM render node used for VA-API
+// The first render node of the GPU vendor is preferred when hwAccel names one (e.g. "amd")
+func vaapiDevice(hwAccel string) string {
+	nodes, _ := filepath.Glob("/dev/dri/renderD*")
+	if len(nodes) == 0 {
+		return DefaultVAAPIDevice
+	}
+	sort.Strings(nodes)
+
+	if vendor, ok := drmVendorIDs[hwAccel]; ok {
+		for _, node := range nodes {
+			if renderNodeVendor(node) == vendor {
+				return node
+			}
+		}
+	}
+
+	return nodes[0]
+}
+
+// renderNodeVendor reads the PCI vendor ID of a DRM render node from sysfs
+func renderNodeVendor(node string) string {
+	data, err := os.ReadFile(filepath.Join("/sys/class/drm", filepath.Base(node), "device", "vendor"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// preservesHDR checks if HDR metadata is kept for the source ("auto" HDR mode on an HDR source)
+func preservesHDR(config *model.TranscodeConfig, sourceVideoInfo *ffprobe.VideoInfo) bool {
+	return len(config.Video.HdrMode) > 0 && config.Video.HdrMode[0] == "auto" &&
+		sourceVideoInfo != nil && sourceVideoInfo.IsHDR
+}
+
+// buildVAAPIFilters builds the filters that move frames to the GPU and scale them there
+// format=...|vaapi accepts both decoded hardware frames and software frames
+// (software decode fallback or software filters such as subtitle burn-in), which hwupload uploads
+func buildVAAPIFilters(config *model.TranscodeConfig, codec string, sourceVideoInfo *ffprobe.VideoInfo) []string {
+	format := "nv12"
+	if preservesHDR(config, sourceVideoInfo) && codec != "h264_vaapi" {
+		format = "p010"
+	}
+
+	scale := "scale_vaapi=format=" + format
+	if config.Video.Resolution != "" && config.Video.Resolution != "original" {
+		if width, height, ok := strings.Cut(config.Video.Resolution, "x"); ok {
+			scale = fmt.Sprintf("scale_vaapi=w=%s:h=%s:format=%s", width, height, format)
+		}
+	}
+
+	return []string{"format=" + format + "|vaapi", "hwupload", scale}
+}
+
+// vaapiPresetArgs maps the preset to a VA-API compression level
+// Accepts numeric levels and the AMF quality presets
+func vaapiPresetArgs(preset string) []string {
+	if level, ok := vaapiCompressionLevels[preset]; ok {
+		return []string{"-compression_level", level}
+	}
+	if _, err := strconv.Atoi(preset); err == nil {
+		return []string{"-compression_level", preset}
+	}
+	return nil
+}
+
+// vaapiQuality converts a CRF value to the encoder's constant QP scale
+// H.264/HEVC use QP 0-51, AV1 and VP9 use a quantizer index 0-255
+func vaapiQuality(codec string, crf int) string {
+	switch codec {
+	case "av1_vaapi", "vp9_vaapi":
+		// CRF values for these families use a 0-63 scale
+		return strconv.Itoa(min(crf*4, 255))
+	}
+	return strconv.Itoa(crf)
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/pkg/ffprobe"
+	"fmt"
+	"math"
+	"os/exec"
+	"strings"
+)
+
+// defaultDurationTolerance is the allowed output duration difference in seconds
+const defaultDurationTolerance = 1.0
+
+// maxDecodeErrors is how many decode error lines are kept on the verification result
+const maxDecodeErrors = 3
+
+// VerificationEnabled reports whether the config asks for a post-encode verification
+func VerificationEnabled(config *model.TranscodeConfig) bool {
+	return config.Verify != nil && config.Verify.Enabled
+}
+
+// validateVerify checks the verification settings
+func validateVerify(verify *model.VerifyConfig) error {
+	if verify != nil && verify.DurationTolerance < 0 {
+		return fmt.Errorf("verification duration tolerance can't be negative")
+	}
+	return nil
+}
+
+// VerifyOutput re-probes an encoded output and compares it with the probed source
+// The duration must match within the tolerance and every stream type needs at least
+// the streams the config keeps; with Decode set the whole output is decoded as well.
+func (fs *FFmpegService) VerifyOutput(ctx context.Context, sourceFile, outputFile string, config *model.TranscodeConfig, source *ffprobe.VideoInfo) *model.Verification {
+	result := &model.Verification{
+		SourceDuration: TrimmedDuration(config, source.Duration),
+		Expected:       expectedStreams(sourceFile, config, source),
+	}
+
+	// Packages are probed through their master playlist or manifest
+	if Packaged(config) {
+		outputFile = PackageEntry(outputFile, config)
+	}
+
+	output, err := fs.ProbeFile(ctx, outputFile)
+	if err != nil {
+		result.Problems = append(result.Problems, fmt.Sprintf("failed to probe output: %v", err))
+		return result
+	}
+	result.OutputDuration = output.Duration
+	result.Output = countStreams(output)
+
+	tolerance := config.Verify.DurationTolerance
+	if tolerance == 0 {
+		tolerance = defaultDurationTolerance
+	}
+	if result.SourceDuration > 0 && math.Abs(output.Duration-result.SourceDuration) > tolerance {
+		result.Problems = append(result.Problems, fmt.Sprintf("output duration %.2fs differs from the source (%.2fs) by more than %.2fs",
+			output.Duration, result.SourceDuration, tolerance))
+	}
+
+	for _, check := range []struct {
+		name             string
+		expected, actual int
+	}{
+		{"video", result.Expected.Video, result.Output.Video},
+		{"audio", result.Expected.Audio, result.Output.Audio},
+		{"subtitle", result.Expected.Subtitle, result.Output.Subtitle},
+	} {
+		if check.actual < check.expected {
+			result.Problems = append(result.Problems, fmt.Sprintf("output has %d %s stream(s), expected %d",
+				check.actual, check.name, check.expected))
+		}
+	}
+
+	if config.Verify.Decode {
+		result.Decoded = true
+		if err := fs.decodeCheck(ctx, outputFile); err != nil {
+			result.Problems = append(result.Problems, err.Error())
+		}
+	}
+
+	result.Passed = len(result.Problems) == 0
+	return result
+}
+
+// expectedStreams returns how many streams of each type the output should have at least
+// Custom commands may map anything, so only the duration is checked for them.
+func expectedStreams(sourceFile string, config *model.TranscodeConfig, source *ffprobe.VideoInfo) model.StreamCounts {
+	expected := model.StreamCounts{}
+	if config.Mode == "advanced" && config.CustomCommand != "" {
+		return expected
+	}
+
+	// Remuxes keep the selected streams the container can hold
+	if RemuxMode(config) {
+		return countStreams(&ffprobe.VideoInfo{Streams: remuxedStreams(config, source)})
+	}
+
+	// Audio tasks keep the selected audio tracks
+	if AudioMode(config) {
+		audio, _ := audioTaskStreams(config, source)
+		expected.Audio = len(audio)
+		return expected
+	}
+
+	// The main video stream is always encoded
+	expected.Video = min(len(source.StreamsOfType("video")), 1)
+
+	// Packages carry the first audio track only
+	if Packaged(config) {
+		expected.Audio = min(len(source.StreamsOfType("audio")), 1)
+		return expected
+	}
+
+	// Every audio track is kept, plus one stereo compatibility track per surround track
+	audioStreams := source.StreamsOfType("audio")
+	expected.Audio = len(audioStreams)
+	if config.Audio.StereoCompat {
+		for _, stream := range audioStreams {
+			if stream.Channels > 2 {
+				expected.Audio++
+			}
+		}
+	}
+
+	// Subtitles follow the subtitle plan (dropped, burned in or kept);
+	// multi-segment trims drop them
+	plan := planSubtitles(sourceFile, config, source)
+	if !plan.dropAll && !trimJoinsSegments(config) {
+		expected.Subtitle = max(len(source.StreamsOfType("subtitle"))-len(plan.drop), 0)
+	}
+
+	return expected
+}
+
+// countStreams counts the video, audio and subtitle streams of a probed file
+func countStreams(info *ffprobe.VideoInfo) model.StreamCounts {
+	return model.StreamCounts{
+		Video:    len(info.StreamsOfType("video")),
+		Audio:    len(info.StreamsOfType("audio")),
+		Subtitle: len(info.StreamsOfType("subtitle")),
+	}
+}
+
+// decodeCheck decodes the audio and video of a file to the null muxer and reports decode errors
+func (fs *FFmpegService) decodeCheck(ctx context.Context, file string) error {
+	cmd := exec.CommandContext(ctx, fs.ffmpegPath,
+		"-hide_banner", "-nostdin", "-nostats",
+		"-v", "error",
+		"-i", file,
+		"-map", "0:v?", "-map", "0:a?",
+		"-f", "null", "-",
+	)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+	if lines[0] == "" {
+		lines = nil
+	}
+	if runErr == nil && len(lines) == 0 {
+		return nil
+	}
+
+	if len(lines) > maxDecodeErrors {
+		lines = append(lines[:maxDecodeErrors], fmt.Sprintf("(%d more)", len(lines)-maxDecodeErrors))
+	}
+	if len(lines) == 0 {
+		lines = []string{runErr.Error()}
+	}
+	return fmt.Errorf("decode check failed: %s", strings.Join(lines, "; "))
+}
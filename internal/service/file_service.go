@@ -114,8 +114,8 @@ func (fs *FileService) BrowseDirectory(pathParam string) ([]*model.FileInfo, err
 			ModTime: info.ModTime(),
 		}
 
-		// Only include video files and directories
-		if !entry.IsDir() && !isVideoFile(entry.Name()) {
+		// Only include video/audio files and directories
+		if !entry.IsDir() && !IsMediaFile(entry.Name()) {
 			continue
 		}
 
@@ -182,7 +182,7 @@ func (fs *FileService) GetFileInfo(relativePath string) (*model.FileInfo, error)
 	}
 
 	// If it's a video file, get metadata using ffprobe
-	if !fileInfo.IsDir() && isVideoFile(fileInfo.Name()) {
+	if !fileInfo.IsDir() && IsVideoFile(fileInfo.Name()) {
 		// Import ffprobe in the import section if not already
 		// For now, we'll just return basic info
 		// The ffprobe integration should be done in the API layer
@@ -191,8 +191,8 @@ func (fs *FileService) GetFileInfo(relativePath string) (*model.FileInfo, error)
 	return info, nil
 }
 
-// isVideoFile checks if a file has a video extension
-func isVideoFile(filename string) bool {
+// IsVideoFile checks if a file has a video extension
+func IsVideoFile(filename string) bool {
 	ext := strings.ToLower(filepath.Ext(filename))
 	videoExts := []string{
 		".mp4", ".mkv", ".avi", ".mov", ".wmv", ".flv", ".webm",
@@ -208,6 +208,28 @@ func isVideoFile(filename string) bool {
 	return false
 }
 
+// IsAudioFile checks if a file has an audio extension
+func IsAudioFile(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	audioExts := []string{
+		".mp3", ".flac", ".m4a", ".aac", ".ogg", ".oga", ".opus", ".wav",
+		".wma", ".alac", ".aiff", ".aif", ".ape", ".wv", ".mka", ".ac3", ".dts",
+	}
+
+	for _, audioExt := range audioExts {
+		if ext == audioExt {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsMediaFile checks if a file has a video or audio extension
+func IsMediaFile(filename string) bool {
+	return IsVideoFile(filename) || IsAudioFile(filename)
+}
+
 // IsDirectory checks if a path is a directory
 func (fs *FileService) IsDirectory(path string) bool {
 	info, err := os.Stat(path)
@@ -220,6 +242,17 @@ func (fs *FileService) IsDirectory(path string) bool {
 // ScanVideoFilesInDirectory recursively scans a directory for video files
 // Returns absolute paths to all video files found
 func (fs *FileService) ScanVideoFilesInDirectory(dirPath string) ([]string, error) {
+	return fs.scanDirectory(dirPath, IsVideoFile)
+}
+
+// ScanMediaFilesInDirectory recursively scans a directory for video and audio files
+// Returns absolute paths to all media files found
+func (fs *FileService) ScanMediaFilesInDirectory(dirPath string) ([]string, error) {
+	return fs.scanDirectory(dirPath, IsMediaFile)
+}
+
+// scanDirectory recursively collects the absolute paths of the files matching a name filter
+func (fs *FileService) scanDirectory(dirPath string, matches func(string) bool) ([]string, error) {
 	var videoFiles []string
 
 	// Walk the directory tree
@@ -236,8 +269,8 @@ func (fs *FileService) ScanVideoFilesInDirectory(dirPath string) ([]string, erro
 			return nil
 		}
 
-		// Add video files to the list
-		if !info.IsDir() && isVideoFile(info.Name()) {
+		// Add matching files to the list
+		if !info.IsDir() && matches(info.Name()) {
 			absPath, err := filepath.Abs(path)
 			if err != nil {
 				absPath = path
@@ -254,3 +287,28 @@ func (fs *FileService) ScanVideoFilesInDirectory(dirPath string) ([]string, erro
 
 	return videoFiles, nil
 }
+
+// ExpandSourceFiles replaces directories in a list of source paths with the video files inside them
+// This allows selecting folders and having all videos within transcoded;
+// with audio set, audio files are picked up too (audio tasks)
+func (fs *FileService) ExpandSourceFiles(paths []string, audio bool) ([]string, error) {
+	scan := fs.ScanVideoFilesInDirectory
+	if audio {
+		scan = fs.ScanMediaFilesInDirectory
+	}
+
+	var sourceFiles []string
+	for _, path := range paths {
+		if fs.IsDirectory(path) {
+			videoFiles, err := scan(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to scan directory: %s", path)
+			}
+			sourceFiles = append(sourceFiles, videoFiles...)
+		} else {
+			sourceFiles = append(sourceFiles, path)
+		}
+	}
+
+	return sourceFiles, nil
+}
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"ffmpeg-web/internal/model"
+	"fmt"
+	"log"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+// encoderTestTimeout limits a single test encode
+const encoderTestTimeout = 15 * time.Second
+
+// encoderFamilies are the encoder families of the capability matrix
+var encoderFamilies = []string{"h265", "av1", "h264", "vp9", "prores"}
+
+// hardwareAccels are the hardware acceleration settings of the capability matrix
+var hardwareAccels = []string{"cpu", "nvidia", "intel", "amd", "vaapi"}
+
+// hwaccelMethods maps hardware backends to the ffmpeg -hwaccel method they decode with
+var hwaccelMethods = map[string]string{
+	"nvidia": "cuda",
+	"intel":  "qsv",
+	"vaapi":  "vaapi",
+}
+
+// GetEncoderCapabilities returns the encoder capability matrix of the ffmpeg build with caching
+// test runs a short lavfi test encode per hardware encoder (the result is cached as well)
+// Returns nil if ffmpeg can't be queried
+func (hs *HardwareService) GetEncoderCapabilities(test bool) *model.EncoderCapabilities {
+	hs.cacheMutex.RLock()
+	if hs.encoderCache != nil && time.Since(hs.encoderCacheTime) < hs.cacheDuration && (hs.encoderCache.Tested || !test) {
+		cached := hs.encoderCache
+		hs.cacheMutex.RUnlock()
+		return cached
+	}
+	hs.cacheMutex.RUnlock()
+
+	caps, err := hs.detectEncoderCapabilities(test)
+	if err != nil {
+		log.Printf("Encoder capability detection failed: %v", err)
+		return nil
+	}
+
+	hs.cacheMutex.Lock()
+	hs.encoderCache = caps
+	hs.encoderCacheTime = time.Now()
+	hs.cacheMutex.Unlock()
+
+	return caps
+}
+
+// detectEncoderCapabilities queries ffmpeg for its encoders, decoders, hwaccels and filters
+func (hs *HardwareService) detectEncoderCapabilities(test bool) (*model.EncoderCapabilities, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	caps := &model.EncoderCapabilities{
+		Matrix: map[string]map[string]model.EncoderStatus{},
+		Tested: test,
+	}
+
+	output, err := hs.runFFmpegQuery(ctx, "-encoders")
+	if err != nil {
+		return nil, err
+	}
+	caps.Encoders = parseCodecList(output)
+
+	if output, err = hs.runFFmpegQuery(ctx, "-decoders"); err != nil {
+		return nil, err
+	}
+	caps.Decoders = parseCodecList(output)
+
+	if output, err = hs.runFFmpegQuery(ctx, "-hwaccels"); err != nil {
+		return nil, err
+	}
+	caps.HWAccels = parseHWAccelList(output)
+
+	if output, err = hs.runFFmpegQuery(ctx, "-filters"); err != nil {
+		return nil, err
+	}
+	caps.Filters = parseFilterList(output)
+
+	for _, family := range encoderFamilies {
+		caps.Matrix[family] = map[string]model.EncoderStatus{}
+		for _, hwAccel := range hardwareAccels {
+			codec, err := selectVideoCodec(family, hwAccel)
+			if err != nil {
+				continue
+			}
+
+			status := model.EncoderStatus{
+				Encoder:   codec,
+				Available: slices.Contains(caps.Encoders, codec),
+			}
+			if method, ok := hwaccelMethods[hardwareBackend(hwAccel)]; ok && !slices.Contains(caps.HWAccels, method) {
+				status.Available = false
+			}
+
+			// Software encoders work whenever they are compiled in
+			if test && status.Available && hwAccel != "cpu" {
+				status.Tested = true
+				if err := hs.testEncode(hwAccel, codec); err != nil {
+					status.Error = err.Error()
+				} else {
+					status.Working = true
+				}
+			}
+
+			caps.Matrix[family][hwAccel] = status
+		}
+	}
+
+	return caps, nil
+}
+
+// runFFmpegQuery runs ffmpeg with an informational option (e.g. -encoders) and returns stdout
+func (hs *HardwareService) runFFmpegQuery(ctx context.Context, option string) (string, error) {
+	cmd := exec.CommandContext(ctx, hs.ffmpegPath, "-hide_banner", option)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run ffmpeg %s: %w", option, err)
+	}
+	return string(output), nil
+}
+
+// testEncode encodes a few frames of a generated source with the encoder
+// Hardware encoders are often compiled in without a usable device or driver
+func (hs *HardwareService) testEncode(hwAccel, codec string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), encoderTestTimeout)
+	defer cancel()
+
+	args := []string{"-hide_banner", "-loglevel", "error"}
+	if hardwareBackend(hwAccel) == "vaapi" {
+		args = append(args, "-vaapi_device", vaapiDevice(hwAccel))
+	}
+	args = append(args, "-f", "lavfi", "-i", "color=c=black:s=256x256:r=25:d=1")
+	if hardwareBackend(hwAccel) == "vaapi" {
+		args = append(args, "-vf", "format=nv12,hwupload")
+	}
+	args = append(args, "-frames:v", "5", "-c:v", codec, "-f", "null", "-")
+
+	cmd := exec.CommandContext(ctx, hs.ffmpegPath, args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		message := strings.TrimSpace(string(output))
+		if len(message) > 500 {
+			message = message[len(message)-500:]
+		}
+		if message == "" {
+			message = err.Error()
+		}
+		return fmt.Errorf("%s", message)
+	}
+
+	log.Printf("✓ Test encode with %s succeeded", codec)
+	return nil
+}
+
+// ValidateEncoderSupport checks that the ffmpeg build supports the encoder, hwaccel and filters of a config
+// Passes when the capabilities can't be detected (ffmpeg then reports the error when the task runs)
+func (hs *HardwareService) ValidateEncoderSupport(config *model.TranscodeConfig) error {
+	if (config.Mode == "advanced" && config.CustomCommand != "") || RemuxMode(config) || AudioMode(config) {
+		return nil
+	}
+
+	return CheckEncoderSupport(hs.GetEncoderCapabilities(false), hs.DetectHardware(), config)
+}
+
+// CheckEncoderSupport checks a config against the capabilities of an ffmpeg build and the
+// detected hardware (the local ones or a remote agent's); nil values are unknown and pass
+func CheckEncoderSupport(caps *model.EncoderCapabilities, hardware *model.HardwareInfo, config *model.TranscodeConfig) error {
+	if (config.Mode == "advanced" && config.CustomCommand != "") || RemuxMode(config) || AudioMode(config) {
+		return nil
+	}
+
+	codec, err := selectVideoCodec(config.Encoder, config.HardwareAccel)
+	if err != nil {
+		return err
+	}
+
+	// VA-API encodes need an encode profile of the family on the GPU (vainfo)
+	if family := encoderFamily(config.Encoder); hardware != nil && hardwareBackend(config.HardwareAccel) == "vaapi" && !slices.Contains(hardware.VAAPIEncoders, family) {
+		return fmt.Errorf("the GPU has no VA-API %s encode profile", family)
+	}
+
+	if caps == nil {
+		return nil
+	}
+	if !slices.Contains(caps.Encoders, codec) {
+		return fmt.Errorf("encoder %s is not available in this ffmpeg build", codec)
+	}
+
+	backend := hardwareBackend(config.HardwareAccel)
+	if method, ok := hwaccelMethods[backend]; ok && !slices.Contains(caps.HWAccels, method) {
+		return fmt.Errorf("%s hardware acceleration is not supported by this ffmpeg build (no %s hwaccel)", config.HardwareAccel, method)
+	}
+
+	// Test encode results from an earlier capability check
+	for _, status := range caps.Matrix {
+		for _, s := range status {
+			if s.Encoder == codec && s.Tested && !s.Working {
+				return fmt.Errorf("encoder %s failed the test encode: %s", codec, s.Error)
+			}
+		}
+	}
+
+	for _, filter := range requiredFilters(config) {
+		if !slices.Contains(caps.Filters, filter) {
+			return fmt.Errorf("ffmpeg filter %s is not available in this ffmpeg build", filter)
+		}
+	}
+
+	return nil
+}
+
+// requiredFilters lists the ffmpeg filters a config needs
+func requiredFilters(config *model.TranscodeConfig) []string {
+	filters := []string{}
+	if hardwareBackend(config.HardwareAccel) == "vaapi" {
+		filters = append(filters, "hwupload", "scale_vaapi")
+	}
+	if config.Audio.Loudnorm != nil && config.Audio.Loudnorm.Enabled {
+		filters = append(filters, "loudnorm")
+	}
+	if config.Subtitle.Mode == "burn" {
+		// Text subtitles are burned in with libass, image subtitles with overlay
+		filters = append(filters, "subtitles", "overlay")
+	}
+	switch config.Video.Deinterlace {
+	case DeinterlaceOn:
+		filters = append(filters, "bwdif")
+	case DeinterlaceIVTC, DeinterlaceAuto:
+		filters = append(filters, "fieldmatch", "bwdif", "decimate")
+	}
+	if trimJoinsSegments(config) {
+		filters = append(filters, "trim", "atrim", "concat")
+	}
+	return filters
+}
+
+// parseCodecList parses the output of ffmpeg -encoders / -decoders into codec names
+// Example line: " V....D libx264              libx264 H.264 / AVC / MPEG-4 AVC"
+func parseCodecList(output string) []string {
+	names := []string{}
+	listing := false
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		// The legend ends with a " ------" separator line
+		if !listing {
+			listing = strings.HasPrefix(fields[0], "---")
+			continue
+		}
+		if len(fields) >= 2 {
+			names = append(names, fields[1])
+		}
+	}
+
+	return names
+}
+
+// parseHWAccelList parses the output of ffmpeg -hwaccels
+func parseHWAccelList(output string) []string {
+	methods := []string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasSuffix(line, ":") {
+			continue // "Hardware acceleration methods:" header
+		}
+		methods = append(methods, line)
+	}
+
+	return methods
+}
+
+// parseFilterList parses the output of ffmpeg -filters into filter names
+// Example line: " ... scale_vaapi       V->V       Scale to/from VAAPI surfaces."
+func parseFilterList(output string) []string {
+	names := []string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Legend lines ("T.. = Timeline support") have no "->" column
+		if len(fields) >= 3 && strings.Contains(fields[2], "->") {
+			names = append(names, fields[1])
+		}
+	}
+
+	return names
+}
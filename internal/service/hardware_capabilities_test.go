@@ -0,0 +1,87 @@
+package service
+
+import (
+	"ffmpeg-web/internal/model"
+	"slices"
+	"testing"
+)
+
+func TestCheckEncoderSupportVAAPI(t *testing.T) {
+	caps := &model.EncoderCapabilities{
+		Encoders: []string{"hevc_vaapi", "av1_vaapi", "h264_vaapi"},
+		HWAccels: []string{"vaapi"},
+		Filters:  []string{"hwupload", "scale_vaapi"},
+	}
+	hardware := &model.HardwareInfo{VAAPI: true, VAAPIEncoders: []string{"h265", "h264"}}
+
+	tests := []struct {
+		name     string
+		encoder  string
+		hardware *model.HardwareInfo
+		caps     *model.EncoderCapabilities
+		wantErr  bool
+	}{
+		{"encode profile", "h265", hardware, caps, false},
+		{"default encoder", "", hardware, caps, false},
+		{"hevc alias", "hevc", hardware, caps, false},
+		{"avc alias", "avc", hardware, caps, false},
+		{"no encode profile", "av1", hardware, caps, true},
+		{"hardware unknown", "av1", nil, caps, false},
+		{"capabilities unknown", "av1", hardware, nil, true},
+		{"nothing known", "av1", nil, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &model.TranscodeConfig{Encoder: tt.encoder, HardwareAccel: "vaapi"}
+			err := CheckEncoderSupport(tt.caps, tt.hardware, config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckEncoderSupport() error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequiredFilters(t *testing.T) {
+	tests := []struct {
+		name   string
+		config model.TranscodeConfig
+		want   []string
+	}{
+		{"plain encode", model.TranscodeConfig{HardwareAccel: "cpu"}, []string{}},
+		{"vaapi", model.TranscodeConfig{HardwareAccel: "vaapi"}, []string{"hwupload", "scale_vaapi"}},
+		{"loudnorm", model.TranscodeConfig{Audio: model.AudioConfig{Loudnorm: &model.LoudnormConfig{Enabled: true}}}, []string{"loudnorm"}},
+		{"burn-in", model.TranscodeConfig{Subtitle: model.SubtitleConfig{Mode: "burn"}}, []string{"subtitles", "overlay"}},
+		{"deinterlace", model.TranscodeConfig{Video: model.VideoConfig{Deinterlace: DeinterlaceOn}}, []string{"bwdif"}},
+		{"auto deinterlace", model.TranscodeConfig{Video: model.VideoConfig{Deinterlace: DeinterlaceAuto}}, []string{"fieldmatch", "bwdif", "decimate"}},
+		{"single trim", model.TranscodeConfig{Trim: &model.TrimConfig{Start: 10}}, []string{}},
+		{
+			name:   "trim segments",
+			config: model.TranscodeConfig{Trim: &model.TrimConfig{Segments: []model.TrimSegment{{Start: 0, End: 10}, {Start: 20}}}},
+			want:   []string{"trim", "atrim", "concat"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := requiredFilters(&tt.config); !slices.Equal(got, tt.want) {
+				t.Errorf("requiredFilters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckEncoderSupportAdvanced(t *testing.T) {
+	caps := &model.EncoderCapabilities{Encoders: []string{"libx265"}}
+
+	// Advanced mode without a custom command is built like simple mode and validated
+	config := &model.TranscodeConfig{Mode: "advanced", Encoder: "av1", HardwareAccel: "cpu"}
+	if err := CheckEncoderSupport(caps, nil, config); err == nil {
+		t.Errorf("CheckEncoderSupport() passed an advanced config with an unavailable encoder")
+	}
+
+	config.CustomCommand = "-c:v libsvtav1"
+	if err := CheckEncoderSupport(caps, nil, config); err != nil {
+		t.Errorf("CheckEncoderSupport() error for a custom command: %v", err)
+	}
+}
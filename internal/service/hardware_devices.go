@@ -0,0 +1,147 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"ffmpeg-web/internal/model"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DeviceAuto lets the worker pool pick the GPU for a task
+const DeviceAuto = "auto"
+
+// nvidiaDevicePrefix prefixes NVIDIA device IDs ("nvidia:<index>")
+const nvidiaDevicePrefix = "nvidia:"
+
+// drmDevicePrefix is the directory of DRM render nodes (device IDs are the node paths)
+const drmDevicePrefix = "/dev/dri/"
+
+// drmVendorNames maps PCI vendor IDs of DRM render nodes to vendor names
+var drmVendorNames = map[string]string{
+	"0x10de": "nvidia",
+	"0x8086": "intel",
+	"0x1002": "amd",
+}
+
+// enumerateDevices lists every NVIDIA GPU reported by nvidia-smi and every DRM render node
+func (hs *HardwareService) enumerateDevices(ctx context.Context) []model.GPUDevice {
+	devices := []model.GPUDevice{}
+
+	cmd := exec.CommandContext(ctx, "nvidia-smi", "--query-gpu=index,name", "--format=csv,noheader")
+	if output, err := cmd.Output(); err == nil {
+		devices = append(devices, parseNVIDIADevices(string(output))...)
+	}
+
+	nodes, _ := filepath.Glob(drmDevicePrefix + "renderD*")
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		vendor := drmVendorNames[renderNodeVendor(node)]
+		name := filepath.Base(node)
+		if vendor != "" {
+			name = fmt.Sprintf("%s GPU (%s)", strings.ToUpper(vendor[:1])+vendor[1:], name)
+		}
+		devices = append(devices, model.GPUDevice{ID: node, Vendor: vendor, Name: name})
+	}
+
+	return devices
+}
+
+// parseNVIDIADevices parses "index, name" lines from nvidia-smi
+func parseNVIDIADevices(output string) []model.GPUDevice {
+	devices := []model.GPUDevice{}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		index, name, ok := strings.Cut(scanner.Text(), ",")
+		if !ok {
+			continue
+		}
+		index = strings.TrimSpace(index)
+		if _, err := strconv.Atoi(index); err != nil {
+			continue
+		}
+		devices = append(devices, model.GPUDevice{
+			ID:     nvidiaDevicePrefix + index,
+			Vendor: "nvidia",
+			Name:   strings.TrimSpace(name),
+		})
+	}
+
+	return devices
+}
+
+// DeviceCandidates returns the devices a task with the given hardware acceleration can run on
+func DeviceCandidates(hwAccel string, devices []model.GPUDevice) []model.GPUDevice {
+	candidates := []model.GPUDevice{}
+	backend := hardwareBackend(hwAccel)
+
+	for _, device := range devices {
+		isNVIDIA := strings.HasPrefix(device.ID, nvidiaDevicePrefix)
+		switch backend {
+		case "nvidia":
+			if isNVIDIA {
+				candidates = append(candidates, device)
+			}
+		case "intel":
+			if device.Vendor == "intel" {
+				candidates = append(candidates, device)
+			}
+		case "vaapi":
+			// "amd" only uses AMD render nodes, "vaapi" any non-NVIDIA one
+			if device.Vendor != "nvidia" && (hwAccel == "vaapi" || device.Vendor == hwAccel) {
+				candidates = append(candidates, device)
+			}
+		}
+	}
+
+	return candidates
+}
+
+// validateDevice checks the device ID matches the hardware acceleration
+func validateDevice(device, hwAccel string) error {
+	if device == "" || device == DeviceAuto {
+		return nil
+	}
+
+	switch hardwareBackend(hwAccel) {
+	case "nvidia":
+		if index, ok := nvidiaDeviceIndex(device); ok && index != "" {
+			return nil
+		}
+	case "intel", "vaapi":
+		if strings.HasPrefix(device, drmDevicePrefix) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("device %s can't be used with %s hardware acceleration", device, hwAccel)
+}
+
+// nvidiaDeviceIndex returns the GPU index of an NVIDIA device ID
+func nvidiaDeviceIndex(device string) (string, bool) {
+	index, ok := strings.CutPrefix(device, nvidiaDevicePrefix)
+	if !ok {
+		return "", false
+	}
+	if _, err := strconv.Atoi(index); err != nil {
+		return "", false
+	}
+	return index, true
+}
+
+// effectiveDevice returns the device a command runs on ("" = ffmpeg default)
+// The device picked by the worker pool takes precedence over the configured one
+func effectiveDevice(config *model.TranscodeConfig, opts *EncodeOptions) string {
+	if opts != nil && opts.Device != "" {
+		return opts.Device
+	}
+	if config.Device == DeviceAuto {
+		return ""
+	}
+	return config.Device
+}
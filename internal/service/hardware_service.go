@@ -15,6 +15,8 @@ import (
 type HardwareService struct {
 	cache             *model.HardwareInfo
 	capabilitiesCache *model.GPUCapabilities
+	encoderCache      *model.EncoderCapabilities
+	encoderCacheTime  time.Time
 	cacheMutex        sync.RWMutex
 	cacheTime         time.Time
 	cacheDuration     time.Duration
@@ -37,9 +39,11 @@ func (hs *HardwareService) SetFFmpegPath(path string) {
 	hs.cacheMutex.Lock()
 	hs.cache = nil
 	hs.capabilitiesCache = nil
+	hs.encoderCache = nil
 	hs.cacheMutex.Unlock()
 	// Trigger background detection
 	go hs.DetectHardware()
+	go hs.GetEncoderCapabilities(false)
 }
 
 // DetectHardware detects available hardware acceleration options with caching
@@ -67,6 +71,8 @@ func (hs *HardwareService) DetectHardware() *model.HardwareInfo {
 		gpuName string
 		intel   bool
 		amd     bool
+		vaapi   []string
+		devices []model.GPUDevice
 	}
 	resultChan := make(chan result, 1)
 
@@ -78,6 +84,8 @@ func (hs *HardwareService) DetectHardware() *model.HardwareInfo {
 		}
 		r.intel = hs.detectIntelQSVWithTimeout(ctx)
 		r.amd = hs.detectAMDWithTimeout(ctx)
+		r.vaapi = hs.detectVAAPIWithTimeout(ctx)
+		r.devices = hs.enumerateDevices(ctx)
 		resultChan <- r
 	}()
 
@@ -87,6 +95,12 @@ func (hs *HardwareService) DetectHardware() *model.HardwareInfo {
 		info.GPUName = r.gpuName
 		info.Intel = r.intel
 		info.AMD = r.amd
+		info.VAAPI = len(r.vaapi) > 0
+		info.VAAPIEncoders = r.vaapi
+		info.Devices = r.devices
+		if info.VAAPI {
+			info.VAAPIDevice = vaapiDevice("")
+		}
 	case <-ctx.Done():
 		// Timeout - return CPU only
 	}
@@ -164,6 +178,50 @@ func (hs *HardwareService) detectAMDWithTimeout(ctx context.Context) bool {
 	return true
 }
 
+// detectVAAPIWithTimeout returns the encoder families the VA-API driver can encode
+// Availability comes from the encode profiles reported by vainfo for the render node
+func (hs *HardwareService) detectVAAPIWithTimeout(ctx context.Context) []string {
+	device := vaapiDevice("")
+	cmd := exec.CommandContext(ctx, "vainfo", "--display", "drm", "--device", device)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	vaInfo := parseVAInfo(string(output))
+	if vaInfo == nil {
+		return nil
+	}
+
+	families := vaapiEncoderFamilies(vaInfo)
+	if len(families) > 0 {
+		log.Printf("✓ VA-API encoding enabled on %s: %s", device, strings.Join(families, ", "))
+	}
+	return families
+}
+
+// vaapiProfilePrefixes maps encoder families to vainfo profile names (see extractProfileName)
+var vaapiProfilePrefixes = map[string]string{
+	"h264": "H.264",
+	"h265": "H.265/HEVC",
+	"av1":  "AV1",
+	"vp9":  "VP9",
+}
+
+// vaapiEncoderFamilies returns the encoder families with at least one VA-API encode profile
+func vaapiEncoderFamilies(info *model.VAInfo) []string {
+	families := []string{}
+	for _, family := range []string{"h265", "av1", "h264", "vp9"} {
+		for _, profile := range info.EncodeProfiles {
+			if strings.HasPrefix(profile, vaapiProfilePrefixes[family]) {
+				families = append(families, family)
+				break
+			}
+		}
+	}
+	return families
+}
+
 // GetGPUCapabilities returns GPU encoding and decoding capabilities with caching
 func (hs *HardwareService) GetGPUCapabilities() *model.GPUCapabilities {
 	// Check cache first
@@ -195,6 +253,12 @@ func (hs *HardwareService) GetGPUCapabilities() *model.GPUCapabilities {
 	// Check AMD
 	caps.HasAMD = hs.detectAMD()
 
+	// VA-API encoders (AMD and Intel on Linux)
+	caps.VAAPIEncoders = hs.DetectHardware().VAAPIEncoders
+
+	// Encoders, hwaccels and filters of the ffmpeg build
+	caps.Encoders = hs.GetEncoderCapabilities(false)
+
 	// Update cache with current timestamp
 	hs.cacheMutex.Lock()
 	hs.capabilitiesCache = caps
@@ -212,12 +276,18 @@ func (hs *HardwareService) getIntelVAInfo() *model.VAInfo {
 		return nil
 	}
 
+	return parseVAInfo(string(output))
+}
+
+// parseVAInfo parses vainfo output into decode and encode profiles
+// Returns nil if no profiles are listed
+func parseVAInfo(output string) *model.VAInfo {
 	info := &model.VAInfo{
 		DecodeProfiles: []string{},
 		EncodeProfiles: []string{},
 	}
 
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	scanner := bufio.NewScanner(strings.NewReader(output))
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 
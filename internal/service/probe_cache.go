@@ -0,0 +1,120 @@
+package service
+
+import (
+	"container/list"
+	"ffmpeg-web/pkg/ffprobe"
+	"log"
+	"sync"
+	"time"
+)
+
+// probeCacheSize is the number of probe results kept in memory
+const probeCacheSize = 2000
+
+// probeStoreTTL is how long a stored probe result is kept
+// Results of changed files are replaced right away, this removes the ones of deleted files.
+const probeStoreTTL = 90 * 24 * time.Hour
+
+// ProbeStore persists probe results across restarts
+// A result belongs to one version of a file: its absolute path, size and modification time.
+type ProbeStore interface {
+	// LoadProbe returns the stored result of a file version (nil when there is none)
+	LoadProbe(path string, size, modTime int64) (*ffprobe.VideoInfo, error)
+	SaveProbe(path string, size, modTime int64, info *ffprobe.VideoInfo) error
+	// PruneProbes removes the results stored before a time
+	PruneProbes(before time.Time) (int64, error)
+}
+
+// ProbeCache keeps probe results in memory (least recently used first out) and in a ProbeStore
+// A changed file has another size or modification time, so it misses the cache and is probed again.
+type ProbeCache struct {
+	store   ProbeStore
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // Most recently used first
+}
+
+// probeEntry is a cached probe result of one file version
+type probeEntry struct {
+	path    string
+	size    int64
+	modTime int64
+	info    *ffprobe.VideoInfo
+}
+
+// NewProbeCache creates a probe cache backed by store (nil = memory only) and removes expired stored results
+func NewProbeCache(store ProbeStore) *ProbeCache {
+	pc := &ProbeCache{
+		store:   store,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+	if store != nil {
+		if removed, err := store.PruneProbes(time.Now().Add(-probeStoreTTL)); err != nil {
+			log.Printf("Warning: Failed to prune probe cache: %v", err)
+		} else if removed > 0 {
+			log.Printf("Removed %d expired probe results", removed)
+		}
+	}
+	return pc
+}
+
+// Get returns the cached probe result of a file version (nil when it isn't cached)
+func (pc *ProbeCache) Get(path string, size int64, modTime time.Time) *ffprobe.VideoInfo {
+	mtime := modTime.UnixNano()
+
+	pc.mu.Lock()
+	if element, ok := pc.entries[path]; ok {
+		entry := element.Value.(*probeEntry)
+		if entry.size == size && entry.modTime == mtime {
+			pc.order.MoveToFront(element)
+			pc.mu.Unlock()
+			return entry.info
+		}
+	}
+	pc.mu.Unlock()
+
+	if pc.store == nil {
+		return nil
+	}
+	info, err := pc.store.LoadProbe(path, size, mtime)
+	if err != nil {
+		log.Printf("Warning: Failed to load probe result of %s: %v", path, err)
+		return nil
+	}
+	if info != nil {
+		pc.remember(&probeEntry{path: path, size: size, modTime: mtime, info: info})
+	}
+	return info
+}
+
+// Put caches the probe result of a file version
+func (pc *ProbeCache) Put(path string, size int64, modTime time.Time, info *ffprobe.VideoInfo) {
+	entry := &probeEntry{path: path, size: size, modTime: modTime.UnixNano(), info: info}
+	pc.remember(entry)
+
+	if pc.store != nil {
+		if err := pc.store.SaveProbe(path, entry.size, entry.modTime, info); err != nil {
+			log.Printf("Warning: Failed to store probe result of %s: %v", path, err)
+		}
+	}
+}
+
+// remember adds an entry to the memory cache, replacing the previous version of the file
+func (pc *ProbeCache) remember(entry *probeEntry) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if element, ok := pc.entries[entry.path]; ok {
+		element.Value = entry
+		pc.order.MoveToFront(element)
+		return
+	}
+	pc.entries[entry.path] = pc.order.PushFront(entry)
+
+	for pc.order.Len() > probeCacheSize {
+		oldest := pc.order.Back()
+		pc.order.Remove(oldest)
+		delete(pc.entries, oldest.Value.(*probeEntry).path)
+	}
+}
@@ -0,0 +1,338 @@
+package service
+
+import (
+	"context"
+	"ffmpeg-web/internal/model"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sample encode limits
+const (
+	DefaultSampleDuration = 30
+	MaxSampleDuration     = 600
+)
+
+// sampleTTL is how long sample files stay downloadable
+const sampleTTL = time.Hour
+
+// sampleCleanupInterval is how often expired sample files are removed
+const sampleCleanupInterval = 5 * time.Minute
+
+// maxConcurrentSamples is how many sample encodes run at once, next to the worker pool;
+// further requests wait for a free slot
+const maxConcurrentSamples = 1
+
+// SampleService encodes short ranges of source files to try a config before a batch run
+// Sample files live in a temp directory and are removed once they expire; encodes run one at a time.
+type SampleService struct {
+	ffmpegService   *FFmpegService
+	hardwareService *HardwareService
+	dir             string
+	mu              sync.Mutex
+	samples         map[string]*sampleFile
+	slots           chan struct{} // Running sample encodes (maxConcurrentSamples)
+	stop            chan struct{}
+	stopOnce        sync.Once
+}
+
+// sampleFile is an encoded sample waiting to be downloaded
+type sampleFile struct {
+	path      string
+	expiresAt time.Time
+}
+
+// NewSampleService creates a sample service and starts removing expired samples
+func NewSampleService(ffmpegService *FFmpegService, hardwareService *HardwareService) *SampleService {
+	ss := &SampleService{
+		ffmpegService:   ffmpegService,
+		hardwareService: hardwareService,
+		dir:             filepath.Join(os.TempDir(), "ffforge-samples"),
+		samples:         make(map[string]*sampleFile),
+		slots:           make(chan struct{}, maxConcurrentSamples),
+		stop:            make(chan struct{}),
+	}
+
+	// Samples of a previous run can't be downloaded anymore
+	os.RemoveAll(ss.dir)
+
+	go ss.cleanupLoop()
+	return ss
+}
+
+// Encode runs the real encode command of a config on length seconds of the source from start
+// The result projects the output size to the whole source; quality adds a score of the sample.
+func (ss *SampleService) Encode(ctx context.Context, sourceFile string, config *model.TranscodeConfig, start, length float64, quality bool) (*model.SampleResult, error) {
+	if config.Mode == "advanced" && config.CustomCommand != "" {
+		return nil, fmt.Errorf("sample encodes are not supported for custom commands")
+	}
+	if start < 0 || length < 0 {
+		return nil, fmt.Errorf("sample start and duration can't be negative")
+	}
+	if length == 0 {
+		length = DefaultSampleDuration
+	}
+	if length > MaxSampleDuration {
+		return nil, fmt.Errorf("sample duration can't exceed %ds", MaxSampleDuration)
+	}
+
+	sourceStat, err := os.Stat(sourceFile)
+	if err != nil {
+		return nil, fmt.Errorf("source file not found: %w", err)
+	}
+	videoInfo, err := ss.ffmpegService.ProbeFile(ctx, sourceFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe source file: %w", err)
+	}
+	if err := CheckSource(config, videoInfo); err != nil {
+		return nil, err
+	}
+
+	// Short sources are sampled up to their end
+	if videoInfo.Duration > 0 {
+		if start >= videoInfo.Duration {
+			return nil, fmt.Errorf("sample start %.1fs is past the end of the source (%.1fs)", start, videoInfo.Duration)
+		}
+		length = min(length, videoInfo.Duration-start)
+	}
+
+	// A sample is always a single regular encode of the given source range
+	sampleConfig := *config
+	sampleConfig.Chunking = nil
+	sampleConfig.Trim = nil
+	if Packaged(config) {
+		// HLS/DASH samples are a single file in the segment format
+		sampleConfig.Output.Container = outputContainer(&config.Output)
+		sampleConfig.Output.Kind = ""
+		sampleConfig.Output.Streaming = nil
+	}
+
+	if err := CheckCompatibility(&sampleConfig, videoInfo).Err(); err != nil {
+		return nil, err
+	}
+
+	sample := ChunkRange{Start: start, Duration: length}
+	opts := &EncodeOptions{Sample: &sample}
+	if scan, err := ss.ffmpegService.AnalyzeScan(ctx, sourceFile, &sampleConfig, videoInfo); err != nil {
+		log.Printf("Warning: Sample of %s: %v", sourceFile, err)
+	} else {
+		opts.Scan = scan
+	}
+	if sampleConfig.Video.RateControl == RateControlTargetSize {
+		bitrate, err := TargetVideoBitrate(&sampleConfig, videoInfo)
+		if err != nil {
+			return nil, err
+		}
+		opts.VideoBitrate = fmt.Sprintf("%dk", bitrate/1000)
+	}
+
+	// Wait for a free slot, the request may be cancelled meanwhile
+	select {
+	case ss.slots <- struct{}{}:
+		defer func() { <-ss.slots }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if err := os.MkdirAll(ss.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create sample directory: %w", err)
+	}
+
+	container := sampleConfig.Output.Container
+	if container == "" {
+		container = "mp4"
+	}
+	id := uuid.New().String()
+	outputFile := filepath.Join(ss.dir, id+"."+container)
+
+	passes := []int{0}
+	if ss.ffmpegService.UsesTwoPass(&sampleConfig) {
+		passLogDir, err := os.MkdirTemp("", "ffforge-passlog-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create pass log directory: %w", err)
+		}
+		defer os.RemoveAll(passLogDir)
+
+		opts.PassLogDir = passLogDir
+		passes = []int{1, 2}
+	}
+
+	// The sample counts as downloadable (and removable) from here on
+	ss.track(id, outputFile)
+
+	began := time.Now()
+	var commands []string
+	for _, pass := range passes {
+		opts.Pass = pass
+		cmd, err := ss.ffmpegService.BuildCommand(ctx, sourceFile, outputFile, &sampleConfig, videoInfo, opts)
+		if err != nil {
+			ss.remove(id)
+			return nil, err
+		}
+		commands = append(commands, strings.Join(cmd.Args, " "))
+
+		if err := runFFmpegCommand(cmd); err != nil {
+			ss.remove(id)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("sample encode failed: %w", err)
+		}
+	}
+	encodeTime := time.Since(began).Seconds()
+
+	outputStat, err := os.Stat(outputFile)
+	if err != nil {
+		ss.remove(id)
+		return nil, fmt.Errorf("sample encode produced no output: %w", err)
+	}
+
+	result := &model.SampleResult{
+		ID:         id,
+		SourceFile: sourceFile,
+		Start:      start,
+		Duration:   length,
+		Command:    strings.Join(commands, "\n"),
+		OutputSize: outputStat.Size(),
+		SourceSize: sourceStat.Size(),
+		EncodeTime: roundScore(encodeTime),
+		ExpiresAt:  ss.expiry(id),
+	}
+	if encodeTime > 0 {
+		result.Speed = roundScore(length / encodeTime)
+	}
+	if length > 0 && videoInfo.Duration > 0 {
+		result.ProjectedSize = int64(float64(result.OutputSize) * videoInfo.Duration / length)
+	}
+
+	if quality {
+		metric := ""
+		if sampleConfig.Quality != nil {
+			metric = sampleConfig.Quality.Metric
+		}
+		metric = SelectQualityMetric(metric, ss.availableFilters())
+		score, err := ss.ffmpegService.MeasureSampleQuality(ctx, sourceFile, outputFile, &sampleConfig, metric, sample, videoInfo, opts.Scan)
+		if err != nil {
+			log.Printf("Sample %s: quality scoring failed: %v", id, err)
+			result.QualityError = err.Error()
+		} else {
+			result.Quality = score
+		}
+	}
+
+	return result, nil
+}
+
+// File returns the path of a sample file that hasn't expired
+func (ss *SampleService) File(id string) (string, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	sample, ok := ss.samples[id]
+	if !ok || time.Now().After(sample.expiresAt) {
+		return "", fmt.Errorf("sample not found or expired")
+	}
+	return sample.path, nil
+}
+
+// Shutdown stops the cleanup loop and removes all sample files
+func (ss *SampleService) Shutdown() {
+	ss.stopOnce.Do(func() {
+		close(ss.stop)
+		os.RemoveAll(ss.dir)
+	})
+}
+
+// runFFmpegCommand runs an ffmpeg command, returning the last ffmpeg error line on failure
+func runFFmpegCommand(cmd *exec.Cmd) error {
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if line := lastLine(stderr.String()); line != "" {
+			return fmt.Errorf("%w: %s", err, line)
+		}
+		return err
+	}
+	return nil
+}
+
+// availableFilters returns the filters of the ffmpeg build (empty when unknown)
+func (ss *SampleService) availableFilters() []string {
+	if ss.hardwareService == nil {
+		return nil
+	}
+	caps := ss.hardwareService.GetEncoderCapabilities(false)
+	if caps == nil {
+		return nil
+	}
+	return caps.Filters
+}
+
+// track registers a sample file and starts its time to live
+func (ss *SampleService) track(id, path string) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.samples[id] = &sampleFile{path: path, expiresAt: time.Now().Add(sampleTTL)}
+}
+
+// expiry returns when a sample file expires
+func (ss *SampleService) expiry(id string) time.Time {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if sample, ok := ss.samples[id]; ok {
+		return sample.expiresAt
+	}
+	return time.Time{}
+}
+
+// remove deletes a sample file
+func (ss *SampleService) remove(id string) {
+	ss.mu.Lock()
+	sample, ok := ss.samples[id]
+	delete(ss.samples, id)
+	ss.mu.Unlock()
+
+	if ok {
+		os.Remove(sample.path)
+	}
+}
+
+// cleanupLoop removes expired sample files until Shutdown
+func (ss *SampleService) cleanupLoop() {
+	ticker := time.NewTicker(sampleCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ss.stop:
+			return
+		case <-ticker.C:
+			ss.removeExpired()
+		}
+	}
+}
+
+// removeExpired deletes every sample file past its time to live
+func (ss *SampleService) removeExpired() {
+	now := time.Now()
+	ss.mu.Lock()
+	expired := []string{}
+	for id, sample := range ss.samples {
+		if now.After(sample.expiresAt) {
+			expired = append(expired, id)
+		}
+	}
+	ss.mu.Unlock()
+
+	for _, id := range expired {
+		ss.remove(id)
+	}
+}
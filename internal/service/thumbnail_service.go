@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// thumbnailCacheTTL is how long an unused cached thumbnail is kept
+const thumbnailCacheTTL = 30 * 24 * time.Hour
+
+// thumbnailPruneInterval is how often stale cache entries are removed
+const thumbnailPruneInterval = time.Hour
+
+// thumbnailTimeout bounds the generation of a single thumbnail
+const thumbnailTimeout = 5 * time.Minute
+
+// spriteName matches the sprite images of a trickplay set
+var spriteName = regexp.MustCompile(`^sprite_\d{3,}\.jpg$`)
+
+// ThumbnailService generates thumbnails of files for the browser and caches them on disk
+// Cache entries are keyed by the path, modification time and size of the file and the
+// thumbnail options, so a changed file gets new thumbnails.
+type ThumbnailService struct {
+	ffmpegService *FFmpegService
+	dir           string
+	mu            sync.Mutex
+	pending       map[string]*thumbnailJob
+	stop          chan struct{}
+	stopOnce      sync.Once
+}
+
+// thumbnailJob is a thumbnail being generated, shared by concurrent requests
+type thumbnailJob struct {
+	done chan struct{}
+	err  error
+}
+
+// NewThumbnailService creates a thumbnail service caching into dir and removes stale entries
+func NewThumbnailService(ffmpegService *FFmpegService, dir string) *ThumbnailService {
+	ts := &ThumbnailService{
+		ffmpegService: ffmpegService,
+		dir:           dir,
+		pending:       make(map[string]*thumbnailJob),
+		stop:          make(chan struct{}),
+	}
+	ts.prune()
+	go ts.pruneLoop()
+	return ts
+}
+
+// Shutdown stops the periodic removal of stale cache entries
+func (ts *ThumbnailService) Shutdown() {
+	ts.stopOnce.Do(func() {
+		close(ts.stop)
+	})
+}
+
+// Thumbnail returns the cached image of a file, generating it first when needed
+// Trickplay returns the WebVTT index; its sprites are looked up with Sprite.
+func (ts *ThumbnailService) Thumbnail(ctx context.Context, path string, opts ThumbnailOptions) (string, error) {
+	entry, err := ts.entry(ctx, path, opts)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(entry, thumbnailFile(opts.Kind)), nil
+}
+
+// Sprite returns a cached sprite image of a file's trickplay set
+func (ts *ThumbnailService) Sprite(ctx context.Context, path string, opts ThumbnailOptions, name string) (string, error) {
+	if !spriteName.MatchString(name) {
+		return "", fmt.Errorf("invalid sprite name: %s", name)
+	}
+	opts.Kind = ThumbnailTrickplay
+	entry, err := ts.entry(ctx, path, opts)
+	if err != nil {
+		return "", err
+	}
+
+	sprite := filepath.Join(entry, name)
+	if _, err := os.Stat(sprite); err != nil {
+		return "", fmt.Errorf("sprite not found: %s", name)
+	}
+	return sprite, nil
+}
+
+// thumbnailFile returns the file name of a thumbnail in its cache entry
+func thumbnailFile(kind string) string {
+	if kind == ThumbnailTrickplay {
+		return TrickplayIndex
+	}
+	return kind + ".jpg"
+}
+
+// entry returns the cache directory of a thumbnail, generating it when it doesn't exist yet
+func (ts *ThumbnailService) entry(ctx context.Context, path string, opts ThumbnailOptions) (string, error) {
+	opts, err := opts.normalized()
+	if err != nil {
+		return "", err
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("file not found: %w", err)
+	}
+	if stat.IsDir() {
+		return "", fmt.Errorf("thumbnails need a file, not a directory")
+	}
+
+	key := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d|%+v", path, stat.ModTime().UnixNano(), stat.Size(), opts)))
+	id := hex.EncodeToString(key[:16])
+	entry := filepath.Join(ts.dir, id)
+
+	if _, err := os.Stat(entry); err == nil {
+		// Used entries stay in the cache
+		now := time.Now()
+		os.Chtimes(entry, now, now)
+		return entry, nil
+	}
+
+	// Requests for the same thumbnail wait for a single generation
+	ts.mu.Lock()
+	job, running := ts.pending[id]
+	if !running {
+		job = &thumbnailJob{done: make(chan struct{})}
+		ts.pending[id] = job
+		go ts.generate(id, path, opts, job)
+	}
+	ts.mu.Unlock()
+
+	select {
+	case <-job.done:
+		if job.err != nil {
+			return "", job.err
+		}
+		return entry, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// generate writes a thumbnail into a temporary directory and moves it into the cache when complete
+// It runs detached from the request, a reload picks up the result.
+func (ts *ThumbnailService) generate(id, path string, opts ThumbnailOptions, job *thumbnailJob) {
+	defer func() {
+		ts.mu.Lock()
+		delete(ts.pending, id)
+		ts.mu.Unlock()
+		close(job.done)
+	}()
+
+	job.err = func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), thumbnailTimeout)
+		defer cancel()
+
+		info, err := ts.ffmpegService.ProbeFile(ctx, path)
+		if err != nil {
+			return fmt.Errorf("failed to probe file: %w", err)
+		}
+		if len(info.StreamsOfType("video")) == 0 {
+			return fmt.Errorf("file has no video stream")
+		}
+		if err := os.MkdirAll(ts.dir, 0755); err != nil {
+			return fmt.Errorf("failed to create thumbnail cache: %w", err)
+		}
+		tmp, err := os.MkdirTemp(ts.dir, id+"-*.tmp")
+		if err != nil {
+			return fmt.Errorf("failed to create thumbnail directory: %w", err)
+		}
+		defer os.RemoveAll(tmp)
+
+		file := filepath.Join(tmp, thumbnailFile(opts.Kind))
+		switch opts.Kind {
+		case ThumbnailPoster:
+			err = ts.ffmpegService.Poster(ctx, path, info, opts, file)
+		case ThumbnailSheet:
+			err = ts.ffmpegService.ContactSheet(ctx, path, info, opts, file)
+		case ThumbnailTrickplay:
+			err = ts.ffmpegService.Trickplay(ctx, path, info, opts, file, tmp, "")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to generate %s thumbnail: %w", opts.Kind, err)
+		}
+
+		if err := os.Rename(tmp, filepath.Join(ts.dir, id)); err != nil {
+			return fmt.Errorf("failed to store thumbnail: %w", err)
+		}
+		return nil
+	}()
+}
+
+// pruneLoop periodically removes stale cache entries until shutdown
+func (ts *ThumbnailService) pruneLoop() {
+	ticker := time.NewTicker(thumbnailPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ts.stop:
+			return
+		case <-ticker.C:
+			ts.prune()
+		}
+	}
+}
+
+// prune removes cache entries that weren't used within thumbnailCacheTTL
+// and the leftovers of generations interrupted by a shutdown
+func (ts *ThumbnailService) prune() {
+	entries, err := os.ReadDir(ts.dir)
+	if err != nil {
+		return
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if strings.HasSuffix(entry.Name(), ".tmp") && ts.generating(entry.Name()) {
+			continue
+		}
+		if strings.HasSuffix(entry.Name(), ".tmp") || time.Since(info.ModTime()) > thumbnailCacheTTL {
+			if os.RemoveAll(filepath.Join(ts.dir, entry.Name())) == nil {
+				removed++
+			}
+		}
+	}
+	if removed > 0 {
+		log.Printf("Removed %d stale thumbnails from the cache", removed)
+	}
+}
+
+// generating reports whether a temporary directory belongs to a running generation
+func (ts *ThumbnailService) generating(name string) bool {
+	id, _, _ := strings.Cut(name, "-")
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	_, running := ts.pending[id]
+	return running
+}
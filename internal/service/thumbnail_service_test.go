@@ -0,0 +1,30 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestThumbnailPrune(t *testing.T) {
+	dir := t.TempDir()
+	ts := &ThumbnailService{dir: dir, pending: map[string]*thumbnailJob{"running": {}}}
+
+	old := time.Now().Add(-thumbnailCacheTTL - time.Hour)
+	for _, name := range []string{"fresh", "stale", "running-123.tmp", "orphan-456.tmp"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Chtimes(filepath.Join(dir, "stale"), old, old)
+
+	ts.prune()
+
+	for name, kept := range map[string]bool{"fresh": true, "stale": false, "running-123.tmp": true, "orphan-456.tmp": false} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if exists := err == nil; exists != kept {
+			t.Errorf("%s: exists = %v, want %v", name, exists, kept)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+//go:build linux
+
+package watch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyMask selects new files, finished writes and files moved into a directory
+const inotifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO
+
+// Filesystem magic numbers (statfs f_type) of network filesystems, inotify doesn't see remote changes there
+var networkFilesystems = map[int64]string{
+	0x6969:     "nfs",
+	0x517b:     "smb",
+	0xff534d42: "cifs",
+	0xfe534d42: "smb2",
+	0x65735546: "fuse",
+	0x01021997: "9p",
+}
+
+// inotifySource reports changes in a directory tree with inotify
+type inotifySource struct {
+	file      *os.File
+	fd        int
+	recursive bool
+	mu        sync.Mutex
+	dirs      map[int32]string // Watch descriptor -> directory
+}
+
+// newEventSource starts watching root (and its subdirectories when recursive) with inotify
+func newEventSource(root string, recursive bool) (eventSource, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize inotify: %w", err)
+	}
+
+	// A non-blocking descriptor uses the runtime poller, so Close interrupts a pending Read
+	source := &inotifySource{
+		file:      os.NewFile(uintptr(fd), "inotify"),
+		fd:        fd,
+		recursive: recursive,
+		dirs:      make(map[int32]string),
+	}
+
+	if err := source.addDir(root); err != nil {
+		source.Close()
+		return nil, err
+	}
+
+	return source, nil
+}
+
+// addDir watches a directory (and its subdirectories when recursive)
+func (s *inotifySource) addDir(dir string) error {
+	wd, err := syscall.InotifyAddWatch(s.fd, dir, inotifyMask)
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+
+	s.mu.Lock()
+	s.dirs[int32(wd)] = dir
+	s.mu.Unlock()
+
+	if !s.recursive {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			// Unreadable subdirectories are skipped, the rest of the tree is still watched
+			s.addDir(filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	return nil
+}
+
+// run reads inotify events until the source is closed
+func (s *inotifySource) run(onChange func(path string)) {
+	buf := make([]byte, 64*1024)
+
+	for {
+		n, err := s.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+int(event.Len)]), "\x00")
+			offset = nameStart + int(event.Len)
+
+			s.mu.Lock()
+			dir, ok := s.dirs[event.Wd]
+			if event.Mask&syscall.IN_IGNORED != 0 {
+				delete(s.dirs, event.Wd)
+			}
+			s.mu.Unlock()
+
+			// Events were lost, report the whole tree for a rescan
+			if event.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				onChange("")
+				continue
+			}
+			if !ok || name == "" {
+				continue
+			}
+
+			path := filepath.Join(dir, name)
+			if event.Mask&syscall.IN_ISDIR != 0 && s.recursive && !strings.HasPrefix(name, ".") {
+				s.addDir(path)
+			}
+			onChange(path)
+		}
+	}
+}
+
+// Close stops watching
+func (s *inotifySource) Close() error {
+	return s.file.Close()
+}
+
+// networkFilesystem returns the network filesystem type of a path ("" for local filesystems)
+func networkFilesystem(path string) string {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return ""
+	}
+	return networkFilesystems[int64(stat.Type)&0xffffffff]
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package watch
+
+import "fmt"
+
+// newEventSource is only available on Linux, watch folders are polled elsewhere
+func newEventSource(root string, recursive bool) (eventSource, error) {
+	return nil, fmt.Errorf("inotify is only available on Linux")
+}
+
+// networkFilesystem can't detect network filesystems outside Linux
+func networkFilesystem(path string) string {
+	return ""
+}
@@ -0,0 +1,443 @@
+// Package watch queues new media files that appear in watch folders
+package watch
+
+import (
+	"context"
+	"ffmpeg-web/internal/database"
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/internal/service"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultSettleSeconds is the settle delay of new watch folders
+const DefaultSettleSeconds = 30
+
+// settleCheckInterval is how often files waiting to settle are checked
+const settleCheckInterval = time.Second
+
+// pollInterval is how often polled watch folders are scanned
+const pollInterval = 30 * time.Second
+
+// Watcher modes
+const (
+	ModeInotify = "inotify"
+	ModePoll    = "poll"
+)
+
+// TaskSubmitter queues created tasks (the worker pool)
+type TaskSubmitter interface {
+	SubmitTask(taskID string)
+}
+
+// eventSource reports changed paths of a watch folder ("" = rescan everything)
+type eventSource interface {
+	run(onChange func(path string))
+	Close() error
+}
+
+// Manager runs a watcher for every enabled watch folder
+type Manager struct {
+	db              *database.DB
+	pool            TaskSubmitter
+	ffmpegService   *service.FFmpegService
+	fileService     *service.FileService
+	hardwareService *service.HardwareService
+	mu              sync.Mutex
+	watchers        map[string]*folderWatcher
+}
+
+// NewManager creates a watch folder manager
+func NewManager(db *database.DB, pool TaskSubmitter, ffmpegService *service.FFmpegService, fileService *service.FileService, hardwareService *service.HardwareService) *Manager {
+	return &Manager{
+		db:              db,
+		pool:            pool,
+		ffmpegService:   ffmpegService,
+		fileService:     fileService,
+		hardwareService: hardwareService,
+		watchers:        make(map[string]*folderWatcher),
+	}
+}
+
+// Start starts the watchers of all enabled watch folders
+func (m *Manager) Start() error {
+	folders, err := m.db.GetAllWatchFolders()
+	if err != nil {
+		return fmt.Errorf("failed to load watch folders: %w", err)
+	}
+
+	for _, folder := range folders {
+		m.Reload(folder)
+	}
+	return nil
+}
+
+// Reload restarts the watcher of a created or updated watch folder
+func (m *Manager) Reload(folder *model.WatchFolder) {
+	m.Remove(folder.ID)
+	if !folder.Enabled {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watcher := &folderWatcher{
+		manager: m,
+		folder:  folder,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		pending: make(map[string]*pendingFile),
+	}
+
+	m.mu.Lock()
+	m.watchers[folder.ID] = watcher
+	m.mu.Unlock()
+
+	go watcher.run(ctx)
+}
+
+// Remove stops the watcher of a watch folder
+func (m *Manager) Remove(id string) {
+	m.mu.Lock()
+	watcher, ok := m.watchers[id]
+	delete(m.watchers, id)
+	m.mu.Unlock()
+
+	if ok {
+		watcher.stop()
+	}
+}
+
+// Status returns a watch folder with the state of its watcher
+func (m *Manager) Status(folder *model.WatchFolder) *model.WatchFolderStatus {
+	status := &model.WatchFolderStatus{WatchFolder: *folder}
+
+	m.mu.Lock()
+	watcher, ok := m.watchers[folder.ID]
+	m.mu.Unlock()
+
+	if ok {
+		watcher.mu.Lock()
+		status.Mode = watcher.mode
+		status.Error = watcher.err
+		watcher.mu.Unlock()
+	}
+	return status
+}
+
+// Shutdown stops all watchers
+func (m *Manager) Shutdown() {
+	m.mu.Lock()
+	ids := make([]string, 0, len(m.watchers))
+	for id := range m.watchers {
+		ids = append(ids, id)
+	}
+	m.mu.Unlock()
+
+	for _, id := range ids {
+		m.Remove(id)
+	}
+}
+
+// pendingFile is a file waiting for its size to settle
+type pendingFile struct {
+	size    int64
+	modTime time.Time
+	since   time.Time // Last time the size or modification time changed
+}
+
+// folderWatcher watches a single watch folder
+type folderWatcher struct {
+	manager *Manager
+	folder  *model.WatchFolder
+	cancel  context.CancelFunc
+	done    chan struct{}
+	mu      sync.Mutex
+	mode    string
+	err     string
+	pending map[string]*pendingFile
+}
+
+// run watches the folder until ctx is cancelled
+// Files found by the initial scan, inotify events or polling wait until they settle, then get queued
+func (w *folderWatcher) run(ctx context.Context) {
+	defer close(w.done)
+
+	if info, err := os.Stat(w.folder.Path); err != nil || !info.IsDir() {
+		w.setState("", fmt.Sprintf("watch folder %s is not a directory", w.folder.Path))
+		log.Printf("Watch folder %s: %s is not a directory", w.folder.Name, w.folder.Path)
+		return
+	}
+
+	mode := ModePoll
+	var source eventSource
+	if fsType := networkFilesystem(w.folder.Path); !w.folder.Poll && fsType == "" {
+		var err error
+		if source, err = newEventSource(w.folder.Path, w.folder.Recursive); err != nil {
+			log.Printf("Watch folder %s: %v, polling instead", w.folder.Name, err)
+		} else {
+			mode = ModeInotify
+		}
+	} else if fsType != "" {
+		log.Printf("Watch folder %s is on %s, polling for changes", w.folder.Name, fsType)
+	}
+	w.setState(mode, "")
+	log.Printf("Watching %s (%s) for %s", w.folder.Path, mode, w.folder.Name)
+
+	changes := make(chan string, 256)
+	if source != nil {
+		defer source.Close()
+		go source.run(func(path string) {
+			select {
+			case changes <- path:
+			case <-ctx.Done():
+			}
+		})
+	}
+
+	w.scan()
+
+	settleTicker := time.NewTicker(settleCheckInterval)
+	defer settleTicker.Stop()
+
+	var poll <-chan time.Time
+	if source == nil {
+		pollTicker := time.NewTicker(pollInterval)
+		defer pollTicker.Stop()
+		poll = pollTicker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case path := <-changes:
+			if path == "" {
+				w.scan()
+			} else {
+				w.add(path)
+			}
+		case <-poll:
+			w.scan()
+		case <-settleTicker.C:
+			w.checkPending(ctx)
+		}
+	}
+}
+
+// stop stops the watcher and waits for it to finish
+func (w *folderWatcher) stop() {
+	w.cancel()
+	<-w.done
+}
+
+// setState stores the watcher mode and error for the API
+func (w *folderWatcher) setState(mode, err string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.mode = mode
+	w.err = err
+}
+
+// scan adds all unprocessed video files of the folder
+func (w *folderWatcher) scan() {
+	w.add(w.folder.Path)
+}
+
+// add adds a changed file, or the video files of a changed directory, to the pending files
+// Directories go through the same expansion as task creation
+func (w *folderWatcher) add(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		if path != w.folder.Path && !w.folder.Recursive {
+			return
+		}
+		if files, err = w.listFiles(path); err != nil {
+			log.Printf("Watch folder %s: %v", w.folder.Name, err)
+			return
+		}
+	}
+
+	for _, file := range files {
+		if _, ok := w.pending[file]; ok || !w.accepts(file) {
+			continue
+		}
+
+		processed, err := w.manager.db.IsWatchFileProcessed(w.folder.ID, file)
+		if err != nil {
+			log.Printf("Watch folder %s: failed to check %s: %v", w.folder.Name, file, err)
+			continue
+		}
+		if processed {
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		w.pending[file] = &pendingFile{size: info.Size(), modTime: info.ModTime(), since: time.Now()}
+	}
+}
+
+// listFiles lists the media files of a directory (recursively for recursive folders)
+func (w *folderWatcher) listFiles(dir string) ([]string, error) {
+	if w.folder.Recursive {
+		return w.manager.fileService.ExpandSourceFiles([]string{dir}, true)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan directory: %s", dir)
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+// accepts checks a file against the media extensions and the folder's glob patterns
+// Patterns with a slash match the path relative to the folder, others the file name
+func (w *folderWatcher) accepts(path string) bool {
+	name := filepath.Base(path)
+	if strings.HasPrefix(name, ".") || !service.IsMediaFile(name) {
+		return false
+	}
+
+	rel, err := filepath.Rel(w.folder.Path, path)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	if len(w.folder.Include) > 0 && !matchesAny(w.folder.Include, rel, name) {
+		return false
+	}
+	return !matchesAny(w.folder.Exclude, rel, name)
+}
+
+// matchesAny reports whether a file matches one of the glob patterns
+func matchesAny(patterns []string, rel, name string) bool {
+	for _, pattern := range patterns {
+		target := name
+		if strings.Contains(pattern, "/") {
+			target = rel
+		}
+		if matched, _ := filepath.Match(pattern, target); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPending queues the pending files whose size didn't change for the settle delay
+func (w *folderWatcher) checkPending(ctx context.Context) {
+	settle := time.Duration(w.folder.SettleSeconds) * time.Second
+
+	for path, file := range w.pending {
+		info, err := os.Stat(path)
+		if err != nil {
+			delete(w.pending, path)
+			continue
+		}
+
+		if info.Size() != file.size || !info.ModTime().Equal(file.modTime) {
+			file.size = info.Size()
+			file.modTime = info.ModTime()
+			file.since = time.Now()
+			continue
+		}
+
+		if time.Since(file.since) >= settle {
+			delete(w.pending, path)
+			if err := w.queue(ctx, path, file.size); err != nil {
+				log.Printf("Watch folder %s: failed to queue %s: %v", w.folder.Name, path, err)
+			}
+		}
+	}
+}
+
+// CheckPreset rejects presets whose outputs a watch folder can't tell apart from new files
+// Outputs are recognized by the output suffix, so presets overwriting the source are rejected.
+func CheckPreset(config *model.TranscodeConfig) error {
+	if config.Output.PathType == "overwrite" {
+		return fmt.Errorf("watch folders can't use presets that overwrite the source file (the output would be queued again)")
+	}
+	return nil
+}
+
+// queue creates a task for a settled file with the folder's preset, unless a skip rule matches
+func (w *folderWatcher) queue(ctx context.Context, path string, size int64) error {
+	db := w.manager.db
+
+	processed, err := db.IsWatchFileProcessed(w.folder.ID, path)
+	if err != nil || processed {
+		return err
+	}
+
+	preset, err := db.GetPreset(w.folder.Preset)
+	if err != nil {
+		return err
+	}
+	if err := service.ValidateTranscodeConfig(&preset.Config); err != nil {
+		return err
+	}
+	if err := w.manager.hardwareService.ValidateEncoderSupport(&preset.Config); err != nil {
+		return err
+	}
+	if err := CheckPreset(&preset.Config); err != nil {
+		return err
+	}
+
+	// Outputs written into the watched tree must not be queued again
+	if service.IsOutputFile(path, &preset.Config) {
+		log.Printf("Watch folder %s: ignored %s (output of the preset)", w.folder.Name, path)
+		return db.AddWatchFile(w.folder.ID, path, size, "")
+	}
+
+	// Skipped files are recorded without a task so they aren't probed again
+	// Audio files only get a task from audio presets
+	if !service.AudioMode(&preset.Config) && service.IsAudioFile(path) && !service.IsVideoFile(path) {
+		log.Printf("Watch folder %s: skipped %s (audio file, the preset isn't an audio task)", w.folder.Name, path)
+		return db.AddWatchFile(w.folder.ID, path, size, "")
+	}
+	if skip := w.manager.ffmpegService.CheckSkipRules(ctx, path, &preset.Config); skip != nil {
+		log.Printf("Watch folder %s: skipped %s (%s)", w.folder.Name, path, skip.Message)
+		return db.AddWatchFile(w.folder.ID, path, size, "")
+	}
+
+	task := &model.Task{
+		ID:         uuid.New().String(),
+		SourceFile: path,
+		OutputFile: "", // Will be set by worker
+		Status:     model.TaskStatusPending,
+		CreatedAt:  time.Now(),
+		Preset:     preset.ID,
+		Config:     preset.Config,
+	}
+	if err := db.CreateTask(task); err != nil {
+		return fmt.Errorf("failed to create task: %w", err)
+	}
+	if err := db.AddWatchFile(w.folder.ID, path, size, task.ID); err != nil {
+		log.Printf("Watch folder %s: failed to record %s: %v", w.folder.Name, path, err)
+	}
+
+	log.Printf("Watch folder %s: queued %s (task %s)", w.folder.Name, path, task.ID)
+	w.manager.pool.SubmitTask(task.ID)
+	return nil
+}
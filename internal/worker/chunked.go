@@ -0,0 +1,230 @@
+package worker
+
+import (
+	"context"
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/internal/service"
+	"ffmpeg-web/pkg/ffprobe"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Share of the task progress used by the segment encodes (the rest is the final mux)
+const chunkEncodeWeight = 95.0
+
+// chunkProgressInterval limits how often aggregated chunk progress is stored
+const chunkProgressInterval = time.Second
+
+// chunkProgress combines the progress of concurrently encoded segments
+type chunkProgress struct {
+	mu            sync.Mutex
+	done          []float64 // Encoded seconds per segment
+	speed         []float64 // Current speed per running segment
+	totalDuration float64
+	lastReport    time.Time
+}
+
+// update records a segment's progress and calls report with the combined progress, speed and ETA
+// report runs with the lock held (segments report concurrently) and at most once per interval
+func (cp *chunkProgress) update(index int, outTime, speed float64, report func(progress, speed float64, eta int64)) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.done[index] = outTime
+	cp.speed[index] = speed
+
+	if time.Since(cp.lastReport) < chunkProgressInterval {
+		return
+	}
+	cp.lastReport = time.Now()
+
+	encoded, totalSpeed := 0.0, 0.0
+	for i := range cp.done {
+		encoded += cp.done[i]
+		totalSpeed += cp.speed[i]
+	}
+
+	progress, eta := 0.0, int64(0)
+	if cp.totalDuration > 0 {
+		progress = min(encoded/cp.totalDuration, 1) * chunkEncodeWeight
+		if totalSpeed > 0 {
+			eta = int64((cp.totalDuration - encoded) / totalSpeed)
+		}
+	}
+
+	report(progress, totalSpeed, eta)
+}
+
+// finish marks a segment as fully encoded (or reset with 0 for a retry)
+func (cp *chunkProgress) finish(index int, encoded float64) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.done[index] = encoded
+	cp.speed[index] = 0
+}
+
+// runChunked encodes the video in segments in parallel and muxes the joined video
+// with the audio, subtitle and attachment streams of the source
+// Each segment is retried on its own before the task fails
+func (p *Pool) runChunked(ctx context.Context, task *model.Task, sourceFile, outputFile string, videoInfo *ffprobe.VideoInfo, encodeOpts *service.EncodeOptions) error {
+	chunkDuration, parallel, retries := service.ChunkSettings(&task.Config)
+
+	keyframes, err := p.ffmpegService.ProbeKeyframes(ctx, sourceFile)
+	if err != nil {
+		return fmt.Errorf("failed to read keyframes: %w", err)
+	}
+	chunks := service.PlanChunks(keyframes, videoInfo.Duration, chunkDuration)
+	log.Printf("Task %s: encoding %d segments, %d in parallel", task.ID, len(chunks), parallel)
+
+	chunkDir, err := os.MkdirTemp(filepath.Dir(outputFile), ".ffforge-chunks-")
+	if err != nil {
+		return fmt.Errorf("failed to create segment directory: %w", err)
+	}
+	defer os.RemoveAll(chunkDir)
+
+	// The first failed segment (after its retries) stops the others
+	chunkCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	progress := &chunkProgress{
+		done:          make([]float64, len(chunks)),
+		speed:         make([]float64, len(chunks)),
+		totalDuration: videoInfo.Duration,
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errMu    sync.Mutex
+		firstErr error
+		commands = make([]string, len(chunks))
+	)
+	semaphore := make(chan struct{}, parallel)
+	chunkFiles := make([]string, len(chunks))
+
+	for i, chunk := range chunks {
+		chunkFiles[i] = fmt.Sprintf("chunk_%05d.mkv", i)
+
+		wg.Add(1)
+		go func(i int, chunk service.ChunkRange) {
+			defer wg.Done()
+
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-chunkCtx.Done():
+				return
+			}
+
+			command, err := p.encodeChunk(chunkCtx, task, sourceFile, filepath.Join(chunkDir, chunkFiles[i]), videoInfo, encodeOpts, i, chunk, retries, progress)
+			commands[i] = command
+			if err != nil {
+				errMu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMu.Unlock()
+				cancel()
+			}
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	// Store the first segment command and the mux command (visible in task details)
+	task.ActualCommand = commands[0]
+	if len(chunks) > 1 {
+		task.ActualCommand += fmt.Sprintf("\n# ... %d segments", len(chunks))
+	}
+	p.store.UpdateTask(task)
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+
+	// Join the segments with the concat demuxer (paths relative to the list file)
+	listFile := filepath.Join(chunkDir, "segments.txt")
+	var list strings.Builder
+	for _, file := range chunkFiles {
+		fmt.Fprintf(&list, "file '%s'\n", file)
+	}
+	if err := os.WriteFile(listFile, []byte(list.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write segment list: %w", err)
+	}
+
+	muxOpts := *encodeOpts
+	muxOpts.ConcatList = listFile
+	cmd, err := p.ffmpegService.BuildCommand(ctx, sourceFile, outputFile, &task.Config, videoInfo, &muxOpts)
+	if err != nil {
+		return err
+	}
+
+	task.ActualCommand += "\n" + strings.Join(cmd.Args, " ")
+	p.store.UpdateTask(task)
+
+	log.Printf("Task %s: joining %d segments", task.ID, len(chunks))
+	return p.runFFmpeg(ctx, task, cmd, videoInfo.Duration, chunkEncodeWeight, 100-chunkEncodeWeight)
+}
+
+// encodeChunk encodes one segment, retrying it up to retries times
+// Returns the ffmpeg command line of the last attempt
+func (p *Pool) encodeChunk(ctx context.Context, task *model.Task, sourceFile, chunkFile string, videoInfo *ffprobe.VideoInfo, encodeOpts *service.EncodeOptions, index int, chunk service.ChunkRange, retries int, progress *chunkProgress) (string, error) {
+	chunkDuration := chunk.Duration
+	if chunkDuration == 0 {
+		chunkDuration = videoInfo.Duration - chunk.Start
+	}
+
+	var command string
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			log.Printf("Task %s: retrying segment %d (attempt %d of %d): %v", task.ID, index, attempt+1, retries+1, err)
+			progress.finish(index, 0)
+		}
+
+		command, err = p.runChunkAttempt(ctx, task, sourceFile, chunkFile, videoInfo, encodeOpts, index, chunk, progress)
+		if err == nil {
+			progress.finish(index, chunkDuration)
+			return command, nil
+		}
+		if ctx.Err() != nil {
+			return command, ctx.Err()
+		}
+	}
+
+	return command, fmt.Errorf("segment %d failed after %d attempts: %w", index, retries+1, err)
+}
+
+// runChunkAttempt runs a single encode of a segment
+// "auto" device tasks pick the least busy GPU per segment, spreading segments across GPUs
+func (p *Pool) runChunkAttempt(ctx context.Context, task *model.Task, sourceFile, chunkFile string, videoInfo *ffprobe.VideoInfo, encodeOpts *service.EncodeOptions, index int, chunk service.ChunkRange, progress *chunkProgress) (string, error) {
+	opts := *encodeOpts
+	opts.Chunk = &chunk
+
+	if device := p.acquireDevice(&task.Config); device != "" {
+		defer p.releaseDevice(device)
+		if task.Config.Device != device {
+			opts.Device = device
+		}
+	}
+
+	cmd, err := p.ffmpegService.BuildCommand(ctx, sourceFile, chunkFile, &task.Config, videoInfo, &opts)
+	if err != nil {
+		return "", err
+	}
+	command := strings.Join(cmd.Args, " ")
+
+	err = execFFmpeg(ctx, cmd, func(update *service.ProgressUpdate) {
+		progress.update(index, update.OutTime, update.Speed, func(value, speed float64, eta int64) {
+			p.updateProgress(task, value, speed, eta)
+		})
+	})
+	return command, err
+}
@@ -0,0 +1,445 @@
+package worker
+
+import (
+	"errors"
+	"ffmpeg-web/internal/database"
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/internal/service"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AgentHeartbeatInterval is how often agents send a heartbeat
+const AgentHeartbeatInterval = 10 * time.Second
+
+// agentTimeout is how long an agent may stay silent before its tasks are reassigned
+const agentTimeout = 3 * AgentHeartbeatInterval
+
+var (
+	// ErrAgentNotFound is returned for agents that aren't registered (anymore)
+	ErrAgentNotFound = errors.New("agent not registered")
+	// ErrTaskNotAssigned is returned when a task doesn't run on the agent anymore (cancelled or reassigned)
+	ErrTaskNotAssigned = errors.New("task is not assigned to this agent")
+)
+
+// agentAssignment is a task running on an agent
+type agentAssignment struct {
+	agentID    string
+	sourcePath string
+	outputPath string
+	mediaKey   string
+}
+
+// Coordinator hands out tasks to remote agents and tracks their heartbeats
+// Agent tasks report into the same progress stream as the local worker pool
+type Coordinator struct {
+	db          *database.DB
+	pool        *Pool
+	media       *localMedia
+	agents      map[string]*model.Agent
+	assignments map[string]*agentAssignment // Task ID -> assignment
+	mu          sync.Mutex
+	done        chan struct{}
+}
+
+// NewCoordinator creates a coordinator for remote agents and starts the heartbeat check
+func NewCoordinator(pool *Pool) *Coordinator {
+	media, _ := pool.media.(*localMedia)
+
+	c := &Coordinator{
+		db:          pool.db,
+		pool:        pool,
+		media:       media,
+		agents:      make(map[string]*model.Agent),
+		assignments: make(map[string]*agentAssignment),
+		done:        make(chan struct{}),
+	}
+
+	go c.watchHeartbeats()
+
+	return c
+}
+
+// Shutdown stops the heartbeat check
+func (c *Coordinator) Shutdown() {
+	close(c.done)
+}
+
+// Register adds an agent and returns it with its new ID
+func (c *Coordinator) Register(registration *model.AgentRegistration) *model.Agent {
+	now := time.Now()
+	agent := &model.Agent{
+		ID:            uuid.New().String(),
+		Name:          registration.Name,
+		MaxTasks:      max(registration.MaxTasks, 1),
+		Hardware:      registration.Hardware,
+		Capabilities:  registration.Capabilities,
+		WritesInPlace: registration.WritesInPlace,
+		Tasks:         []string{},
+		RegisteredAt:  now,
+		LastSeen:      now,
+	}
+
+	c.mu.Lock()
+	c.agents[agent.ID] = agent
+	c.mu.Unlock()
+
+	log.Printf("Agent %s (%s) registered, up to %d tasks", agent.Name, agent.ID, agent.MaxTasks)
+	return agent
+}
+
+// Unregister removes an agent, its tasks go back to the queue
+func (c *Coordinator) Unregister(agentID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	agent, ok := c.agents[agentID]
+	if !ok {
+		return ErrAgentNotFound
+	}
+
+	log.Printf("Agent %s (%s) unregistered", agent.Name, agent.ID)
+	c.removeAgent(agent)
+	return nil
+}
+
+// Heartbeat records that an agent is alive
+func (c *Coordinator) Heartbeat(agentID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	agent, ok := c.agents[agentID]
+	if !ok {
+		return ErrAgentNotFound
+	}
+	agent.LastSeen = time.Now()
+	return nil
+}
+
+// Agents returns the registered agents
+func (c *Coordinator) Agents() []model.Agent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	agents := make([]model.Agent, 0, len(c.agents))
+	for _, agent := range c.agents {
+		copied := *agent
+		copied.Tasks = slices.Clone(agent.Tasks)
+		agents = append(agents, copied)
+	}
+	slices.SortFunc(agents, func(a, b model.Agent) int {
+		return a.RegisteredAt.Compare(b.RegisteredAt)
+	})
+
+	return agents
+}
+
+// ClaimTask assigns the oldest pending task the agent can run to the agent
+// Returns nil when there is no such task or the agent is busy
+func (c *Coordinator) ClaimTask(agentID string) (*model.AgentTask, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	agent, ok := c.agents[agentID]
+	if !ok {
+		return nil, ErrAgentNotFound
+	}
+	agent.LastSeen = time.Now()
+
+	if len(agent.Tasks) >= agent.MaxTasks {
+		return nil, nil
+	}
+
+	tasks, err := c.db.GetPendingTasks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending tasks: %w", err)
+	}
+
+	for _, task := range tasks {
+		if !c.canRun(agent, task) {
+			continue
+		}
+
+		sourcePath, err := c.media.SourcePath(task)
+		if err != nil {
+			continue
+		}
+
+		now := time.Now()
+		claimed, err := c.db.ClaimTask(task.ID, agent.ID, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim task: %w", err)
+		}
+		if !claimed {
+			continue
+		}
+
+		// The output path is decided here, so it follows the server's settings
+		task.Status = model.TaskStatusRunning
+		task.StartedAt = &now
+		task.AgentID = agent.ID
+		task.OutputFile, _ = c.media.OutputPath(task)
+		if info, err := os.Stat(sourcePath); err == nil {
+			task.SourceFileSize = info.Size()
+		}
+		if err := c.db.UpdateTask(task); err != nil {
+			log.Printf("Failed to update task %s: %v", task.ID, err)
+		}
+
+		assignment := &agentAssignment{
+			agentID:    agent.ID,
+			sourcePath: sourcePath,
+			outputPath: task.OutputFile,
+			mediaKey:   uuid.New().String(),
+		}
+		c.assignments[task.ID] = assignment
+		agent.Tasks = append(agent.Tasks, task.ID)
+
+		log.Printf("Task %s assigned to agent %s", task.ID, agent.Name)
+		c.pool.progressChan <- &ProgressUpdate{
+			TaskID: task.ID,
+			Status: string(model.TaskStatusRunning),
+		}
+
+		return &model.AgentTask{
+			Task:       task,
+			SourcePath: sourcePath,
+			MediaKey:   assignment.mediaKey,
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// canRun reports whether an agent supports a task's config
+// Tasks pinned to a GPU device only run locally, device IDs are specific to the server.
+// HLS/DASH packages are directories that can't be uploaded, they need an agent writing in place.
+func (c *Coordinator) canRun(agent *model.Agent, task *model.Task) bool {
+	if task.Config.Device != "" && task.Config.Device != service.DeviceAuto {
+		return false
+	}
+	if service.Packaged(&task.Config) && !agent.WritesInPlace {
+		return false
+	}
+	return service.CheckEncoderSupport(agent.Capabilities, agent.Hardware, &task.Config) == nil
+}
+
+// UpdateTask applies a task state reported by an agent and broadcasts its progress
+// Returns ErrTaskNotAssigned when the task was cancelled or reassigned, the agent then stops it
+func (c *Coordinator) UpdateTask(agentID string, update *model.Task) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	agent, ok := c.agents[agentID]
+	if !ok {
+		return ErrAgentNotFound
+	}
+	agent.LastSeen = time.Now()
+
+	assignment, ok := c.assignments[update.ID]
+	if !ok || assignment.agentID != agentID {
+		return ErrTaskNotAssigned
+	}
+
+	task, err := c.db.GetTask(update.ID)
+	if err != nil || task.Status != model.TaskStatusRunning || task.AgentID != agentID {
+		c.releaseTask(agent, update.ID)
+		return ErrTaskNotAssigned
+	}
+
+	// Only the runtime state comes from the agent, paths and config stay as on the server
+	task.Progress = update.Progress
+	task.Speed = update.Speed
+	task.ETA = update.ETA
+	task.Error = update.Error
+	task.Loudness = update.Loudness
+	task.Warnings = update.Warnings
+	task.Verification = update.Verification
+	task.Quality = update.Quality
+	task.ActualCommand = update.ActualCommand
+	if update.SourceFileSize > 0 {
+		task.SourceFileSize = update.SourceFileSize
+	}
+
+	switch update.Status {
+	case model.TaskStatusRunning:
+	case model.TaskStatusCompleted, model.TaskStatusFailed, model.TaskStatusCancelled, model.TaskStatusSkipped:
+		task.Status = update.Status
+		task.CompletedAt = update.CompletedAt
+		if task.CompletedAt == nil {
+			now := time.Now()
+			task.CompletedAt = &now
+		}
+		if update.Status == model.TaskStatusCompleted {
+			if size, err := service.OutputSize(assignment.outputPath); err == nil {
+				task.OutputFileSize = size
+			} else {
+				task.OutputFileSize = update.OutputFileSize
+			}
+			// Thumbnails sit next to the output, the agent reports them under its own paths
+			task.Thumbnails = nil
+			for _, thumbnail := range update.Thumbnails {
+				task.Thumbnails = append(task.Thumbnails, filepath.Join(filepath.Dir(assignment.outputPath), filepath.Base(thumbnail)))
+			}
+			c.media.applyFilePermissions(task, assignment.sourcePath, assignment.outputPath)
+		}
+		if update.Status == model.TaskStatusSkipped {
+			// The agent discarded the output without uploading it
+			task.SkipReason = update.SkipReason
+			task.OutputFileSize = update.OutputFileSize
+		}
+		c.releaseTask(agent, task.ID)
+	default:
+		return fmt.Errorf("invalid task status: %s", update.Status)
+	}
+
+	if err := c.db.UpdateTask(task); err != nil {
+		return fmt.Errorf("failed to update task: %w", err)
+	}
+
+	c.pool.progressChan <- &ProgressUpdate{
+		TaskID:   task.ID,
+		Status:   string(task.Status),
+		Progress: task.Progress,
+		Speed:    task.Speed,
+		ETA:      task.ETA,
+		Error:    task.Error,
+	}
+
+	return nil
+}
+
+// MediaSource returns the source path of an agent task for its media key
+func (c *Coordinator) MediaSource(agentID, taskID, key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	assignment, err := c.assignment(agentID, taskID, key)
+	if err != nil {
+		return "", err
+	}
+	return assignment.sourcePath, nil
+}
+
+// WriteOutput stores an output file uploaded by an agent
+// The upload goes to a temporary file next to the output path, which is replaced when the upload is complete
+func (c *Coordinator) WriteOutput(agentID, taskID, key string, body io.Reader) error {
+	c.mu.Lock()
+	assignment, err := c.assignment(agentID, taskID, key)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	outputDir := filepath.Dir(assignment.outputPath)
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	file, err := os.CreateTemp(outputDir, ".ffforge-upload-")
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, body); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	if err := os.Rename(file.Name(), assignment.outputPath); err != nil {
+		return fmt.Errorf("failed to move output file: %w", err)
+	}
+	if err := os.Chmod(assignment.outputPath, 0644); err != nil {
+		log.Printf("Warning: Failed to set output file mode: %v", err)
+	}
+
+	return nil
+}
+
+// assignment returns the assignment of an agent task, checking the media key
+func (c *Coordinator) assignment(agentID, taskID, key string) (*agentAssignment, error) {
+	assignment, ok := c.assignments[taskID]
+	if !ok || assignment.agentID != agentID || assignment.mediaKey != key {
+		return nil, ErrTaskNotAssigned
+	}
+	return assignment, nil
+}
+
+// watchHeartbeats removes agents that missed their heartbeats and reassigns their tasks
+func (c *Coordinator) watchHeartbeats() {
+	ticker := time.NewTicker(AgentHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			for _, agent := range c.agents {
+				if time.Since(agent.LastSeen) > agentTimeout {
+					log.Printf("Agent %s (%s) timed out", agent.Name, agent.ID)
+					c.removeAgent(agent)
+				}
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+// removeAgent drops an agent and requeues its running tasks (called with the lock held)
+func (c *Coordinator) removeAgent(agent *model.Agent) {
+	delete(c.agents, agent.ID)
+
+	for _, taskID := range agent.Tasks {
+		delete(c.assignments, taskID)
+
+		task, err := c.db.GetTask(taskID)
+		if err != nil || task.Status != model.TaskStatusRunning || task.AgentID != agent.ID {
+			continue
+		}
+
+		resetTask(task)
+		if err := c.db.UpdateTask(task); err != nil {
+			log.Printf("Failed to requeue task %s: %v", taskID, err)
+			continue
+		}
+
+		log.Printf("Task %s requeued (agent %s gone)", taskID, agent.Name)
+		c.pool.progressChan <- &ProgressUpdate{
+			TaskID: taskID,
+			Status: string(model.TaskStatusPending),
+		}
+		c.pool.SubmitTask(taskID)
+	}
+}
+
+// resetTask puts a task taken from an agent back into the pending state
+func resetTask(task *model.Task) {
+	task.Status = model.TaskStatusPending
+	task.AgentID = ""
+	task.Progress = 0
+	task.Speed = 0
+	task.ETA = 0
+	task.StartedAt = nil
+}
+
+// releaseTask removes a finished task from its agent (called with the lock held)
+func (c *Coordinator) releaseTask(agent *model.Agent, taskID string) {
+	delete(c.assignments, taskID)
+	agent.Tasks = slices.DeleteFunc(agent.Tasks, func(id string) bool {
+		return id == taskID
+	})
+}
@@ -11,6 +11,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -19,13 +20,15 @@ import (
 
 // Pool manages a pool of workers for processing transcode tasks
 type Pool struct {
-	db                *database.DB
+	db                *database.DB // nil for an agent's pool
+	store             TaskStore
+	media             MediaPaths
 	ffmpegService     *service.FFmpegService
-	fileService       *service.FileService
-	permissionService *service.PermissionService
+	hardwareService   *service.HardwareService
 	maxWorkers        int
 	taskQueue         chan string // Task IDs
 	cancelFuncs       map[string]context.CancelFunc
+	deviceSessions    map[string]int // Running tasks per GPU device ID
 	mu                sync.RWMutex
 	progressChan      chan *ProgressUpdate
 	externalBroadcast chan<- *ProgressUpdate // External broadcast channel (e.g., WebSocket)
@@ -45,20 +48,44 @@ type ProgressUpdate struct {
 }
 
 // NewPool creates a new worker pool
-func NewPool(db *database.DB, ffmpegService *service.FFmpegService, fileService *service.FileService, maxWorkers int) *Pool {
-	ctx, cancel := context.WithCancel(context.Background())
-
-	pool := &Pool{
+func NewPool(db *database.DB, ffmpegService *service.FFmpegService, fileService *service.FileService, hardwareService *service.HardwareService, maxWorkers int) *Pool {
+	media := &localMedia{
 		db:                db,
 		ffmpegService:     ffmpegService,
 		fileService:       fileService,
 		permissionService: service.NewPermissionService(),
-		maxWorkers:        maxWorkers,
-		taskQueue:         make(chan string, 100),
-		cancelFuncs:       make(map[string]context.CancelFunc),
-		progressChan:      make(chan *ProgressUpdate, 100),
-		ctx:               ctx,
-		cancel:            cancel,
+	}
+	pool := newPool(&dbStore{db: db}, media, ffmpegService, hardwareService, maxWorkers)
+	pool.db = db
+
+	// Load pending tasks from database
+	go pool.loadPendingTasks()
+
+	return pool
+}
+
+// NewAgentPool creates a worker pool for a remote agent
+// Tasks come from the store instead of the database and are submitted by the agent
+func NewAgentPool(store TaskStore, media MediaPaths, ffmpegService *service.FFmpegService, hardwareService *service.HardwareService, maxWorkers int) *Pool {
+	return newPool(store, media, ffmpegService, hardwareService, maxWorkers)
+}
+
+// newPool creates a worker pool and starts its workers
+func newPool(store TaskStore, media MediaPaths, ffmpegService *service.FFmpegService, hardwareService *service.HardwareService, maxWorkers int) *Pool {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	pool := &Pool{
+		store:           store,
+		media:           media,
+		ffmpegService:   ffmpegService,
+		hardwareService: hardwareService,
+		maxWorkers:      maxWorkers,
+		taskQueue:       make(chan string, 100),
+		cancelFuncs:     make(map[string]context.CancelFunc),
+		deviceSessions:  make(map[string]int),
+		progressChan:    make(chan *ProgressUpdate, 100),
+		ctx:             ctx,
+		cancel:          cancel,
 	}
 
 	// Start workers
@@ -71,9 +98,6 @@ func NewPool(db *database.DB, ffmpegService *service.FFmpegService, fileService
 	pool.wg.Add(1)
 	go pool.progressBroadcaster()
 
-	// Load pending tasks from database
-	go pool.loadPendingTasks()
-
 	return pool
 }
 
@@ -102,29 +126,18 @@ func (p *Pool) worker(id int) {
 
 // processTask processes a single transcode task
 func (p *Pool) processTask(taskID string) {
-	// Get task from database
-	task, err := p.db.GetTask(taskID)
+	// Mark the task as running (skipped if it was paused, cancelled or claimed by an agent)
+	task, err := p.store.ClaimTask(taskID)
 	if err != nil {
-		log.Printf("Failed to get task %s: %v", taskID, err)
+		log.Printf("Failed to claim task %s: %v", taskID, err)
 		return
 	}
-
-	// Skip if already processing or completed
-	if task.Status != model.TaskStatusPending {
-		return
-	}
-
-	// Update task status to running
-	now := time.Now()
-	task.Status = model.TaskStatusRunning
-	task.StartedAt = &now
-	if err := p.db.UpdateTask(task); err != nil {
-		log.Printf("Failed to update task status: %v", err)
+	if task == nil {
 		return
 	}
 
 	// Get full source file path
-	sourceFile, err := p.fileService.GetFullPath(task.SourceFile)
+	sourceFile, err := p.media.SourcePath(task)
 	if err != nil {
 		p.failTask(task, err.Error())
 		return
@@ -136,28 +149,60 @@ func (p *Pool) processTask(taskID string) {
 		log.Printf("Warning: Failed to get source file size: %v", err)
 	} else {
 		task.SourceFileSize = sourceFileInfo.Size()
-		p.db.UpdateTask(task)
+		p.store.UpdateTask(task)
 	}
 
 	// Probe source file to get duration
-	videoInfo, err := p.ffmpegService.ProbeFile(sourceFile)
+	videoInfo, err := p.ffmpegService.ProbeFile(p.ctx, sourceFile)
 	if err != nil {
 		p.failTask(task, "failed to probe source file: "+err.Error())
 		return
 	}
+	if err := service.CheckSource(&task.Config, videoInfo); err != nil {
+		p.failTask(task, err.Error())
+		return
+	}
+
+	// Progress runs against the output duration, which trimming shortens
+	totalDuration := service.TrimmedDuration(&task.Config, videoInfo.Duration)
 
-	totalDuration := videoInfo.Duration
+	// Check the streams against the output container before ffmpeg starts
+	compat := service.CheckCompatibility(&task.Config, videoInfo)
+	if err := compat.Err(); err != nil {
+		p.failTask(task, err.Error())
+		return
+	}
+	if len(compat.Issues) > 0 {
+		for _, warning := range compat.Warnings() {
+			log.Printf("Task %s: %s", taskID, warning)
+		}
+		task.Warnings = compat.Warnings()
+		p.store.UpdateTask(task)
+	}
 
 	// Generate output file path
-	outputFile := p.ffmpegService.GenerateOutputPath(task.SourceFile, &task.Config)
+	outputFile, err := p.media.OutputPath(task)
+	if err != nil {
+		p.failTask(task, err.Error())
+		return
+	}
 	task.OutputFile = outputFile
-	p.db.UpdateTask(task)
+	p.store.UpdateTask(task)
 
 	// Output file is already a full path from GenerateOutputPath
 	fullOutputFile := outputFile
 
 	// Ensure output directory exists
 	outputDir := filepath.Dir(fullOutputFile)
+	if service.Packaged(&task.Config) {
+		// HLS/DASH packages are written into their own directory;
+		// segments of a previous run would be left next to the new ones
+		if err := service.RemovePreviousPackage(fullOutputFile, &task.Config); err != nil {
+			p.failTask(task, err.Error())
+			return
+		}
+		outputDir = fullOutputFile
+	}
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		p.failTask(task, "failed to create output directory: "+err.Error())
 		return
@@ -175,83 +220,236 @@ func (p *Pool) processTask(taskID string) {
 		p.mu.Unlock()
 	}()
 
-	// Build FFmpeg command (pass videoInfo for dynamic HDR handling)
-	cmd := p.ffmpegService.BuildCommand(taskCtx, sourceFile, fullOutputFile, &task.Config, videoInfo)
+	encodeOpts := &service.EncodeOptions{}
+
+	// Custom commands and remuxes don't take the run-time encode settings below,
+	// audio tasks only take the loudness measurements
+	passthrough := (task.Config.Mode == "advanced" && task.Config.CustomCommand != "") || service.RemuxMode(&task.Config)
+	audioOnly := service.AudioMode(&task.Config)
+
+	// Loudness normalization: run the measurement pass over every normalized track first,
+	// the real encode then applies linear normalization with the measured values
+	// (tracks that can't be measured, e.g. silent ones, fall back to single-pass normalization)
+	loudnorm := task.Config.Audio.Loudnorm
+	if !passthrough && loudnorm != nil && loudnorm.Enabled && len(videoInfo.StreamsOfType("audio")) > 0 {
+		log.Printf("Measuring loudness for task %s", taskID)
+		measured, warnings, err := p.ffmpegService.MeasureLoudness(taskCtx, sourceFile, &task.Config, videoInfo)
+		if err != nil {
+			if taskCtx.Err() == context.Canceled {
+				task.Status = model.TaskStatusCancelled
+				p.store.UpdateTask(task)
+				return
+			}
+			p.failTask(task, err.Error())
+			return
+		}
 
-	// Store actual command for debugging (visible in task details)
-	task.ActualCommand = strings.Join(cmd.Args, " ")
-	p.db.UpdateTask(task)
+		for _, stats := range measured {
+			log.Printf("Task %s loudness of stream %d: I=%.1f LUFS, TP=%.1f dBTP, LRA=%.1f LU",
+				taskID, stats.Stream, stats.InputI, stats.InputTP, stats.InputLRA)
+		}
+		for _, warning := range warnings {
+			log.Printf("Task %s: %s", taskID, warning)
+		}
+		task.Loudness = measured
+		task.Warnings = append(task.Warnings, warnings...)
+		encodeOpts.Loudness = measured
+		p.store.UpdateTask(task)
+	}
 
-	// Get stderr pipe for progress and error messages
-	stderr, err := cmd.StderrPipe()
+	// Scan analysis for "auto" deinterlacing and frame rate modes; ambiguous sources are
+	// sampled with idet, without it the metadata analysis is used
+	scan, err := p.ffmpegService.AnalyzeScan(taskCtx, sourceFile, &task.Config, videoInfo)
 	if err != nil {
-		p.failTask(task, "failed to get stderr pipe: "+err.Error())
-		return
+		if taskCtx.Err() == context.Canceled {
+			task.Status = model.TaskStatusCancelled
+			p.store.UpdateTask(task)
+			return
+		}
+		log.Printf("Warning: Task %s: %v", taskID, err)
+	} else if scan != nil {
+		kind := scan.Type
+		if kind == "" {
+			kind = "unknown"
+		}
+		if scan.Pulldown != "" {
+			kind += " (" + scan.Pulldown + ")"
+		}
+		log.Printf("Task %s scan: %s, field order %q, %s fps, VFR %t", taskID, kind, scan.FieldOrder, scan.AvgFrameRate, scan.VFR)
+		encodeOpts.Scan = scan
 	}
 
-	// Also capture stderr for error messages
-	var stderrBuf bytes.Buffer
-
-	// Start command
-	if err := cmd.Start(); err != nil {
-		p.failTask(task, "failed to start ffmpeg: "+err.Error())
-		return
+	// Target-size rate control: derive the video bitrate from the probed duration
+	if !passthrough && !audioOnly && task.Config.Video.RateControl == service.RateControlTargetSize {
+		bitrate, err := service.TargetVideoBitrate(&task.Config, videoInfo)
+		if err != nil {
+			p.failTask(task, err.Error())
+			return
+		}
+		encodeOpts.VideoBitrate = fmt.Sprintf("%dk", bitrate/1000)
+		log.Printf("Task %s: target size %s -> video bitrate %s", taskID, task.Config.Video.TargetSize, encodeOpts.VideoBitrate)
 	}
 
-	log.Printf("FFmpeg command started for task %s", taskID)
+	// GPU selection: "auto" tasks go to the matching GPU with the fewest running tasks
+	// (chunked tasks pick a GPU per segment)
+	chunked := service.ChunkingEnabled(&task.Config)
+	if !passthrough && !audioOnly && !chunked {
+		if device := p.acquireDevice(&task.Config); device != "" {
+			defer p.releaseDevice(device)
+			if task.Config.Device != device {
+				encodeOpts.Device = device
+			}
+			log.Printf("Task %s: using device %s", taskID, device)
+		}
+	}
 
-	// Monitor progress
-	go func() {
-		progressChan := make(chan *service.ProgressUpdate, 10)
+	// Two-pass encoding: pass 1 writes the stats files into a temp directory,
+	// progress is split evenly across both passes
+	passes := []int{0}
+	if p.ffmpegService.UsesTwoPass(&task.Config) {
+		passLogDir, err := os.MkdirTemp("", "ffforge-passlog-")
+		if err != nil {
+			p.failTask(task, "failed to create pass log directory: "+err.Error())
+			return
+		}
+		defer os.RemoveAll(passLogDir)
 
-		// Tee stderr to both progress parser and buffer
-		teeReader := io.TeeReader(stderr, &stderrBuf)
-		scanner := bufio.NewScanner(teeReader)
+		// ffmpeg runs inside the pass log directory, so all paths must be absolute
+		if absOutput, err := filepath.Abs(fullOutputFile); err == nil {
+			fullOutputFile = absOutput
+		}
 
-		go service.StreamProgress(scanner, progressChan)
+		encodeOpts.PassLogDir = passLogDir
+		passes = []int{1, 2}
+	}
 
-		for update := range progressChan {
-			progress, eta := service.CalculateProgress(update.OutTime, totalDuration, update.Speed)
-
-			p.progressChan <- &ProgressUpdate{
-				TaskID:   taskID,
-				Status:   string(model.TaskStatusRunning),
-				Progress: progress,
-				Speed:    update.Speed,
-				ETA:      eta,
+	// Chunked encoding: segments encoded in parallel, then joined with the source's other streams
+	if chunked {
+		if err := p.runChunked(taskCtx, task, sourceFile, fullOutputFile, videoInfo, encodeOpts); err != nil {
+			if taskCtx.Err() == context.Canceled {
+				task.Status = model.TaskStatusCancelled
+				p.store.UpdateTask(task)
+				return
 			}
 
-			// Update task in database
-			task.Progress = progress
-			task.Speed = update.Speed
-			task.ETA = eta
-			p.db.UpdateTask(task)
+			p.failTask(task, err.Error())
+			return
 		}
-	}()
+		passes = nil
+	}
 
-	// Wait for command to complete
-	err = cmd.Wait()
+	var commands []string
+	for i, pass := range passes {
+		encodeOpts.Pass = pass
 
-	if err != nil {
+		// Build FFmpeg command (pass videoInfo for dynamic HDR handling)
+		cmd, err := p.ffmpegService.BuildCommand(taskCtx, sourceFile, fullOutputFile, &task.Config, videoInfo, encodeOpts)
+		if err != nil {
+			p.failTask(task, err.Error())
+			return
+		}
+
+		// Store actual command for debugging (visible in task details)
+		commands = append(commands, strings.Join(cmd.Args, " "))
+		task.ActualCommand = strings.Join(commands, "\n")
+		p.store.UpdateTask(task)
+
+		if pass > 0 {
+			log.Printf("Task %s: starting pass %d of %d", taskID, pass, len(passes))
+		}
+
+		weight := 100 / float64(len(passes))
+		if err := p.runFFmpeg(taskCtx, task, cmd, totalDuration, float64(i)*weight, weight); err != nil {
+			if taskCtx.Err() == context.Canceled {
+				// Task was cancelled
+				task.Status = model.TaskStatusCancelled
+				p.store.UpdateTask(task)
+				return
+			}
+
+			p.failTask(task, err.Error())
+			return
+		}
+	}
+
+	// Verification: the output is re-probed and compared with the source before it counts as done
+	if service.VerificationEnabled(&task.Config) {
+		log.Printf("Verifying output of task %s", taskID)
+		verification := p.ffmpegService.VerifyOutput(taskCtx, sourceFile, fullOutputFile, &task.Config, videoInfo)
 		if taskCtx.Err() == context.Canceled {
-			// Task was cancelled
 			task.Status = model.TaskStatusCancelled
-			p.db.UpdateTask(task)
+			p.store.UpdateTask(task)
 			return
 		}
 
-		// Include stderr output in error message
-		errorMsg := fmt.Sprintf("ffmpeg error: %v", err)
-		if stderrBuf.Len() > 0 {
-			// Get last 1000 characters of stderr
-			stderrStr := stderrBuf.String()
-			if len(stderrStr) > 1000 {
-				stderrStr = stderrStr[len(stderrStr)-1000:]
+		task.Verification = verification
+		if !verification.Passed {
+			p.failTask(task, "verification failed: "+strings.Join(verification.Problems, "; "))
+			return
+		}
+		p.store.UpdateTask(task)
+	}
+
+	// Size policy: outputs larger than allowed are discarded (the task is skipped) or kept with a warning
+	if outputSize, err := service.OutputSize(fullOutputFile); err == nil {
+		percent, exceeded := service.CheckOutputSize(&task.Config, task.SourceFileSize, outputSize)
+		if exceeded {
+			limit := task.Config.SizePolicy.MaxPercent
+			if service.DiscardsLargerOutput(&task.Config) && fullOutputFile != sourceFile {
+				p.skipTask(task, fullOutputFile, outputSize, fmt.Sprintf("output was %.0f%% of the source size (limit %d%%), discarded", percent, limit))
+				return
 			}
-			errorMsg = fmt.Sprintf("%s\nFFmpeg output:\n%s", errorMsg, stderrStr)
+			warning := fmt.Sprintf("output is %.0f%% of the source size (limit %d%%)", percent, limit)
+			log.Printf("Task %s: %s", taskID, warning)
+			task.Warnings = append(task.Warnings, warning)
+		}
+	}
+
+	// Quality scoring: sampled VMAF/SSIM/PSNR of the output against the source (failures only warn)
+	if service.QualityEnabled(&task.Config) {
+		metric := service.SelectQualityMetric(task.Config.Quality.Metric, p.availableFilters())
+		log.Printf("Scoring quality of task %s (%s)", taskID, metric)
+		score, err := p.ffmpegService.MeasureQuality(taskCtx, sourceFile, fullOutputFile, &task.Config, metric, videoInfo, encodeOpts.Scan)
+		if taskCtx.Err() == context.Canceled {
+			task.Status = model.TaskStatusCancelled
+			p.store.UpdateTask(task)
+			return
 		}
 
-		p.failTask(task, errorMsg)
+		if err != nil {
+			log.Printf("Task %s: quality scoring failed: %v", taskID, err)
+			task.Warnings = append(task.Warnings, "quality scoring failed: "+err.Error())
+		} else {
+			log.Printf("Task %s %s: mean %.2f, min %.2f, p5 %.2f", taskID, score.Metric, score.Mean, score.Min, score.P5)
+			task.Quality = score
+		}
+		p.store.UpdateTask(task)
+	}
+
+	// Thumbnails: preview images written next to the output (failures only warn)
+	if service.ThumbnailsEnabled(&task.Config) {
+		log.Printf("Writing thumbnails of task %s", taskID)
+		thumbnails, warnings := p.ffmpegService.WriteThumbnails(taskCtx, fullOutputFile, &task.Config)
+		if taskCtx.Err() == context.Canceled {
+			task.Status = model.TaskStatusCancelled
+			p.store.UpdateTask(task)
+			return
+		}
+
+		task.Thumbnails = thumbnails
+		task.Warnings = append(task.Warnings, warnings...)
+		p.store.UpdateTask(task)
+	}
+
+	// Hand the output over (file permissions, upload to the server for agents)
+	if err := p.media.Finish(taskCtx, task, sourceFile, fullOutputFile); err != nil {
+		if taskCtx.Err() == context.Canceled {
+			task.Status = model.TaskStatusCancelled
+			p.store.UpdateTask(task)
+			return
+		}
+
+		p.failTask(task, err.Error())
 		return
 	}
 
@@ -263,21 +461,18 @@ func (p *Pool) processTask(taskID string) {
 	task.Speed = 0
 	task.ETA = 0
 
-	// Get output file size
-	outputFileInfo, err := os.Stat(fullOutputFile)
+	// Get output file size (all files of a package)
+	outputSize, err := service.OutputSize(fullOutputFile)
 	if err != nil {
 		log.Printf("Warning: Failed to get output file size: %v", err)
 	} else {
-		task.OutputFileSize = outputFileInfo.Size()
+		task.OutputFileSize = outputSize
 	}
 
-	if err := p.db.UpdateTask(task); err != nil {
+	if err := p.store.UpdateTask(task); err != nil {
 		log.Printf("Failed to update completed task: %v", err)
 	}
 
-	// Apply file permissions based on settings
-	p.applyFilePermissions(task, sourceFile, fullOutputFile)
-
 	p.progressChan <- &ProgressUpdate{
 		TaskID:   taskID,
 		Status:   string(model.TaskStatusCompleted),
@@ -289,6 +484,57 @@ func (p *Pool) processTask(taskID string) {
 	log.Printf("Task %s completed successfully", taskID)
 }
 
+// availableFilters returns the filters of the ffmpeg build (empty when unknown)
+func (p *Pool) availableFilters() []string {
+	if p.hardwareService == nil {
+		return nil
+	}
+	caps := p.hardwareService.GetEncoderCapabilities(false)
+	if caps == nil {
+		return nil
+	}
+	return caps.Filters
+}
+
+// acquireDevice reserves a session on the task's GPU and returns its device ID
+// Returns "" when there is no device choice to track (CPU, single GPU, unknown devices)
+func (p *Pool) acquireDevice(config *model.TranscodeConfig) string {
+	device := config.Device
+	var candidates []model.GPUDevice
+	if device == "" || device == service.DeviceAuto {
+		device = ""
+		if p.hardwareService != nil {
+			candidates = service.DeviceCandidates(config.HardwareAccel, p.hardwareService.DetectHardware().Devices)
+		}
+		if len(candidates) < 2 {
+			return ""
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, candidate := range candidates {
+		if device == "" || p.deviceSessions[candidate.ID] < p.deviceSessions[device] {
+			device = candidate.ID
+		}
+	}
+	p.deviceSessions[device]++
+
+	return device
+}
+
+// releaseDevice ends a session reserved by acquireDevice
+func (p *Pool) releaseDevice(device string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.deviceSessions[device]--
+	if p.deviceSessions[device] <= 0 {
+		delete(p.deviceSessions, device)
+	}
+}
+
 // failTask marks a task as failed
 func (p *Pool) failTask(task *model.Task, errorMsg string) {
 	log.Printf("Task %s failed: %s", task.ID, errorMsg)
@@ -298,7 +544,7 @@ func (p *Pool) failTask(task *model.Task, errorMsg string) {
 	completedAt := time.Now()
 	task.CompletedAt = &completedAt
 
-	if err := p.db.UpdateTask(task); err != nil {
+	if err := p.store.UpdateTask(task); err != nil {
 		log.Printf("Failed to update failed task: %v", err)
 	}
 
@@ -309,6 +555,138 @@ func (p *Pool) failTask(task *model.Task, errorMsg string) {
 	}
 }
 
+// skipTask deletes an output that gained nothing and marks the task as skipped
+// The output size stays on the task so the size stats remain visible
+func (p *Pool) skipTask(task *model.Task, outputFile string, outputSize int64, reason string) {
+	log.Printf("Task %s skipped: %s", task.ID, reason)
+
+	remove := os.Remove
+	if service.Packaged(&task.Config) {
+		remove = os.RemoveAll
+	}
+	if err := remove(outputFile); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: Failed to remove discarded output %s: %v", outputFile, err)
+	}
+
+	completedAt := time.Now()
+	task.Status = model.TaskStatusSkipped
+	task.SkipReason = model.SkipReasonNoGain
+	task.CompletedAt = &completedAt
+	task.Progress = 100
+	task.Speed = 0
+	task.ETA = 0
+	task.OutputFileSize = outputSize
+	task.Warnings = append(task.Warnings, reason)
+
+	if err := p.store.UpdateTask(task); err != nil {
+		log.Printf("Failed to update skipped task: %v", err)
+	}
+
+	p.progressChan <- &ProgressUpdate{
+		TaskID:   task.ID,
+		Status:   string(model.TaskStatusSkipped),
+		Progress: 100,
+	}
+}
+
+// runFFmpeg runs an ffmpeg command and reports its progress
+// Progress is mapped onto [progressBase, progressBase+progressWeight] of the task,
+// so multi-pass encodes report across all passes
+func (p *Pool) runFFmpeg(ctx context.Context, task *model.Task, cmd *exec.Cmd, totalDuration, progressBase, progressWeight float64) error {
+	log.Printf("FFmpeg command started for task %s", task.ID)
+
+	return execFFmpeg(ctx, cmd, func(update *service.ProgressUpdate) {
+		passProgress, _ := service.CalculateProgress(update.OutTime, totalDuration, update.Speed)
+		progress := progressBase + passProgress*progressWeight/100
+
+		// ETA covers the remaining passes as well
+		eta := int64(0)
+		if update.Speed > 0 && totalDuration > 0 {
+			remaining := (100 - progress) / 100 * totalDuration * (100 / progressWeight)
+			eta = int64(remaining / update.Speed)
+		}
+
+		p.updateProgress(task, progress, update.Speed, eta)
+	})
+}
+
+// updateProgress stores and broadcasts the progress of a running task
+func (p *Pool) updateProgress(task *model.Task, progress, speed float64, eta int64) {
+	p.progressChan <- &ProgressUpdate{
+		TaskID:   task.ID,
+		Status:   string(model.TaskStatusRunning),
+		Progress: progress,
+		Speed:    speed,
+		ETA:      eta,
+	}
+
+	// Update task in database
+	task.Progress = progress
+	task.Speed = speed
+	task.ETA = eta
+	p.store.UpdateTask(task)
+}
+
+// execFFmpeg runs an ffmpeg command (with -progress pipe:2), calling onProgress for every update
+// Errors include the end of ffmpeg's stderr output
+func execFFmpeg(ctx context.Context, cmd *exec.Cmd, onProgress func(*service.ProgressUpdate)) error {
+	// Get stderr pipe for progress and error messages
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to get stderr pipe: %w", err)
+	}
+
+	// Also capture stderr for error messages
+	var stderrBuf bytes.Buffer
+
+	// Start command
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	// Monitor progress
+	monitorDone := make(chan struct{})
+	go func() {
+		defer close(monitorDone)
+		progressChan := make(chan *service.ProgressUpdate, 10)
+
+		// Tee stderr to both progress parser and buffer
+		teeReader := io.TeeReader(stderr, &stderrBuf)
+		scanner := bufio.NewScanner(teeReader)
+
+		go service.StreamProgress(scanner, progressChan)
+
+		for update := range progressChan {
+			onProgress(update)
+		}
+
+		// StreamProgress stops at progress=end, keep the rest of stderr for error messages
+		io.Copy(io.Discard, teeReader)
+	}()
+
+	// Wait for the monitor to drain stderr before waiting on the command
+	<-monitorDone
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// Include stderr output in error message
+		errorMsg := fmt.Sprintf("ffmpeg error: %v", err)
+		if stderrBuf.Len() > 0 {
+			// Get last 1000 characters of stderr
+			stderrStr := stderrBuf.String()
+			if len(stderrStr) > 1000 {
+				stderrStr = stderrStr[len(stderrStr)-1000:]
+			}
+			errorMsg = fmt.Sprintf("%s\nFFmpeg output:\n%s", errorMsg, stderrStr)
+		}
+		return fmt.Errorf("%s", errorMsg)
+	}
+
+	return nil
+}
+
 // progressBroadcaster broadcasts progress updates
 func (p *Pool) progressBroadcaster() {
 	defer p.wg.Done()
@@ -372,6 +750,8 @@ func (p *Pool) CancelTask(taskID string) error {
 }
 
 // loadPendingTasks loads pending tasks from database and submits them to queue
+// Agent assignments only live in memory, so tasks that were running on an agent
+// are reset to pending and queued again as well.
 func (p *Pool) loadPendingTasks() {
 	// Wait a bit for workers to start
 	time.Sleep(time.Second)
@@ -383,6 +763,16 @@ func (p *Pool) loadPendingTasks() {
 	}
 
 	for _, task := range tasks {
+		if task.Status == model.TaskStatusRunning && task.AgentID != "" {
+			agentID := task.AgentID
+			resetTask(task)
+			if err := p.db.UpdateTask(task); err != nil {
+				log.Printf("Failed to requeue task %s: %v", task.ID, err)
+				continue
+			}
+			log.Printf("Task %s requeued (was running on agent %s before the restart)", task.ID, agentID)
+		}
+
 		if task.Status == model.TaskStatusPending {
 			p.SubmitTask(task.ID)
 		}
@@ -391,33 +781,6 @@ func (p *Pool) loadPendingTasks() {
 	log.Printf("Loaded %d pending tasks", len(tasks))
 }
 
-// applyFilePermissions applies file permissions to the output file based on settings
-func (p *Pool) applyFilePermissions(task *model.Task, sourceFile, outputFile string) {
-	// Get settings from database
-	var settings model.Settings
-	err := p.db.Conn().QueryRow(`
-		SELECT file_permission_mode, file_permission_uid, file_permission_gid
-		FROM settings WHERE id = 1
-	`).Scan(&settings.FilePermissionMode, &settings.FilePermissionUID, &settings.FilePermissionGID)
-
-	if err != nil {
-		log.Printf("Warning: Failed to get settings for file permissions: %v", err)
-		return
-	}
-
-	// Apply permissions
-	applied, err := p.permissionService.ApplyFilePermissions(outputFile, sourceFile, &settings)
-	if err != nil {
-		log.Printf("Warning: Failed to apply file permissions for task %s: %v", task.ID, err)
-		// Don't fail the task, just log the warning
-		return
-	}
-
-	if applied {
-		log.Printf("File permissions applied successfully for task %s", task.ID)
-	}
-}
-
 // Shutdown gracefully shuts down the worker pool
 func (p *Pool) Shutdown() {
 	log.Println("Shutting down worker pool...")
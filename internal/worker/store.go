@@ -0,0 +1,114 @@
+package worker
+
+import (
+	"context"
+	"ffmpeg-web/internal/database"
+	"ffmpeg-web/internal/model"
+	"ffmpeg-web/internal/service"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+// TaskStore loads and saves the tasks run by a pool
+// The server's pool uses the database, an agent's pool reports to the server
+type TaskStore interface {
+	// ClaimTask marks a pending task as running and returns it (nil when it isn't pending anymore)
+	ClaimTask(id string) (*model.Task, error)
+	UpdateTask(task *model.Task) error
+}
+
+// MediaPaths resolves the files a pool reads and writes for a task
+type MediaPaths interface {
+	SourcePath(task *model.Task) (string, error)
+	OutputPath(task *model.Task) (string, error)
+	// Finish runs after a successful encode, before the task is marked as completed
+	Finish(ctx context.Context, task *model.Task, sourceFile, outputFile string) error
+}
+
+// dbStore is the TaskStore of the server's own worker pool
+type dbStore struct {
+	db *database.DB
+}
+
+// ClaimTask claims a pending task for the local worker pool
+func (s *dbStore) ClaimTask(id string) (*model.Task, error) {
+	claimed, err := s.db.ClaimTask(id, "", time.Now())
+	if err != nil || !claimed {
+		return nil, err
+	}
+	return s.db.GetTask(id)
+}
+
+// UpdateTask saves a task to the database
+func (s *dbStore) UpdateTask(task *model.Task) error {
+	return s.db.UpdateTask(task)
+}
+
+// localMedia resolves task files on the server's filesystem
+type localMedia struct {
+	db                *database.DB
+	ffmpegService     *service.FFmpegService
+	fileService       *service.FileService
+	permissionService *service.PermissionService
+}
+
+// SourcePath returns the full path of the task's source file
+func (m *localMedia) SourcePath(task *model.Task) (string, error) {
+	return m.fileService.GetFullPath(task.SourceFile)
+}
+
+// OutputPath generates the output path from the task's output settings
+func (m *localMedia) OutputPath(task *model.Task) (string, error) {
+	return m.ffmpegService.GenerateOutputPath(task.SourceFile, &task.Config), nil
+}
+
+// Finish applies the configured file permissions to the output file
+func (m *localMedia) Finish(ctx context.Context, task *model.Task, sourceFile, outputFile string) error {
+	m.applyFilePermissions(task, sourceFile, outputFile)
+	return nil
+}
+
+// applyFilePermissions applies file permissions to the output file and its thumbnails based on settings
+// HLS/DASH packages get them on the directory and every file in it
+func (m *localMedia) applyFilePermissions(task *model.Task, sourceFile, outputFile string) {
+	// Get settings from database
+	var settings model.Settings
+	err := m.db.Conn().QueryRow(`
+		SELECT file_permission_mode, file_permission_uid, file_permission_gid
+		FROM settings WHERE id = 1
+	`).Scan(&settings.FilePermissionMode, &settings.FilePermissionUID, &settings.FilePermissionGID)
+
+	if err != nil {
+		log.Printf("Warning: Failed to get settings for file permissions: %v", err)
+		return
+	}
+
+	// Packages and trickplay sprites are directories, every file in them gets the permissions too
+	outputFiles := []string{}
+	for _, root := range append([]string{outputFile}, task.Thumbnails...) {
+		filepath.WalkDir(root, func(path string, _ fs.DirEntry, err error) error {
+			if err == nil {
+				outputFiles = append(outputFiles, path)
+			}
+			return nil
+		})
+	}
+
+	// Apply permissions
+	applied := false
+	for _, outputFile := range outputFiles {
+		changed, err := m.permissionService.ApplyFilePermissions(outputFile, sourceFile, &settings)
+		if err != nil {
+			log.Printf("Warning: Failed to apply file permissions for task %s: %v", task.ID, err)
+			// Don't fail the task, just log the warning
+			return
+		}
+		applied = applied || changed
+	}
+
+	if applied {
+		log.Printf("File permissions applied successfully for task %s", task.ID)
+	}
+}
@@ -1,13 +1,21 @@
 package ffprobe
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os/exec"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// InfoVersion is bumped whenever VideoInfo changes, so stored probe results of older versions are probed again
+const InfoVersion = 2
+
+// waitDelay is how long Probe waits for the output of a killed ffprobe
+const waitDelay = 2 * time.Second
+
 // VideoInfo represents information about a video file
 type VideoInfo struct {
 	Duration       float64 // Duration in seconds
@@ -15,7 +23,9 @@ type VideoInfo struct {
 	Height         int
 	Codec          string
 	Bitrate        int64
-	FrameRate      string
+	FrameRate      string // Base frame rate (r_frame_rate), e.g. "30000/1001"
+	AvgFrameRate   string // Average frame rate over the stream (avg_frame_rate)
+	FieldOrder     string // progressive, tt, bb, tb, bt (empty if not reported)
 	PixelFormat    string
 	ColorSpace     string
 	ColorTransfer  string
@@ -27,26 +37,117 @@ type VideoInfo struct {
 	MasteringDisplay string // Format: "G(x,y)B(x,y)R(x,y)WP(x,y)L(max,min)" for x265/svtav1
 	MaxCLL           int    // Maximum Content Light Level (nits)
 	MaxFALL          int    // Maximum Frame-Average Light Level (nits)
+	// All streams of the file in input order (video, audio, subtitle, attachment, data)
+	Streams []StreamInfo
+	// Container format (ffprobe format_name, e.g. "matroska,webm") and its tags (title, encoder, ...)
+	Format string
+	Tags   map[string]string
+	// Chapters in start order
+	Chapters []Chapter
+}
+
+// StreamInfo represents a single stream of a probed file
+type StreamInfo struct {
+	Index         int    // Absolute stream index in the input (matches -map 0:N)
+	Type          string // video, audio, subtitle, attachment, data
+	Codec         string
+	Profile       string
+	Width         int    // Video only
+	Height        int    // Video only
+	FrameRate     string // Video only, e.g. "24000/1001"
+	Channels      int    // Audio only
+	ChannelLayout string // Audio only, e.g. "5.1(side)"
+	SampleRate    int    // Audio only, in Hz
+	SubtitleType  string // Subtitle only: text or image (bitmap)
+	Language      string // From the "language" tag, empty if unset
+	Title         string // From the "title" tag, empty if unset
+	Bitrate       int64  // Per-stream bitrate in bits/s, 0 if unknown
+	AttachedPic   bool   // Video only: embedded cover art (a single picture, not a video track)
+	// Disposition flags set on the stream in ffprobe order, e.g. default, forced, hearing_impaired
+	Disposition []string
+}
+
+// Chapter is a chapter marker of a probed file
+type Chapter struct {
+	Start float64 // Seconds
+	End   float64 // Seconds
+	Title string  // From the "title" tag, empty if unset
+}
+
+// Subtitle types (StreamInfo.SubtitleType)
+const (
+	SubtitleText  = "text"
+	SubtitleImage = "image"
+)
+
+// imageSubtitleCodecs are bitmap subtitle codecs, which can't be converted to text formats
+var imageSubtitleCodecs = map[string]bool{
+	"hdmv_pgs_subtitle": true,
+	"dvd_subtitle":      true,
+	"dvb_subtitle":      true,
+	"xsub":              true,
+}
+
+// IsImageSubtitle checks if a subtitle codec is bitmap-based
+func IsImageSubtitle(codec string) bool {
+	return imageSubtitleCodecs[codec]
+}
+
+// dispositionFlags is the order disposition flags are reported in
+var dispositionFlags = []string{
+	"default", "forced", "dub", "original", "comment", "lyrics", "karaoke",
+	"hearing_impaired", "visual_impaired", "clean_effects", "attached_pic",
+	"timed_thumbnails", "captions", "descriptions", "metadata", "dependent", "still_image",
+}
+
+// StreamsOfType returns the streams of the given type in input order.
+// The position in the returned slice matches ffmpeg's type-relative
+// stream specifier (e.g. the second audio stream is 0:a:1).
+func (v *VideoInfo) StreamsOfType(codecType string) []StreamInfo {
+	var streams []StreamInfo
+	for _, s := range v.Streams {
+		if s.Type == codecType {
+			streams = append(streams, s)
+		}
+	}
+	return streams
+}
+
+// HasVideo reports whether the file has a video track (cover art doesn't count)
+func (v *VideoInfo) HasVideo() bool {
+	for _, s := range v.Streams {
+		if s.Type == "video" && !s.AttachedPic {
+			return true
+		}
+	}
+	return false
 }
 
-// Probe executes ffprobe on a video file and returns video information
-func Probe(ffprobePath, filePath string) (*VideoInfo, error) {
+// Probe executes ffprobe on a media file and returns its information
+// ffprobe is killed when ctx ends, e.g. on a hung network mount.
+func Probe(ctx context.Context, ffprobePath, filePath string) (*VideoInfo, error) {
 	// Use provided ffprobe path or default to "ffprobe"
 	if ffprobePath == "" {
 		ffprobePath = "ffprobe"
 	}
 
 	// Run ffprobe with JSON output, including side_data for HDR metadata
-	cmd := exec.Command(ffprobePath,
+	cmd := exec.CommandContext(ctx, ffprobePath,
 		"-v", "quiet",
 		"-print_format", "json",
 		"-show_format",
 		"-show_streams",
+		"-show_chapters",
 		filePath,
 	)
+	// A killed ffprobe stuck in I/O may not close its output right away, don't wait for it
+	cmd.WaitDelay = waitDelay
 
 	output, err := cmd.Output()
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("ffprobe aborted: %w", ctx.Err())
+		}
 		return nil, fmt.Errorf("ffprobe failed: %w", err)
 	}
 
@@ -56,17 +157,23 @@ func Probe(ffprobePath, filePath string) (*VideoInfo, error) {
 		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
 	}
 
-	// Extract video stream info
+	// Extract video stream info (cover art of music files isn't the video)
+	// Audio-only files have no video stream, their video fields stay empty
 	var videoStream *Stream
+	hasAudio := false
 	for i := range result.Streams {
-		if result.Streams[i].CodecType == "video" {
-			videoStream = &result.Streams[i]
-			break
+		stream := &result.Streams[i]
+		if stream.CodecType == "video" && videoStream == nil && stream.Disposition["attached_pic"] == 0 {
+			videoStream = stream
 		}
+		hasAudio = hasAudio || stream.CodecType == "audio"
 	}
 
 	if videoStream == nil {
-		return nil, fmt.Errorf("no video stream found")
+		if !hasAudio {
+			return nil, fmt.Errorf("no video or audio stream found")
+		}
+		videoStream = &Stream{}
 	}
 
 	// Parse duration
@@ -107,6 +214,11 @@ func Probe(ffprobePath, filePath string) (*VideoInfo, error) {
 		}
 	}
 
+	level := ""
+	if videoStream.CodecType != "" {
+		level = strconv.Itoa(videoStream.Level)
+	}
+
 	info := &VideoInfo{
 		Duration:         duration,
 		Width:            videoStream.Width,
@@ -114,21 +226,82 @@ func Probe(ffprobePath, filePath string) (*VideoInfo, error) {
 		Codec:            videoStream.CodecName,
 		Bitrate:          bitrate,
 		FrameRate:        videoStream.FrameRate,
+		AvgFrameRate:     videoStream.AvgFrameRate,
+		FieldOrder:       videoStream.FieldOrder,
 		PixelFormat:      videoStream.PixelFormat,
 		ColorSpace:       videoStream.ColorSpace,
 		ColorTransfer:    videoStream.ColorTransfer,
 		ColorPrimaries:   videoStream.ColorPrimaries,
 		IsHDR:            isHDR,
 		Profile:          videoStream.Profile,
-		Level:            strconv.Itoa(videoStream.Level),
+		Level:            level,
 		MasteringDisplay: masteringDisplay,
 		MaxCLL:           maxCLL,
 		MaxFALL:          maxFALL,
 	}
 
+	for _, stream := range result.Streams {
+		info.Streams = append(info.Streams, streamInfo(stream))
+	}
+
+	info.Format = result.Format.FormatName
+	info.Tags = result.Format.Tags
+	for _, chapter := range result.Chapters {
+		start, _ := strconv.ParseFloat(chapter.StartTime, 64)
+		end, _ := strconv.ParseFloat(chapter.EndTime, 64)
+		info.Chapters = append(info.Chapters, Chapter{
+			Start: start,
+			End:   end,
+			Title: chapter.Tags["title"],
+		})
+	}
+
 	return info, nil
 }
 
+// streamInfo converts an ffprobe stream into its StreamInfo
+func streamInfo(stream Stream) StreamInfo {
+	bitrate := int64(0)
+	if stream.BitRate != "" {
+		bitrate, _ = strconv.ParseInt(stream.BitRate, 10, 64)
+	}
+	sampleRate := 0
+	if stream.SampleRate != "" {
+		sampleRate, _ = strconv.Atoi(stream.SampleRate)
+	}
+
+	info := StreamInfo{
+		Index:         stream.Index,
+		Type:          stream.CodecType,
+		Codec:         stream.CodecName,
+		Profile:       stream.Profile,
+		Channels:      stream.Channels,
+		ChannelLayout: stream.ChannelLayout,
+		SampleRate:    sampleRate,
+		Language:      stream.Tags["language"],
+		Title:         stream.Tags["title"],
+		Bitrate:       bitrate,
+		AttachedPic:   stream.Disposition["attached_pic"] != 0,
+	}
+	if stream.CodecType == "video" {
+		info.Width = stream.Width
+		info.Height = stream.Height
+		info.FrameRate = stream.FrameRate
+	}
+	if stream.CodecType == "subtitle" {
+		info.SubtitleType = SubtitleText
+		if IsImageSubtitle(stream.CodecName) {
+			info.SubtitleType = SubtitleImage
+		}
+	}
+	for _, flag := range dispositionFlags {
+		if stream.Disposition[flag] != 0 {
+			info.Disposition = append(info.Disposition, flag)
+		}
+	}
+	return info
+}
+
 // buildMasteringDisplayString converts ffprobe mastering display metadata to x265/svtav1 format
 // Input format from ffprobe: "red_x": "34000/50000", "red_y": "16000/50000", etc.
 // Output format: G(gx,gy)B(bx,by)R(rx,ry)WP(wpx,wpy)L(max_lum,min_lum)
@@ -165,8 +338,9 @@ func buildMasteringDisplayString(sd SideData) string {
 
 // FFprobeResult represents the JSON output from ffprobe
 type FFprobeResult struct {
-	Streams []Stream `json:"streams"`
-	Format  Format   `json:"format"`
+	Streams  []Stream         `json:"streams"`
+	Format   Format           `json:"format"`
+	Chapters []FFprobeChapter `json:"chapters"`
 }
 
 // Stream represents a media stream
@@ -177,6 +351,8 @@ type Stream struct {
 	Width          int        `json:"width"`
 	Height         int        `json:"height"`
 	FrameRate      string     `json:"r_frame_rate"`
+	AvgFrameRate   string     `json:"avg_frame_rate"`
+	FieldOrder     string     `json:"field_order"`
 	PixelFormat    string     `json:"pix_fmt"`
 	ColorSpace     string     `json:"color_space"`
 	ColorTransfer  string     `json:"color_transfer"`
@@ -184,6 +360,14 @@ type Stream struct {
 	Profile        string     `json:"profile"`
 	Level          int        `json:"level"`
 	SideDataList   []SideData `json:"side_data_list"`
+	// Audio stream fields
+	Channels      int    `json:"channels"`
+	ChannelLayout string `json:"channel_layout"`
+	SampleRate    string `json:"sample_rate"`
+	// Common stream fields
+	BitRate     string            `json:"bit_rate"`
+	Tags        map[string]string `json:"tags"`
+	Disposition map[string]int    `json:"disposition"`
 }
 
 // SideData represents HDR metadata from side_data_list
@@ -207,8 +391,17 @@ type SideData struct {
 
 // Format represents the container format
 type Format struct {
-	Filename   string `json:"filename"`
-	Duration   string `json:"duration"`
-	BitRate    string `json:"bit_rate"`
-	FormatName string `json:"format_name"`
+	Filename   string            `json:"filename"`
+	Duration   string            `json:"duration"`
+	BitRate    string            `json:"bit_rate"`
+	FormatName string            `json:"format_name"`
+	StartTime  string            `json:"start_time"`
+	Tags       map[string]string `json:"tags"`
+}
+
+// FFprobeChapter represents a chapter (times in seconds as strings)
+type FFprobeChapter struct {
+	StartTime string            `json:"start_time"`
+	EndTime   string            `json:"end_time"`
+	Tags      map[string]string `json:"tags"`
 }
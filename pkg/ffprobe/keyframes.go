@@ -0,0 +1,66 @@
+package ffprobe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// keyframeResult represents the JSON output of a packet listing
+type keyframeResult struct {
+	Packets []struct {
+		PtsTime string `json:"pts_time"`
+		Flags   string `json:"flags"`
+	} `json:"packets"`
+	Format Format `json:"format"`
+}
+
+// Keyframes returns the keyframe timestamps (seconds) of the first video stream
+// Timestamps are relative to the start of the file, as used by ffmpeg's -ss
+// Only packets are read (no decoding), which is fast even for long files
+func Keyframes(ctx context.Context, ffprobePath, filePath string) ([]float64, error) {
+	if ffprobePath == "" {
+		ffprobePath = "ffprobe"
+	}
+
+	cmd := exec.CommandContext(ctx, ffprobePath,
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "packet=pts_time,flags:format=start_time",
+		"-print_format", "json",
+		filePath,
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var result keyframeResult
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	startTime, _ := strconv.ParseFloat(result.Format.StartTime, 64)
+
+	keyframes := []float64{}
+	for _, packet := range result.Packets {
+		if !strings.HasPrefix(packet.Flags, "K") {
+			continue
+		}
+		pts, err := strconv.ParseFloat(packet.PtsTime, 64)
+		if err != nil {
+			continue // N/A
+		}
+		keyframes = append(keyframes, pts-startTime)
+	}
+
+	// Packets are listed in decode order
+	sort.Float64s(keyframes)
+
+	return keyframes, nil
+}
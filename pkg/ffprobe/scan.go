@@ -0,0 +1,114 @@
+package ffprobe
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Scan types of a video stream
+const (
+	ScanProgressive = "progressive"
+	ScanInterlaced  = "interlaced"
+	ScanTelecined   = "telecined" // 24 fps film carried as 30 fps video (3:2 pulldown)
+)
+
+// Pulldown kinds of telecined video
+const (
+	PulldownSoft = "soft" // Repeat flags: the decoder already outputs the film frames
+	PulldownHard = "hard" // Pulldown fields are encoded: needs inverse telecine (IVTC)
+)
+
+// rateTolerance is the relative difference below which two frame rates are the same
+const rateTolerance = 0.01
+
+// ScanInfo classifies how the main video stream is scanned and timed
+type ScanInfo struct {
+	Type         string      // progressive, interlaced, telecined (empty = unknown)
+	Pulldown     string      // Telecined only: soft or hard
+	FieldOrder   string      // ffprobe field_order
+	FrameRate    string      // Base frame rate (r_frame_rate)
+	AvgFrameRate string      // Average frame rate (avg_frame_rate)
+	VFR          bool        // Frame timing varies: the average rate doesn't match the base rate
+	Idet         *IdetCounts // Frame counts of the idet filter (nil = classified from metadata)
+}
+
+// IdetCounts are the frame counts the idet filter reports for a sample of the video
+type IdetCounts struct {
+	TFF            int // Interlaced, top field first
+	BFF            int // Interlaced, bottom field first
+	Progressive    int
+	Undetermined   int
+	RepeatedTop    int // Frames repeating the top field of the previous frame
+	RepeatedBottom int // Frames repeating the bottom field of the previous frame
+}
+
+// Interlaced reports whether the scan needs deinterlacing or inverse telecine
+func (s *ScanInfo) Interlaced() bool {
+	return s != nil && (s.Type == ScanInterlaced || (s.Type == ScanTelecined && s.Pulldown == PulldownHard))
+}
+
+// AnalyzeScan classifies the main video stream from the probed metadata
+// field_order tells progressive from interlaced, the average and base frame rates
+// reveal soft telecine (4:5) and variable frame rates. Hard telecine looks like
+// interlaced video here; only idet (see DetectScan in the service) can tell them apart.
+// Returns nil for files without video.
+func AnalyzeScan(info *VideoInfo) *ScanInfo {
+	if info == nil || !info.HasVideo() {
+		return nil
+	}
+
+	scan := &ScanInfo{
+		FieldOrder:   info.FieldOrder,
+		FrameRate:    info.FrameRate,
+		AvgFrameRate: info.AvgFrameRate,
+	}
+
+	switch info.FieldOrder {
+	case "progressive":
+		scan.Type = ScanProgressive
+	case "tt", "bb", "tb", "bt":
+		scan.Type = ScanInterlaced
+	}
+
+	base, avg := ParseFrameRate(info.FrameRate), ParseFrameRate(info.AvgFrameRate)
+	if base <= 0 || avg <= 0 {
+		return scan
+	}
+	ratio := avg / base
+	switch {
+	case sameRate(ratio, 0.8) || sameRate(ratio, 1.25):
+		// 24 film frames flagged to play as 30 video frames
+		scan.Type = ScanTelecined
+		scan.Pulldown = PulldownSoft
+	case sameRate(ratio, 1):
+		// Constant frame rate
+	case scan.Type == ScanInterlaced && (sameRate(ratio, 0.5) || sameRate(ratio, 2)):
+		// Field rate reported as the base rate
+	default:
+		scan.VFR = true
+	}
+	return scan
+}
+
+// ParseFrameRate converts an ffprobe frame rate ("30000/1001", "25") to frames per second (0 = unknown)
+func ParseFrameRate(rate string) float64 {
+	num, den, fraction := strings.Cut(rate, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	if !fraction {
+		return n
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
+
+// sameRate reports whether a frame rate ratio matches a target within rateTolerance
+func sameRate(ratio, target float64) bool {
+	return math.Abs(ratio-target) <= target*rateTolerance
+}
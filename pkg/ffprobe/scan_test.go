@@ -0,0 +1,74 @@
+package ffprobe
+
+import "testing"
+
+func TestAnalyzeScan(t *testing.T) {
+	video := []StreamInfo{{Index: 0, Type: "video"}}
+
+	tests := []struct {
+		name string
+		info *VideoInfo
+		want *ScanInfo
+	}{
+		{"no info", nil, nil},
+		{"no video", &VideoInfo{Streams: []StreamInfo{{Type: "audio"}}}, nil},
+		{
+			name: "progressive",
+			info: &VideoInfo{Streams: video, FieldOrder: "progressive", FrameRate: "24000/1001", AvgFrameRate: "24000/1001"},
+			want: &ScanInfo{Type: ScanProgressive, FieldOrder: "progressive", FrameRate: "24000/1001", AvgFrameRate: "24000/1001"},
+		},
+		{
+			name: "interlaced",
+			info: &VideoInfo{Streams: video, FieldOrder: "tt", FrameRate: "25", AvgFrameRate: "25"},
+			want: &ScanInfo{Type: ScanInterlaced, FieldOrder: "tt", FrameRate: "25", AvgFrameRate: "25"},
+		},
+		{
+			name: "interlaced with the field rate",
+			info: &VideoInfo{Streams: video, FieldOrder: "bb", FrameRate: "60000/1001", AvgFrameRate: "30000/1001"},
+			want: &ScanInfo{Type: ScanInterlaced, FieldOrder: "bb", FrameRate: "60000/1001", AvgFrameRate: "30000/1001"},
+		},
+		{
+			name: "soft telecine",
+			info: &VideoInfo{Streams: video, FieldOrder: "progressive", FrameRate: "30000/1001", AvgFrameRate: "24000/1001"},
+			want: &ScanInfo{Type: ScanTelecined, Pulldown: PulldownSoft, FieldOrder: "progressive", FrameRate: "30000/1001", AvgFrameRate: "24000/1001"},
+		},
+		{
+			name: "variable frame rate",
+			info: &VideoInfo{Streams: video, FieldOrder: "progressive", FrameRate: "60", AvgFrameRate: "2997/100"},
+			want: &ScanInfo{Type: ScanProgressive, FieldOrder: "progressive", FrameRate: "60", AvgFrameRate: "2997/100", VFR: true},
+		},
+		{
+			name: "unknown",
+			info: &VideoInfo{Streams: video, FrameRate: "0/0", AvgFrameRate: "0/0"},
+			want: &ScanInfo{FrameRate: "0/0", AvgFrameRate: "0/0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AnalyzeScan(tt.info)
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("AnalyzeScan() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFrameRate(t *testing.T) {
+	tests := []struct {
+		rate string
+		want float64
+	}{
+		{"25", 25},
+		{"30000/1001", 30000.0 / 1001},
+		{"0/0", 0},
+		{"", 0},
+		{"N/A", 0},
+	}
+
+	for _, tt := range tests {
+		if got := ParseFrameRate(tt.rate); got != tt.want {
+			t.Errorf("ParseFrameRate(%q) = %v, want %v", tt.rate, got, tt.want)
+		}
+	}
+}